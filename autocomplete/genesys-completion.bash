@@ -8,7 +8,7 @@ _genesys_completions() {
     prev="${COMP_WORDS[COMP_CWORD-1]}"
 
     # Main commands
-    local commands="interact execute discover config version help"
+    local commands="interact execute plan apply drift workspace state output discover config schema validate version help"
     
     # Provider options
     local providers="aws gcp azure tencent"
@@ -17,7 +17,7 @@ _genesys_completions() {
     local resource_types="S3_Storage_Bucket Compute_Instance Database Function Network"
     
     # Config subcommands
-    local config_commands="setup list show validate"
+    local config_commands="setup list show validate migrate"
 
     case "${COMP_CWORD}" in
         1)
@@ -31,11 +31,24 @@ _genesys_completions() {
                     # No direct completion, interactive mode
                     return 0
                     ;;
-                execute)
+                execute|plan|drift|validate)
                     # Complete with .yaml and .toml files
                     COMPREPLY=( $(compgen -f -X '!*.@(yaml|yml|toml)' -- ${cur}) )
                     return 0
                     ;;
+                apply)
+                    # Complete with saved plan files
+                    COMPREPLY=( $(compgen -f -X '!*.json' -- ${cur}) )
+                    return 0
+                    ;;
+                workspace)
+                    COMPREPLY=( $(compgen -W "new select list show delete" -- ${cur}) )
+                    return 0
+                    ;;
+                state)
+                    COMPREPLY=( $(compgen -W "list show rm mv import history rollback rotate-key force-unlock" -- ${cur}) )
+                    return 0
+                    ;;
                 discover)
                     # Complete with provider names
                     COMPREPLY=( $(compgen -W "${providers}" -- ${cur}) )
@@ -46,6 +59,10 @@ _genesys_completions() {
                     COMPREPLY=( $(compgen -W "${config_commands}" -- ${cur}) )
                     return 0
                     ;;
+                schema)
+                    COMPREPLY=( $(compgen -W "export" -- ${cur}) )
+                    return 0
+                    ;;
                 *)
                     return 0
                     ;;
@@ -67,6 +84,16 @@ _genesys_completions() {
                     fi
                     return 0
                     ;;
+                validate)
+                    # More configuration files to check
+                    COMPREPLY=( $(compgen -f -X '!*.@(yaml|yml|toml)' -- ${cur}) )
+                    return 0
+                    ;;
+                schema)
+                    # Configuration kinds for schema export
+                    COMPREPLY=( $(compgen -W "Config S3Bucket EC2Instance LambdaFunction" -- ${cur}) )
+                    return 0
+                    ;;
                 config)
                     case "${prev}" in
                         setup)
@@ -88,7 +115,7 @@ _genesys_completions() {
             case "${cur}" in
                 -*)
                     # Global flags
-                    local global_flags="--help -h --version -v --verbose --debug"
+                    local global_flags="--help -h --version -v --verbose --debug --lock-timeout --env --var --var-file --policy-dir"
                     
                     # Command-specific flags
                     case "${COMP_WORDS[1]}" in
@@ -100,10 +127,24 @@ _genesys_completions() {
                             local discover_flags="--output -o --format -f --filter"
                             COMPREPLY=( $(compgen -W "${global_flags} ${discover_flags}" -- ${cur}) )
                             ;;
+                        state)
+                            local state_flags="--config --serial --output -o --type --provider --region --tag --name"
+                            COMPREPLY=( $(compgen -W "${global_flags} ${state_flags}" -- ${cur}) )
+                            ;;
+                        output)
+                            local output_flags="--config --output -o --refresh"
+                            COMPREPLY=( $(compgen -W "${global_flags} ${output_flags}" -- ${cur}) )
+                            ;;
                         config)
-                            local config_flags="--global --show-path"
+                            local config_flags="--global --show-path --dry-run"
                             COMPREPLY=( $(compgen -W "${global_flags} ${config_flags}" -- ${cur}) )
                             ;;
+                        schema)
+                            COMPREPLY=( $(compgen -W "${global_flags} --out" -- ${cur}) )
+                            ;;
+                        validate)
+                            COMPREPLY=( $(compgen -W "${global_flags} --output -o" -- ${cur}) )
+                            ;;
                         *)
                             COMPREPLY=( $(compgen -W "${global_flags}" -- ${cur}) )
                             ;;
@@ -3,8 +3,16 @@
 # Main commands
 complete -c genesys -n __fish_use_subcommand -a interact -d "Interactive resource creation wizard"
 complete -c genesys -n __fish_use_subcommand -a execute -d "Execute a configuration file"
+complete -c genesys -n __fish_use_subcommand -a plan -d "Show and save the plan for a configuration"
+complete -c genesys -n __fish_use_subcommand -a apply -d "Apply a saved plan file"
+complete -c genesys -n __fish_use_subcommand -a drift -d "Detect changes made outside Genesys"
+complete -c genesys -n __fish_use_subcommand -a workspace -d "Manage state workspaces"
+complete -c genesys -n __fish_use_subcommand -a state -d "Inspect and manage state"
+complete -c genesys -n __fish_use_subcommand -a output -d "Show the outputs of deployed resources"
 complete -c genesys -n __fish_use_subcommand -a discover -d "Discover existing cloud resources"
 complete -c genesys -n __fish_use_subcommand -a config -d "Manage Genesys configuration"
+complete -c genesys -n __fish_use_subcommand -a schema -d "Export the JSON Schema of configuration files"
+complete -c genesys -n __fish_use_subcommand -a validate -d "Check configuration files without contacting a provider"
 complete -c genesys -n __fish_use_subcommand -a version -d "Show version information"
 complete -c genesys -n __fish_use_subcommand -a help -d "Show help information"
 
@@ -12,6 +20,11 @@ complete -c genesys -n __fish_use_subcommand -a help -d "Show help information"
 complete -c genesys -s h -l help -d "Show help"
 complete -c genesys -s v -l version -d "Show version"
 complete -c genesys -l verbose -d "Enable verbose output"
+complete -c genesys -l lock-timeout -x -d "Wait this long for the state lock"
+complete -c genesys -l env -x -d "Merge the overlay of this environment"
+complete -c genesys -l var -x -d "Set a configuration variable (name=value)"
+complete -c genesys -l var-file -r -d "Read configuration variables from a file"
+complete -c genesys -l policy-dir -x -a "(__fish_complete_directories)" -d "Read policy rules from this directory"
 complete -c genesys -l debug -d "Enable debug output"
 
 # Execute command
@@ -21,6 +34,40 @@ complete -c genesys -n "__fish_seen_subcommand_from execute" -l force -d "Force
 complete -c genesys -n "__fish_seen_subcommand_from execute" -s o -l output -x -a "json yaml table" -d "Output format"
 complete -c genesys -n "__fish_seen_subcommand_from execute" -s p -l parallel -d "Execute resources in parallel"
 
+# Plan and apply
+complete -c genesys -n "__fish_seen_subcommand_from plan" -F -r -d "Configuration file" -a "*.yaml *.yml *.toml"
+complete -c genesys -n "__fish_seen_subcommand_from plan" -l out -r -d "Write the plan to a file"
+complete -c genesys -n "__fish_seen_subcommand_from apply" -F -r -d "Plan file" -a "*.json"
+complete -c genesys -n "__fish_seen_subcommand_from apply" -l rollback-on-failure -d "Remove created resources if the apply fails"
+
+# Drift command
+complete -c genesys -n "__fish_seen_subcommand_from drift" -F -r -d "Configuration file" -a "*.yaml *.yml *.toml"
+complete -c genesys -n "__fish_seen_subcommand_from drift" -l provider -x -a "aws gcp azure tencent mock" -d "Only check resources from this provider"
+complete -c genesys -n "__fish_seen_subcommand_from drift" -l region -x -d "Only check resources in this region"
+complete -c genesys -n "__fish_seen_subcommand_from drift" -s o -l output -x -a "human json" -d "Output format"
+complete -c genesys -n "__fish_seen_subcommand_from drift" -l ignore-unmanaged -d "Do not look for resources missing from state"
+
+# Workspace command
+complete -c genesys -n "__fish_seen_subcommand_from workspace; and not __fish_seen_subcommand_from new select list show delete" -a "new select list show delete" -d "Workspace command"
+complete -c genesys -n "__fish_seen_subcommand_from workspace" -l dir -r -d "Project directory or configuration file"
+complete -c genesys -n "__fish_seen_subcommand_from workspace; and __fish_seen_subcommand_from delete" -l force -d "Delete a workspace that still tracks resources"
+
+# State command
+complete -c genesys -n "__fish_seen_subcommand_from state; and not __fish_seen_subcommand_from list show rm mv import history rollback rotate-key force-unlock" -a "list show rm mv import history rollback rotate-key force-unlock" -d "State command"
+complete -c genesys -n "__fish_seen_subcommand_from state" -l config -r -d "Configuration file or project directory whose state to use"
+complete -c genesys -n "__fish_seen_subcommand_from state; and __fish_seen_subcommand_from show" -l serial -r -d "Show the state as it was written with this serial"
+complete -c genesys -n "__fish_seen_subcommand_from state; and __fish_seen_subcommand_from list" -l type -r -a "s3 ec2 lambda rds vpc subnet security-group" -d "Only list records of this type"
+complete -c genesys -n "__fish_seen_subcommand_from state; and __fish_seen_subcommand_from list import" -l provider -r -d "Provider of the records or of the imported resource"
+complete -c genesys -n "__fish_seen_subcommand_from state; and __fish_seen_subcommand_from list import" -l region -r -d "Region of the records or of the imported resource"
+complete -c genesys -n "__fish_seen_subcommand_from state; and __fish_seen_subcommand_from list" -l tag -r -d "Only list records with this tag (key=value or key)"
+complete -c genesys -n "__fish_seen_subcommand_from state; and __fish_seen_subcommand_from import" -l name -r -d "Name to record the imported resource under"
+complete -c genesys -n "__fish_seen_subcommand_from state; and __fish_seen_subcommand_from list show history" -s o -l output -r -a "human json" -d "Output format"
+
+# Output command
+complete -c genesys -n "__fish_seen_subcommand_from output" -l config -r -d "Configuration file or project directory whose state to use"
+complete -c genesys -n "__fish_seen_subcommand_from output" -s o -l output -r -a "human json" -d "Output format"
+complete -c genesys -n "__fish_seen_subcommand_from output" -l refresh -d "Read the current outputs from the provider"
+
 # Discover command
 complete -c genesys -n "__fish_seen_subcommand_from discover; and not __fish_seen_subcommand_from aws gcp azure tencent" -a "aws gcp azure tencent" -d "Cloud provider"
 complete -c genesys -n "__fish_seen_subcommand_from discover; and __fish_seen_subcommand_from aws gcp azure tencent" -a "compute storage network database serverless all" -d "Resource type"
@@ -29,12 +76,23 @@ complete -c genesys -n "__fish_seen_subcommand_from discover" -s f -l format -x
 complete -c genesys -n "__fish_seen_subcommand_from discover" -l filter -x -d "Filter resources"
 
 # Config command
-complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate" -a setup -d "Setup provider configuration"
-complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate" -a list -d "List configured providers"
-complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate" -a show -d "Show provider configuration"
-complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate" -a validate -d "Validate provider configuration"
+complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate migrate" -a setup -d "Setup provider configuration"
+complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate migrate" -a list -d "List configured providers"
+complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate migrate" -a show -d "Show provider configuration"
+complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate migrate" -a validate -d "Validate provider configuration"
+complete -c genesys -n "__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from setup list show validate migrate" -a migrate -d "Add apiVersion and kind headers to configuration files"
+complete -c genesys -n "__fish_seen_subcommand_from config; and __fish_seen_subcommand_from migrate" -l dry-run -d "Print the headers without changing files"
 
 # Config subcommands with providers
 complete -c genesys -n "__fish_seen_subcommand_from config; and __fish_seen_subcommand_from setup show validate" -a "aws gcp azure tencent" -d "Cloud provider"
 complete -c genesys -n "__fish_seen_subcommand_from config" -l global -d "Use global configuration"
-complete -c genesys -n "__fish_seen_subcommand_from config" -l show-path -d "Show configuration file path"
\ No newline at end of file
+complete -c genesys -n "__fish_seen_subcommand_from config" -l show-path -d "Show configuration file path"
+
+# Schema command
+complete -c genesys -n "__fish_seen_subcommand_from schema; and not __fish_seen_subcommand_from export" -a export -d "Print or write the JSON Schema of configuration kinds"
+complete -c genesys -n "__fish_seen_subcommand_from schema; and __fish_seen_subcommand_from export" -a "Config S3Bucket EC2Instance LambdaFunction" -d "Configuration kind"
+complete -c genesys -n "__fish_seen_subcommand_from schema" -l out -r -d "Write the schemas to this directory"
+
+# Validate command
+complete -c genesys -n "__fish_seen_subcommand_from validate; and not __fish_seen_subcommand_from config" -F -r -d "Configuration file" -a "*.yaml *.yml *.toml"
+complete -c genesys -n "__fish_seen_subcommand_from validate; and not __fish_seen_subcommand_from config" -s o -l output -x -a "human json sarif" -d "Output format"
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/javanhut/genesys/pkg/executor"
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/spf13/cobra"
+)
+
+// NewApplyCommand creates the apply command
+func NewApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply <plan-file>",
+		Short: "Apply a plan saved with 'genesys plan --out'",
+		Long: `Apply exactly the plan saved by 'genesys plan --out'.
+
+The apply is refused when the configuration file or the state has changed
+since the plan was created; generate a new plan in that case.
+
+Examples:
+  genesys plan genesys.yaml --out plan.json
+  genesys apply plan.json
+  genesys apply plan.json --rollback-on-failure`,
+		Args: cobra.ExactArgs(1),
+		RunE: runApply,
+	}
+
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", "human", "Output format (human|json)")
+	cmd.Flags().IntVar(&parallelism, "parallelism", executor.DefaultParallelism, "Maximum number of plan steps applied concurrently")
+	cmd.Flags().BoolVar(&rollbackOnErr, "rollback-on-failure", false, "Remove the resources created by this run if the deployment fails")
+	cmd.Flags().BoolVar(&allowReplace, "allow-replace", false, "Allow replacing stateful resources such as databases, deleting their data")
+
+	return cmd
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	planFile, err := planner.LoadPlanFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	data, err := readConfigFiles(append([]string{planFile.ConfigFile}, planFile.Inputs...))
+	if err != nil {
+		return fmt.Errorf("failed to read the configuration the plan was made from: %w", err)
+	}
+
+	localState, err := loadProjectState(planFile.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to load local state: %w", err)
+	}
+
+	// Keep other runs from changing the state between the check and the apply
+	unlock, err := localState.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if planFile.Workspace != "" && planFile.Workspace != localState.Workspace() {
+		return fmt.Errorf("refusing to apply %s: it was planned in workspace %s but workspace %s is selected",
+			args[0], planFile.Workspace, localState.Workspace())
+	}
+	if err := planFile.Verify(data, localState.Serial); err != nil {
+		return fmt.Errorf("refusing to apply %s: %w", args[0], err)
+	}
+
+	tags, err := defaultTags(planFile.ConfigFile)
+	if err != nil {
+		return err
+	}
+	p, err := getProvider(planFile.Provider, planFile.Region, tags)
+	if err != nil {
+		return err
+	}
+
+	// Policy files may have changed since the plan was made
+	if err := checkPolicies(planFile.Plan, planFile.Policies, planFile.ConfigFile, outputFormat == "json"); err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+	if err := newPlanExecutor(p).Check(planFile.Plan); err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+
+	if outputFormat != "json" {
+		fmt.Printf("Applying plan %s (created %s from %s)\n\n", planFile.Plan.ID, planFile.CreatedAt.Format("2006-01-02 15:04:05"), planFile.ConfigFile)
+	}
+
+	return applyPlan(ctx, p, planFile.Plan, planFile.ConfigFile)
+}
@@ -0,0 +1,244 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/provider/aws"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+// memoryStateBackend is shared by every command in the process so the
+// in-memory backend behaves like a store for the lifetime of a run
+var memoryStateBackend = provider.NewMockStateBackend()
+
+// loadProjectState loads the state of the selected workspace of the project
+// containing path, from the backend the project's settings select. Local
+// state notes records copied from the old global state file.
+func loadProjectState(path string) (*state.LocalState, error) {
+	cfg, projectDir, err := stateConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := stateKeyring(projectDir, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := stateBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if backend != nil {
+		return state.OpenProjectState(path, state.WithEncryption(backend, keyring), cfg.State.Key)
+	}
+
+	state.Keys = keyring
+	localState, err := state.LoadProjectState(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if localState.Migrated > 0 {
+		fmt.Fprintf(os.Stderr, "Copied %d resource record(s) from ~/.genesys-state.json into %s\n",
+			localState.Migrated, localState.Location())
+	}
+	return localState, nil
+}
+
+// stateConfig returns the provider, region and state settings of the project
+// containing path, and the project directory. They are resolved once per
+// project and kept in .genesys/settings.yaml: the first configuration with a
+// Config document whose state is loaded records them, and every later
+// command uses them whatever file or directory it names. A configuration
+// that disagrees with them is an error. Projects without settings use local
+// state, and nil is returned for them.
+func stateConfig(path string) (*config.Config, string, error) {
+	projectDir, err := state.FindProjectDir(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	settingsPath := state.SettingsPath(projectDir)
+	settings, err := config.LoadProjectSettings(settingsPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	declared, err := declaredSettings(path, projectDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case settings != nil && declared != nil:
+		if !reflect.DeepEqual(declared.State, settings.State) {
+			return nil, "", fmt.Errorf("%s stores state with %s, but project %s stores it with %s as recorded in %s; edit that file to move the project's state",
+				path, describeState(declared.State), projectDir, describeState(settings.State), settingsPath)
+		}
+	case declared != nil:
+		local := declared.State.Backend == "" || declared.State.Backend == "local"
+		if _, err := os.Stat(state.StatePath(projectDir, state.DefaultWorkspace)); err == nil && !local {
+			return nil, "", fmt.Errorf("%s stores state with %s, but project %s already has local state in %s; add 'state: {backend: local}' to the configuration or move the state first",
+				path, describeState(declared.State), projectDir, state.ProjectDirName)
+		}
+		if err := os.MkdirAll(filepath.Dir(settingsPath), 0755); err != nil {
+			return nil, "", fmt.Errorf("failed to create %s: %w", filepath.Dir(settingsPath), err)
+		}
+		if err := config.SaveProjectSettings(settingsPath, declared); err != nil {
+			return nil, "", err
+		}
+		settings = declared
+	case settings == nil:
+		return nil, projectDir, nil
+	}
+
+	return &config.Config{Provider: settings.Provider, Region: settings.Region, State: settings.State}, projectDir, nil
+}
+
+// declaredSettings returns the project settings a configuration file
+// declares, or nil when path is a directory or holds only resource-specific
+// documents. A key file is recorded relative to the project directory.
+func declaredSettings(path, projectDir string) (*config.ProjectSettings, error) {
+	if info, err := os.Stat(path); err != nil || info.IsDir() || !hasConfigDocument(path) {
+		return nil, nil
+	}
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	settings := &config.ProjectSettings{Provider: cfg.Provider, Region: cfg.Region, State: cfg.State}
+	if keyFile := settings.State.KeyFile; keyFile != "" && !filepath.IsAbs(keyFile) && !strings.HasPrefix(keyFile, "~/") {
+		abs, err := filepath.Abs(filepath.Join(filepath.Dir(path), keyFile))
+		if err != nil {
+			return nil, err
+		}
+		if rel, err := filepath.Rel(projectDir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			abs = rel
+		}
+		settings.State.KeyFile = abs
+	}
+	return settings, nil
+}
+
+// describeState names the backend and key settings of a state section
+func describeState(cfg config.StateConfig) string {
+	backend := cfg.Backend
+	if backend == "" {
+		backend = "local"
+	}
+	description := "the " + backend + " backend"
+	if cfg.Bucket != "" {
+		description += " (bucket " + cfg.Bucket + ")"
+	}
+	if cfg.KeyFile != "" || cfg.KMSKeyID != "" {
+		description += " and a state key"
+	}
+	return description
+}
+
+// hasConfigDocument reports whether a file holds a document of kind Config
+func hasConfigDocument(path string) bool {
+	docs, err := config.ReadDocuments(path)
+	if err != nil {
+		return false
+	}
+	for _, doc := range docs {
+		if doc.Kind == config.KindConfig {
+			return true
+		}
+	}
+	return false
+}
+
+// envKeyring is the keyring from the GENESYS_STATE_* environment variables,
+// loaded once so passphrase keys are derived once per run
+var envKeyring *state.Keyring
+
+// stateKeyring returns the keys state is encrypted with. A key file or KMS
+// key set in the settings of the project at projectDir encrypts new writes;
+// keys from the environment encrypt new writes otherwise, and are kept for
+// reading state written with them.
+func stateKeyring(projectDir string, cfg *config.Config) (*state.Keyring, error) {
+	if envKeyring == nil {
+		keyring, err := state.EnvKeyring()
+		if err != nil {
+			return nil, err
+		}
+		envKeyring = keyring
+	}
+	if cfg == nil || (cfg.State.KeyFile == "" && cfg.State.KMSKeyID == "") {
+		return envKeyring, nil
+	}
+	if cfg.State.KeyFile != "" && cfg.State.KMSKeyID != "" {
+		return nil, fmt.Errorf("state.key_file and state.kms_key_id cannot both be set")
+	}
+
+	var primary state.KeyWrapper
+	if cfg.State.KeyFile != "" {
+		keyFile := cfg.State.KeyFile
+		if !filepath.IsAbs(keyFile) && !strings.HasPrefix(keyFile, "~/") {
+			keyFile = filepath.Join(projectDir, keyFile)
+		}
+		key, err := state.LoadKeyFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		primary = key
+	} else {
+		p, err := getProvider(cfg.Provider, cfg.Region, nil)
+		if err != nil {
+			return nil, err
+		}
+		awsProvider, ok := p.(*aws.AWSProvider)
+		if !ok {
+			return nil, fmt.Errorf("state.kms_key_id requires the aws provider, not %s", cfg.Provider)
+		}
+		primary = aws.NewKMSKeyWrapper(awsProvider, cfg.State.KMSKeyID)
+	}
+
+	keyring := &state.Keyring{Primary: primary}
+	if envKeyring.Primary != nil {
+		keyring.Previous = append(keyring.Previous, envKeyring.Primary)
+	}
+	keyring.Previous = append(keyring.Previous, envKeyring.Previous...)
+	return keyring, nil
+}
+
+// stateBackend returns the remote backend selected by a configuration's
+// state.backend setting, or nil for local state
+func stateBackend(cfg *config.Config) (provider.StateBackend, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	switch cfg.State.Backend {
+	case "", "local":
+		return nil, nil
+
+	case "memory":
+		return memoryStateBackend, nil
+
+	case "s3":
+		p, err := getProvider(cfg.Provider, cfg.Region, nil)
+		if err != nil {
+			return nil, err
+		}
+		awsProvider, ok := p.(*aws.AWSProvider)
+		if !ok {
+			return nil, fmt.Errorf("the s3 state backend requires the aws provider, not %s", cfg.Provider)
+		}
+		return aws.NewS3StateBackend(awsProvider, cfg.State.Bucket, cfg.State.LockTable), nil
+
+	default:
+		return nil, fmt.Errorf("state backend %q is not supported (use local, s3 or memory)", cfg.State.Backend)
+	}
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+func TestProjectBackend(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(state.WorkspaceEnvVar, "")
+
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	configPath := write("genesys.yaml", `provider: mock
+region: us-east-1
+state:
+  backend: memory
+  key: backend-test
+resources:
+  storage:
+    - name: backend-test-bucket
+      type: bucket
+`)
+	bucketPath := write("bucket.yaml", `apiVersion: genesys.dev/v1
+kind: S3Bucket
+metadata:
+  name: other-bucket
+spec:
+  versioning: true
+`)
+
+	// Every command in the project uses the backend the configuration
+	// selected, whatever it names
+	for _, path := range []string{configPath, dir, bucketPath, configPath} {
+		st, err := loadProjectState(path)
+		if err != nil {
+			t.Fatalf("loadProjectState(%s) error = %v", path, err)
+		}
+		if location := st.Location(); location != "memory:backend-test/state.json" {
+			t.Errorf("loadProjectState(%s) location = %s, want the memory backend", path, location)
+		}
+	}
+
+	// A configuration that disagrees with the project's settings is refused
+	otherPath := write("other.yaml", `provider: mock
+region: us-east-1
+state:
+  backend: local
+resources:
+  storage:
+    - name: other
+      type: bucket
+`)
+	if _, err := loadProjectState(otherPath); err == nil || !strings.Contains(err.Error(), "settings.yaml") {
+		t.Errorf("loadProjectState(other.yaml) error = %v, want a conflict with the project settings", err)
+	}
+}
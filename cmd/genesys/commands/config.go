@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/javanhut/genesys/pkg/config"
@@ -32,6 +33,7 @@ This command allows you to:
 	cmd.AddCommand(newConfigDefaultCommand())
 	cmd.AddCommand(newConfigRefreshCommand())
 	cmd.AddCommand(newConfigValidateCommand())
+	cmd.AddCommand(newConfigMigrateCommand())
 
 	return cmd
 }
@@ -322,6 +324,68 @@ This command will:
 	return cmd
 }
 
+var configMigrateDryRun bool
+
+// newConfigMigrateCommand creates the config migrate subcommand
+func newConfigMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate <config-file...>",
+		Short: "Add apiVersion and kind headers to configuration files",
+		Long: fmt.Sprintf(`Upgrade configuration files written before documents carried a header.
+
+Every document without one gets an apiVersion and kind header, with the kind
+worked out from its contents the way Genesys has always told files apart:
+
+  apiVersion: %s
+  kind: S3Bucket
+
+Kinds: %s
+
+Comments and formatting are kept. Files that are already up to date are left
+alone. Use --dry-run to print the headers without changing the files.`, config.APIVersion, strings.Join(config.KindNames(), ", ")),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, path := range args {
+				data, migrations, err := config.Migrate(path)
+				if err != nil {
+					return err
+				}
+				if len(migrations) == 0 {
+					fmt.Printf("%s: up to date\n", path)
+					continue
+				}
+
+				for _, migration := range migrations {
+					fmt.Printf("%s: kind %s\n", migration.Document.Name(), migration.Document.Kind)
+					for _, line := range migration.Lines {
+						fmt.Printf("  + %s", line)
+					}
+				}
+				if configMigrateDryRun {
+					continue
+				}
+
+				info, err := os.Stat(path)
+				if err != nil {
+					return fmt.Errorf("failed to read config file: %w", err)
+				}
+				if err := os.WriteFile(path, data, info.Mode().Perm()); err != nil {
+					return fmt.Errorf("failed to write %s: %w", path, err)
+				}
+			}
+
+			if configMigrateDryRun {
+				fmt.Println("\nDry run: no files were changed.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&configMigrateDryRun, "dry-run", false, "Print the headers that would be added without changing files")
+
+	return cmd
+}
+
 // validateAWSConfig validates AWS configuration
 func validateAWSConfig() error {
 	// Try to validate AWS credentials by importing the validation function
@@ -36,12 +36,13 @@ Examples:
   genesys discover                    # Same as list (alias)
   genesys list --service storage      # List only storage resources (S3 buckets)
   genesys list --provider aws         # Use specific provider
+  genesys list --provider mock        # Use the mock provider (testing)
   genesys list --region us-west-2     # Use specific region
   genesys list --output json          # JSON output format`,
 		RunE: runDiscover,
 	}
 
-	cmd.Flags().StringVar(&discoverProvider, "provider", "aws", "Cloud provider (aws|gcp|azure)")
+	cmd.Flags().StringVar(&discoverProvider, "provider", "aws", "Cloud provider (aws|gcp|azure|mock)")
 	cmd.Flags().StringVar(&discoverRegion, "region", "", "Cloud region")
 	cmd.Flags().StringVarP(&discoverFormat, "output", "o", "human", "Output format (human|json)")
 	cmd.Flags().StringVar(&discoverService, "service", "", "Specific service to discover (storage|compute|network|database|serverless)")
@@ -57,19 +58,15 @@ func runDiscover(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 
 	// Get the provider
-	p, err := provider.Get(discoverProvider, map[string]string{
-		"region": discoverRegion,
-	})
+	p, err := getProvider(discoverProvider, discoverRegion, nil)
 	if err != nil {
-		// For now, create a mock provider for testing
-		fmt.Printf("Note: Using mock provider (real provider not yet implemented)\n\n")
-		p = provider.NewMockProvider(discoverProvider, discoverRegion)
+		return err
 	}
 
 	// Discover resources based on service filter
 	discoveryResults := &DiscoveryResults{
 		Provider: discoverProvider,
-		Region:   discoverRegion,
+		Region:   p.Region(),
 		Services: make(map[string]*ServiceDiscovery),
 	}
 
@@ -0,0 +1,328 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/drift"
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/provider/aws"
+	"github.com/javanhut/genesys/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	driftProvider  string
+	driftRegion    string
+	driftFormat    string
+	driftUnmanaged bool
+)
+
+// NewDriftCommand creates the drift command
+func NewDriftCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drift [config-file...]",
+		Short: "Detect changes made to resources outside Genesys",
+		Long: `Compare the resources recorded in local state with the live resources and
+the configuration files they were created from.
+
+Resources are reported as drifted when a setting such as tags, versioning,
+encryption, instance type, memory or timeout no longer matches the
+configuration, and as missing when they were deleted outside Genesys. With
+--unmanaged, every resource in the account that is not recorded in state is
+listed as unmanaged.
+
+The command exits with a non-zero status when anything has drifted, so it
+can gate CI pipelines. Unmanaged resources do not count as drift.
+
+Examples:
+  genesys drift                          # Check every resource in state
+  genesys drift genesys.yaml             # Check the resources from one configuration
+  genesys drift --provider aws --region us-west-2 --unmanaged
+  genesys drift -o json`,
+		Args: cobra.ArbitraryArgs,
+		RunE: runDrift,
+	}
+
+	cmd.Flags().StringVar(&driftProvider, "provider", "", "Only check resources from this provider")
+	cmd.Flags().StringVar(&driftRegion, "region", "", "Only check resources in this region")
+	cmd.Flags().StringVarP(&driftFormat, "output", "o", "human", "Output format (human|json)")
+	cmd.Flags().BoolVar(&driftUnmanaged, "unmanaged", false, "Also list resources in the account that are not recorded in state")
+
+	return cmd
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	project := "."
+	if len(args) > 0 {
+		project = args[0]
+	}
+
+	localState, err := loadProjectState(project)
+	if err != nil {
+		return fmt.Errorf("failed to load local state: %w", err)
+	}
+	projectDir, err := state.FindProjectDir(project)
+	if err != nil {
+		return err
+	}
+
+	groups := driftTargets(localState.Resources, projectDir, args)
+	if len(groups) == 0 {
+		if driftFormat == "json" {
+			fmt.Println("[]")
+		} else {
+			fmt.Println("No resources recorded in state. Use --provider with --unmanaged to look for unmanaged resources.")
+		}
+		return nil
+	}
+
+	desired := newDesiredResolver(projectDir)
+	var reports []*drift.Report
+
+	for _, group := range groups {
+		p, err := getProvider(group.provider, group.region, nil)
+		if err != nil {
+			return err
+		}
+
+		detector := drift.NewDetector(p, desired.step)
+		detector.Unmanaged = driftUnmanaged
+
+		report, err := detector.Detect(ctx, group.records)
+		if err != nil {
+			return fmt.Errorf("failed to check %s (%s) for drift: %w", group.provider, group.region, err)
+		}
+		reports = append(reports, report)
+	}
+
+	if driftFormat == "json" {
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode drift report: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		for i, report := range reports {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Print(report.ToHumanReadable())
+		}
+	}
+
+	drifted := 0
+	for _, report := range reports {
+		if report.HasDrift() {
+			drifted++
+		}
+	}
+	if drifted > 0 {
+		// The report already explains what drifted
+		cmd.SilenceUsage = true
+		return fmt.Errorf("drift detected in %d of %d provider region(s)", drifted, len(reports))
+	}
+
+	return nil
+}
+
+// driftTarget is a provider region and the records checked in it
+type driftTarget struct {
+	provider string
+	region   string
+	records  []state.ResourceRecord
+}
+
+// driftTargets groups the records to check by provider and region, applying
+// the --provider and --region filters and the config files given as
+// arguments. Configuration files are compared as absolute paths.
+func driftTargets(records []state.ResourceRecord, projectDir string, configFiles []string) []driftTarget {
+	selected := make(map[string]bool)
+	for _, file := range configFiles {
+		selected[state.ConfigPath(file)] = true
+	}
+
+	groups := make(map[string]*driftTarget)
+	var keys []string
+
+	addGroup := func(providerName, region string) *driftTarget {
+		key := providerName + "/" + region
+		if group, ok := groups[key]; ok {
+			return group
+		}
+		groups[key] = &driftTarget{provider: providerName, region: region}
+		keys = append(keys, key)
+		return groups[key]
+	}
+
+	for _, record := range records {
+		providerName := record.Provider
+		if providerName == "" {
+			providerName = "aws"
+		}
+		if driftProvider != "" && providerName != driftProvider {
+			continue
+		}
+		if driftRegion != "" && record.Region != driftRegion {
+			continue
+		}
+		if len(configFiles) > 0 && !selected[recordConfigPath(projectDir, record.ConfigFile)] {
+			continue
+		}
+
+		group := addGroup(providerName, record.Region)
+		group.records = append(group.records, record)
+	}
+
+	// An explicit provider is checked for unmanaged resources even when
+	// nothing is recorded for it
+	if driftUnmanaged && driftProvider != "" && len(keys) == 0 && len(configFiles) == 0 {
+		addGroup(driftProvider, driftRegion)
+	}
+
+	sort.Strings(keys)
+	targets := make([]driftTarget, 0, len(keys))
+	for _, key := range keys {
+		targets = append(targets, *groups[key])
+	}
+	return targets
+}
+
+// desiredResolver reads the configuration files resources were created from,
+// loading each file once
+type desiredResolver struct {
+	projectDir string
+	steps      map[string][]planner.PlanStep
+}
+
+func newDesiredResolver(projectDir string) *desiredResolver {
+	return &desiredResolver{projectDir: projectDir, steps: make(map[string][]planner.PlanStep)}
+}
+
+// step returns the step declaring the recorded resource, or nil when its
+// configuration no longer exists or does not declare it
+func (r *desiredResolver) step(record state.ResourceRecord) (*planner.PlanStep, error) {
+	if record.ConfigFile == "" {
+		return nil, nil
+	}
+
+	configPath := recordConfigPath(r.projectDir, record.ConfigFile)
+	steps, ok := r.steps[configPath]
+	if !ok {
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			r.steps[configPath] = nil
+			return nil, nil
+		}
+
+		var err error
+		steps, err = configSteps(configPath)
+		if err != nil {
+			return nil, err
+		}
+		r.steps[configPath] = steps
+	}
+
+	resource, _ := planner.ResourceForState(record.Type)
+	for _, step := range steps {
+		if step.Resource == resource && step.Target == record.Name {
+			return &step, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordConfigPath returns the absolute path of the configuration file a
+// record was created from. Records written before paths were recorded as
+// absolute hold paths relative to the project directory.
+func recordConfigPath(projectDir, configFile string) string {
+	if configFile == "" || filepath.IsAbs(configFile) {
+		return filepath.Clean(configFile)
+	}
+	return filepath.Join(projectDir, configFile)
+}
+
+// configSteps returns the create steps a configuration file declares
+func configSteps(configPath string) ([]planner.PlanStep, error) {
+	docs, err := config.ReadDocuments(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []planner.PlanStep
+	for _, doc := range docs {
+		if doc.Kind != config.KindConfig {
+			handler, ok := kindHandlers[doc.Kind]
+			if !ok {
+				return nil, fmt.Errorf("%s: kind %s has no drift support", doc.Name(), doc.Kind)
+			}
+			docSteps, err := handler.steps(doc)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, docSteps...)
+			continue
+		}
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+		plan, err := planner.NewConfigPlan(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate plan: %w", err)
+		}
+		steps = append(steps, plan.Steps...)
+	}
+	return steps, nil
+}
+
+// s3ConfigSteps returns the create steps of an S3Bucket document
+func s3ConfigSteps(doc *config.Document) ([]planner.PlanStep, error) {
+	var s3Config config.S3BucketConfig
+	if err := doc.Decode(&s3Config); err != nil {
+		return nil, fmt.Errorf("failed to parse S3 configuration: %w", err)
+	}
+	var steps []planner.PlanStep
+	for _, bucket := range s3Config.Resources.Storage {
+		steps = append(steps, s3BucketStep(bucket))
+	}
+	return steps, nil
+}
+
+// ec2ConfigSteps returns the create steps of an EC2Instance document
+func ec2ConfigSteps(doc *config.Document) ([]planner.PlanStep, error) {
+	var ec2Config config.EC2InstanceConfig
+	if err := doc.Decode(&ec2Config); err != nil {
+		return nil, fmt.Errorf("failed to parse EC2 configuration: %w", err)
+	}
+	var steps []planner.PlanStep
+	for _, instance := range ec2Config.Resources.Compute {
+		steps = append(steps, planner.PlanStep{
+			ID:       "compute-" + instance.Name,
+			Action:   planner.ActionCreate,
+			Resource: "instance",
+			Target:   instance.Name,
+			Properties: map[string]string{
+				"type": aws.ReportedInstanceType(instance.Type),
+			},
+			Tags: instance.Tags,
+		})
+	}
+	return steps, nil
+}
+
+// lambdaConfigSteps returns the create step of a LambdaFunction document
+func lambdaConfigSteps(doc *config.Document) ([]planner.PlanStep, error) {
+	var lambdaConfig config.LambdaFunctionConfig
+	if err := doc.Decode(&lambdaConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse Lambda configuration: %w", err)
+	}
+	return []planner.PlanStep{lambdaFunctionStep(lambdaConfig)}, nil
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+func TestDriftTargetsMatchesConfigFilesByPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(dir, "sub"))
+
+	records := []state.ResourceRecord{
+		{ID: "abs", Provider: "mock", Region: "us-east-1", ConfigFile: filepath.Join(dir, "sub", "app.yaml")},
+		{ID: "legacy", Provider: "mock", Region: "us-east-1", ConfigFile: "sub/app.yaml"},
+		{ID: "other", Provider: "mock", Region: "us-east-1", ConfigFile: filepath.Join(dir, "other.yaml")},
+	}
+
+	for _, arg := range []string{"app.yaml", "./app.yaml", filepath.Join(dir, "sub", "app.yaml")} {
+		targets := driftTargets(records, dir, []string{arg})
+		if len(targets) != 1 || len(targets[0].records) != 2 {
+			t.Fatalf("driftTargets(%q) = %+v, want both records of app.yaml", arg, targets)
+		}
+		for _, record := range targets[0].records {
+			if record.ID == "other" {
+				t.Errorf("driftTargets(%q) selected a record of other.yaml", arg)
+			}
+		}
+	}
+}
+
+func TestDriftTargetsUnmanagedIsOptIn(t *testing.T) {
+	defer func(name string, unmanaged bool) { driftProvider, driftUnmanaged = name, unmanaged }(driftProvider, driftUnmanaged)
+	driftProvider = "mock"
+
+	driftUnmanaged = false
+	if targets := driftTargets(nil, t.TempDir(), nil); len(targets) != 0 {
+		t.Errorf("driftTargets() without --unmanaged = %+v, want none", targets)
+	}
+
+	driftUnmanaged = true
+	if targets := driftTargets(nil, t.TempDir(), nil); len(targets) != 1 || targets[0].provider != "mock" {
+		t.Errorf("driftTargets() with --unmanaged = %+v, want the mock provider", targets)
+	}
+}
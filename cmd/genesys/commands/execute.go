@@ -6,20 +6,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/executor"
 	"github.com/javanhut/genesys/pkg/intent"
 	"github.com/javanhut/genesys/pkg/lambda"
 	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/policy"
 	"github.com/javanhut/genesys/pkg/provider"
 	providerTypes "github.com/javanhut/genesys/pkg/provider"
 	"github.com/javanhut/genesys/pkg/provider/aws"
 	"github.com/javanhut/genesys/pkg/state"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -30,6 +32,9 @@ var (
 	providerName  string
 	region        string
 	outputFormat  string
+	parallelism   int
+	rollbackOnErr bool
+	allowReplace  bool
 )
 
 // NewExecuteCommand creates the execute command
@@ -58,9 +63,12 @@ Legacy intent-based usage (for backwards compatibility):
 	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Show what would be done without making changes")
 	cmd.Flags().BoolVar(&forceDeletion, "force-deletion", false, "Force delete bucket contents including all versions (use with deletion)")
 	cmd.Flags().StringVarP(&configFile, "config", "c", "", "Configuration file (YAML or TOML)")
-	cmd.Flags().StringVar(&providerName, "provider", "aws", "Cloud provider (aws|gcp|azure)")
+	cmd.Flags().StringVar(&providerName, "provider", "aws", "Cloud provider (aws|gcp|azure|mock)")
 	cmd.Flags().StringVar(&region, "region", "", "Cloud region")
 	cmd.Flags().StringVarP(&outputFormat, "output", "o", "human", "Output format (human|json)")
+	cmd.Flags().IntVar(&parallelism, "parallelism", executor.DefaultParallelism, "Maximum number of plan steps applied concurrently")
+	cmd.Flags().BoolVar(&rollbackOnErr, "rollback-on-failure", false, "Remove the resources created by this run if the deployment fails")
+	cmd.Flags().BoolVar(&allowReplace, "allow-replace", false, "Allow replacing stateful resources such as databases, deleting their data")
 
 	return cmd
 }
@@ -75,8 +83,12 @@ func runExecute(cmd *cobra.Command, args []string) error {
 	}
 
 	// Handle direct config file execution (e.g., "genesys execute bucket.yaml")
-	if len(args) == 1 && (strings.HasSuffix(args[0], ".yaml") || strings.HasSuffix(args[0], ".yml") || strings.HasSuffix(args[0], ".toml")) {
+	directConfig := len(args) == 1 && (strings.HasSuffix(args[0], ".yaml") || strings.HasSuffix(args[0], ".yml") || strings.HasSuffix(args[0], ".toml"))
+	if directConfig {
 		configFile = args[0]
+	}
+
+	if directConfig {
 		return executeConfigFile(ctx, configFile)
 	}
 
@@ -113,13 +125,13 @@ func runExecute(cmd *cobra.Command, args []string) error {
 	}
 
 	// Get the provider
-	p, err := provider.Get(cfg.Provider, map[string]string{
-		"region": cfg.Region,
-	})
+	tags, err := defaultTags(configFile)
 	if err != nil {
-		// For now, create a mock provider for testing
-		fmt.Printf("Note: Using mock provider (real provider not yet implemented)\n\n")
-		p = provider.NewMockProvider(cfg.Provider, cfg.Region)
+		return err
+	}
+	p, err := getProvider(cfg.Provider, cfg.Region, tags)
+	if err != nil {
+		return err
 	}
 
 	// Create planner
@@ -139,33 +151,37 @@ func runExecute(cmd *cobra.Command, args []string) error {
 	}
 
 	// Apply if requested
-	if applyFlag {
-		fmt.Println("\nApplying changes...")
-		// TODO: Implement executor
-		fmt.Println("Executor not yet implemented - this is a preview of what would happen")
-	} else {
+	if !applyFlag {
 		fmt.Println("\nNote: This is a preview. Use --apply to make these changes.")
+		return nil
 	}
 
-	return nil
+	if err := checkPolicies(plan, config.Policies{}, "", outputFormat == "json"); err != nil {
+		return err
+	}
+	if err := newPlanExecutor(p).Check(plan); err != nil {
+		return err
+	}
+
+	fmt.Println("\nApplying changes...")
+	return applyPlan(ctx, p, plan, "")
 }
 
 // executeFromConfig executes based on configuration file content
 func executeFromConfig(ctx context.Context, cfg *config.Config) error {
 	fmt.Println("Executing from configuration file...")
 
-	// Get the provider
-	p, err := provider.Get(cfg.Provider, map[string]string{
-		"region": cfg.Region,
-	})
+	tags, err := defaultTags(configFile)
 	if err != nil {
-		// For now, create a mock provider for testing
-		fmt.Printf("Note: Using mock provider (real provider not yet implemented)\n\n")
-		p = provider.NewMockProvider(cfg.Provider, cfg.Region)
+		return err
 	}
+	config.ApplyDefaultTags(cfg, tags)
 
-	// Create planner (for future use)
-	_ = planner.New(p)
+	// Get the provider
+	p, err := getProvider(cfg.Provider, cfg.Region, tags)
+	if err != nil {
+		return err
+	}
 
 	// Process outcomes if they exist
 	if len(cfg.Outcomes) > 0 {
@@ -183,157 +199,166 @@ func executeFromConfig(ctx context.Context, cfg *config.Config) error {
 		}
 	}
 
-	// Process resources if they exist
-	if len(cfg.Resources.Compute) > 0 || len(cfg.Resources.Storage) > 0 ||
-		len(cfg.Resources.Database) > 0 || len(cfg.Resources.Serverless) > 0 {
-
-		fmt.Println("Processing resources from configuration:")
-
-		// Process compute resources
-		for _, compute := range cfg.Resources.Compute {
-			fmt.Printf("- Compute: %s (%s, count: %d)\n", compute.Name, compute.Type, compute.Count)
-		}
-
-		// Process storage resources
-		for _, storage := range cfg.Resources.Storage {
-			fmt.Printf("- Storage: %s (%s)\n", storage.Name, storage.Type)
-		}
+	if !hasResources(cfg) {
+		return nil
+	}
 
-		// Process database resources
-		for _, database := range cfg.Resources.Database {
-			fmt.Printf("- Database: %s (%s %s, %dGB)\n", database.Name, database.Engine, database.Size, database.Storage)
-		}
+	return executeResources(ctx, p, cfg, configFile)
+}
 
-		// Process serverless resources
-		for _, serverless := range cfg.Resources.Serverless {
-			fmt.Printf("- Function: %s (%s, %dMB)\n", serverless.Name, serverless.Runtime, serverless.Memory)
-		}
+// hasResources reports whether the configuration declares any resources
+func hasResources(cfg *config.Config) bool {
+	return len(cfg.Resources.Compute) > 0 || len(cfg.Resources.Storage) > 0 ||
+		len(cfg.Resources.Network) > 0 || len(cfg.Resources.Database) > 0 ||
+		len(cfg.Resources.Serverless) > 0
+}
 
-		fmt.Println()
-		fmt.Println("Note: Full resource execution from config not yet implemented")
+// executeResources plans and creates the resources declared in a configuration
+func executeResources(ctx context.Context, p provider.Provider, cfg *config.Config, source string) error {
+	config.ApplyDefaults(cfg)
+	if err := config.ValidateConfig(cfg); err != nil {
+		return fmt.Errorf("config validation failed: %w", err)
 	}
 
-	return nil
-}
+	plan, err := planner.NewConfigPlan(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to generate plan: %w", err)
+	}
 
-// executeConfigFile handles execution of S3 and other config files
-func executeConfigFile(ctx context.Context, configPath string) error {
-	// Check if file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return fmt.Errorf("configuration file does not exist: %s", configPath)
+	if err := diffAgainstState(ctx, p, plan, source); err != nil {
+		return err
 	}
 
-	// Try to parse as S3 config first
-	if isS3Config(configPath) {
-		return executeS3Config(ctx, configPath)
+	if outputFormat == "json" {
+		fmt.Println(plan.ToJSON())
+	} else {
+		fmt.Println(plan.ToHumanReadable())
 	}
 
-	// Try to parse as EC2 config
-	if isEC2Config(configPath) {
-		return executeEC2Config(ctx, configPath)
+	if err := checkPolicies(plan, cfg.Policies, source, outputFormat == "json"); err != nil {
+		return err
+	}
+	if err := newPlanExecutor(p).Check(plan); err != nil {
+		return err
 	}
 
-	// Try to parse as Lambda config
-	if isLambdaConfig(configPath) {
-		return executeLambdaConfig(ctx, configPath)
+	if dryRunFlag {
+		fmt.Println("\nDry run: no resources were created.")
+		return nil
 	}
 
-	// Fall back to generic config execution
-	fmt.Printf("Executing configuration file: %s\n", configPath)
-	fmt.Println("Generic config execution not yet implemented")
-	return nil
+	fmt.Println("\nApplying changes...")
+	return applyPlan(ctx, p, plan, source)
 }
 
-// isS3Config checks if the file is an S3 bucket configuration
-func isS3Config(configPath string) bool {
-	data, err := os.ReadFile(configPath)
+// diffAgainstState compares a configuration plan with the resources recorded
+// in local state so that existing resources are updated instead of created again
+func diffAgainstState(ctx context.Context, p provider.Provider, plan *planner.Plan, source string) error {
+	localState, err := loadProjectState(source)
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to load local state: %w", err)
 	}
 
-	content := string(data)
-
-	// Check for TOML format first
-	if strings.HasSuffix(configPath, ".toml") {
-		return (strings.Contains(content, "provider = \"aws\"") || strings.Contains(content, "provider=\"aws\"")) &&
-			(strings.Contains(content, "[[resources.storage]]") || strings.Contains(content, "storage") &&
-				(strings.Contains(content, "bucket") || strings.Contains(content, "type = \"bucket\"")))
+	if err := planner.New(p).Diff(ctx, plan, localState, source); err != nil {
+		return fmt.Errorf("failed to compare plan with existing resources: %w", err)
 	}
 
-	// Fall back to YAML format
-	return strings.Contains(content, "provider: aws") &&
-		strings.Contains(content, "storage:") &&
-		(strings.Contains(content, "bucket") || strings.Contains(content, "type: bucket"))
+	return nil
 }
 
-// isEC2Config checks if the file is an EC2 instance configuration
-func isEC2Config(configPath string) bool {
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return false
-	}
+// kindHandler executes and deletes the resources of one kind of
+// configuration document
+type kindHandler struct {
+	execute func(ctx context.Context, doc *config.Document) error
+	delete  func(ctx context.Context, doc *config.Document) error
+	// steps returns the desired state of the document's resources for drift
+	steps func(doc *config.Document) ([]planner.PlanStep, error)
+}
 
-	content := string(data)
+// kindHandlers maps the kinds of config.Kinds to their handlers. Config
+// documents are loaded with config.LoadConfig and go through the planner, so
+// they have no handler.
+var kindHandlers = map[string]kindHandler{
+	config.KindS3Bucket:       {execute: executeS3Config, delete: executeS3Deletion, steps: s3ConfigSteps},
+	config.KindEC2Instance:    {execute: executeEC2Config, delete: executeEC2Deletion, steps: ec2ConfigSteps},
+	config.KindLambdaFunction: {execute: executeLambdaConfig, delete: executeLambdaDeletion, steps: lambdaConfigSteps},
+}
 
-	// Check for TOML format first
-	if strings.HasSuffix(configPath, ".toml") {
-		return (strings.Contains(content, "provider = \"aws\"") || strings.Contains(content, "provider=\"aws\"")) &&
-			(strings.Contains(content, "[[resources.compute]]") || strings.Contains(content, "compute") &&
-				(strings.Contains(content, "instance") || strings.Contains(content, "type = \"t3\"")))
+// handleDocuments executes, or deletes the resources of, every document of a
+// configuration file in order. On deletion the Config document destroys the
+// resources recorded in state for the file.
+func handleDocuments(ctx context.Context, configPath string, deletion bool) error {
+	// Check if file exists
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return fmt.Errorf("configuration file does not exist: %s", configPath)
 	}
 
-	// Fall back to YAML format
-	return strings.Contains(content, "provider: aws") &&
-		strings.Contains(content, "compute:") &&
-		(strings.Contains(content, "instance") || strings.Contains(content, "type:"))
-}
-
-// isLambdaConfig checks if the file is a Lambda function configuration
-func isLambdaConfig(configPath string) bool {
-	data, err := os.ReadFile(configPath)
+	docs, err := config.ReadDocuments(configPath)
 	if err != nil {
-		return false
+		return err
 	}
 
-	content := string(data)
+	for _, doc := range docs {
+		// A single document without a header reads as a Config as well, so
+		// only documents of kind Config go through the planner here
+		if doc.Kind == config.KindConfig {
+			// Multi-resource configurations go through the planner and executor
+			cfg, err := config.LoadConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if deletion {
+				err = destroyFromConfig(ctx, cfg, configPath)
+			} else {
+				err = executeFromConfig(ctx, cfg)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
 
-	// Check for TOML format - Lambda configs have [metadata], [build], [function], [deployment] sections
-	if strings.HasSuffix(configPath, ".toml") {
-		return strings.Contains(content, "[metadata]") &&
-			strings.Contains(content, "[build]") &&
-			strings.Contains(content, "[function]") &&
-			strings.Contains(content, "[deployment]") &&
-			(strings.Contains(content, "runtime =") || strings.Contains(content, "handler ="))
+		handler, ok := kindHandlers[doc.Kind]
+		if !ok {
+			return fmt.Errorf("%s: kind %s cannot be executed", doc.Name(), doc.Kind)
+		}
+		if doc.Count > 1 {
+			fmt.Printf("\n%s: %s\n\n", doc.Name(), doc.Kind)
+		}
+		run := handler.execute
+		if deletion {
+			run = handler.delete
+		}
+		if err := run(ctx, doc); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return false
+// executeConfigFile creates or updates the resources of a configuration file
+func executeConfigFile(ctx context.Context, configPath string) error {
+	return handleDocuments(ctx, configPath, false)
 }
 
 // executeS3Config handles S3 bucket configuration
-func executeS3Config(ctx context.Context, configPath string) error {
-	// Load S3 configuration
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read configuration file: %w", err)
-	}
+func executeS3Config(ctx context.Context, doc *config.Document) error {
+	configPath := doc.Path
 
 	var s3Config config.S3BucketConfig
-
-	// Try to parse based on file extension
-	if strings.HasSuffix(configPath, ".toml") {
-		if err := toml.Unmarshal(data, &s3Config); err != nil {
-			return fmt.Errorf("failed to parse S3 configuration as TOML: %w", err)
-		}
-	} else {
-		// Fall back to YAML for backward compatibility
-		if err := yaml.Unmarshal(data, &s3Config); err != nil {
-			return fmt.Errorf("failed to parse S3 configuration as YAML: %w", err)
-		}
+	if err := doc.Decode(&s3Config); err != nil {
+		return fmt.Errorf("failed to parse S3 configuration: %w", err)
 	}
 
 	bucketName := s3Config.Resources.Storage[0].Name
 
 	if dryRunFlag {
+		// Show the changes to an existing bucket when AWS can be reached
+		if step, ok := existingS3Bucket(ctx, s3Config); ok {
+			printBucketChanges(step, configPath, true)
+			return nil
+		}
+
 		fmt.Printf("================================================================================\n")
 		fmt.Printf("DRY RUN: S3 Bucket Creation Plan\n")
 		fmt.Printf("Configuration: %s\n", configPath)
@@ -410,7 +435,7 @@ func executeS3Config(ctx context.Context, configPath string) error {
 	}
 
 	// Create AWS provider
-	provider, err := aws.NewAWSProvider(s3Config.Region)
+	provider, err := newAWSProvider(s3Config.Region, configPath)
 	if err != nil {
 		return fmt.Errorf("failed to create AWS provider: %w", err)
 	}
@@ -421,6 +446,32 @@ func executeS3Config(ctx context.Context, configPath string) error {
 	// Get bucket configuration
 	bucketResource := s3Config.Resources.Storage[0]
 
+	// Update the bucket if it already exists instead of creating it again
+	step := s3BucketStep(bucketResource)
+	exists, err := planner.New(provider).DiffExisting(ctx, &step, bucketResource.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check for an existing bucket: %w", err)
+	}
+	if exists {
+		printBucketChanges(step, configPath, false)
+		if step.Action == planner.ActionNoOp {
+			return nil
+		}
+
+		if err := storageService.UpdateBucket(ctx, bucketResource.Name, &providerTypes.BucketConfig{
+			Name:         bucketResource.Name,
+			Versioning:   bucketResource.Versioning,
+			Encryption:   bucketResource.Encryption,
+			PublicAccess: bucketResource.PublicAccess,
+			Tags:         bucketResource.Tags,
+		}); err != nil {
+			return fmt.Errorf("failed to update S3 bucket: %w", err)
+		}
+
+		fmt.Printf("\n✓ Bucket %s updated\n", bucketResource.Name)
+		return nil
+	}
+
 	// Create bucket configuration
 	bucketConf := &providerTypes.BucketConfig{
 		Name:         bucketResource.Name,
@@ -464,26 +515,77 @@ func executeS3Config(ctx context.Context, configPath string) error {
 	return nil
 }
 
-// executeEC2Config handles EC2 instance configuration
-func executeEC2Config(ctx context.Context, configPath string) error {
-	// Load EC2 configuration
-	data, err := os.ReadFile(configPath)
+// s3BucketStep describes the bucket of an S3 configuration as a plan step
+func s3BucketStep(bucket config.S3StorageResource) planner.PlanStep {
+	return planner.PlanStep{
+		ID:       "storage-" + bucket.Name,
+		Action:   planner.ActionCreate,
+		Resource: "s3-bucket",
+		Target:   bucket.Name,
+		Properties: map[string]string{
+			"versioning": strconv.FormatBool(bucket.Versioning),
+			"encryption": strconv.FormatBool(bucket.Encryption),
+		},
+		Tags: bucket.Tags,
+	}
+}
+
+// existingS3Bucket compares the configured bucket with the bucket in AWS,
+// reporting false when it does not exist or AWS cannot be reached
+func existingS3Bucket(ctx context.Context, s3Config config.S3BucketConfig) (planner.PlanStep, bool) {
+	step := s3BucketStep(s3Config.Resources.Storage[0])
+
+	interactiveConfig, err := config.NewInteractiveConfig()
 	if err != nil {
-		return fmt.Errorf("failed to read configuration file: %w", err)
+		return step, false
+	}
+	if _, err := interactiveConfig.LoadProviderConfig("aws"); err != nil {
+		return step, false
 	}
 
-	var ec2Config config.EC2InstanceConfig
+	provider, err := aws.NewAWSProvider(s3Config.Region)
+	if err != nil {
+		return step, false
+	}
 
-	// Try to parse based on file extension
-	if strings.HasSuffix(configPath, ".toml") {
-		if err := toml.Unmarshal(data, &ec2Config); err != nil {
-			return fmt.Errorf("failed to parse EC2 configuration as TOML: %w", err)
-		}
-	} else {
-		// Fall back to YAML for backward compatibility
-		if err := yaml.Unmarshal(data, &ec2Config); err != nil {
-			return fmt.Errorf("failed to parse EC2 configuration as YAML: %w", err)
-		}
+	exists, err := planner.New(provider).DiffExisting(ctx, &step, step.Target)
+	return step, err == nil && exists
+}
+
+// printBucketChanges prints the field-level changes to an existing bucket
+func printBucketChanges(step planner.PlanStep, configPath string, dryRun bool) {
+	title := "APPLYING: S3 Bucket Update"
+	if dryRun {
+		title = "DRY RUN: S3 Bucket Update Plan"
+	}
+
+	fmt.Printf("================================================================================\n")
+	fmt.Printf("%s\n", title)
+	fmt.Printf("Configuration: %s\n", configPath)
+	fmt.Printf("================================================================================\n\n")
+
+	fmt.Printf("Bucket %s already exists.\n", step.Target)
+	if step.Action == planner.ActionNoOp {
+		fmt.Printf("No changes: the bucket already matches the configuration.\n")
+		return
+	}
+
+	fmt.Printf("\nCHANGES:\n")
+	for _, change := range step.Changes {
+		fmt.Printf("  ~ %s\n", change)
+	}
+	if dryRun {
+		fmt.Printf("\nNo actual changes will be made. Run without --dry-run to update the bucket.\n")
+	}
+}
+
+// executeEC2Config handles EC2 instance configuration
+func executeEC2Config(ctx context.Context, doc *config.Document) error {
+	configPath := doc.Path
+
+	var ec2Config config.EC2InstanceConfig
+	if err := doc.Decode(&ec2Config); err != nil {
+		return fmt.Errorf("failed to parse EC2 configuration: %w", err)
 	}
 
 	if len(ec2Config.Resources.Compute) == 0 {
@@ -519,7 +621,7 @@ func executeEC2Config(ctx context.Context, configPath string) error {
 	}
 
 	// Create AWS provider
-	provider, err := aws.NewAWSProvider(ec2Config.Region)
+	provider, err := newAWSProvider(ec2Config.Region, configPath)
 	if err != nil {
 		return fmt.Errorf("failed to create AWS provider: %w", err)
 	}
@@ -545,7 +647,7 @@ func executeEC2Config(ctx context.Context, configPath string) error {
 	}
 
 	// Store in local state for tracking
-	localState, err := state.LoadLocalState()
+	localState, err := loadProjectState(configPath)
 	if err != nil {
 		fmt.Printf("Warning: Failed to load local state: %v\n", err)
 	} else {
@@ -558,6 +660,7 @@ func executeEC2Config(ctx context.Context, configPath string) error {
 			ConfigFile: configPath,
 			CreatedAt:  time.Now(),
 			Tags:       instanceResource.Tags,
+			Outputs:    executor.InstanceOutputs(instance),
 		}
 
 		if err := localState.AddResource(record); err != nil {
@@ -618,17 +721,11 @@ func executeEC2Config(ctx context.Context, configPath string) error {
 }
 
 // executeLambdaConfig handles Lambda function configuration
-func executeLambdaConfig(ctx context.Context, configPath string) error {
-	// Load Lambda configuration
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read configuration file: %w", err)
-	}
+func executeLambdaConfig(ctx context.Context, doc *config.Document) (err error) {
+	configPath := doc.Path
 
 	var lambdaConfig config.LambdaFunctionConfig
-
-	// Parse TOML configuration
-	if err := toml.Unmarshal(data, &lambdaConfig); err != nil {
+	if err := doc.Decode(&lambdaConfig); err != nil {
 		return fmt.Errorf("failed to parse Lambda configuration: %w", err)
 	}
 
@@ -670,7 +767,7 @@ func executeLambdaConfig(ctx context.Context, configPath string) error {
 		}
 	}
 
-	provider, err := aws.NewAWSProvider(region)
+	provider, err := newAWSProvider(region, configPath)
 	if err != nil {
 		return fmt.Errorf("failed to create AWS provider: %w", err)
 	}
@@ -678,10 +775,18 @@ func executeLambdaConfig(ctx context.Context, configPath string) error {
 	// Get serverless service
 	serverlessService := provider.Serverless()
 
+	// Journal everything created from here on so a failed deployment can be undone
+	journal := executor.NewJournal()
+	defer func() {
+		if err != nil {
+			err = handleFailedApply(ctx, journal, err)
+		}
+	}()
+
 	// Step 0: Ensure IAM role (no user interaction)
 	fmt.Printf("Step 0/4: Ensuring IAM role...\n")
 
-	roleArn, err := ensureIAMRoleAutomated(ctx, provider, lambdaConfig.IAM, functionName, configPath)
+	roleArn, err := ensureIAMRoleAutomated(ctx, provider, lambdaConfig.IAM, functionName, doc, journal)
 	if err != nil {
 		return fmt.Errorf("failed to ensure IAM role: %w", err)
 	}
@@ -806,11 +911,19 @@ func executeLambdaConfig(ctx context.Context, configPath string) error {
 		functionConfig.Code.Layers = []string{layerVersionArn}
 	}
 
-	// Check if function already exists
-	existingFunction, err := serverlessService.AdoptFunction(ctx, functionName)
-	if err == nil && existingFunction != nil {
+	// Check if function already exists and show what changes
+	existing := lambdaFunctionStep(lambdaConfig)
+	var function *providerTypes.Function
+	exists, err := planner.New(provider).DiffExisting(ctx, &existing, functionName)
+	if err != nil {
+		return fmt.Errorf("failed to check for an existing function: %w", err)
+	}
+	if exists {
 		// Update existing function
 		fmt.Printf("  Function already exists, updating...\n")
+		for _, change := range existing.Changes {
+			fmt.Printf("    ~ %s\n", change)
+		}
 		if err := serverlessService.UpdateFunction(ctx, functionName, functionConfig); err != nil {
 			return fmt.Errorf("failed to update function: %w", err)
 		}
@@ -818,10 +931,13 @@ func executeLambdaConfig(ctx context.Context, configPath string) error {
 	} else {
 		// Create new function
 		fmt.Printf("  Creating new function...\n")
-		_, err := serverlessService.CreateFunction(ctx, functionConfig)
+		function, err = serverlessService.CreateFunction(ctx, functionConfig)
 		if err != nil {
 			return fmt.Errorf("failed to create function: %w", err)
 		}
+		journal.Record("lambda", functionName, functionName, func(ctx context.Context) error {
+			return serverlessService.DeleteFunction(ctx, functionName)
+		})
 		fmt.Printf("  ✓ Function created successfully\n")
 	}
 
@@ -839,7 +955,7 @@ func executeLambdaConfig(ctx context.Context, configPath string) error {
 	}
 
 	// Store in local state for tracking
-	localState, err := state.LoadLocalState()
+	localState, err := loadProjectState(configPath)
 	if err != nil {
 		fmt.Printf("Warning: Failed to load local state: %v\n", err)
 	} else {
@@ -856,6 +972,15 @@ func executeLambdaConfig(ctx context.Context, configPath string) error {
 				"Handler":      lambdaConfig.Metadata.Handler,
 				"Architecture": lambdaConfig.Deployment.Architecture,
 			},
+			Outputs: map[string]string{"function_name": functionName},
+		}
+		if function != nil {
+			record.Outputs = executor.FunctionOutputs(function)
+			// Only functions deployed with a URL have one
+			delete(record.Outputs, "function_url")
+		}
+		if functionURL != "" {
+			record.Outputs["function_url"] = functionURL
 		}
 
 		if err := localState.AddResource(record); err != nil {
@@ -897,6 +1022,27 @@ func executeLambdaConfig(ctx context.Context, configPath string) error {
 	return nil
 }
 
+// lambdaFunctionStep describes the function of a Lambda configuration as a plan step
+func lambdaFunctionStep(lambdaConfig config.LambdaFunctionConfig) planner.PlanStep {
+	props := map[string]string{
+		"runtime": lambdaConfig.Metadata.Runtime,
+		"handler": lambdaConfig.Metadata.Handler,
+		"memory":  strconv.Itoa(lambdaConfig.Function.MemoryMB),
+		"timeout": strconv.Itoa(lambdaConfig.Function.TimeoutSeconds),
+	}
+	for key, value := range lambdaConfig.Function.Environment {
+		props[planner.EnvPropertyPrefix+key] = value
+	}
+
+	return planner.PlanStep{
+		ID:         "function-" + lambdaConfig.Metadata.Name,
+		Action:     planner.ActionCreate,
+		Resource:   "lambda-function",
+		Target:     lambdaConfig.Metadata.Name,
+		Properties: props,
+	}
+}
+
 // performLambdaDryRun performs a dry run for Lambda function deployment
 func performLambdaDryRun(ctx context.Context, configPath string, lambdaConfig config.LambdaFunctionConfig) error {
 	fmt.Printf("================================================================================\n")
@@ -997,50 +1143,53 @@ func performLambdaDryRun(ctx context.Context, configPath string, lambdaConfig co
 
 // executeDeletion handles deletion of resources
 func executeDeletion(ctx context.Context, configPath string) error {
-	// Check if file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return fmt.Errorf("configuration file does not exist: %s", configPath)
+	return handleDocuments(ctx, configPath, true)
+}
+
+// destroyFromConfig deletes the resources recorded in state for a
+// configuration file
+func destroyFromConfig(ctx context.Context, cfg *config.Config, source string) error {
+	p, err := getProvider(cfg.Provider, cfg.Region, nil)
+	if err != nil {
+		return err
 	}
 
-	// Try to parse as S3 config first
-	if isS3Config(configPath) {
-		return executeS3Deletion(ctx, configPath)
+	localState, err := loadProjectState(source)
+	if err != nil {
+		return fmt.Errorf("failed to load local state: %w", err)
 	}
 
-	// Try to parse as EC2 config
-	if isEC2Config(configPath) {
-		return executeEC2Deletion(ctx, configPath)
+	plan, kept := planner.NewDestroyPlan(localState, source)
+	for _, record := range kept {
+		fmt.Printf("Warning: %s '%s' (%s) cannot be deleted yet and is left in place\n", record.Type, record.Name, record.ID)
+	}
+	if len(plan.Steps) == 0 {
+		fmt.Printf("No resources recorded in state for %s to delete.\n", source)
+		return nil
 	}
 
-	// Try to parse as Lambda config
-	if isLambdaConfig(configPath) {
-		return executeLambdaDeletion(ctx, configPath)
+	if outputFormat == "json" {
+		fmt.Println(plan.ToJSON())
+	} else {
+		fmt.Println(plan.ToHumanReadable())
 	}
 
-	fmt.Printf("Deletion for configuration file '%s' not yet implemented\n", configPath)
-	return nil
+	if dryRunFlag {
+		fmt.Println("\nDry run: no resources were deleted.")
+		return nil
+	}
+
+	fmt.Println("\nDeleting resources...")
+	return applyPlan(ctx, p, plan, source)
 }
 
 // executeS3Deletion handles S3 bucket deletion
-func executeS3Deletion(ctx context.Context, configPath string) error {
-	// Load S3 configuration
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read configuration file: %w", err)
-	}
+func executeS3Deletion(ctx context.Context, doc *config.Document) error {
+	configPath := doc.Path
 
 	var s3Config config.S3BucketConfig
-
-	// Try to parse based on file extension
-	if strings.HasSuffix(configPath, ".toml") {
-		if err := toml.Unmarshal(data, &s3Config); err != nil {
-			return fmt.Errorf("failed to parse S3 configuration as TOML: %w", err)
-		}
-	} else {
-		// Fall back to YAML for backward compatibility
-		if err := yaml.Unmarshal(data, &s3Config); err != nil {
-			return fmt.Errorf("failed to parse S3 configuration as YAML: %w", err)
-		}
+	if err := doc.Decode(&s3Config); err != nil {
+		return fmt.Errorf("failed to parse S3 configuration: %w", err)
 	}
 
 	bucketName := s3Config.Resources.Storage[0].Name
@@ -1126,25 +1275,12 @@ func executeS3Deletion(ctx context.Context, configPath string) error {
 }
 
 // executeEC2Deletion handles EC2 instance deletion
-func executeEC2Deletion(ctx context.Context, configPath string) error {
-	// Load EC2 configuration
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read configuration file: %w", err)
-	}
+func executeEC2Deletion(ctx context.Context, doc *config.Document) error {
+	configPath := doc.Path
 
 	var ec2Config config.EC2InstanceConfig
-
-	// Try to parse based on file extension
-	if strings.HasSuffix(configPath, ".toml") {
-		if err := toml.Unmarshal(data, &ec2Config); err != nil {
-			return fmt.Errorf("failed to parse EC2 configuration as TOML: %w", err)
-		}
-	} else {
-		// Fall back to YAML for backward compatibility
-		if err := yaml.Unmarshal(data, &ec2Config); err != nil {
-			return fmt.Errorf("failed to parse EC2 configuration as YAML: %w", err)
-		}
+	if err := doc.Decode(&ec2Config); err != nil {
+		return fmt.Errorf("failed to parse EC2 configuration: %w", err)
 	}
 
 	if len(ec2Config.Resources.Compute) == 0 {
@@ -1215,7 +1351,7 @@ func executeEC2Deletion(ctx context.Context, configPath string) error {
 		fmt.Printf("No instances found with Name tag '%s'.\n", instanceName)
 
 		// Try to find instances from local state as fallback
-		localState, err := state.LoadLocalState()
+		localState, err := loadProjectState(configPath)
 		if err == nil {
 			stateInstances := localState.FindResourcesByConfigFile(configPath)
 			if len(stateInstances) > 0 {
@@ -1255,7 +1391,7 @@ func executeEC2Deletion(ctx context.Context, configPath string) error {
 	}
 
 	// Load local state to update it
-	localState, err := state.LoadLocalState()
+	localState, err := loadProjectState(configPath)
 	if err != nil {
 		fmt.Printf("Warning: Failed to load local state: %v\n", err)
 	}
@@ -1297,17 +1433,11 @@ func executeEC2Deletion(ctx context.Context, configPath string) error {
 }
 
 // executeLambdaDeletion handles Lambda function deletion
-func executeLambdaDeletion(ctx context.Context, configPath string) error {
-	// Load Lambda configuration
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read configuration file: %w", err)
-	}
+func executeLambdaDeletion(ctx context.Context, doc *config.Document) error {
+	configPath := doc.Path
 
 	var lambdaConfig config.LambdaFunctionConfig
-
-	// Parse TOML configuration
-	if err := toml.Unmarshal(data, &lambdaConfig); err != nil {
+	if err := doc.Decode(&lambdaConfig); err != nil {
 		return fmt.Errorf("failed to parse Lambda configuration: %w", err)
 	}
 
@@ -1582,7 +1712,7 @@ func performEC2DryRun(ctx context.Context, configPath string, ec2Config config.E
 
 // validateAMIAccess validates that the AMI can be resolved and is accessible
 func validateAMIAccess(ctx context.Context, provider *aws.AWSProvider, image, region string) (string, error) {
-	client, err := provider.CreateClient("ec2")
+	client, err := provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return "", fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -1614,7 +1744,7 @@ func validateAMIAccess(ctx context.Context, provider *aws.AWSProvider, image, re
 
 // validateInstanceType validates that the instance type is available in the region
 func validateInstanceType(ctx context.Context, provider *aws.AWSProvider, instanceType, region string) error {
-	client, err := provider.CreateClient("ec2")
+	client, err := provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -1656,6 +1786,219 @@ func validateInstanceType(ctx context.Context, provider *aws.AWSProvider, instan
 	return nil
 }
 
+// getProvider resolves a provider from the registry. The default tags are
+// added to every resource the provider creates.
+func getProvider(name, region string, defaultTags map[string]string) (provider.Provider, error) {
+	p, err := provider.Get(name, providerConfig(region, defaultTags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize provider %s: %w (available: %s; use --provider mock for testing)",
+			name, err, strings.Join(provider.ListProviders(), ", "))
+	}
+	return p, nil
+}
+
+// newAWSProvider creates the AWS provider for a configuration document,
+// adding the default tags of its policy files to the resources it creates
+func newAWSProvider(region, source string) (*aws.AWSProvider, error) {
+	tags, err := defaultTags(source)
+	if err != nil {
+		return nil, err
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	p, err := aws.NewFactory(providerConfig(region, tags))
+	if err != nil {
+		return nil, err
+	}
+	return p.(*aws.AWSProvider), nil
+}
+
+// providerConfig builds the registry configuration of a provider
+func providerConfig(region string, defaultTags map[string]string) map[string]string {
+	config := map[string]string{"region": region}
+	for key, value := range defaultTags {
+		config[provider.DefaultTagPrefix+key] = value
+	}
+	return config
+}
+
+// newPlanExecutor creates an executor with the provider-specific handlers registered
+func newPlanExecutor(p provider.Provider) *executor.Executor {
+	exec := executor.New(p)
+	exec.AllowReplace = allowReplace
+	switch p := p.(type) {
+	case *aws.AWSProvider:
+		exec.RegisterHandler("iam-role", awsRoleHandler(p))
+	case *provider.MockProvider:
+		exec.RegisterHandler("iam-role", mockRoleHandler)
+	}
+	return exec
+}
+
+// applyPlan executes a plan, records created resources in local state and prints the results
+func applyPlan(ctx context.Context, p provider.Provider, plan *planner.Plan, source string) error {
+	if parallelism < 1 {
+		return fmt.Errorf("--parallelism must be at least 1")
+	}
+
+	exec := newPlanExecutor(p)
+	exec.Source = source
+	exec.Parallelism = parallelism
+
+	localState, err := loadProjectState(source)
+	if err != nil {
+		fmt.Printf("Warning: Failed to load local state, resources will not be tracked: %v\n", err)
+	} else {
+		// Hold the state lock for the whole run so concurrent runs wait for
+		// each other instead of interleaving their records
+		unlock, err := localState.Lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		exec.State = localState
+	}
+
+	result, err := exec.Execute(ctx, plan)
+	if err != nil {
+		return fmt.Errorf("failed to execute plan: %w", err)
+	}
+
+	if outputFormat == "json" {
+		fmt.Println(result.ToJSON())
+	} else {
+		fmt.Println(result.ToHumanReadable())
+	}
+
+	if result.Failed() {
+		return handleFailedApply(ctx, exec.Journal(), fmt.Errorf("%d step(s) failed", result.Count(executor.StatusFailed)))
+	}
+
+	return nil
+}
+
+// defaultTags reads the default tags of the organization and project
+// policy files of a configuration
+func defaultTags(source string) (map[string]string, error) {
+	settings, err := policy.LoadSettings(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	return settings.DefaultTags, nil
+}
+
+// checkPolicies checks a plan against the configuration's and the policy
+// files' rules, refusing plans that break a deny rule
+func checkPolicies(plan *planner.Plan, policies config.Policies, source string, jsonOutput bool) error {
+	engine, err := policy.Load(source, policies)
+	if err != nil {
+		return fmt.Errorf("failed to load policies: %w", err)
+	}
+	if len(engine.Rules) == 0 {
+		return nil
+	}
+
+	report := engine.Evaluate(plan)
+	out := os.Stdout
+	if jsonOutput {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "\n%s", report.ToHumanReadable())
+
+	if report.Denied() {
+		return fmt.Errorf("plan denied by %d policy violation(s)", report.Count(policy.SeverityDeny))
+	}
+	return nil
+}
+
+// handleFailedApply deals with the resources a failed run created: with
+// --rollback-on-failure they are removed in reverse order, otherwise they are
+// listed so they can be cleaned up by hand
+func handleFailedApply(ctx context.Context, journal *executor.Journal, cause error) error {
+	if journal.Len() == 0 {
+		return cause
+	}
+
+	if !rollbackOnErr {
+		fmt.Printf("\nThe following resources were created before the failure and were left in place:\n")
+		for _, entry := range journal.Entries() {
+			fmt.Printf("  • %s\n", entry)
+		}
+		fmt.Printf("Re-run with --rollback-on-failure to remove them automatically.\n")
+		return cause
+	}
+
+	fmt.Printf("\nRolling back %d resource(s) created by this run...\n", journal.Len())
+
+	// Roll back even if the run was interrupted
+	report := journal.Rollback(context.WithoutCancel(ctx))
+	if outputFormat == "json" {
+		fmt.Println(report.ToJSON())
+	} else {
+		fmt.Print(report.ToHumanReadable())
+	}
+
+	if !report.Complete() {
+		return fmt.Errorf("%w; rollback left %d resource(s) behind", cause, len(report.Failed))
+	}
+	return fmt.Errorf("%w; all created resources were rolled back", cause)
+}
+
+// awsRoleHandler creates the Lambda execution role for iam-role plan steps,
+// reusing the role when it already exists
+func awsRoleHandler(awsProvider *aws.AWSProvider) executor.Handler {
+	return func(ctx context.Context, e *executor.Executor, step planner.PlanStep) (*executor.Outcome, error) {
+		iamService := awsProvider.IAM()
+
+		existingRole, err := iamService.GetRole(ctx, step.Target)
+		if err == nil {
+			return &executor.Outcome{
+				ResourceID:   existingRole.ARN,
+				ResourceName: existingRole.Name,
+				Message:      fmt.Sprintf("Using existing role %s", existingRole.Name),
+				Outputs:      map[string]string{"role_arn": existingRole.ARN},
+			}, nil
+		}
+		if !aws.IsRoleNotFoundError(err) {
+			return nil, fmt.Errorf("error checking role: %w", err)
+		}
+
+		iamConfig := &config.LambdaIAM{
+			RoleName:         step.Target,
+			RequiredPolicies: []string{"Basic CloudWatch Logs access"},
+			AutoManage:       true,
+			AutoCleanup:      true,
+		}
+		functionName := strings.TrimPrefix(step.Target, "genesys-lambda-")
+
+		roleArn, err := createRoleAutomated(ctx, iamService, iamConfig, step.Target, functionName, e.Journal())
+		if err != nil {
+			return nil, err
+		}
+
+		return &executor.Outcome{
+			ResourceID:   roleArn,
+			ResourceName: step.Target,
+			Message:      fmt.Sprintf("Created role %s", step.Target),
+			Outputs:      map[string]string{"role_arn": roleArn},
+		}, nil
+	}
+}
+
+// mockRoleHandler stands in for the Lambda execution role with the mock provider
+func mockRoleHandler(ctx context.Context, e *executor.Executor, step planner.PlanStep) (*executor.Outcome, error) {
+	roleArn := fmt.Sprintf("arn:aws:iam::123456789012:role/%s", step.Target)
+	return &executor.Outcome{
+		ResourceID:   roleArn,
+		ResourceName: step.Target,
+		Message:      fmt.Sprintf("Created IAM role %s", step.Target),
+		Outputs:      map[string]string{"role_arn": roleArn},
+	}, nil
+}
+
 // formatBool formats a boolean value with custom true/false labels
 func formatBool(value bool, trueLabel, falseLabel string) string {
 	if value {
@@ -1665,7 +2008,8 @@ func formatBool(value bool, trueLabel, falseLabel string) string {
 }
 
 // ensureIAMRoleAutomated ensures the IAM role exists with no user interaction
-func ensureIAMRoleAutomated(ctx context.Context, provider *aws.AWSProvider, iamConfig *config.LambdaIAM, functionName, configPath string) (string, error) {
+// A newly created role is recorded in the journal.
+func ensureIAMRoleAutomated(ctx context.Context, provider *aws.AWSProvider, iamConfig *config.LambdaIAM, functionName string, doc *config.Document, journal *executor.Journal) (string, error) {
 	// If no IAM config, use defaults
 	if iamConfig == nil {
 		iamConfig = &config.LambdaIAM{
@@ -1693,21 +2037,23 @@ func ensureIAMRoleAutomated(ctx context.Context, provider *aws.AWSProvider, iamC
 
 	// Role doesn't exist - create it
 	fmt.Printf("  Creating role: %s\n", roleName)
-	roleArn, err := createRoleAutomated(ctx, iamService, iamConfig, roleName, functionName)
+	roleArn, err := createRoleAutomated(ctx, iamService, iamConfig, roleName, functionName, journal)
 	if err != nil {
 		return "", err
 	}
 
 	// Update config file with created role ARN
-	if err := updateConfigWithRoleArn(configPath, iamConfig, roleArn); err != nil {
+	if err := updateConfigWithRoleArn(doc, iamConfig, roleArn); err != nil {
 		fmt.Printf("  ⚠️ Warning: Failed to update config file with role ARN: %v\n", err)
 	}
 
 	return roleArn, nil
 }
 
-// createRoleAutomated creates a new IAM role with all required policies
-func createRoleAutomated(ctx context.Context, iamService *aws.IAMService, config *config.LambdaIAM, roleName, functionName string) (string, error) {
+// createRoleAutomated creates a new IAM role with all required policies.
+// The role is recorded in the journal as soon as it exists, so it can be
+// cleaned up even when attaching a policy fails.
+func createRoleAutomated(ctx context.Context, iamService *aws.IAMService, config *config.LambdaIAM, roleName, functionName string, journal *executor.Journal) (string, error) {
 	// Create role
 	roleConfig := &aws.RoleConfig{
 		Name:        roleName,
@@ -1725,6 +2071,11 @@ func createRoleAutomated(ctx context.Context, iamService *aws.IAMService, config
 	}
 
 	fmt.Printf("  ✓ Created role: %s\n", roleName)
+	if journal != nil {
+		journal.Record("iam-role", role.ARN, roleName, func(ctx context.Context) error {
+			return cleanupIAMRole(ctx, iamService, roleName)
+		})
+	}
 
 	// Attach required policies
 	policyARNs := aws.ConvertRequirementsToARNs(config.RequiredPolicies)
@@ -1778,16 +2129,15 @@ func handleExistingRole(ctx context.Context, iamService *aws.IAMService, role *a
 	return role.ARN, nil
 }
 
-// updateConfigWithRoleArn updates the TOML config file with the created role ARN
-func updateConfigWithRoleArn(configPath string, iamConfig *config.LambdaIAM, roleArn string) error {
-	// Read existing TOML
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return err
+// updateConfigWithRoleArn updates the TOML config file with the created role ARN.
+// Only files holding the function alone are rewritten.
+func updateConfigWithRoleArn(doc *config.Document, iamConfig *config.LambdaIAM, roleArn string) error {
+	if doc.Format != config.FormatTOML || doc.Count > 1 {
+		return fmt.Errorf("%s is not a single-document TOML file; set iam.role_arn to %s by hand", doc.Name(), roleArn)
 	}
 
 	var lambdaConfig config.LambdaFunctionConfig
-	if err := toml.Unmarshal(data, &lambdaConfig); err != nil {
+	if err := doc.Decode(&lambdaConfig); err != nil {
 		return err
 	}
 
@@ -1805,7 +2155,7 @@ func updateConfigWithRoleArn(configPath string, iamConfig *config.LambdaIAM, rol
 		return err
 	}
 
-	return os.WriteFile(configPath, buf.Bytes(), 0644)
+	return os.WriteFile(doc.Path, buf.Bytes(), 0644)
 }
 
 // extractRoleName extracts the role name from its ARN
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/executor"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+func TestExecuteDeletionConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	defer func(file string, workers int) { configFile, parallelism = file, workers }(configFile, parallelism)
+	parallelism = executor.DefaultParallelism
+
+	configPath := filepath.Join(dir, "genesys.yaml")
+	content := `provider: mock
+region: us-east-1
+resources:
+  storage:
+    - name: deletion-test-bucket
+      type: bucket
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFile = configPath
+
+	records := func() []state.ResourceRecord {
+		t.Helper()
+		st, err := state.LoadProjectState(configPath)
+		if err != nil {
+			t.Fatalf("LoadProjectState() error = %v", err)
+		}
+		return st.FindResourcesByConfigFile(configPath)
+	}
+
+	// Deleting before anything exists must not create the bucket
+	if err := executeDeletion(context.Background(), configPath); err != nil {
+		t.Fatalf("executeDeletion() error = %v", err)
+	}
+	if got := records(); len(got) != 0 {
+		t.Fatalf("deletion without resources recorded %d resources, want none", len(got))
+	}
+
+	if err := executeConfigFile(context.Background(), configPath); err != nil {
+		t.Fatalf("executeConfigFile() error = %v", err)
+	}
+	if got := records(); len(got) != 1 || got[0].Name != "deletion-test-bucket" {
+		t.Fatalf("records after execute = %+v, want the bucket", got)
+	}
+
+	if err := executeDeletion(context.Background(), configPath); err != nil {
+		t.Fatalf("executeDeletion() error = %v", err)
+	}
+	if got := records(); len(got) != 0 {
+		t.Errorf("records after deletion = %+v, want none", got)
+	}
+}
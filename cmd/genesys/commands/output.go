@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/executor"
+	"github.com/javanhut/genesys/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outputConfigPath string
+	outputsFormat    string
+	outputRefresh    bool
+)
+
+// NewOutputCommand creates the output command
+func NewOutputCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "output [name]",
+		Short: "Show the outputs of deployed resources",
+		Long: `Show the outputs recorded in state for deployed resources, such as a bucket
+ARN, an instance's public IP, a database endpoint and port or a function URL.
+
+Without a name, every output is shown. A resource name shows the outputs of
+that resource, and <resource>.<output> prints a single value on its own, so
+scripts can use it directly. Resources sharing a name with a resource of
+another type are named <type>/<name>.
+
+Some values are only known once a resource has finished starting, such as
+the public IP of an instance or the endpoint of a database. Use --refresh to
+read the current values from the provider and record them in state.
+
+Examples:
+  genesys output                        # Every output
+  genesys output web-server             # The outputs of one resource
+  genesys output web-server.public_ip   # One value
+  genesys output -o json                # Every output as JSON
+  genesys output app-db --refresh       # Record the endpoint once it is available`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runOutput,
+	}
+
+	cmd.Flags().StringVar(&outputConfigPath, "config", ".", "Configuration file or project directory whose state to use")
+	cmd.Flags().StringVarP(&outputsFormat, "output", "o", "human", "Output format (human|json)")
+	cmd.Flags().BoolVar(&outputRefresh, "refresh", false, "Read the current outputs from the provider and record them in state")
+
+	return cmd
+}
+
+func runOutput(cmd *cobra.Command, args []string) error {
+	localState, err := loadProjectState(outputConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	if outputRefresh {
+		if err := refreshOutputs(localState); err != nil {
+			return err
+		}
+	}
+
+	// Lookup errors below list what exists instead of the usage
+	cmd.SilenceUsage = true
+
+	outputs := localState.Outputs()
+	if len(args) == 0 {
+		if outputsFormat == "json" {
+			return printJSON(outputs)
+		}
+		if len(outputs) == 0 {
+			fmt.Printf("No outputs recorded in state %s\n", localState.Location())
+			return nil
+		}
+		for _, resource := range sortedKeys(outputs) {
+			for _, name := range sortedKeys(outputs[resource]) {
+				fmt.Printf("%s.%s = %s\n", resource, name, outputs[resource][name])
+			}
+		}
+		return nil
+	}
+
+	name := args[0]
+	if values, ok := outputs[name]; ok {
+		if outputsFormat == "json" {
+			return printJSON(values)
+		}
+		for _, output := range sortedKeys(values) {
+			fmt.Printf("%s = %s\n", output, values[output])
+		}
+		return nil
+	}
+
+	if dot := strings.LastIndex(name, "."); dot > 0 {
+		resource, output := name[:dot], name[dot+1:]
+		if values, ok := outputs[resource]; ok {
+			value, ok := values[output]
+			if !ok {
+				return fmt.Errorf("resource %s has no output %s (outputs: %s)", resource, output, strings.Join(sortedKeys(values), ", "))
+			}
+			if outputsFormat == "json" {
+				return printJSON(value)
+			}
+			fmt.Println(value)
+			return nil
+		}
+	}
+
+	if len(outputs) == 0 {
+		return fmt.Errorf("no outputs recorded in state %s", localState.Location())
+	}
+	return fmt.Errorf("no resource named %s has outputs (resources: %s)", name, strings.Join(sortedKeys(outputs), ", "))
+}
+
+// refreshOutputs reads the current outputs of the recorded resources from
+// their providers and records the ones that changed
+func refreshOutputs(localState *state.LocalState) error {
+	ctx := context.Background()
+
+	records := append([]state.ResourceRecord(nil), localState.Resources...)
+	for _, record := range records {
+		providerName := record.Provider
+		if providerName == "" {
+			providerName = "aws"
+		}
+		p, err := getProvider(providerName, record.Region, nil)
+		if err != nil {
+			return err
+		}
+
+		outputs, err := executor.RefreshOutputs(ctx, p, record)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to refresh the outputs of %s: %v\n", record.Name, err)
+			continue
+		}
+		if outputs == nil || maps.Equal(outputs, record.Outputs) {
+			continue
+		}
+
+		record.Outputs = outputs
+		if err := localState.ReplaceResource(record.ID, record); err != nil {
+			return fmt.Errorf("failed to record the outputs of %s: %w", record.Name, err)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	planOutFile string
+	planFormat  string
+)
+
+// NewPlanCommand creates the plan command
+func NewPlanCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan <config-file>",
+		Short: "Show and save the changes a configuration would make",
+		Long: `Generate the plan for the resources declared in a configuration file.
+
+With --out the plan is saved to a file that 'genesys apply' executes later
+exactly as reviewed. The file records a hash of the configuration and the
+state serial, and apply refuses to run if either has changed.
+
+Examples:
+  genesys plan genesys.yaml                    # Show the plan
+  genesys plan genesys.yaml --out plan.json    # Save the plan for review
+  genesys apply plan.json                      # Apply the saved plan`,
+		Args: cobra.ExactArgs(1),
+		RunE: runPlan,
+	}
+
+	cmd.Flags().StringVar(&planOutFile, "out", "", "Write the plan to this file for a later 'genesys apply'")
+	cmd.Flags().StringVarP(&planFormat, "output", "o", "human", "Output format (human|json)")
+	cmd.Flags().BoolVar(&allowReplace, "allow-replace", false, "Allow replacing stateful resources such as databases, deleting their data")
+
+	return cmd
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	configPath := args[0]
+
+	tags, err := defaultTags(configPath)
+	if err != nil {
+		return err
+	}
+
+	files, err := config.InputFiles(configPath)
+	if err != nil {
+		return err
+	}
+	data, err := readConfigFiles(files)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	config.ApplyDefaultTags(cfg, tags)
+	config.ApplyDefaults(cfg)
+	if err := config.ValidateConfig(cfg); err != nil {
+		return fmt.Errorf("config validation failed: %w", err)
+	}
+
+	plan, err := planner.NewConfigPlan(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to generate plan: %w", err)
+	}
+
+	p, err := getProvider(cfg.Provider, cfg.Region, tags)
+	if err != nil {
+		return err
+	}
+
+	localState, err := loadProjectState(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load local state: %w", err)
+	}
+
+	if err := planner.New(p).Diff(ctx, plan, localState, configPath); err != nil {
+		return fmt.Errorf("failed to compare plan with existing resources: %w", err)
+	}
+
+	if planFormat == "json" {
+		fmt.Println(plan.ToJSON())
+	} else {
+		fmt.Println(plan.ToHumanReadable())
+	}
+
+	// Denied plans, and plans with steps the executor cannot perform, are
+	// not saved, so they cannot be applied later
+	if err := checkPolicies(plan, cfg.Policies, configPath, planFormat == "json"); err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+	if err := newPlanExecutor(p).Check(plan); err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+
+	if planOutFile == "" {
+		return nil
+	}
+
+	planFile := planner.NewPlanFile(plan, configPath, data, localState.Serial)
+	planFile.Inputs = files[1:]
+	planFile.Provider = cfg.Provider
+	planFile.Region = cfg.Region
+	planFile.Workspace = localState.Workspace()
+	planFile.Policies = cfg.Policies
+
+	if err := planFile.Save(planOutFile); err != nil {
+		return err
+	}
+
+	if planFormat != "json" {
+		fmt.Printf("\nPlan saved to %s. Apply it with: genesys apply %s\n", planOutFile, planOutFile)
+	}
+
+	return nil
+}
+
+// readConfigFiles reads a configuration and its overlays for the hash stored
+// in plan files
+func readConfigFiles(files []string) ([]byte, error) {
+	var data []byte
+	for _, file := range files {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configuration file: %w", err)
+		}
+		data = append(data, contents...)
+	}
+	return data, nil
+}
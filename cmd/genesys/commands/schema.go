@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var schemaOutDir string
+
+// NewSchemaCommand creates the schema command
+func NewSchemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Export the JSON Schema of configuration files",
+		Long: `Export JSON Schema describing Genesys configuration files, so editors can
+autocomplete and validate them.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newSchemaExportCommand())
+
+	return cmd
+}
+
+// newSchemaExportCommand creates the schema export subcommand
+func newSchemaExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [kind]",
+		Short: "Print or write the JSON Schema of configuration kinds",
+		Long: fmt.Sprintf(`Print the JSON Schema of a configuration kind, or without a kind a schema
+for documents of every kind that applies the schema named by their kind header.
+
+Kinds: %s
+
+With --out, genesys.schema.json and a <kind>.schema.json file per kind are
+written to a directory instead.
+
+Fields a schema does not declare are rejected, as they are when Genesys reads
+a file. Config documents may set numbers and booleans with ${var.name}.
+
+To use the schema with the YAML language server, add a modeline to a file:
+
+  # yaml-language-server: $schema=./schemas/genesys.schema.json
+
+Examples:
+  genesys schema export > genesys.schema.json
+  genesys schema export S3Bucket
+  genesys schema export --out schemas`, strings.Join(config.KindNames(), ", ")),
+		Args: cobra.MaximumNArgs(1),
+		RunE: runSchemaExport,
+	}
+
+	cmd.Flags().StringVar(&schemaOutDir, "out", "", "Write the schemas to this directory")
+
+	return cmd
+}
+
+func runSchemaExport(cmd *cobra.Command, args []string) error {
+	if schemaOutDir == "" {
+		schema := config.CombinedJSONSchema()
+		if len(args) == 1 {
+			var err error
+			if schema, err = config.JSONSchema(args[0]); err != nil {
+				return err
+			}
+		}
+		data, err := marshalSchema(schema)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.MkdirAll(schemaOutDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", schemaOutDir, err)
+	}
+
+	schemas := map[string]map[string]interface{}{"genesys": config.CombinedJSONSchema()}
+	kinds := config.KindNames()
+	if len(args) == 1 {
+		schemas = make(map[string]map[string]interface{})
+		kinds = args
+	}
+	for _, kind := range kinds {
+		schema, err := config.JSONSchema(kind)
+		if err != nil {
+			return err
+		}
+		schemas[kind] = schema
+	}
+
+	for _, name := range sortedKeys(schemas) {
+		data, err := marshalSchema(schemas[name])
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(schemaOutDir, name+".schema.json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return nil
+}
+
+func marshalSchema(schema map[string]interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
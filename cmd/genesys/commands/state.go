@@ -0,0 +1,557 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stateConfigPath string
+	stateFormat     string
+	stateSerial     int64
+
+	stateListType     string
+	stateListProvider string
+	stateListRegion   string
+	stateListTags     []string
+
+	stateImportName     string
+	stateImportProvider string
+	stateImportRegion   string
+)
+
+// NewStateCommand creates the state command
+func NewStateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Inspect and manage state",
+		Long: `Inspect and manage the state of the selected workspace.
+
+The state is read from the backend selected by the configuration given with
+--config, or from the local state of the project containing the current
+directory.
+
+Examples:
+  genesys state list --type s3            # List the recorded buckets
+  genesys state show i-0abc123            # Show one record
+  genesys state rm my-bucket              # Forget a resource without deleting it
+  genesys state mv my-bucket storage.yaml # Associate a record with another configuration
+  genesys state import ec2 i-0abc123 --config web.yaml
+  genesys state show                      # Show the records in state
+  genesys state history                   # List the versions kept of the state
+  genesys state show --serial 12          # Show the state as written with serial 12
+  genesys state rollback 12               # Restore the records of serial 12
+  genesys state rotate-key                # Re-encrypt the state with the current key
+  genesys state force-unlock 3f2a9c0d1b7e4a65 --config genesys.yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&stateConfigPath, "config", ".", "Configuration file or project directory whose state to use")
+
+	cmd.AddCommand(newStateListCommand())
+	cmd.AddCommand(newStateShowCommand())
+	cmd.AddCommand(newStateRmCommand())
+	cmd.AddCommand(newStateMvCommand())
+	cmd.AddCommand(newStateImportCommand())
+	cmd.AddCommand(newStateHistoryCommand())
+	cmd.AddCommand(newStateRollbackCommand())
+	cmd.AddCommand(newStateRotateKeyCommand())
+	cmd.AddCommand(newStateForceUnlockCommand())
+
+	return cmd
+}
+
+// newStateListCommand creates the state list subcommand
+func newStateListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the records in state",
+		Long: `List the records in state, optionally filtered by type, provider, region
+or tag. Tags are given as key=value, or as key to match any value, and all
+given tags must match.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			records := filterStateRecords(localState.Resources)
+			if stateFormat == "json" {
+				if records == nil {
+					records = []state.ResourceRecord{}
+				}
+				return printJSON(records)
+			}
+			printStateRecords(records)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&stateListType, "type", "", "Only list records of this type (s3, ec2, lambda, rds, vpc, ...)")
+	cmd.Flags().StringVar(&stateListProvider, "provider", "", "Only list records from this provider")
+	cmd.Flags().StringVar(&stateListRegion, "region", "", "Only list records in this region")
+	cmd.Flags().StringArrayVar(&stateListTags, "tag", nil, "Only list records with this tag (key=value or key; repeatable)")
+	cmd.Flags().StringVarP(&stateFormat, "output", "o", "human", "Output format (human|json)")
+
+	return cmd
+}
+
+// filterStateRecords applies the state list filters
+func filterStateRecords(records []state.ResourceRecord) []state.ResourceRecord {
+	var filtered []state.ResourceRecord
+	for _, record := range records {
+		if stateListType != "" && record.Type != stateListType {
+			continue
+		}
+		if stateListProvider != "" && record.Provider != stateListProvider {
+			continue
+		}
+		if stateListRegion != "" && record.Region != stateListRegion {
+			continue
+		}
+		if !hasTags(record.Tags, stateListTags) {
+			continue
+		}
+		filtered = append(filtered, record)
+	}
+	return filtered
+}
+
+// hasTags reports whether tags contain every key=value (or key) filter
+func hasTags(tags map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, "=")
+		actual, ok := tags[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// newStateShowCommand creates the state show subcommand
+func newStateShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show [id]",
+		Short: "Show a record, or all records in state",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			shown := localState
+			if cmd.Flags().Changed("serial") {
+				if shown, err = localState.AtSerial(stateSerial); err != nil {
+					return err
+				}
+			}
+
+			if len(args) == 1 {
+				record, ok := shown.FindResourceByID(args[0])
+				if !ok {
+					return fmt.Errorf("no resource with ID %s in state %s", args[0], localState.Location())
+				}
+				if stateFormat == "json" {
+					return printJSON(record)
+				}
+				printStateRecord(record)
+				return nil
+			}
+
+			if stateFormat == "json" {
+				return printJSON(shown)
+			}
+
+			fmt.Printf("State:     %s\n", localState.Location())
+			if localState.Workspace() != "" {
+				fmt.Printf("Workspace: %s\n", localState.Workspace())
+			}
+			fmt.Printf("Serial:    %d\n", shown.Serial)
+			fmt.Println()
+			printStateRecords(shown.Resources)
+			return nil
+		},
+	}
+
+	cmd.Flags().Int64Var(&stateSerial, "serial", 0, "Show the state as it was written with this serial")
+	cmd.Flags().StringVarP(&stateFormat, "output", "o", "human", "Output format (human|json)")
+
+	return cmd
+}
+
+// newStateHistoryCommand creates the state history subcommand
+func newStateHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List the versions kept of the state",
+		Long: `List the versions kept of the state, newest first.
+
+Every write to the state increments its serial. The last 20 versions are
+kept: in .genesys/history for local state and as object versions in the
+state bucket for S3.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			history, err := localState.History()
+			if err != nil {
+				return err
+			}
+
+			if stateFormat == "json" {
+				return printJSON(history)
+			}
+
+			if len(history) == 0 {
+				fmt.Printf("No history recorded for state %s\n", localState.Location())
+				return nil
+			}
+
+			fmt.Printf("%-8s %-20s %-10s %s\n", "SERIAL", "WRITTEN", "RESOURCES", "VERSION")
+			for i, snapshot := range history {
+				current := ""
+				if i == 0 {
+					current = " (current)"
+				}
+				if snapshot.Error != "" {
+					fmt.Printf("%-8s %-20s %-10s %s (unreadable: %s)\n", "?", snapshot.Modified.Local().Format("2006-01-02 15:04:05"),
+						"?", snapshot.VersionID, snapshot.Error)
+					continue
+				}
+				fmt.Printf("%-8d %-20s %-10d %s%s\n", snapshot.Serial, snapshot.Modified.Local().Format("2006-01-02 15:04:05"),
+					snapshot.Resources, snapshot.VersionID, current)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&stateFormat, "output", "o", "human", "Output format (human|json)")
+
+	return cmd
+}
+
+// newStateRollbackCommand creates the state rollback subcommand
+func newStateRollbackCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback <serial>",
+		Short: "Restore the records of an earlier version of the state",
+		Long: `Restore the records of an earlier version of the state, for example after a
+bad apply or a record removed by mistake.
+
+Only the state changes; resources are not created or deleted. The restored
+records are written as a new version, so a rollback can itself be rolled
+back and plans saved before it are refused by apply.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serial, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid serial %q: %w", args[0], err)
+			}
+
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			if err := localState.Rollback(serial); err != nil {
+				return err
+			}
+
+			fmt.Printf("Restored the %d record(s) of serial %d as serial %d of state %s\n",
+				len(localState.Resources), serial, localState.Serial, localState.Location())
+			return nil
+		},
+	}
+}
+
+// newStateRotateKeyCommand creates the state rotate-key subcommand
+func newStateRotateKeyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Re-encrypt the state with the current state key",
+		Long: `Re-encrypt the state with the current state key.
+
+State is encrypted with the key from state.key_file or state.kms_key_id in
+the configuration, GENESYS_STATE_KEY_FILE or GENESYS_STATE_PASSPHRASE. To
+rotate, configure the new key and pass the old one in
+GENESYS_STATE_PREVIOUS_KEY_FILE or GENESYS_STATE_PREVIOUS_PASSPHRASE, then
+run this command. Unencrypted state is encrypted the same way.
+
+Versions kept in the state history stay encrypted with the key they were
+written with; keep the old key while they are needed.
+
+Examples:
+  GENESYS_STATE_PREVIOUS_PASSPHRASE="$OLD" GENESYS_STATE_PASSPHRASE="$NEW" genesys state rotate-key`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			if err := localState.RotateKey(); err != nil {
+				return err
+			}
+
+			fmt.Printf("Re-encrypted state %s as serial %d\n", localState.Location(), localState.Serial)
+			return nil
+		},
+	}
+}
+
+// newStateRmCommand creates the state rm subcommand
+func newStateRmCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm <id>",
+		Short: "Forget a resource without deleting it",
+		Long: `Remove a record from state. The resource itself is left in place and is no
+longer managed by Genesys; drift --unmanaged reports it as unmanaged.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			record, ok := localState.FindResourceByID(args[0])
+			if !ok {
+				return fmt.Errorf("no resource with ID %s in state %s", args[0], localState.Location())
+			}
+			if err := localState.RemoveResource(record.ID); err != nil {
+				return fmt.Errorf("failed to update state: %w", err)
+			}
+
+			fmt.Printf("Removed %s %s (%s) from state; the resource was not deleted\n", record.Type, record.Name, record.ID)
+			return nil
+		},
+	}
+}
+
+// newStateMvCommand creates the state mv subcommand
+func newStateMvCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "mv <id> <config-file>",
+		Short: "Associate a record with another configuration file",
+		Long: `Associate a record with another configuration file, for example after
+moving a resource's declaration between files. Plans for the new file then
+manage the resource, and plans for the old file no longer delete it.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Stat(args[1]); err != nil {
+				return fmt.Errorf("configuration file %s: %w", args[1], err)
+			}
+
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			record, ok := localState.FindResourceByID(args[0])
+			if !ok {
+				return fmt.Errorf("no resource with ID %s in state %s", args[0], localState.Location())
+			}
+
+			previous := record.ConfigFile
+			record.ConfigFile = args[1]
+			if err := localState.ReplaceResource(record.ID, record); err != nil {
+				return fmt.Errorf("failed to update state: %w", err)
+			}
+
+			fmt.Printf("Moved %s %s (%s) from %s to %s\n", record.Type, record.Name, record.ID, valueOrNone(previous), args[1])
+			return nil
+		},
+	}
+}
+
+// newStateImportCommand creates the state import subcommand
+func newStateImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <type> <id> --config <file>",
+		Short: "Bring an existing resource under management",
+		Long: `Record an existing resource in state so that Genesys manages it as part of
+a configuration file. The resource is looked up with the provider; nothing
+is changed. Supported types: ` + strings.Join(state.ImportTypes, ", ") + `.
+
+The provider and region come from the configuration file when it is a
+multi-resource configuration, and otherwise from --provider and --region.
+Use --name when the resource is declared under a different name than it
+has in the provider.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			if !cmd.Flags().Changed("config") {
+				return fmt.Errorf("--config is required: it names the configuration file that declares the resource")
+			}
+
+			providerName, region := stateImportProvider, stateImportRegion
+			if cfg, _, err := stateConfig(stateConfigPath); err != nil {
+				return err
+			} else if cfg != nil {
+				if !cmd.Flags().Changed("provider") {
+					providerName = cfg.Provider
+				}
+				if !cmd.Flags().Changed("region") {
+					region = cfg.Region
+				}
+			}
+
+			p, err := getProvider(providerName, region, nil)
+			if err != nil {
+				return err
+			}
+
+			record, err := state.Adopt(ctx, p, args[0], args[1])
+			if err != nil {
+				return err
+			}
+			record.ConfigFile = stateConfigPath
+			if stateImportName != "" {
+				record.Name = stateImportName
+			}
+
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+			if err := localState.ImportResource(record); err != nil {
+				return err
+			}
+
+			fmt.Printf("Imported %s %s (%s) into state %s\n", record.Type, record.Name, record.ID, localState.Location())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&stateImportName, "name", "", "Name to record the resource under (default: its name in the provider)")
+	cmd.Flags().StringVar(&stateImportProvider, "provider", "aws", "Provider to look the resource up with")
+	cmd.Flags().StringVar(&stateImportRegion, "region", "", "Region to look the resource up in")
+
+	return cmd
+}
+
+// printStateRecord prints every field of a resource record
+func printStateRecord(record state.ResourceRecord) {
+	fmt.Printf("ID:       %s\n", record.ID)
+	fmt.Printf("Name:     %s\n", record.Name)
+	fmt.Printf("Type:     %s\n", record.Type)
+	fmt.Printf("Provider: %s\n", record.Provider)
+	fmt.Printf("Region:   %s\n", record.Region)
+	fmt.Printf("Config:   %s\n", valueOrNone(record.ConfigFile))
+	fmt.Printf("Created:  %s\n", record.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	printStringMap("Tags", record.Tags)
+	printStringMap("Attributes", record.Attributes)
+	printStringMap("Outputs", record.Outputs)
+}
+
+// printStringMap prints a titled map with sorted keys, if it has entries
+func printStringMap(title string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("%s:\n", title)
+	for _, key := range keys {
+		fmt.Printf("  %s = %s\n", key, values[key])
+	}
+}
+
+// valueOrNone returns value, or "(none)" when it is empty
+func valueOrNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// printStateRecords prints one line per resource record
+func printStateRecords(records []state.ResourceRecord) {
+	if len(records) == 0 {
+		fmt.Println("No resources recorded in state.")
+		return
+	}
+
+	fmt.Printf("%-16s %-30s %-30s %-12s %s\n", "TYPE", "NAME", "ID", "REGION", "CONFIG")
+	for _, record := range records {
+		fmt.Printf("%-16s %-30s %-30s %-12s %s\n", record.Type, record.Name, record.ID, record.Region, record.ConfigFile)
+	}
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// newStateForceUnlockCommand creates the state force-unlock subcommand
+func newStateForceUnlockCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "force-unlock <lock-id>",
+		Short: "Release a state lock left behind by a run that no longer exists",
+		Long: `Release a state lock left behind by a run that crashed or was killed.
+
+The lock ID is shown in the error reported while the state is locked. The
+lock is only released when it is still held under that ID. Make sure no one
+is using the state: releasing the lock of a run that is still going lets
+other runs overwrite its changes.
+
+Local locks are released by the operating system when their process exits
+and cannot be forced.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localState, err := loadProjectState(stateConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			holder, err := localState.LockHolder()
+			if err != nil {
+				return err
+			}
+			if holder == nil {
+				return fmt.Errorf("state %s is not locked", localState.Location())
+			}
+
+			if err := localState.ForceUnlock(args[0]); err != nil {
+				return err
+			}
+
+			fmt.Printf("Released lock %s on state %s\n", args[0], localState.Location())
+			fmt.Printf("  Held by: %s (process %d)\n", holder.Who, holder.PID)
+			if holder.Operation != "" {
+				fmt.Printf("  Running: %s\n", holder.Operation)
+			}
+			fmt.Printf("  Since:   %s\n", holder.Acquired.Format("2006-01-02 15:04:05"))
+			if reason := state.StaleReason(holder); reason != "" {
+				fmt.Printf("  Stale:   %s\n", reason)
+			}
+			return nil
+		},
+	}
+}
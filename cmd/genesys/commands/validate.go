@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var validateFormat string
+
+// NewValidateCommand creates the validate command
+func NewValidateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate <files...>",
+		Short: "Check configuration files without contacting a provider",
+		Long: `Check configuration files without contacting a provider or reading state, so
+mistakes are caught before plan or execute.
+
+Every problem found is reported, each under one of these rules:
+  schema     Files parse, have known kinds, declare only known fields and
+             resolve their variables and modules
+  resource   Resource settings are within the values Genesys and providers accept
+  naming     Resource names follow the AWS naming rules
+  policy     Resources comply with the policies of their configuration
+  cidr       Network and subnet CIDR blocks are valid, nested and do not overlap
+  runtime    Lambda runtimes are supported and handlers and source paths are
+             well formed
+
+Config documents are checked with defaults applied, merged with the overlay
+of --env and with --var and --var-file values filled in, as plan reads them.
+Pass the configuration files themselves: overlays and modules are checked
+through the files that use them.
+
+Diagnostics are printed as file:line: severity: message [rule], or with
+--output json as a list, or with --output sarif as a SARIF 2.1.0 log for code
+scanning tools. The command fails when any diagnostic is an error; warnings
+alone do not fail it.
+
+Examples:
+  genesys validate app.yaml
+  genesys validate configs/*.yaml --env prod
+  genesys validate app.yaml -o json
+  genesys validate app.yaml -o sarif > genesys.sarif`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runValidate,
+	}
+
+	cmd.Flags().StringVarP(&validateFormat, "output", "o", "human", "Output format (human|json|sarif)")
+
+	return cmd
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	if validateFormat != "human" && validateFormat != "json" && validateFormat != "sarif" {
+		return fmt.Errorf("unknown output format %q (formats: human, json, sarif)", validateFormat)
+	}
+
+	diagnostics := []config.Diagnostic{}
+	for _, path := range args {
+		diagnostics = append(diagnostics, config.ValidateFile(path)...)
+	}
+
+	switch validateFormat {
+	case "json":
+		if err := printJSON(diagnostics); err != nil {
+			return err
+		}
+	case "sarif":
+		if err := printJSON(sarifLog(diagnostics)); err != nil {
+			return err
+		}
+	default:
+		for _, d := range diagnostics {
+			fmt.Println(d)
+		}
+	}
+
+	errors, warnings := 0, 0
+	for _, d := range diagnostics {
+		if d.Severity == config.SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	if validateFormat == "human" {
+		fmt.Printf("%d file(s) checked: %d error(s), %d warning(s)\n", len(args), errors, warnings)
+	}
+	if errors > 0 {
+		// The diagnostics already explain what failed
+		cmd.SilenceUsage = true
+		return fmt.Errorf("validation failed with %d error(s)", errors)
+	}
+	return nil
+}
+
+// sarifLog formats diagnostics as a SARIF 2.1.0 log
+func sarifLog(diagnostics []config.Diagnostic) map[string]interface{} {
+	rules := make([]string, 0, len(config.DiagnosticRules))
+	for rule := range config.DiagnosticRules {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	ruleIndex := make(map[string]int)
+	descriptors := make([]interface{}, len(rules))
+	for i, rule := range rules {
+		ruleIndex[rule] = i
+		descriptors[i] = map[string]interface{}{
+			"id":               rule,
+			"shortDescription": map[string]interface{}{"text": config.DiagnosticRules[rule]},
+		}
+	}
+
+	results := make([]interface{}, len(diagnostics))
+	for i, d := range diagnostics {
+		physical := map[string]interface{}{
+			"artifactLocation": map[string]interface{}{"uri": filepath.ToSlash(d.File)},
+		}
+		if d.Line > 0 {
+			physical["region"] = map[string]interface{}{"startLine": d.Line}
+		}
+		location := map[string]interface{}{"physicalLocation": physical}
+		if d.Field != "" {
+			location["logicalLocations"] = []interface{}{
+				map[string]interface{}{"fullyQualifiedName": d.Field},
+			}
+		}
+		results[i] = map[string]interface{}{
+			"ruleId":    d.Rule,
+			"ruleIndex": ruleIndex[d.Rule],
+			"level":     d.Severity,
+			"message":   map[string]interface{}{"text": d.Message},
+			"locations": []interface{}{location},
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":           "genesys",
+						"informationUri": "https://github.com/javanhut/genesys",
+						"rules":          descriptors,
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+}
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/javanhut/genesys/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	workspaceDir   string
+	workspaceForce bool
+)
+
+// NewWorkspaceCommand creates the workspace command
+func NewWorkspaceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Manage state workspaces",
+		Long: `Manage the workspaces of a project.
+
+State is kept per project in .genesys/state.json, found by walking up from
+the configuration file (or the current directory). Workspaces keep separate
+state for deployments of the same configuration, such as dev, staging and
+prod. The GENESYS_WORKSPACE environment variable overrides the selected
+workspace.
+
+Examples:
+  genesys workspace new staging     # Create and select a workspace
+  genesys workspace list            # List workspaces, marking the selected one
+  genesys workspace select default  # Switch back to the default workspace
+  genesys workspace delete staging  # Delete an empty workspace`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&workspaceDir, "dir", ".", "Project directory or configuration file")
+
+	cmd.AddCommand(newWorkspaceNewCommand())
+	cmd.AddCommand(newWorkspaceSelectCommand())
+	cmd.AddCommand(newWorkspaceListCommand())
+	cmd.AddCommand(newWorkspaceShowCommand())
+	cmd.AddCommand(newWorkspaceDeleteCommand())
+
+	return cmd
+}
+
+// newWorkspaceNewCommand creates the workspace new subcommand
+func newWorkspaceNewCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "new <name>",
+		Short: "Create a workspace and select it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projectDir, err := state.FindProjectDir(workspaceDir)
+			if err != nil {
+				return err
+			}
+
+			if err := state.NewWorkspace(projectDir, args[0]); err != nil {
+				return err
+			}
+			if err := state.SelectWorkspace(projectDir, args[0]); err != nil {
+				return err
+			}
+
+			fmt.Printf("Created and selected workspace %s in %s\n", args[0], projectDir)
+			return nil
+		},
+	}
+}
+
+// newWorkspaceSelectCommand creates the workspace select subcommand
+func newWorkspaceSelectCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "select <name>",
+		Short: "Select the workspace used by later commands",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projectDir, err := state.FindProjectDir(workspaceDir)
+			if err != nil {
+				return err
+			}
+
+			if err := state.SelectWorkspace(projectDir, args[0]); err != nil {
+				return err
+			}
+
+			fmt.Printf("Selected workspace %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+// newWorkspaceListCommand creates the workspace list subcommand
+func newWorkspaceListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the workspaces of the project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projectDir, err := state.FindProjectDir(workspaceDir)
+			if err != nil {
+				return err
+			}
+
+			workspaces, err := state.ListWorkspaces(projectDir)
+			if err != nil {
+				return err
+			}
+
+			current, err := state.CurrentWorkspace(projectDir)
+			if err != nil {
+				return err
+			}
+			for _, workspace := range workspaces {
+				marker := " "
+				if workspace == current {
+					marker = "*"
+				}
+				fmt.Printf("%s %s\n", marker, workspace)
+			}
+			return nil
+		},
+	}
+}
+
+// newWorkspaceShowCommand creates the workspace show subcommand
+func newWorkspaceShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Show the selected workspace and its state file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projectDir, err := state.FindProjectDir(workspaceDir)
+			if err != nil {
+				return err
+			}
+
+			workspace, err := state.CurrentWorkspace(projectDir)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Project:   %s\n", projectDir)
+			fmt.Printf("Workspace: %s\n", workspace)
+			fmt.Printf("State:     %s\n", state.StatePath(projectDir, workspace))
+			return nil
+		},
+	}
+}
+
+// newWorkspaceDeleteCommand creates the workspace delete subcommand
+func newWorkspaceDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a workspace and its state",
+		Long: `Delete a workspace and its state.
+
+Workspaces that still track resources are only deleted with --force; the
+resources themselves are left in place. The default workspace and the
+selected workspace cannot be deleted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projectDir, err := state.FindProjectDir(workspaceDir)
+			if err != nil {
+				return err
+			}
+
+			if err := state.DeleteWorkspace(projectDir, args[0], workspaceForce); err != nil {
+				return err
+			}
+
+			fmt.Printf("Deleted workspace %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&workspaceForce, "force", false, "Delete the workspace even if it still tracks resources")
+
+	return cmd
+}
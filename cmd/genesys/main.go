@@ -5,6 +5,9 @@ import (
 	"os"
 
 	"github.com/javanhut/genesys/cmd/genesys/commands"
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/policy"
+	"github.com/javanhut/genesys/pkg/state"
 	"github.com/spf13/cobra"
 )
 
@@ -34,13 +37,36 @@ Get started:
   3. Deploy safely:       genesys execute config.yaml --dry-run
   4. Deploy for real:     genesys execute config.yaml`,
 		Version: fmt.Sprintf("%s (%s)", version, commit),
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// Recorded in state locks so others can see what holds them
+			state.Operation = cmd.CommandPath()
+		},
 	}
 
+	rootCmd.PersistentFlags().DurationVar(&state.LockTimeout, "lock-timeout", state.DefaultLockTimeout,
+		"How long to wait for another genesys process to release the state lock")
+	rootCmd.PersistentFlags().StringVar(&config.Options.Environment, "env", os.Getenv(config.EnvironmentEnvVar),
+		"Environment whose overlay (app.<env>.yaml for app.yaml) is merged over configurations")
+	rootCmd.PersistentFlags().StringArrayVar(&config.Options.Vars, "var", nil,
+		"Set a configuration variable (name=value, repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&config.Options.VarFiles, "var-file", nil,
+		"Read configuration variables from a YAML, TOML or JSON file (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&policy.Dir, "policy-dir", "",
+		"Directory of the project's policy files (default: policies/ in the project)")
+
 	// Add commands
 	rootCmd.AddCommand(commands.NewExecuteCommand())
+	rootCmd.AddCommand(commands.NewPlanCommand())
+	rootCmd.AddCommand(commands.NewApplyCommand())
+	rootCmd.AddCommand(commands.NewDriftCommand())
+	rootCmd.AddCommand(commands.NewWorkspaceCommand())
+	rootCmd.AddCommand(commands.NewStateCommand())
+	rootCmd.AddCommand(commands.NewOutputCommand())
 	rootCmd.AddCommand(commands.NewInteractCommand())
 	rootCmd.AddCommand(commands.NewDiscoverCommand())
 	rootCmd.AddCommand(commands.NewConfigCommand())
+	rootCmd.AddCommand(commands.NewSchemaCommand())
+	rootCmd.AddCommand(commands.NewValidateCommand())
 	rootCmd.AddCommand(commands.NewVersionCommand(version, commit))
 
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/lambda"
+	"github.com/javanhut/genesys/pkg/validation"
+)
+
+// checkConfig checks a Config with defaults applied. Diagnostics point at the
+// document declaring a resource, or at the file for resources added by
+// modules and overlays.
+func checkConfig(path string, docs []*Document, config *Config) []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(resourceType, name string, field []string, rule, severity, format string, args ...interface{}) {
+		doc, at := configResource(docs, resourceType, name)
+		if doc != nil {
+			at = append(at, field...)
+		}
+		diagnostics = append(diagnostics, diagnose(path, doc, at, rule, severity, fmt.Sprintf(format, args...)))
+	}
+	field := func(key ...string) []string { return key }
+	aws := config.Provider == "aws"
+
+	if err := validateProvider(config); err != nil {
+		diagnostics = append(diagnostics, diagnose(path, docs[0], field("provider"), RuleResource, SeverityError, err.Error()))
+	}
+	if err := validatePolicies(config); err != nil {
+		diagnostics = append(diagnostics, diagnose(path, docs[0], field("policies", "max_cost_per_month"), RulePolicy, SeverityError, err.Error()))
+	}
+	requireTags := func(resourceType, name string, tags map[string]string) {
+		if missing := missingTags(config.Policies.RequireTags, tags); len(missing) > 0 {
+			report(resourceType, name, field("tags"), RulePolicy, SeverityError,
+				"%s resource '%s' is missing required tags: %s", resourceType, name, strings.Join(missing, ", "))
+		}
+	}
+
+	for i, compute := range config.Resources.Compute {
+		if err := validateComputeResource(&compute, i); err != nil {
+			report("compute", compute.Name, nil, RuleResource, SeverityError, "%v", err)
+		}
+		if aws {
+			if err := validation.IsValidName("ec2", compute.Name); err != nil {
+				report("compute", compute.Name, field("name"), RuleNaming, SeverityError, "compute resource '%s': %v", compute.Name, err)
+			}
+		}
+		requireTags("compute", compute.Name, compute.Tags)
+	}
+
+	for i, storage := range config.Resources.Storage {
+		if err := validateStorageResource(&storage, i); err != nil {
+			report("storage", storage.Name, nil, RuleResource, SeverityError, "%v", err)
+		}
+		if aws && storage.Type == "bucket" {
+			if err := validation.IsValidName("s3", storage.Name); err != nil {
+				report("storage", storage.Name, field("name"), RuleNaming, SeverityError, "storage resource '%s': %v", storage.Name, err)
+			}
+		}
+		if config.Policies.NoPublicBuckets && storage.PublicAccess {
+			report("storage", storage.Name, field("public_access"), RulePolicy, SeverityError,
+				"storage resource '%s' is public but no_public_buckets is set", storage.Name)
+		}
+		requireTags("storage", storage.Name, storage.Tags)
+	}
+
+	for i, database := range config.Resources.Database {
+		if err := validateDatabaseResource(&database, i); err != nil {
+			report("database", database.Name, nil, RuleResource, SeverityError, "%v", err)
+		}
+		requireTags("database", database.Name, database.Tags)
+	}
+
+	for i, serverless := range config.Resources.Serverless {
+		if err := validateServerlessResource(&serverless, i); err != nil {
+			report("serverless", serverless.Name, nil, RuleResource, SeverityError, "%v", err)
+		}
+		if aws {
+			if err := validation.IsValidName("lambda", serverless.Name); err != nil {
+				report("serverless", serverless.Name, field("name"), RuleNaming, SeverityError, "serverless resource '%s': %v", serverless.Name, err)
+			}
+		}
+		if err := lambda.ValidateHandler(serverless.Runtime, serverless.Handler); err != nil {
+			report("serverless", serverless.Name, field("handler"), RuleRuntime, SeverityError,
+				"serverless resource '%s': %v", serverless.Name, err)
+		}
+		requireTags("serverless", serverless.Name, serverless.Tags)
+	}
+
+	checkNetworks(config.Resources.Network, func(network string, field []string, severity, msg string) {
+		report("network", network, field, RuleCIDR, severity, "%s", msg)
+	})
+
+	return diagnostics
+}
+
+// configResource finds the document and path declaring a resource, if one of
+// the documents does
+func configResource(docs []*Document, resourceType, name string) (*Document, []string) {
+	for _, doc := range docs {
+		resources, _ := doc.tree["resources"].(map[string]interface{})
+		list, _ := resources[resourceType].([]interface{})
+		for i, item := range list {
+			if entry, ok := item.(map[string]interface{}); ok && entry["name"] == name {
+				return doc, []string{"resources", resourceType, strconv.Itoa(i)}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// checkNetworks reports CIDR blocks that do not parse or that AWS does not
+// accept, subnets outside their network and address ranges that overlap
+func checkNetworks(networks []NetworkResource, report func(network string, field []string, severity, msg string)) {
+	type block struct {
+		name string
+		cidr *net.IPNet
+	}
+
+	var vpcs []block
+	for _, network := range networks {
+		vpc, msg := parseCIDR(network.CIDR)
+		if vpc == nil {
+			report(network.Name, []string{"cidr"}, SeverityError, fmt.Sprintf("network '%s' %s", network.Name, msg))
+			continue
+		}
+		if msg != "" {
+			report(network.Name, []string{"cidr"}, SeverityWarning, fmt.Sprintf("network '%s' %s", network.Name, msg))
+		}
+		for _, other := range vpcs {
+			if cidrsOverlap(vpc, other.cidr) {
+				report(network.Name, []string{"cidr"}, SeverityWarning,
+					fmt.Sprintf("network '%s' (%s) overlaps network '%s' (%s)", network.Name, vpc, other.name, other.cidr))
+			}
+		}
+		vpcs = append(vpcs, block{network.Name, vpc})
+
+		var subnets []block
+		for i, subnet := range network.Subnets {
+			at := []string{"subnets", strconv.Itoa(i), "cidr"}
+			cidr, msg := parseCIDR(subnet.CIDR)
+			if cidr == nil {
+				report(network.Name, at, SeverityError, fmt.Sprintf("subnet '%s' %s", subnet.Name, msg))
+				continue
+			}
+			if msg != "" {
+				report(network.Name, at, SeverityWarning, fmt.Sprintf("subnet '%s' %s", subnet.Name, msg))
+			}
+
+			vpcOnes, _ := vpc.Mask.Size()
+			subnetOnes, _ := cidr.Mask.Size()
+			if subnetOnes < vpcOnes || !vpc.Contains(cidr.IP) {
+				report(network.Name, at, SeverityError,
+					fmt.Sprintf("subnet '%s' (%s) is outside network '%s' (%s)", subnet.Name, cidr, network.Name, vpc))
+			}
+			for _, other := range subnets {
+				if cidrsOverlap(cidr, other.cidr) {
+					report(network.Name, at, SeverityError,
+						fmt.Sprintf("subnet '%s' (%s) overlaps subnet '%s' (%s)", subnet.Name, cidr, other.name, other.cidr))
+				}
+			}
+			subnets = append(subnets, block{subnet.Name, cidr})
+		}
+	}
+}
+
+// parseCIDR parses an IPv4 CIDR block with a /16 to /28 prefix, as AWS
+// requires of VPCs and subnets. It returns nil and why the block is invalid,
+// or the block and a warning when the address has host bits set.
+func parseCIDR(s string) (*net.IPNet, string) {
+	ip, cidr, err := net.ParseCIDR(s)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Sprintf("has invalid CIDR block %q, must be an IPv4 block such as 10.0.0.0/16", s)
+	}
+	if ones, _ := cidr.Mask.Size(); ones < 16 || ones > 28 {
+		return nil, fmt.Sprintf("CIDR block %s must have a prefix between /16 and /28", s)
+	}
+	if !ip.Equal(cidr.IP) {
+		return cidr, fmt.Sprintf("CIDR block %s has host bits set and is read as %s", s, cidr)
+	}
+	return cidr, ""
+}
+
+// cidrsOverlap reports whether two CIDR blocks share addresses
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// missingTags returns the required tags a resource does not set
+func missingTags(required []string, tags map[string]string) []string {
+	var missing []string
+	for _, tag := range required {
+		if _, ok := tags[tag]; !ok {
+			missing = append(missing, tag)
+		}
+	}
+	return missing
+}
+
+// checkS3Bucket checks the names and policies of an S3Bucket document
+func checkS3Bucket(doc *Document) []Diagnostic {
+	var config S3BucketConfig
+	if err := doc.Decode(&config); err != nil {
+		return []Diagnostic{diagnose(doc.Path, nil, nil, RuleSchema, SeverityError, err.Error())}
+	}
+
+	r := &reporter{doc: doc}
+	for i, bucket := range config.Resources.Storage {
+		at := func(key string) []string { return []string{"resources", "storage", strconv.Itoa(i), key} }
+		if err := validation.IsValidName("s3", bucket.Name); err != nil {
+			r.report(at("name"), RuleNaming, SeverityError, "bucket '%s': %v", bucket.Name, err)
+		}
+		if config.Policies.RequireEncryption && !bucket.Encryption {
+			r.report(at("encryption"), RulePolicy, SeverityError, "bucket '%s' is not encrypted but require_encryption is set", bucket.Name)
+		}
+		if config.Policies.NoPublicBuckets && bucket.PublicAccess {
+			r.report(at("public_access"), RulePolicy, SeverityError, "bucket '%s' is public but no_public_buckets is set", bucket.Name)
+		}
+		if missing := missingTags(config.Policies.RequireTags, bucket.Tags); len(missing) > 0 {
+			r.report(at("tags"), RulePolicy, SeverityError, "bucket '%s' is missing required tags: %s", bucket.Name, strings.Join(missing, ", "))
+		}
+	}
+	return r.diagnostics
+}
+
+// checkEC2Instance checks the names and policies of an EC2Instance document
+func checkEC2Instance(doc *Document) []Diagnostic {
+	var config EC2InstanceConfig
+	if err := doc.Decode(&config); err != nil {
+		return []Diagnostic{diagnose(doc.Path, nil, nil, RuleSchema, SeverityError, err.Error())}
+	}
+
+	r := &reporter{doc: doc}
+	for i, instance := range config.Resources.Compute {
+		at := func(key ...string) []string {
+			return append([]string{"resources", "compute", strconv.Itoa(i)}, key...)
+		}
+		if err := validation.IsValidName("ec2", instance.Name); err != nil {
+			r.report(at("name"), RuleNaming, SeverityError, "instance '%s': %v", instance.Name, err)
+		}
+		if config.Policies.RequireEncryption && (instance.Storage == nil || !instance.Storage.Encrypted) {
+			r.report(at("storage", "encrypted"), RulePolicy, SeverityError, "instance '%s' storage is not encrypted but require_encryption is set", instance.Name)
+		}
+		if config.Policies.NoPublicInstances && instance.PublicIP {
+			r.report(at("public_ip"), RulePolicy, SeverityError, "instance '%s' has a public IP but no_public_instances is set", instance.Name)
+		}
+		if missing := missingTags(config.Policies.RequireTags, instance.Tags); len(missing) > 0 {
+			r.report(at("tags"), RulePolicy, SeverityError, "instance '%s' is missing required tags: %s", instance.Name, strings.Join(missing, ", "))
+		}
+	}
+	return r.diagnostics
+}
+
+// checkLambdaFunction checks a LambdaFunction document as
+// ValidateLambdaConfig does, reporting every problem, along with its naming
+// rules and the source file of its handler
+func checkLambdaFunction(doc *Document) []Diagnostic {
+	var config LambdaFunctionConfig
+	if err := doc.Decode(&config); err != nil {
+		return []Diagnostic{diagnose(doc.Path, nil, nil, RuleSchema, SeverityError, err.Error())}
+	}
+
+	r := &reporter{doc: doc}
+	at := func(key ...string) []string { return key }
+	metadata := config.Metadata
+
+	if err := validation.IsValidName("lambda", metadata.Name); err != nil {
+		r.report(at("metadata", "name"), RuleNaming, SeverityError, "function '%s': %v", metadata.Name, err)
+	}
+	if config.IAM != nil && config.IAM.RoleName != "" {
+		if err := validation.IsValidName("iam-role", config.IAM.RoleName); err != nil {
+			r.report(at("iam", "role_name"), RuleNaming, SeverityError, "role '%s': %v", config.IAM.RoleName, err)
+		}
+	}
+
+	_, runtimeErr := lambda.GetRuntimeByName(metadata.Runtime)
+	if runtimeErr != nil {
+		r.report(at("metadata", "runtime"), RuleRuntime, SeverityError, "%v", runtimeErr)
+	}
+	handlerErr := lambda.ValidateHandler(metadata.Runtime, metadata.Handler)
+	if handlerErr != nil {
+		r.report(at("metadata", "handler"), RuleRuntime, SeverityError, "%v", handlerErr)
+	}
+
+	if config.Function.MemoryMB < 128 || config.Function.MemoryMB > 10240 {
+		r.report(at("function", "memory_mb"), RuleResource, SeverityError, "memory must be between 128 and 10240 MB")
+	}
+	if config.Function.TimeoutSeconds < 1 || config.Function.TimeoutSeconds > 900 {
+		r.report(at("function", "timeout_seconds"), RuleResource, SeverityError, "timeout must be between 1 and 900 seconds")
+	}
+
+	source := sourcePath(doc.Path, config.Build.SourcePath)
+	if source == "" {
+		r.report(at("build", "source_path"), RuleRuntime, SeverityError, "source path does not exist: %s", config.Build.SourcePath)
+	} else if runtimeErr == nil && handlerErr == nil {
+		files := lambda.HandlerFiles(metadata.Runtime, metadata.Handler)
+		found := len(files) == 0
+		for _, file := range files {
+			if _, err := os.Stat(filepath.Join(source, file)); err == nil {
+				found = true
+			}
+		}
+		if !found {
+			r.report(at("metadata", "handler"), RuleRuntime, SeverityWarning,
+				"handler %s has no source file in %s (looked for %s)", metadata.Handler, source, strings.Join(files, ", "))
+		}
+	}
+	return r.diagnostics
+}
+
+// sourcePath finds a function's source directory, which execute reads from
+// the working directory, falling back to the directory of the configuration
+// file. It returns "" when neither holds it.
+func sourcePath(configPath, path string) string {
+	if path == "" {
+		return ""
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	if !filepath.IsAbs(path) {
+		relative := filepath.Join(filepath.Dir(configPath), path)
+		if _, err := os.Stat(relative); err == nil {
+			return relative
+		}
+	}
+	return ""
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -21,13 +22,16 @@ type ConfigManager struct {
 
 // Config represents the main configuration structure
 type Config struct {
-	Provider  string             `yaml:"provider" toml:"provider"`
-	Region    string             `yaml:"region" toml:"region"`
-	Project   string             `yaml:"project,omitempty" toml:"project,omitempty"` // For GCP
-	Outcomes  map[string]Outcome `yaml:"outcomes,omitempty" toml:"outcomes,omitempty"`
-	Resources Resources          `yaml:"resources,omitempty" toml:"resources,omitempty"`
-	State     StateConfig        `yaml:"state,omitempty" toml:"state,omitempty"`
-	Policies  Policies           `yaml:"policies,omitempty" toml:"policies,omitempty"`
+	TypeMeta  `yaml:",inline"`
+	Provider  string              `yaml:"provider" toml:"provider"`
+	Region    string              `yaml:"region" toml:"region"`
+	Project   string              `yaml:"project,omitempty" toml:"project,omitempty"` // For GCP
+	Outcomes  map[string]Outcome  `yaml:"outcomes,omitempty" toml:"outcomes,omitempty"`
+	Resources Resources           `yaml:"resources,omitempty" toml:"resources,omitempty"`
+	State     StateConfig         `yaml:"state,omitempty" toml:"state,omitempty"`
+	Policies  Policies            `yaml:"policies,omitempty" toml:"policies,omitempty"`
+	Variables map[string]Variable `yaml:"variables,omitempty" toml:"variables,omitempty"`
+	Use       []ModuleUse         `yaml:"use,omitempty" toml:"use,omitempty"`
 }
 
 // Outcome represents a high-level deployment outcome
@@ -65,6 +69,7 @@ type ComputeResource struct {
 	Image          string            `yaml:"image" toml:"image"`
 	Count          int               `yaml:"count,omitempty" toml:"count,omitempty"`
 	Network        string            `yaml:"network,omitempty" toml:"network,omitempty"`
+	Subnet         string            `yaml:"subnet,omitempty" toml:"subnet,omitempty"`
 	SecurityGroups []string          `yaml:"security_groups,omitempty" toml:"security_groups,omitempty"`
 	Tags           map[string]string `yaml:"tags,omitempty" toml:"tags,omitempty"`
 }
@@ -142,59 +147,95 @@ type TriggerConfig struct {
 
 // StateConfig for state management
 type StateConfig struct {
-	Backend   string `yaml:"backend,omitempty" toml:"backend,omitempty"` // s3|gcs|azureblob|local
+	Backend   string `yaml:"backend,omitempty" toml:"backend,omitempty"` // s3|local|memory
 	Bucket    string `yaml:"bucket,omitempty" toml:"bucket,omitempty"`
+	Key       string `yaml:"key,omitempty" toml:"key,omitempty"` // prefix of the project's state keys
 	LockTable string `yaml:"lock_table,omitempty" toml:"lock_table,omitempty"`
 	Encrypt   bool   `yaml:"encrypt,omitempty" toml:"encrypt,omitempty"`
+	KeyFile   string `yaml:"key_file,omitempty" toml:"key_file,omitempty"`     // encrypts state with a local key file
+	KMSKeyID  string `yaml:"kms_key_id,omitempty" toml:"kms_key_id,omitempty"` // encrypts state with a KMS key
 }
 
 // Policies for governance
 type Policies struct {
-	NoPublicBuckets   bool     `yaml:"no_public_buckets,omitempty" toml:"no_public_buckets,omitempty"`
-	RequireEncryption bool     `yaml:"require_encryption,omitempty" toml:"require_encryption,omitempty"`
-	RequireTags       []string `yaml:"require_tags,omitempty" toml:"require_tags,omitempty"`
-	MaxCostPerMonth   float64  `yaml:"max_cost_per_month,omitempty" toml:"max_cost_per_month,omitempty"`
+	NoPublicBuckets   bool     `yaml:"no_public_buckets,omitempty" toml:"no_public_buckets,omitempty" json:"no_public_buckets,omitempty"`
+	RequireEncryption bool     `yaml:"require_encryption,omitempty" toml:"require_encryption,omitempty" json:"require_encryption,omitempty"`
+	RequireTags       []string `yaml:"require_tags,omitempty" toml:"require_tags,omitempty" json:"require_tags,omitempty"`
+	MaxCostPerMonth   float64  `yaml:"max_cost_per_month,omitempty" toml:"max_cost_per_month,omitempty" json:"max_cost_per_month,omitempty"`
 }
 
-// LoadConfig loads configuration from a file
+// LoadConfig loads the Config documents of a file, merging the overlay of the
+// selected environment over it, filling in variables and environment
+// variables as set in Options and adding the resources of the modules it uses
 func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	config, _, err := assembleConfig(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
 
-	var config Config
+	// Apply defaults and validate
+	ApplyDefaults(config)
+	if err := ValidateConfig(config); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
 
-	// Detect format by extension
-	switch filepath.Ext(path) {
-	case ".yaml", ".yml":
-		err = yaml.Unmarshal(data, &config)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse YAML: %w", err)
-		}
-	case ".toml":
-		_, err = toml.Decode(string(data), &config)
+	return config, nil
+}
+
+// InputFiles returns every file LoadConfig reads for a configuration, in the
+// order it reads them: the file itself, the overlay of the selected
+// environment, the var files and the module files it uses
+func InputFiles(path string) ([]string, error) {
+	_, files, err := assembleConfig(path)
+	return files, err
+}
+
+// assembleConfig reads the Config a file declares with its overlay,
+// variables and modules, before defaults are applied. It also returns the
+// files it read.
+func assembleConfig(path string) (*Config, []string, error) {
+	files, err := SourceFiles(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree, err := configTree(files[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, overlay := range files[1:] {
+		doc, err := readDocument(overlay)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse TOML: %w", err)
+			return nil, nil, err
 		}
-	default:
-		// Try to auto-detect format
-		err = yaml.Unmarshal(data, &config)
-		if err != nil {
-			_, err = toml.Decode(string(data), &config)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse config (tried YAML and TOML): %w", err)
-			}
+		if err := strictError(doc.checkFields(reflect.TypeOf(Config{}))); err != nil {
+			return nil, nil, err
 		}
+		tree = mergeTrees(tree, doc.tree).(map[string]interface{})
 	}
 
-	// Apply defaults and validate
-	ApplyDefaults(&config)
-	if err := ValidateConfig(&config); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
+	variables, err := resolveVariables(tree, Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	files = append(files, Options.VarFiles...)
+	if _, err := interpolate(tree, variables, ""); err != nil {
+		return nil, nil, err
+	}
+	if err := expandModules(tree, filepath.Dir(path), "", nil, &files); err != nil {
+		return nil, nil, err
 	}
 
-	return &config, nil
+	// The assembled tree is decoded through YAML, whose field names match TOML's
+	data, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to assemble config: %w", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return &config, files, nil
 }
 
 // SaveConfig saves configuration to a file
@@ -195,6 +195,27 @@ func TestApplyDefaults(t *testing.T) {
 	}
 }
 
+func TestApplyDefaultTags(t *testing.T) {
+	config := &Config{
+		Resources: Resources{
+			Network: []NetworkResource{{Name: "vpc"}},
+			Storage: []StorageResource{{Name: "assets", Tags: map[string]string{"owner": "web"}}},
+		},
+	}
+	ApplyDefaults(config)
+	ApplyDefaultTags(config, map[string]string{"owner": "platform", "cost-center": "1234"})
+
+	network := config.Resources.Network[0]
+	if network.Tags["owner"] != "platform" || network.Tags["cost-center"] != "1234" {
+		t.Errorf("Expected default tags on networks, got %v", network.Tags)
+	}
+
+	storage := config.Resources.Storage[0]
+	if storage.Tags["owner"] != "web" || storage.Tags["cost-center"] != "1234" || storage.Tags["ManagedBy"] != "Genesys" {
+		t.Errorf("Expected resource tags to take precedence over default tags, got %v", storage.Tags)
+	}
+}
+
 func TestSaveConfig_YAML(t *testing.T) {
 	config := &Config{
 		Provider: "aws",
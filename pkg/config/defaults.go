@@ -1,5 +1,7 @@
 package config
 
+import "github.com/javanhut/genesys/pkg/provider"
+
 // ApplyDefaults sets default values for configuration
 func ApplyDefaults(config *Config) {
 	// Provider defaults
@@ -34,6 +36,32 @@ func ApplyDefaults(config *Config) {
 	applyPolicyDefaults(config)
 }
 
+// ApplyDefaultTags adds the default tags of the organization and project
+// policy files to every resource of a configuration. Tags set on a resource
+// take precedence.
+func ApplyDefaultTags(config *Config, tags map[string]string) {
+	for i := range config.Resources.Network {
+		network := &config.Resources.Network[i]
+		network.Tags = provider.WithDefaultTags(tags, network.Tags)
+	}
+	for i := range config.Resources.Compute {
+		compute := &config.Resources.Compute[i]
+		compute.Tags = provider.WithDefaultTags(tags, compute.Tags)
+	}
+	for i := range config.Resources.Storage {
+		storage := &config.Resources.Storage[i]
+		storage.Tags = provider.WithDefaultTags(tags, storage.Tags)
+	}
+	for i := range config.Resources.Database {
+		database := &config.Resources.Database[i]
+		database.Tags = provider.WithDefaultTags(tags, database.Tags)
+	}
+	for i := range config.Resources.Serverless {
+		serverless := &config.Resources.Serverless[i]
+		serverless.Tags = provider.WithDefaultTags(tags, serverless.Tags)
+	}
+}
+
 // applyComputeDefaults applies defaults to compute resources
 func applyComputeDefaults(config *Config) {
 	for i := range config.Resources.Compute {
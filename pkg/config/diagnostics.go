@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Rules that diagnostics are reported under
+const (
+	RuleSchema   = "schema"
+	RuleResource = "resource"
+	RuleNaming   = "naming"
+	RulePolicy   = "policy"
+	RuleCIDR     = "cidr"
+	RuleRuntime  = "runtime"
+)
+
+// DiagnosticRules describes what each rule checks
+var DiagnosticRules = map[string]string{
+	RuleSchema:   "Files parse, have known kinds, declare only known fields and resolve their variables and modules",
+	RuleResource: "Resource settings are within the values Genesys and providers accept",
+	RuleNaming:   "Resource names follow the AWS naming rules",
+	RulePolicy:   "Resources comply with the policies of their configuration",
+	RuleCIDR:     "Network and subnet CIDR blocks are valid, nested and do not overlap",
+	RuleRuntime:  "Lambda runtimes are supported and handlers and source paths are well formed",
+}
+
+// Diagnostic is a problem ValidateFile found in a configuration file
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`  // 0 when the line is not known
+	Field    string `json:"field,omitempty"` // such as resources.storage[0].name
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	location := d.File
+	if d.Line > 0 {
+		location = fmt.Sprintf("%s:%d", d.File, d.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", location, d.Severity, d.Message, d.Rule)
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// ValidateFile runs the checks that need no provider on a configuration file:
+// its schema, the resource rules ValidateConfig applies, AWS naming rules, the
+// policies the file declares, network CIDR blocks and Lambda runtimes and
+// handlers. Every problem found is returned, in file order.
+func ValidateFile(path string) []Diagnostic {
+	docs, err := readKinds(path)
+	if err != nil {
+		d := Diagnostic{
+			File:     path,
+			Rule:     RuleSchema,
+			Severity: SeverityError,
+			Message:  strings.TrimPrefix(err.Error(), path+": "),
+		}
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			d.Line, _ = strconv.Atoi(match[1])
+		}
+		return []Diagnostic{d}
+	}
+
+	var diagnostics []Diagnostic
+	var configDocs []*Document
+	for _, doc := range docs {
+		// A single document without a header is loaded as a Config
+		if doc.Kind == KindConfig || (doc.Count == 1 && doc.Detected) {
+			configDocs = append(configDocs, doc)
+			continue
+		}
+
+		fieldErrors := doc.CheckFields()
+		if len(fieldErrors) > 0 {
+			diagnostics = append(diagnostics, fieldDiagnostics(fieldErrors)...)
+			continue
+		}
+		if kind, ok := LookupKind(doc.Kind); ok && kind.Check != nil {
+			diagnostics = append(diagnostics, kind.Check(doc)...)
+		}
+	}
+	if len(configDocs) > 0 {
+		diagnostics = append(diagnostics, checkConfigDocuments(path, configDocs)...)
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+	return diagnostics
+}
+
+// checkConfigDocuments loads the Config documents of a file as LoadConfig
+// does and checks the resulting configuration
+func checkConfigDocuments(path string, docs []*Document) []Diagnostic {
+	config, _, err := assembleConfig(path)
+	if err != nil {
+		if fieldErrors := FieldErrors(err); len(fieldErrors) > 0 {
+			return fieldDiagnostics(fieldErrors)
+		}
+		return []Diagnostic{{File: path, Rule: RuleSchema, Severity: SeverityError, Message: err.Error()}}
+	}
+	ApplyDefaults(config)
+	return checkConfig(path, docs, config)
+}
+
+// fieldDiagnostics reports unknown fields as schema errors
+func fieldDiagnostics(fieldErrors []*FieldError) []Diagnostic {
+	diagnostics := make([]Diagnostic, len(fieldErrors))
+	for i, fieldErr := range fieldErrors {
+		diagnostics[i] = Diagnostic{
+			File:     fieldErr.File,
+			Line:     fieldErr.Line,
+			Field:    fieldErr.Field,
+			Rule:     RuleSchema,
+			Severity: SeverityError,
+			Message:  fieldErr.message(),
+		}
+	}
+	return diagnostics
+}
+
+// diagnose returns a diagnostic about the key at path in a document. Without
+// a document the diagnostic is about the file as a whole.
+func diagnose(file string, doc *Document, path []string, rule, severity, msg string) Diagnostic {
+	d := Diagnostic{File: file, Rule: rule, Severity: severity, Message: msg}
+	if doc == nil {
+		return d
+	}
+
+	d.File = doc.Path
+	d.Field = fieldPath(path)
+
+	// Keys the document leaves out have no line, so point at the closest key
+	// it has, and at the name of list entries, whose line TOML has no key for
+	at := path
+	for len(at) > 1 && !hasPath(doc.tree, at) {
+		at = at[:len(at)-1]
+	}
+	if len(at) > 0 {
+		if _, err := strconv.Atoi(at[len(at)-1]); err == nil {
+			at = append(append([]string(nil), at...), "name")
+		}
+	}
+	d.Line = doc.line(at)
+	return d
+}
+
+// hasPath reports whether a tree holds a value at path
+func hasPath(tree interface{}, path []string) bool {
+	for _, element := range path {
+		switch node := tree.(type) {
+		case map[string]interface{}:
+			value, ok := node[element]
+			if !ok {
+				return false
+			}
+			tree = value
+		case []interface{}:
+			i, err := strconv.Atoi(element)
+			if err != nil || i < 0 || i >= len(node) {
+				return false
+			}
+			tree = node[i]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// reporter collects the diagnostics of one document
+type reporter struct {
+	doc         *Document
+	diagnostics []Diagnostic
+}
+
+func (r *reporter) report(path []string, rule, severity, format string, args ...interface{}) {
+	r.diagnostics = append(r.diagnostics, diagnose(r.doc.Path, r.doc, path, rule, severity, fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string // line: severity rule
+	}{
+		{
+			name: "valid",
+			file: "valid.yaml",
+			content: `kind: Config
+provider: aws
+resources:
+  network:
+    - name: vpc
+      cidr: 10.0.0.0/16
+      subnets:
+        - name: a
+          cidr: 10.0.1.0/24
+  storage:
+    - name: app-data
+`,
+		},
+		{
+			name: "config",
+			file: "app.yaml",
+			content: `kind: Config
+provider: aws
+resources:
+  network:
+    - name: vpc
+      cidr: 10.0.0.1/16
+      subnets:
+        - name: a
+          cidr: 10.0.1.0/24
+        - name: b
+          cidr: 10.0.1.128/25
+        - name: c
+          cidr: 10.1.0.0/24
+  storage:
+    - name: Data_Bucket
+      public_access: true
+  serverless:
+    - name: fn
+      runtime: python3.11
+      handler: handler
+  database:
+    - name: db
+      storage: 5
+policies:
+  require_tags: [Team]
+`,
+			want: []string{
+				"6: warning cidr",
+				"11: error cidr",
+				"13: error cidr",
+				"15: error naming",
+				"15: error policy",
+				"16: error policy",
+				"18: error policy",
+				"20: error runtime",
+				"22: error resource",
+				"22: error policy",
+			},
+		},
+		{
+			name: "single resource kinds",
+			file: "kinds.yaml",
+			content: `kind: S3Bucket
+provider: aws
+resources:
+  storage:
+    - name: ab
+policies:
+  require_encryption: true
+---
+kind: EC2Instance
+provider: aws
+resources:
+  compute:
+    - name: web
+      public_ip: true
+policies:
+  no_public_instances: true
+`,
+			want: []string{
+				"5: error naming",
+				"5: error policy",
+				"14: error policy",
+			},
+		},
+		{
+			name: "lambda",
+			file: "function.toml",
+			content: `kind = "LambdaFunction"
+[metadata]
+name = "fn"
+runtime = "python2"
+handler = "app"
+
+[build]
+source_path = "src"
+
+[function]
+memory_mb = 64
+timeout_seconds = 3
+
+[iam]
+role_name = "role name"
+`,
+			want: []string{
+				"4: error runtime",
+				"5: error runtime",
+				"11: error resource",
+				"15: error naming",
+			},
+		},
+		{
+			name: "missing handler file",
+			file: "handler.toml",
+			content: `kind = "LambdaFunction"
+[metadata]
+name = "fn"
+runtime = "python3.11"
+handler = "app.handler"
+
+[build]
+source_path = "src"
+
+[function]
+memory_mb = 128
+timeout_seconds = 3
+`,
+			want: []string{"5: warning runtime"},
+		},
+		{
+			name:    "unknown field",
+			file:    "typo.yaml",
+			content: "kind: Config\nprovider: aws\nregoin: us-east-1\n",
+			want:    []string{"3: error schema"},
+		},
+		{
+			name:    "parse error",
+			file:    "broken.yaml",
+			content: "provider: aws\nresources: [\n",
+			want:    []string{"2: error schema"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, dir, tt.file, tt.content)
+			diagnostics := ValidateFile(path)
+
+			var got []string
+			for _, d := range diagnostics {
+				if d.File != path {
+					t.Errorf("diagnostic %s is not about %s", d, path)
+				}
+				got = append(got, fmt.Sprintf("%d: %s %s", d.Line, d.Severity, d.Rule))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("ValidateFile() = %v, want %v", diagnostics, tt.want)
+			}
+		})
+	}
+}
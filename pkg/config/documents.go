@@ -0,0 +1,271 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Document formats
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// Document is one configuration document of a file. YAML files may hold
+// several documents separated by "---"; a TOML file is a single document.
+type Document struct {
+	TypeMeta
+	Path   string
+	Index  int    // position of the document in its file, from 0
+	Count  int    // number of documents in the file
+	Format string // yaml|toml
+	// Detected is set when the document has no kind header and its kind was
+	// worked out from its contents
+	Detected bool
+
+	tree map[string]interface{}
+	node *yaml.Node
+	data string
+}
+
+// Name identifies the document in messages
+func (d *Document) Name() string {
+	if d.Count <= 1 {
+		return d.Path
+	}
+	return fmt.Sprintf("%s (document %d)", d.Path, d.Index+1)
+}
+
+// Decode decodes the document into the schema of its kind
+func (d *Document) Decode(v interface{}) error {
+	if d.Format == FormatTOML {
+		if _, err := toml.Decode(d.data, v); err != nil {
+			return fmt.Errorf("failed to parse TOML %s: %w", d.Name(), err)
+		}
+		return nil
+	}
+	if err := d.node.Decode(v); err != nil {
+		return fmt.Errorf("failed to parse YAML %s: %w", d.Name(), err)
+	}
+	return nil
+}
+
+// ReadDocuments reads the configuration documents of a file and works out
+// their kinds, from the kind header or, for files written before headers,
+// from their contents. Fields the schema of a document's kind does not
+// declare are reported as FieldErrors.
+func ReadDocuments(path string) ([]*Document, error) {
+	docs, err := readKinds(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fieldErrors []*FieldError
+	for _, doc := range docs {
+		fieldErrors = append(fieldErrors, doc.CheckFields()...)
+	}
+	if err := strictError(fieldErrors); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// readKinds reads the documents of a file and works out their kinds. A file
+// may hold at most one Config document.
+func readKinds(path string) ([]*Document, error) {
+	docs, err := parseDocuments(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("%s contains no configuration", path)
+	}
+
+	var configDoc *Document
+	for _, doc := range docs {
+		var ok bool
+		if doc.APIVersion, ok = headerField(doc.tree, "apiVersion"); !ok {
+			return nil, fmt.Errorf("%s: apiVersion must be a string", doc.Name())
+		}
+		if doc.Kind, ok = headerField(doc.tree, "kind"); !ok {
+			return nil, fmt.Errorf("%s: kind must be a string", doc.Name())
+		}
+
+		if doc.APIVersion == "" && doc.Kind == "" {
+			doc.Kind = detectKind(doc.tree)
+			doc.Detected = true
+		} else if err := checkHeader(doc.APIVersion, doc.Kind); err != nil {
+			return nil, fmt.Errorf("%s: %w", doc.Name(), err)
+		}
+
+		if doc.Kind == KindConfig {
+			if configDoc != nil {
+				return nil, fmt.Errorf("%s: a file may hold only one %s document; declare its resources in %s",
+					doc.Name(), KindConfig, configDoc.Name())
+			}
+			configDoc = doc
+		}
+	}
+	return docs, nil
+}
+
+// parseDocuments splits a YAML or TOML file into documents, detecting the
+// format by extension
+func parseDocuments(path string) ([]*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var docs []*Document
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		docs, err = yamlDocuments(path, data)
+	case ".toml":
+		docs, err = tomlDocuments(path, data)
+	default:
+		// Try to auto-detect format
+		if docs, err = yamlDocuments(path, data); err != nil {
+			if docs, err = tomlDocuments(path, data); err != nil {
+				return nil, fmt.Errorf("failed to parse config %s (tried YAML and TOML): %w", path, err)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, doc := range docs {
+		doc.Path = path
+		doc.Index = i
+		doc.Count = len(docs)
+	}
+	return docs, nil
+}
+
+// readDocument reads the first document of a file, or an empty document
+// when the file holds none
+func readDocument(path string) (*Document, error) {
+	docs, err := parseDocuments(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return &Document{Path: path, Count: 1, tree: make(map[string]interface{})}, nil
+	}
+	return docs[0], nil
+}
+
+func yamlDocuments(path string, data []byte) ([]*Document, error) {
+	var docs []*Document
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		node := new(yaml.Node)
+		if err := decoder.Decode(node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse YAML %s: %w", path, err)
+		}
+		// Separators around a document and comment-only documents hold nothing
+		if len(node.Content) == 0 || node.Content[0].Tag == "!!null" {
+			continue
+		}
+
+		tree := make(map[string]interface{})
+		if err := node.Decode(&tree); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML %s: %w", path, err)
+		}
+		docs = append(docs, &Document{
+			Format: FormatYAML,
+			tree:   normalize(tree).(map[string]interface{}),
+			node:   node,
+		})
+	}
+	return docs, nil
+}
+
+func tomlDocuments(path string, data []byte) ([]*Document, error) {
+	tree := make(map[string]interface{})
+	if _, err := toml.Decode(string(data), &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse TOML %s: %w", path, err)
+	}
+	if len(tree) == 0 {
+		return nil, nil
+	}
+	return []*Document{{
+		Format: FormatTOML,
+		tree:   normalize(tree).(map[string]interface{}),
+		data:   string(data),
+	}}, nil
+}
+
+func headerField(tree map[string]interface{}, key string) (string, bool) {
+	value, ok := tree[key]
+	if !ok {
+		return "", true
+	}
+	s, ok := value.(string)
+	return strings.TrimSpace(s), ok
+}
+
+// configTree reads the Config document of a file into a tree. A file holding
+// a single document without a header is read as a Config whatever it
+// declares, so single-resource files can still be planned.
+func configTree(path string) (map[string]interface{}, error) {
+	docs, err := readKinds(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fieldErrors []*FieldError
+	for _, doc := range docs {
+		if doc.Kind == KindConfig || (doc.Count == 1 && doc.Detected) {
+			fieldErrors = append(fieldErrors, doc.checkFields(reflect.TypeOf(Config{}))...)
+		}
+	}
+	if err := strictError(fieldErrors); err != nil {
+		return nil, err
+	}
+
+	tree := make(map[string]interface{})
+	found := false
+	for _, doc := range docs {
+		if doc.Kind != KindConfig && !(doc.Count == 1 && doc.Detected) {
+			continue
+		}
+		found = true
+		for _, key := range sortedNames(doc.tree) {
+			value := doc.tree[key]
+			if key != "resources" {
+				tree[key] = mergeTrees(tree[key], value)
+				continue
+			}
+			resources, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: resources must map resource types to lists", doc.Name())
+			}
+			if err := addResources(tree, resources, doc.Name()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !found {
+		var found []string
+		for _, doc := range docs {
+			found = append(found, doc.Kind)
+		}
+		return nil, fmt.Errorf("%s has no %s documents (found %s)", path, KindConfig, strings.Join(found, ", "))
+	}
+	return tree, nil
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadDocumentsKinds(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "legacy bucket",
+			file:    "bucket.toml",
+			content: "provider = \"aws\"\n\n[[resources.storage]]\nname = \"b\"\ntype = \"bucket\"\n",
+			want:    []string{KindS3Bucket},
+		},
+		{
+			name:    "legacy instance",
+			file:    "instance.yaml",
+			content: "provider: aws\nresources:\n  compute:\n    - name: web\n      type: t3.micro\n",
+			want:    []string{KindEC2Instance},
+		},
+		{
+			name:    "legacy function",
+			file:    "function.toml",
+			content: "[metadata]\nname = \"fn\"\n[build]\nsource_path = \".\"\n[function]\nmemory_mb = 128\n[deployment]\nauth_type = \"NONE\"\n",
+			want:    []string{KindLambdaFunction},
+		},
+		{
+			name:    "several resources",
+			file:    "app.yaml",
+			content: "provider: aws\nresources:\n  storage:\n    - name: a\n    - name: b\n",
+			want:    []string{KindConfig},
+		},
+		{
+			name:    "other provider",
+			file:    "mock.yaml",
+			content: "provider: mock\nresources:\n  storage:\n    - name: a\n",
+			want:    []string{KindConfig},
+		},
+		{
+			name:    "headers and separators",
+			file:    "multi.yaml",
+			content: "---\nkind: S3Bucket\nprovider: aws\n---\n# nothing here\n---\napiVersion: genesys/v1\nkind: Config\n",
+			want:    []string{KindS3Bucket, KindConfig},
+		},
+		{
+			name:    "unknown kind",
+			file:    "unknown.yaml",
+			content: "kind: Bucket\n",
+			wantErr: `unknown kind "Bucket" (kinds: Config, EC2Instance, LambdaFunction, S3Bucket)`,
+		},
+		{
+			name:    "unsupported version",
+			file:    "version.yaml",
+			content: "apiVersion: genesys/v9\nkind: Config\n",
+			wantErr: `unsupported apiVersion "genesys/v9"`,
+		},
+		{
+			name:    "empty",
+			file:    "empty.yaml",
+			content: "# nothing\n",
+			wantErr: "contains no configuration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := ReadDocuments(writeConfig(t, dir, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ReadDocuments() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadDocuments() error = %v", err)
+			}
+
+			var kinds []string
+			for _, doc := range docs {
+				kinds = append(kinds, doc.Kind)
+			}
+			if strings.Join(kinds, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("kinds = %v, want %v", kinds, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDocuments(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "app.yaml", `
+apiVersion: genesys/v1
+kind: Config
+provider: aws
+resources:
+  storage:
+    - name: logs
+      type: bucket
+---
+apiVersion: genesys/v1
+kind: S3Bucket
+provider: aws
+resources:
+  storage:
+    - name: legacy
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Provider != "aws" {
+		t.Errorf("provider = %s, want aws", cfg.Provider)
+	}
+	var buckets []string
+	for _, storage := range cfg.Resources.Storage {
+		buckets = append(buckets, storage.Name)
+	}
+	if got := strings.Join(buckets, ","); got != "logs" {
+		t.Errorf("buckets = %s, want logs from the Config document", got)
+	}
+
+	docs, err := ReadDocuments(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bucket S3BucketConfig
+	if err := docs[1].Decode(&bucket); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if bucket.Kind != KindS3Bucket || bucket.Resources.Storage[0].Name != "legacy" {
+		t.Errorf("decoded %+v, want the S3Bucket document", bucket)
+	}
+
+	path = writeConfig(t, dir, "two.yaml", "kind: Config\nresources:\n  storage:\n    - name: a\n---\nkind: S3Bucket\n---\nkind: Config\nresources:\n  storage:\n    - name: b\n")
+	want := "two.yaml (document 3): a file may hold only one Config document; declare its resources in " + path + " (document 1)"
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("LoadConfig() error = %v, want a second Config document error", err)
+	}
+	if _, err := ReadDocuments(path); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("ReadDocuments() error = %v, want a second Config document error", err)
+	}
+
+	path = writeConfig(t, dir, "bucket.yaml", "kind: S3Bucket\nprovider: aws\n")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "has no Config documents (found S3Bucket)") {
+		t.Errorf("LoadConfig() error = %v, want a missing Config error", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+
+	path := writeConfig(t, dir, "bucket.toml", "# Generated by genesys\nprovider = \"aws\"\n\n[[resources.storage]]\nname = \"b\"\n")
+	data, migrations, err := Migrate(path)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	want := "# Generated by genesys\napiVersion = \"genesys/v1\"\nkind = \"S3Bucket\"\nprovider = \"aws\"\n\n[[resources.storage]]\nname = \"b\"\n"
+	if string(data) != want {
+		t.Errorf("Migrate() =\n%s\nwant\n%s", data, want)
+	}
+	if len(migrations) != 1 || migrations[0].Document.Kind != KindS3Bucket {
+		t.Errorf("migrations = %v, want one S3Bucket", migrations)
+	}
+
+	path = writeConfig(t, dir, "multi.yaml", "provider: aws\nresources:\n  compute:\n    - name: web\n---\napiVersion: genesys/v1\nkind: Config\n---\nkind: S3Bucket\nprovider: aws\n")
+	data, migrations, err = Migrate(path)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	want = "apiVersion: genesys/v1\nkind: EC2Instance\nprovider: aws\nresources:\n  compute:\n    - name: web\n---\napiVersion: genesys/v1\nkind: Config\n---\napiVersion: genesys/v1\nkind: S3Bucket\nprovider: aws\n"
+	if string(data) != want {
+		t.Errorf("Migrate() =\n%s\nwant\n%s", data, want)
+	}
+	if len(migrations) != 2 {
+		t.Errorf("migrations = %d, want the first and last documents", len(migrations))
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, migrations, err := Migrate(path); err != nil || len(migrations) != 0 {
+		t.Errorf("Migrate() of a migrated file = %v, %v, want no migrations", migrations, err)
+	}
+}
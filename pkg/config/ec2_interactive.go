@@ -40,6 +40,7 @@ type EC2StorageConfig struct {
 
 // EC2InstanceConfig represents a simple EC2 instance configuration
 type EC2InstanceConfig struct {
+	TypeMeta `yaml:",inline"`
 	Provider string `yaml:"provider" toml:"provider"`
 	Region   string `yaml:"region" toml:"region"`
 
@@ -88,6 +89,7 @@ func (iec *InteractiveEC2Config) CreateInstanceConfig() (*EC2InstanceConfig, str
 	fmt.Println("")
 
 	config := &EC2InstanceConfig{
+		TypeMeta: NewTypeMeta(KindEC2Instance),
 		Provider: "aws",
 	}
 
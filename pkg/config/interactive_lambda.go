@@ -29,72 +29,73 @@ func NewInteractiveLambdaConfig() (*InteractiveLambdaConfig, error) {
 	}, nil
 }
 
-// LambdaFunctionConfig represents Lambda function configuration for TOML
+// LambdaFunctionConfig represents Lambda function configuration
 type LambdaFunctionConfig struct {
-	Metadata   LambdaMetadata   `toml:"metadata"`
-	Build      LambdaBuild      `toml:"build"`
-	Function   LambdaFunction   `toml:"function"`
-	Deployment LambdaDeployment `toml:"deployment"`
-	Triggers   []LambdaTrigger  `toml:"triggers,omitempty"`
-	Layer      *LambdaLayer     `toml:"layer,omitempty"`
-	IAM        *LambdaIAM       `toml:"iam,omitempty"`
+	TypeMeta   `yaml:",inline"`
+	Metadata   LambdaMetadata   `yaml:"metadata" toml:"metadata"`
+	Build      LambdaBuild      `yaml:"build" toml:"build"`
+	Function   LambdaFunction   `yaml:"function" toml:"function"`
+	Deployment LambdaDeployment `yaml:"deployment" toml:"deployment"`
+	Triggers   []LambdaTrigger  `yaml:"triggers,omitempty" toml:"triggers,omitempty"`
+	Layer      *LambdaLayer     `yaml:"layer,omitempty" toml:"layer,omitempty"`
+	IAM        *LambdaIAM       `yaml:"iam,omitempty" toml:"iam,omitempty"`
 }
 
 // LambdaMetadata contains function metadata
 type LambdaMetadata struct {
-	Name        string `toml:"name"`
-	Runtime     string `toml:"runtime"`
-	Handler     string `toml:"handler"`
-	Description string `toml:"description"`
+	Name        string `yaml:"name" toml:"name"`
+	Runtime     string `yaml:"runtime" toml:"runtime"`
+	Handler     string `yaml:"handler" toml:"handler"`
+	Description string `yaml:"description" toml:"description"`
 }
 
 // LambdaBuild contains build configuration
 type LambdaBuild struct {
-	SourcePath       string `toml:"source_path"`
-	BuildMethod      string `toml:"build_method"`
-	LayerAuto        bool   `toml:"layer_auto"`
-	RequirementsFile string `toml:"requirements_file,omitempty"`
+	SourcePath       string `yaml:"source_path" toml:"source_path"`
+	BuildMethod      string `yaml:"build_method" toml:"build_method"`
+	LayerAuto        bool   `yaml:"layer_auto" toml:"layer_auto"`
+	RequirementsFile string `yaml:"requirements_file,omitempty" toml:"requirements_file,omitempty"`
 }
 
 // LambdaFunction contains function configuration
 type LambdaFunction struct {
-	MemoryMB       int               `toml:"memory_mb"`
-	TimeoutSeconds int               `toml:"timeout_seconds"`
-	Environment    map[string]string `toml:"environment,omitempty"`
+	MemoryMB       int               `yaml:"memory_mb" toml:"memory_mb"`
+	TimeoutSeconds int               `yaml:"timeout_seconds" toml:"timeout_seconds"`
+	Environment    map[string]string `yaml:"environment,omitempty" toml:"environment,omitempty"`
 }
 
 // LambdaDeployment contains deployment configuration
 type LambdaDeployment struct {
-	FunctionURL  bool   `toml:"function_url"`
-	CORSEnabled  bool   `toml:"cors_enabled"`
-	AuthType     string `toml:"auth_type"`
-	Architecture string `toml:"architecture"`
+	FunctionURL  bool   `yaml:"function_url" toml:"function_url"`
+	CORSEnabled  bool   `yaml:"cors_enabled" toml:"cors_enabled"`
+	AuthType     string `yaml:"auth_type" toml:"auth_type"`
+	Architecture string `yaml:"architecture" toml:"architecture"`
 }
 
 // LambdaTrigger represents a function trigger
 type LambdaTrigger struct {
-	Type   string `toml:"type"`
-	Path   string `toml:"path,omitempty"`
-	Method string `toml:"method,omitempty"`
+	Type   string `yaml:"type" toml:"type"`
+	Path   string `yaml:"path,omitempty" toml:"path,omitempty"`
+	Method string `yaml:"method,omitempty" toml:"method,omitempty"`
 }
 
 // LambdaLayer represents layer configuration
 type LambdaLayer struct {
-	Name               string   `toml:"name"`
-	Description        string   `toml:"description"`
-	CompatibleRuntimes []string `toml:"compatible_runtimes"`
+	Name               string   `yaml:"name" toml:"name"`
+	Description        string   `yaml:"description" toml:"description"`
+	CompatibleRuntimes []string `yaml:"compatible_runtimes" toml:"compatible_runtimes"`
 }
 
 // LambdaIAM represents IAM configuration for Lambda
 type LambdaIAM struct {
-	RoleName         string            `toml:"role_name"`
-	RoleArn          string            `toml:"role_arn,omitempty"`
-	RequiredPolicies []string          `toml:"required_policies"`
-	CustomPolicies   []string          `toml:"custom_policies,omitempty"`
-	AutoManage       bool              `toml:"auto_manage"`
-	AutoCleanup      bool              `toml:"auto_cleanup"`
-	ManagedBy        string            `toml:"managed_by,omitempty"`
-	PolicyDetails    map[string]string `toml:"policy_details,omitempty"` // Policy ARN -> Description mapping
+	RoleName         string            `yaml:"role_name" toml:"role_name"`
+	RoleArn          string            `yaml:"role_arn,omitempty" toml:"role_arn,omitempty"`
+	RequiredPolicies []string          `yaml:"required_policies" toml:"required_policies"`
+	CustomPolicies   []string          `yaml:"custom_policies,omitempty" toml:"custom_policies,omitempty"`
+	AutoManage       bool              `yaml:"auto_manage" toml:"auto_manage"`
+	AutoCleanup      bool              `yaml:"auto_cleanup" toml:"auto_cleanup"`
+	ManagedBy        string            `yaml:"managed_by,omitempty" toml:"managed_by,omitempty"`
+	PolicyDetails    map[string]string `yaml:"policy_details,omitempty" toml:"policy_details,omitempty"` // Policy ARN -> Description mapping
 }
 
 // CreateLambdaConfig creates Lambda configuration interactively
@@ -372,6 +373,7 @@ func (ilc *InteractiveLambdaConfig) CreateLambdaConfig() (*LambdaFunctionConfig,
 
 	// Create configuration
 	config := &LambdaFunctionConfig{
+		TypeMeta: NewTypeMeta(KindLambdaFunction),
 		Metadata: LambdaMetadata{
 			Name:        functionName,
 			Runtime:     runtime,
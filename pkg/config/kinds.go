@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// APIVersion is the schema version written in configuration headers
+const APIVersion = "genesys/v1"
+
+// Configuration kinds
+const (
+	KindConfig         = "Config"
+	KindS3Bucket       = "S3Bucket"
+	KindEC2Instance    = "EC2Instance"
+	KindLambdaFunction = "LambdaFunction"
+)
+
+// TypeMeta is the header naming the schema of a configuration document:
+//
+//	apiVersion: genesys/v1
+//	kind: S3Bucket
+type TypeMeta struct {
+	APIVersion string `yaml:"apiVersion,omitempty" toml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty" toml:"kind,omitempty"`
+}
+
+// NewTypeMeta returns the header of a kind at the current APIVersion
+func NewTypeMeta(kind string) TypeMeta {
+	return TypeMeta{APIVersion: APIVersion, Kind: kind}
+}
+
+// Kind describes a kind of configuration document
+type Kind struct {
+	Name        string
+	Description string
+	// New returns an empty value of the kind's schema for documents to be
+	// decoded into
+	New func() interface{}
+	// Detect recognises documents of the kind written without a header
+	Detect func(tree map[string]interface{}) bool
+	// Check runs the offline checks of the kind on a document whose fields
+	// match its schema. Config documents are checked by ValidateFile once
+	// their file is loaded.
+	Check func(doc *Document) []Diagnostic
+}
+
+var kinds []Kind
+
+// RegisterKind adds a kind to the registry. Documents without a header are
+// checked against the kinds in the order they were registered.
+func RegisterKind(kind Kind) {
+	for i, existing := range kinds {
+		if existing.Name == kind.Name {
+			kinds[i] = kind
+			return
+		}
+	}
+	kinds = append(kinds, kind)
+}
+
+// LookupKind returns the registered kind with the given name
+func LookupKind(name string) (Kind, bool) {
+	for _, kind := range kinds {
+		if kind.Name == name {
+			return kind, true
+		}
+	}
+	return Kind{}, false
+}
+
+// Kinds returns the registered kinds in registration order
+func Kinds() []Kind {
+	return append([]Kind(nil), kinds...)
+}
+
+// KindNames returns the names of the registered kinds, sorted
+func KindNames() []string {
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = kind.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detectKind works out the kind of a document without a header from its
+// contents, the way configuration files were told apart before headers
+func detectKind(tree map[string]interface{}) string {
+	for _, kind := range kinds {
+		if kind.Detect != nil && kind.Detect(tree) {
+			return kind.Name
+		}
+	}
+	return KindConfig
+}
+
+// checkHeader validates the apiVersion and kind of a document
+func checkHeader(apiVersion, kind string) error {
+	if apiVersion != "" && apiVersion != APIVersion {
+		return fmt.Errorf("unsupported apiVersion %q (supported: %s)", apiVersion, APIVersion)
+	}
+	if kind == "" {
+		return fmt.Errorf("apiVersion is set without a kind (kinds: %s)", strings.Join(KindNames(), ", "))
+	}
+	if _, ok := LookupKind(kind); !ok {
+		return fmt.Errorf("unknown kind %q (kinds: %s)", kind, strings.Join(KindNames(), ", "))
+	}
+	return nil
+}
+
+// resourceCounts returns the number of entries in each resource list
+func resourceCounts(tree map[string]interface{}) map[string]int {
+	counts := make(map[string]int)
+	resources, _ := tree["resources"].(map[string]interface{})
+	for kind, value := range resources {
+		if list, ok := value.([]interface{}); ok && len(list) > 0 {
+			counts[kind] = len(list)
+		}
+	}
+	return counts
+}
+
+// isSingleResource reports whether an AWS document declares exactly one
+// resource, of the given resource kind
+func isSingleResource(tree map[string]interface{}, kind string) bool {
+	provider, _ := tree["provider"].(string)
+	counts := resourceCounts(tree)
+	return provider == "aws" && len(counts) == 1 && counts[kind] == 1
+}
+
+func init() {
+	// Configurations with several resources, or any network, database or
+	// serverless resource, are detected first as they were before headers.
+	// So are those using sections only Config has.
+	RegisterKind(Kind{
+		Name:        KindConfig,
+		Description: "Resources of any type, planned and applied together",
+		New:         func() interface{} { return &Config{} },
+		Detect: func(tree map[string]interface{}) bool {
+			for _, section := range []string{"variables", "use", "state", "outcomes"} {
+				if _, ok := tree[section]; ok {
+					return true
+				}
+			}
+			counts := resourceCounts(tree)
+			return len(counts) > 1 || counts["network"] > 0 || counts["database"] > 0 ||
+				counts["serverless"] > 0 || counts["storage"] > 1 || counts["compute"] > 1
+		},
+	})
+	RegisterKind(Kind{
+		Name:        KindS3Bucket,
+		Description: "A single S3 bucket, as written by 'genesys interact'",
+		New:         func() interface{} { return &S3BucketConfig{} },
+		Detect: func(tree map[string]interface{}) bool {
+			return isSingleResource(tree, "storage")
+		},
+		Check: checkS3Bucket,
+	})
+	RegisterKind(Kind{
+		Name:        KindEC2Instance,
+		Description: "A single EC2 instance, as written by 'genesys interact'",
+		New:         func() interface{} { return &EC2InstanceConfig{} },
+		Detect: func(tree map[string]interface{}) bool {
+			return isSingleResource(tree, "compute")
+		},
+		Check: checkEC2Instance,
+	})
+	RegisterKind(Kind{
+		Name:        KindLambdaFunction,
+		Description: "A Lambda function with its build, deployment and IAM role",
+		New:         func() interface{} { return &LambdaFunctionConfig{} },
+		Detect: func(tree map[string]interface{}) bool {
+			for _, section := range []string{"metadata", "build", "function", "deployment"} {
+				if _, ok := tree[section].(map[string]interface{}); !ok {
+					return false
+				}
+			}
+			return true
+		},
+		Check: checkLambdaFunction,
+	})
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Migration is the header added to a document by Migrate
+type Migration struct {
+	Document *Document
+	Lines    []string
+}
+
+// Migrate returns the contents of a file with the apiVersion and kind header
+// added to every document missing it, and the headers added. Comments and
+// formatting are kept. A file that needs no changes is returned unchanged
+// with no migrations.
+func Migrate(path string) ([]byte, []Migration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	docs, err := ReadDocuments(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	var migrations []Migration
+
+	// Documents are migrated from the last, so that inserting lines does not
+	// move the documents still to be migrated
+	for i := len(docs) - 1; i >= 0; i-- {
+		doc := docs[i]
+		if doc.APIVersion == APIVersion && !doc.Detected {
+			continue
+		}
+
+		at, indent, err := headerPosition(doc, lines)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		format := "%s%s: %s\n"
+		if doc.Format == FormatTOML {
+			format = "%s%s = %q\n"
+		}
+		var header []string
+		if doc.APIVersion == "" {
+			header = append(header, fmt.Sprintf(format, indent, "apiVersion", APIVersion))
+		}
+		if doc.Detected {
+			header = append(header, fmt.Sprintf(format, indent, "kind", doc.Kind))
+		}
+
+		lines = append(lines[:at], append(header, lines[at:]...)...)
+		migrations = append([]Migration{{Document: doc, Lines: header}}, migrations...)
+	}
+
+	return []byte(strings.Join(lines, "")), migrations, nil
+}
+
+// headerPosition returns the index of the line a document's header is
+// inserted before, and the indentation of the document's keys
+func headerPosition(doc *Document, lines []string) (int, string, error) {
+	if doc.Format == FormatTOML {
+		// Keys must come before the first table, so the header goes above the
+		// first line that is not a comment
+		for i, line := range lines {
+			trimmed := strings.TrimSpace(line)
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				return i, "", nil
+			}
+		}
+		return len(lines), "", nil
+	}
+
+	mapping := doc.node.Content[0]
+	if mapping.Kind != yaml.MappingNode || len(mapping.Content) == 0 || mapping.Style&yaml.FlowStyle != 0 {
+		return 0, "", fmt.Errorf("%s: only block mappings can be migrated; add apiVersion: %s and kind: %s by hand", doc.Name(), APIVersion, doc.Kind)
+	}
+	key := mapping.Content[0]
+	return key.Line - 1, strings.Repeat(" ", key.Column-1), nil
+}
@@ -0,0 +1,392 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// ModuleFiles are the file names looked up in a module directory, in order
+var ModuleFiles = []string{"module.yaml", "module.yml", "module.toml"}
+
+// ModuleUse includes a module in a configuration. The module's resources are
+// added to the configuration with their names prefixed by "<name>-".
+type ModuleUse struct {
+	Name   string                 `yaml:"name" toml:"name"`
+	Source string                 `yaml:"source" toml:"source"` // module directory or file, relative to the configuration
+	Inputs map[string]interface{} `yaml:"inputs,omitempty" toml:"inputs,omitempty"`
+}
+
+// moduleSections are the top-level sections a module file may contain
+var moduleSections = map[string]bool{"variables": true, "use": true, "resources": true, "outputs": true}
+
+// moduleSchema is the schema of module files
+type moduleSchema struct {
+	Variables map[string]Variable    `yaml:"variables,omitempty" toml:"variables,omitempty"`
+	Use       []ModuleUse            `yaml:"use,omitempty" toml:"use,omitempty"`
+	Resources Resources              `yaml:"resources,omitempty" toml:"resources,omitempty"`
+	Outputs   map[string]interface{} `yaml:"outputs,omitempty" toml:"outputs,omitempty"`
+}
+
+// namedKinds are the resource lists whose entries can be referred to by name
+var namedKinds = []string{"storage", "network", "compute", "database", "serverless"}
+
+var moduleReferencePattern = regexp.MustCompile(`\$\{module\.([^.}]+)\.([^}]+)\}`)
+
+var resourceReferencePattern = regexp.MustCompile(`\$\{(storage|network|compute|database|serverless)\.([^.}]+)(\.[^}]*)\}`)
+
+// expandModules adds the resources of the modules a configuration uses to its
+// resource lists and replaces ${module.<name>.<output>} with the outputs of
+// those modules. dir is the directory sources are relative to, prefix is
+// prepended to the names of the modules' resources and stack lists the
+// module files being expanded, to detect modules that use themselves. The
+// paths of the module files read are appended to files.
+func expandModules(tree map[string]interface{}, dir, prefix string, stack []string, files *[]string) error {
+	section, ok := tree["use"]
+	if !ok {
+		return nil
+	}
+
+	uses, ok := section.([]interface{})
+	if !ok {
+		return fmt.Errorf("use must be a list of modules")
+	}
+
+	outputs := make(map[string]map[string]interface{})
+	for i, entry := range uses {
+		use, ok := entry.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("use[%d] must have name, source and inputs fields", i)
+		}
+		name, _ := use["name"].(string)
+		source, _ := use["source"].(string)
+		if name == "" || source == "" {
+			return fmt.Errorf("use[%d] must have a name and a source", i)
+		}
+		if _, ok := outputs[name]; ok {
+			return fmt.Errorf("module name %s is used more than once", name)
+		}
+
+		inputs := make(map[string]interface{})
+		if value, ok := use["inputs"]; ok {
+			if inputs, ok = value.(map[string]interface{}); !ok {
+				return fmt.Errorf("inputs of module %s must map variable names to values", name)
+			}
+		}
+
+		module, err := loadModule(name, moduleSource(dir, source), prefix+name+"-", inputs, stack, files)
+		if err != nil {
+			return err
+		}
+		if err := addResources(tree, module.resources, "module "+name); err != nil {
+			return err
+		}
+		outputs[name] = module.outputs
+	}
+
+	_, err := substituteModuleOutputs(tree, outputs, "")
+	return err
+}
+
+// expandedModule is a module with its inputs applied and resources namespaced
+type expandedModule struct {
+	resources map[string]interface{}
+	outputs   map[string]interface{}
+}
+
+// loadModule reads a module, namespaces its resources, applies its inputs and
+// expands the modules it uses in turn
+func loadModule(name, source, prefix string, inputs map[string]interface{}, stack []string, files *[]string) (*expandedModule, error) {
+	path, err := moduleFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %w", name, err)
+	}
+	for _, used := range stack {
+		if used == path {
+			return nil, fmt.Errorf("module %s: %s uses itself (%s)", name, path, strings.Join(append(stack, path), " -> "))
+		}
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %w", name, err)
+	}
+	*files = append(*files, path)
+	tree := doc.tree
+	for _, section := range sortedNames(tree) {
+		if !moduleSections[section] {
+			return nil, fmt.Errorf("module %s: %s sets %s; modules may only declare variables, use, resources and outputs", name, path, section)
+		}
+	}
+	if err := strictError(doc.checkFields(reflect.TypeOf(moduleSchema{}))); err != nil {
+		return nil, fmt.Errorf("module %s: %w", name, err)
+	}
+
+	// References between the module's own resources are renamed before the
+	// inputs come in, so references passed in from outside are left alone
+	namespace(tree, prefix)
+
+	assigner, err := newVariableAssigner(tree)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %w", name, err)
+	}
+	for _, input := range sortedNames(inputs) {
+		if err := assigner.set(input, inputs[input], "inputs of module "+name); err != nil {
+			return nil, err
+		}
+	}
+	variables, err := assigner.result("pass them in the inputs of module " + name)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %w", name, err)
+	}
+	if _, err := interpolate(tree, variables, ""); err != nil {
+		return nil, fmt.Errorf("module %s: %w", name, err)
+	}
+
+	if err := expandModules(tree, filepath.Dir(path), prefix, append(stack, path), files); err != nil {
+		return nil, fmt.Errorf("module %s: %w", name, err)
+	}
+
+	module := &expandedModule{
+		resources: make(map[string]interface{}),
+		outputs:   make(map[string]interface{}),
+	}
+	if resources, ok := tree["resources"].(map[string]interface{}); ok {
+		module.resources = resources
+	}
+	if outputs, ok := tree["outputs"].(map[string]interface{}); ok {
+		module.outputs = outputs
+	}
+	return module, nil
+}
+
+// moduleSource resolves a module source relative to the directory of the
+// configuration that uses it
+func moduleSource(dir, source string) string {
+	if strings.HasPrefix(source, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, source[2:])
+		}
+	}
+	if filepath.IsAbs(source) {
+		return source
+	}
+	return filepath.Join(dir, source)
+}
+
+// moduleFile returns the module file of a source, which is either the file
+// itself or a directory containing one of ModuleFiles
+func moduleFile(source string) (string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read module source: %w", err)
+	}
+
+	path := source
+	if info.IsDir() {
+		path = ""
+		for _, name := range ModuleFiles {
+			candidate := filepath.Join(source, name)
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+		if path == "" {
+			return "", fmt.Errorf("module directory %s has no %s", source, strings.Join(ModuleFiles, ", "))
+		}
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path, nil
+}
+
+// namespace prefixes the names of a module's resources and renames them in
+// the references and network settings that point at them
+func namespace(tree map[string]interface{}, prefix string) {
+	names := make(map[string]map[string]bool)
+	if resources, ok := tree["resources"].(map[string]interface{}); ok {
+		for _, kind := range namedKinds {
+			names[kind] = make(map[string]bool)
+			list, _ := resources[kind].([]interface{})
+			for _, entry := range list {
+				resource, ok := entry.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if name, ok := resource["name"].(string); ok && name != "" {
+					names[kind][name] = true
+					resource["name"] = prefix + name
+				}
+			}
+		}
+
+		// Instances name the network they run in directly
+		list, _ := resources["compute"].([]interface{})
+		for _, entry := range list {
+			if resource, ok := entry.(map[string]interface{}); ok {
+				if network, ok := resource["network"].(string); ok && names["network"][network] {
+					resource["network"] = prefix + network
+				}
+			}
+		}
+	}
+
+	rename := func(value string) string {
+		return resourceReferencePattern.ReplaceAllStringFunc(value, func(expr string) string {
+			match := resourceReferencePattern.FindStringSubmatch(expr)
+			kind, name, rest := match[1], match[2], match[3]
+			if !ownResource(names[kind], kind, name) {
+				return expr
+			}
+			return "${" + kind + "." + prefix + name + rest + "}"
+		})
+	}
+
+	for _, section := range []string{"resources", "outputs", "use"} {
+		if value, ok := tree[section]; ok {
+			tree[section] = mapStrings(value, rename)
+		}
+	}
+}
+
+// ownResource reports whether a reference names one of the module's
+// resources, including the numbered instances of a compute resource
+func ownResource(names map[string]bool, kind, name string) bool {
+	if names[name] {
+		return true
+	}
+	if kind != "compute" {
+		return false
+	}
+	if dash := strings.LastIndex(name, "-"); dash > 0 {
+		suffix := name[dash+1:]
+		return names[name[:dash]] && suffix != "" && strings.Trim(suffix, "0123456789") == ""
+	}
+	return false
+}
+
+// addResources appends the resources of a module or document to the resource
+// lists of a configuration, refusing names that are already declared
+func addResources(tree, added map[string]interface{}, source string) error {
+	if len(added) == 0 {
+		return nil
+	}
+
+	resources, ok := tree["resources"].(map[string]interface{})
+	if !ok {
+		resources = make(map[string]interface{})
+		tree["resources"] = resources
+	}
+
+	for _, kind := range sortedNames(added) {
+		list, ok := added[kind].([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: resources.%s must be a list", source, kind)
+		}
+		existing, _ := resources[kind].([]interface{})
+
+		taken := make(map[string]bool)
+		for _, entry := range existing {
+			taken[entryName(entry)] = true
+		}
+		for _, entry := range list {
+			if name := entryName(entry); name != "" && taken[name] {
+				return fmt.Errorf("%s: %s resource %s is already declared", source, kind, name)
+			}
+		}
+
+		resources[kind] = append(existing, list...)
+	}
+	return nil
+}
+
+// substituteModuleOutputs replaces ${module.<name>.<output>} in the string
+// values of a tree. A value that is a single reference takes the output as is.
+func substituteModuleOutputs(value interface{}, outputs map[string]map[string]interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedNames(v) {
+			if path == "" && key == "variables" {
+				continue
+			}
+			resolved, err := substituteModuleOutputs(v[key], outputs, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := substituteModuleOutputs(item, outputs, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+
+	case string:
+		lookup := func(module, output string) (interface{}, error) {
+			values, ok := outputs[module]
+			if !ok {
+				return nil, fmt.Errorf("%s refers to module %s, which is not used", path, module)
+			}
+			value, ok := values[output]
+			if !ok {
+				return nil, fmt.Errorf("%s refers to output %s of module %s, which it does not expose (outputs: %s)",
+					path, output, module, strings.Join(sortedNames(values), ", "))
+			}
+			return value, nil
+		}
+
+		if match := moduleReferencePattern.FindStringSubmatch(v); match != nil && match[0] == v {
+			return lookup(match[1], match[2])
+		}
+
+		var err error
+		result := moduleReferencePattern.ReplaceAllStringFunc(v, func(expr string) string {
+			match := moduleReferencePattern.FindStringSubmatch(expr)
+			value, lookupErr := lookup(match[1], match[2])
+			if lookupErr != nil {
+				if err == nil {
+					err = lookupErr
+				}
+				return expr
+			}
+			return fmt.Sprint(value)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	return value, nil
+}
+
+// mapStrings applies fn to every string in a tree
+func mapStrings(value interface{}, fn func(string) string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = mapStrings(item, fn)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = mapStrings(item, fn)
+		}
+		return v
+	case string:
+		return fn(v)
+	}
+	return value
+}
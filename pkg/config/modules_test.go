@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigModules(t *testing.T) {
+	defer func(options LoadOptions) { Options = options }(Options)
+	dir := t.TempDir()
+	for _, sub := range []string{"modules/service", "modules/logs"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig(t, dir, "modules/service/module.yaml", `
+variables:
+  memory:
+    type: number
+    default: 128
+  upstream:
+    type: string
+use:
+  - name: audit
+    source: ../logs
+resources:
+  storage:
+    - name: data
+      type: bucket
+  serverless:
+    - name: handler
+      runtime: python3.11
+      handler: main.handler
+      memory: ${var.memory}
+      environment:
+        BUCKET: ${storage.data.name}
+        UPSTREAM: ${var.upstream}
+        AUDIT: ${module.audit.bucket}
+outputs:
+  bucket: ${storage.data.name}
+`)
+	writeConfig(t, dir, "modules/logs/module.toml", `
+[[resources.storage]]
+name = "data"
+type = "bucket"
+
+[outputs]
+bucket = "${storage.data.name}"
+`)
+	path := writeConfig(t, dir, "app.yaml", `
+provider: aws
+use:
+  - name: uploads
+    source: ./modules/service
+    inputs:
+      memory: 256
+      upstream: ${storage.data.name}
+resources:
+  storage:
+    - name: data
+      type: bucket
+      tags:
+        downstream: ${module.uploads.bucket}
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	var buckets []string
+	for _, storage := range cfg.Resources.Storage {
+		buckets = append(buckets, storage.Name)
+	}
+	if got := strings.Join(buckets, ","); got != "data,uploads-data,uploads-audit-data" {
+		t.Errorf("buckets = %s, want data,uploads-data,uploads-audit-data", got)
+	}
+	if got := cfg.Resources.Storage[0].Tags["downstream"]; got != "${storage.uploads-data.name}" {
+		t.Errorf("downstream tag = %s, want the module's bucket", got)
+	}
+
+	if len(cfg.Resources.Serverless) != 1 {
+		t.Fatalf("serverless = %v, want the module's function", cfg.Resources.Serverless)
+	}
+	function := cfg.Resources.Serverless[0]
+	if function.Name != "uploads-handler" || function.Memory != 256 {
+		t.Errorf("function = %s with %dMB, want uploads-handler with 256MB", function.Name, function.Memory)
+	}
+	want := map[string]string{
+		"BUCKET":   "${storage.uploads-data.name}",
+		"UPSTREAM": "${storage.data.name}",
+		"AUDIT":    "${storage.uploads-audit-data.name}",
+	}
+	for key, value := range want {
+		if function.Environment[key] != value {
+			t.Errorf("environment %s = %s, want %s", key, function.Environment[key], value)
+		}
+	}
+
+	varFile := writeConfig(t, dir, "values.yaml", "{}\n")
+	Options = LoadOptions{VarFiles: []string{varFile}}
+	files, err := InputFiles(path)
+	if err != nil {
+		t.Fatalf("InputFiles() error = %v", err)
+	}
+	wantFiles := []string{path, varFile, filepath.Join(dir, "modules/service/module.yaml"), filepath.Join(dir, "modules/logs/module.toml")}
+	if strings.Join(files, ",") != strings.Join(wantFiles, ",") {
+		t.Errorf("InputFiles() = %v, want %v", files, wantFiles)
+	}
+	Options = LoadOptions{}
+
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "missing input",
+			config: "use:\n  - name: uploads\n    source: ./modules/service\n",
+			want:   "no value for variables upstream: pass them in the inputs of module uploads",
+		},
+		{
+			name:   "unknown output",
+			config: "use:\n  - name: logs\n    source: ./modules/logs\nresources:\n  storage:\n    - name: a\n      type: bucket\n      tags:\n        x: ${module.logs.arn}\n",
+			want:   "does not expose (outputs: bucket)",
+		},
+		{
+			name:   "name clash",
+			config: "use:\n  - name: logs\n    source: ./modules/logs\nresources:\n  storage:\n    - name: logs-data\n      type: bucket\n",
+			want:   "storage resource logs-data is already declared",
+		},
+		{
+			name:   "missing source",
+			config: "use:\n  - name: logs\n    source: ./modules/nothing\n",
+			want:   "failed to read module source",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, dir, "broken.yaml", "provider: aws\n"+tt.config)
+			if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	writeConfig(t, dir, "modules/logs/module.toml", "[[use]]\nname = \"again\"\nsource = \".\"\n")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "uses itself") {
+		t.Errorf("LoadConfig() error = %v, want a module cycle error", err)
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvironmentEnvVar selects the overlay merged over configurations when
+// LoadOptions.Environment is not set by a flag
+const EnvironmentEnvVar = "GENESYS_ENV"
+
+// OverlayPath returns the overlay file of a configuration for an environment:
+// app.prod.yaml for app.yaml and "prod"
+func OverlayPath(path, environment string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + environment + ext
+}
+
+// SourceFiles returns the files LoadConfig reads for a configuration: the
+// file itself and the overlay of the selected environment
+func SourceFiles(path string) ([]string, error) {
+	files := []string{path}
+	if Options.Environment == "" {
+		return files, nil
+	}
+
+	overlay := OverlayPath(path, Options.Environment)
+	if _, err := os.Stat(overlay); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("environment %s has no overlay file %s", Options.Environment, overlay)
+		}
+		return nil, fmt.Errorf("failed to read overlay %s: %w", overlay, err)
+	}
+	return append(files, overlay), nil
+}
+
+// readTree reads the first document of a YAML or TOML file into generic maps
+// and lists
+func readTree(path string) (map[string]interface{}, error) {
+	doc, err := readDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	return doc.tree, nil
+}
+
+// normalize converts the tables and arrays of tables the TOML decoder
+// produces into the maps and lists the YAML decoder produces
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case []map[string]interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = normalize(item)
+		}
+		return list
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	case int64:
+		return int(v)
+	}
+	return value
+}
+
+// mergeTrees deep-merges an overlay into a configuration. Maps are merged key
+// by key and lists of named entries, such as resources, entry by entry, so an
+// overlay only needs the fields it changes. Other values in the overlay
+// replace those of the configuration.
+func mergeTrees(base, overlay interface{}) interface{} {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return overlay
+		}
+		for key, value := range o {
+			if existing, ok := b[key]; ok {
+				b[key] = mergeTrees(existing, value)
+			} else {
+				b[key] = value
+			}
+		}
+		return b
+
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok || !namedEntries(b) || !namedEntries(o) {
+			return overlay
+		}
+		index := make(map[string]int)
+		for i, entry := range b {
+			index[entryName(entry)] = i
+		}
+		for _, entry := range o {
+			if i, ok := index[entryName(entry)]; ok {
+				b[i] = mergeTrees(b[i], entry)
+			} else {
+				b = append(b, entry)
+			}
+		}
+		return b
+	}
+
+	return overlay
+}
+
+// namedEntries reports whether every entry of a list is a map with a name
+func namedEntries(list []interface{}) bool {
+	for _, entry := range list {
+		if entryName(entry) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func entryName(entry interface{}) string {
+	m, ok := entry.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	name, _ := m["name"].(string)
+	return name
+}
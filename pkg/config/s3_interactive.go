@@ -30,6 +30,7 @@ type S3LifecycleConfig struct {
 
 // S3BucketConfig represents a simple S3 bucket configuration
 type S3BucketConfig struct {
+	TypeMeta `yaml:",inline"`
 	Provider string `yaml:"provider" toml:"provider"`
 	Region   string `yaml:"region" toml:"region"`
 
@@ -68,6 +69,7 @@ func (isc *InteractiveS3Config) CreateBucketConfig() (*S3BucketConfig, string, e
 	fmt.Println("")
 
 	config := &S3BucketConfig{
+		TypeMeta: NewTypeMeta(KindS3Bucket),
 		Provider: "aws",
 	}
 
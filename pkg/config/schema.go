@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JSONSchemaDialect is the JSON Schema draft exported schemas follow
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// interpolationSchema matches a value set from ${var.name} or another
+// expression that LoadConfig or the planner replaces
+var interpolationSchema = map[string]interface{}{
+	"type":    "string",
+	"pattern": `^\$\{[^}]+\}$`,
+}
+
+// JSONSchema returns the JSON Schema of the documents of a kind
+func JSONSchema(name string) (map[string]interface{}, error) {
+	kind, ok := LookupKind(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown kind %q (kinds: %s)", name, strings.Join(KindNames(), ", "))
+	}
+
+	schema := kindSchema(kind)
+	schema["$schema"] = JSONSchemaDialect
+	return schema, nil
+}
+
+// CombinedJSONSchema returns a JSON Schema for documents of any kind. The
+// schema of a document's kind applies when it has a kind header.
+func CombinedJSONSchema() map[string]interface{} {
+	definitions := make(map[string]interface{})
+	var conditions []interface{}
+	for _, name := range KindNames() {
+		kind, _ := LookupKind(name)
+		definitions[name] = kindSchema(kind)
+		conditions = append(conditions, map[string]interface{}{
+			"if": map[string]interface{}{
+				"properties": map[string]interface{}{"kind": map[string]interface{}{"const": name}},
+				"required":   []string{"kind"},
+			},
+			"then": map[string]interface{}{"$ref": "#/$defs/" + name},
+		})
+	}
+
+	return map[string]interface{}{
+		"$schema":     JSONSchemaDialect,
+		"title":       "Genesys configuration",
+		"description": "A Genesys configuration document of any kind",
+		"type":        "object",
+		"properties": map[string]interface{}{
+			"apiVersion": map[string]interface{}{"const": APIVersion},
+			"kind":       map[string]interface{}{"enum": KindNames()},
+		},
+		"allOf": conditions,
+		"$defs": definitions,
+	}
+}
+
+// kindSchema returns the schema of a kind, with its header fixed to the kind
+func kindSchema(kind Kind) map[string]interface{} {
+	// Only Config documents go through variable interpolation
+	schema := typeSchema(reflect.TypeOf(kind.New()), kind.Name == KindConfig)
+	schema["title"] = kind.Name
+	schema["description"] = kind.Description
+
+	properties := schema["properties"].(map[string]interface{})
+	properties["apiVersion"] = map[string]interface{}{"const": APIVersion}
+	properties["kind"] = map[string]interface{}{"const": kind.Name}
+	return schema
+}
+
+// typeSchema returns the JSON Schema of the YAML encoding of a Go type.
+// Structs allow only the fields they declare, as ReadDocuments does.
+func typeSchema(t reflect.Type, interpolated bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	scalar := func(typ string) map[string]interface{} {
+		if !interpolated {
+			return map[string]interface{}{"type": typ}
+		}
+		return map[string]interface{}{
+			"anyOf": []interface{}{map[string]interface{}{"type": typ}, interpolationSchema},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for name, field := range schemaFields(t) {
+			properties[name] = typeSchema(field, interpolated)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), interpolated),
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), interpolated),
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return scalar("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return scalar("integer")
+	case reflect.Float32, reflect.Float64:
+		return scalar("number")
+	}
+
+	// Any value, such as variable defaults and module inputs
+	return map[string]interface{}{}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	for _, name := range KindNames() {
+		schema, err := JSONSchema(name)
+		if err != nil {
+			t.Fatalf("JSONSchema(%s) error = %v", name, err)
+		}
+		if _, err := json.Marshal(schema); err != nil {
+			t.Errorf("JSONSchema(%s) does not encode: %v", name, err)
+		}
+		properties := schema["properties"].(map[string]interface{})
+		if kind := properties["kind"].(map[string]interface{}); kind["const"] != name {
+			t.Errorf("JSONSchema(%s) kind = %v", name, kind)
+		}
+	}
+
+	schema, _ := JSONSchema(KindConfig)
+	storage := schema["properties"].(map[string]interface{})["resources"].(map[string]interface{})["properties"].(map[string]interface{})["storage"].(map[string]interface{})
+	bucket := storage["items"].(map[string]interface{})
+	if bucket["additionalProperties"] != false {
+		t.Errorf("storage items allow unknown fields: %v", bucket)
+	}
+	versioning := bucket["properties"].(map[string]interface{})["versioning"].(map[string]interface{})
+	if _, ok := versioning["anyOf"]; !ok {
+		t.Errorf("versioning = %v, want booleans or ${var.name}", versioning)
+	}
+
+	schema, _ = JSONSchema(KindS3Bucket)
+	encryption := schema["properties"].(map[string]interface{})["resources"].(map[string]interface{})["properties"].(map[string]interface{})["storage"].(map[string]interface{})["items"].(map[string]interface{})["properties"].(map[string]interface{})["encryption"].(map[string]interface{})
+	if encryption["type"] != "boolean" {
+		t.Errorf("S3Bucket encryption = %v, want a plain boolean", encryption)
+	}
+
+	if _, err := JSONSchema("Bucket"); err == nil {
+		t.Error("JSONSchema(Bucket) error = nil, want an unknown kind error")
+	}
+
+	combined := CombinedJSONSchema()
+	if definitions := combined["$defs"].(map[string]interface{}); len(definitions) != len(KindNames()) {
+		t.Errorf("combined schema defines %d kinds, want %d", len(definitions), len(KindNames()))
+	}
+}
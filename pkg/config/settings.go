@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectSettings are the settings shared by every configuration of a
+// project, whichever file or directory a command names. They are recorded
+// from the first configuration that declares them.
+type ProjectSettings struct {
+	Provider string      `yaml:"provider"`
+	Region   string      `yaml:"region,omitempty"`
+	State    StateConfig `yaml:"state"`
+}
+
+// LoadProjectSettings reads project settings from path, returning nil when
+// the file does not exist
+func LoadProjectSettings(path string) (*ProjectSettings, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project settings: %w", err)
+	}
+
+	var settings ProjectSettings
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse project settings %s: %w", path, err)
+	}
+	return &settings, nil
+}
+
+// SaveProjectSettings writes project settings to path
+func SaveProjectSettings(path string, settings *ProjectSettings) error {
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project settings: %w", err)
+	}
+
+	header := "# State settings of this project, used by every genesys command run in it.\n" +
+		"# Edit them to move the project's state; configurations must agree with them.\n"
+	if err := os.WriteFile(path, append([]byte(header), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write project settings: %w", err)
+	}
+	return nil
+}
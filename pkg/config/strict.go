@@ -0,0 +1,304 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError reports a field that the schema of a document does not declare,
+// such as a misspelt key
+type FieldError struct {
+	File       string
+	Line       int    // 0 when the line is not known
+	Field      string // path of the field, such as resources.storage[0].versoning
+	Suggestion string // the declared field the key is closest to, if any
+}
+
+func (e *FieldError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	return fmt.Sprintf("%s: %s", location, e.message())
+}
+
+// message describes the error without its location
+func (e *FieldError) message() string {
+	msg := "unknown field " + e.Field
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %s?)", e.Suggestion)
+	}
+	return msg
+}
+
+// FieldErrors returns the FieldErrors an error is made of
+func FieldErrors(err error) []*FieldError {
+	switch e := err.(type) {
+	case *FieldError:
+		return []*FieldError{e}
+	case interface{ Unwrap() []error }:
+		var fieldErrors []*FieldError
+		for _, err := range e.Unwrap() {
+			fieldErrors = append(fieldErrors, FieldErrors(err)...)
+		}
+		return fieldErrors
+	case interface{ Unwrap() error }:
+		return FieldErrors(e.Unwrap())
+	}
+	return nil
+}
+
+// CheckFields returns the fields of the document that its kind's schema does
+// not declare
+func (d *Document) CheckFields() []*FieldError {
+	kind, ok := LookupKind(d.Kind)
+	if !ok {
+		return nil
+	}
+	return d.checkFields(reflect.TypeOf(kind.New()))
+}
+
+// checkFields returns the fields of the document that schema does not declare
+func (d *Document) checkFields(schema reflect.Type) []*FieldError {
+	var fieldErrors []*FieldError
+	unknownFields(d.tree, schema, nil, func(path []string, suggestion string) {
+		fieldErrors = append(fieldErrors, &FieldError{
+			File:       d.Path,
+			Line:       d.line(path),
+			Field:      fieldPath(path),
+			Suggestion: suggestion,
+		})
+	})
+	return fieldErrors
+}
+
+// strictError joins field errors into one error in file order, or returns nil
+func strictError(fieldErrors []*FieldError) error {
+	sort.SliceStable(fieldErrors, func(i, j int) bool {
+		if fieldErrors[i].File != fieldErrors[j].File {
+			return fieldErrors[i].File < fieldErrors[j].File
+		}
+		return fieldErrors[i].Line < fieldErrors[j].Line
+	})
+	errs := make([]error, len(fieldErrors))
+	for i, err := range fieldErrors {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
+
+// unknownFields walks a configuration tree along a Go type and reports the
+// keys of maps decoded into structs that the struct has no field for
+func unknownFields(value interface{}, t reflect.Type, path []string, report func(path []string, suggestion string)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := schemaFields(t)
+		for _, key := range sortedNames(m) {
+			field, ok := fields[key]
+			if !ok {
+				report(append(append([]string(nil), path...), key), closestField(key, fields))
+				continue
+			}
+			unknownFields(m[key], field, append(path, key), report)
+		}
+
+	case reflect.Map:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, key := range sortedNames(m) {
+			unknownFields(m[key], t.Elem(), append(path, key), report)
+		}
+
+	case reflect.Slice, reflect.Array:
+		list, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range list {
+			unknownFields(item, t.Elem(), append(path, strconv.Itoa(i)), report)
+		}
+	}
+}
+
+// schemaFields returns the types of a struct's fields by their YAML name,
+// including the fields of inline structs
+func schemaFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if options == "inline" || (field.Anonymous && name == "") {
+			for name, inline := range schemaFields(field.Type) {
+				fields[name] = inline
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// closestField returns the declared field a key is most likely a misspelling
+// of, or "" when none is close
+func closestField(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", len(key)/2+1
+	for _, name := range sortedNames(fields) {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// fieldPath formats a tree path as resources.storage[0].name
+func fieldPath(path []string) string {
+	var b strings.Builder
+	for _, element := range path {
+		if _, err := strconv.Atoi(element); err == nil {
+			b.WriteString("[" + element + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(element)
+	}
+	return b.String()
+}
+
+// line returns the line of the key at path in the document's file, or 0
+func (d *Document) line(path []string) int {
+	if d.node != nil {
+		return yamlLine(d.node, path)
+	}
+	return tomlLine(d.data, path)
+}
+
+// yamlLine follows a path through YAML nodes to the line of its last key
+func yamlLine(node *yaml.Node, path []string) int {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := 0
+	for _, element := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			found := false
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == element {
+					line = node.Content[i].Line
+					node = node.Content[i+1]
+					found = true
+					break
+				}
+			}
+			if !found {
+				return line
+			}
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(element)
+			if err != nil || i >= len(node.Content) {
+				return line
+			}
+			node = node.Content[i]
+			line = node.Line
+		default:
+			return line
+		}
+	}
+	return line
+}
+
+var tomlTablePattern = regexp.MustCompile(`^\s*\[\[?\s*([^\]]+?)\s*\]\]?`)
+
+// tomlLine finds the line of the key at path in TOML text. TOML decoders do
+// not report positions, so the key is looked up under the table holding it,
+// counting arrays of tables to find the entry; keys of inline tables are
+// matched by name.
+func tomlLine(data string, path []string) int {
+	if len(path) == 0 {
+		return 0
+	}
+	key := path[len(path)-1]
+	var tables []string
+	entry := -1
+	for _, element := range path[:len(path)-1] {
+		if i, err := strconv.Atoi(element); err == nil {
+			entry = i
+			continue
+		}
+		tables = append(tables, element)
+	}
+	table := strings.Join(tables, ".")
+	full := strings.Join(append(tables, key), ".")
+	keyPattern := regexp.MustCompile(`^\s*"?` + regexp.QuoteMeta(key) + `"?\s*=`)
+	inlinePattern := regexp.MustCompile(`[{,]\s*"?` + regexp.QuoteMeta(key) + `"?\s*=`)
+
+	current, occurrence, fallback := "", -1, 0
+	for i, line := range strings.Split(data, "\n") {
+		if match := tomlTablePattern.FindStringSubmatch(line); match != nil {
+			current = match[1]
+			if current == full {
+				// The unknown key names a table
+				return i + 1
+			}
+			if current == table {
+				occurrence++
+			}
+			continue
+		}
+		if current == table && (entry < 0 || occurrence == entry) && keyPattern.MatchString(line) {
+			return i + 1
+		}
+		if fallback == 0 && (keyPattern.MatchString(line) || inlinePattern.MatchString(line)) {
+			fallback = i + 1
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadDocumentsUnknownFields(t *testing.T) {
+	defer func(options LoadOptions) { Options = options }(Options)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{
+			name: "yaml",
+			file: "app.yaml",
+			content: `kind: Config
+provider: aws
+regoin: us-east-1
+resources:
+  storage:
+    - name: a
+      type: bucket
+    - name: b
+      verisoning: true
+      lifecycle:
+        delete_after: 3
+`,
+			want: []string{
+				"app.yaml:3: unknown field regoin (did you mean region?)",
+				"app.yaml:9: unknown field resources.storage[1].verisoning (did you mean versioning?)",
+				"app.yaml:11: unknown field resources.storage[1].lifecycle.delete_after (did you mean delete_after_days?)",
+			},
+		},
+		{
+			name: "second document",
+			file: "multi.yaml",
+			content: `kind: Config
+provider: aws
+---
+kind: S3Bucket
+provider: aws
+resources:
+  storage:
+    - name: c
+      encrypt: true
+`,
+			want: []string{"multi.yaml:9: unknown field resources.storage[0].encrypt (did you mean encryption?)"},
+		},
+		{
+			name: "toml",
+			file: "function.toml",
+			content: `[metadata]
+name = "fn"
+handlr = "main.handler"
+
+[build]
+source_path = "."
+
+[function]
+memory_mb = 128
+
+[deployment]
+auth_type = "NONE"
+
+[[triggers]]
+type = "http"
+
+[[triggers]]
+type = "http"
+methd = "GET"
+
+[iam_role]
+role_name = "x"
+`,
+			want: []string{
+				"function.toml:3: unknown field metadata.handlr (did you mean handler?)",
+				"function.toml:19: unknown field triggers[1].methd (did you mean method?)",
+				"function.toml:21: unknown field iam_role",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, dir, tt.file, tt.content)
+			_, err := ReadDocuments(path)
+			fieldErrors := FieldErrors(err)
+			if len(fieldErrors) != len(tt.want) {
+				t.Fatalf("ReadDocuments() error = %v, want %d unknown fields", err, len(tt.want))
+			}
+			for i, fieldErr := range fieldErrors {
+				if got := strings.TrimPrefix(fieldErr.Error(), dir+"/"); got != tt.want[i] {
+					t.Errorf("error %d = %s, want %s", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	// Overlays and modules are checked too
+	path := writeConfig(t, dir, "base.yaml", "provider: aws\nuse:\n  - name: m\n    source: ./module.yaml\n")
+	writeConfig(t, dir, "base.prod.yaml", "region: us-east-1\nresources:\n  compute:\n    - name: web\n      size: large\n")
+	writeConfig(t, dir, "module.yaml", "resources:\n  storage:\n    - name: data\n")
+	Options = LoadOptions{Environment: "prod"}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "base.prod.yaml:5: unknown field resources.compute[0].size") {
+		t.Errorf("LoadConfig() error = %v, want the overlay's unknown field", err)
+	}
+
+	Options = LoadOptions{}
+	writeConfig(t, dir, "module.yaml", "resources:\n  storage:\n    - name: data\n      tag:\n        a: b\n")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "module m: ") || !strings.Contains(err.Error(), "module.yaml:4: unknown field resources.storage[0].tag (did you mean tags?)") {
+		t.Errorf("LoadConfig() error = %v, want the module's unknown field", err)
+	}
+}
@@ -28,7 +28,7 @@ func ValidateConfig(config *Config) error {
 
 // validateProvider validates provider-specific configuration
 func validateProvider(config *Config) error {
-	validProviders := []string{"aws", "gcp", "azure", "alibaba", "tencent"}
+	validProviders := []string{"aws", "gcp", "azure", "alibaba", "tencent", "mock"}
 	
 	for _, p := range validProviders {
 		if config.Provider == p {
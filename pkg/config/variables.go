@@ -0,0 +1,351 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VariableEnvPrefix prefixes environment variables that set input variables:
+// GENESYS_VAR_region sets the variable region
+const VariableEnvPrefix = "GENESYS_VAR_"
+
+// Variable types
+const (
+	VariableString = "string"
+	VariableNumber = "number"
+	VariableBool   = "bool"
+	VariableList   = "list"
+	VariableMap    = "map"
+)
+
+// Variable declares an input variable that configuration values refer to
+// with ${var.name}
+type Variable struct {
+	Type        string      `yaml:"type,omitempty" toml:"type,omitempty"` // string|number|bool|list|map
+	Default     interface{} `yaml:"default,omitempty" toml:"default,omitempty"`
+	Description string      `yaml:"description,omitempty" toml:"description,omitempty"`
+}
+
+// LoadOptions selects the overlay and the variable values LoadConfig applies
+type LoadOptions struct {
+	// Environment names the overlay merged over the configuration, so that
+	// app.yaml is loaded together with app.prod.yaml for "prod"
+	Environment string
+	// VarFiles are YAML, TOML or JSON files of variable values, applied in order
+	VarFiles []string
+	// Vars are name=value assignments, taking precedence over VarFiles
+	Vars []string
+}
+
+// Options is used by LoadConfig. Commands set it from the global --env, --var
+// and --var-file flags.
+var Options LoadOptions
+
+var interpolationPattern = regexp.MustCompile(`\$\{(var|env)\.([^}]*)\}`)
+
+// resolveVariables works out the value of every declared variable from, in
+// increasing precedence, its default, GENESYS_VAR_<name>, the var files and
+// the --var assignments
+func resolveVariables(tree map[string]interface{}, options LoadOptions) (map[string]interface{}, error) {
+	assigner, err := newVariableAssigner(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range sortedNames(assigner.declared) {
+		if value, ok := os.LookupEnv(VariableEnvPrefix + name); ok {
+			if err := assigner.set(name, value, VariableEnvPrefix+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, path := range options.VarFiles {
+		file, err := readTree(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read var file: %w", err)
+		}
+		for _, name := range sortedNames(file) {
+			if err := assigner.set(name, file[name], path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, assignment := range options.Vars {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --var %q: use name=value", assignment)
+		}
+		if err := assigner.set(name, value, "--var "+name); err != nil {
+			return nil, err
+		}
+	}
+
+	return assigner.result("set them with --var, --var-file or " + VariableEnvPrefix + "<name>")
+}
+
+// variableAssigner collects the values of the variables a configuration
+// declares, starting from their defaults
+type variableAssigner struct {
+	declared map[string]Variable
+	values   map[string]interface{}
+}
+
+func newVariableAssigner(tree map[string]interface{}) (*variableAssigner, error) {
+	declared, err := declaredVariables(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	assigner := &variableAssigner{declared: declared, values: make(map[string]interface{})}
+	for _, name := range sortedNames(declared) {
+		if declared[name].Default != nil {
+			if err := assigner.set(name, declared[name].Default, "default"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return assigner, nil
+}
+
+// set assigns a value from source to a declared variable
+func (a *variableAssigner) set(name string, value interface{}, source string) error {
+	variable, ok := a.declared[name]
+	if !ok {
+		return fmt.Errorf("%s sets undeclared variable %s", source, name)
+	}
+	typed, err := variable.convert(value)
+	if err != nil {
+		return fmt.Errorf("%s: variable %s %w", source, name, err)
+	}
+	a.values[name] = typed
+	return nil
+}
+
+// result returns the assigned values, or an error naming the variables
+// without a value and how to set them
+func (a *variableAssigner) result(hint string) (map[string]interface{}, error) {
+	var missing []string
+	for _, name := range sortedNames(a.declared) {
+		if _, ok := a.values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no value for variables %s: %s", strings.Join(missing, ", "), hint)
+	}
+	return a.values, nil
+}
+
+// declaredVariables reads the variables section of a configuration tree
+func declaredVariables(tree map[string]interface{}) (map[string]Variable, error) {
+	declared := make(map[string]Variable)
+	section, ok := tree["variables"]
+	if !ok {
+		return declared, nil
+	}
+
+	entries, ok := section.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("variables must map variable names to their declarations")
+	}
+	for name, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("variable %s must be declared with type, default and description fields", name)
+		}
+
+		var variable Variable
+		variable.Type, _ = fields["type"].(string)
+		variable.Description, _ = fields["description"].(string)
+		variable.Default = fields["default"]
+
+		switch variable.Type {
+		case "", VariableString, VariableNumber, VariableBool, VariableList, VariableMap:
+		default:
+			return nil, fmt.Errorf("variable %s has invalid type %q (valid: string, number, bool, list, map)", name, variable.Type)
+		}
+		declared[name] = variable
+	}
+	return declared, nil
+}
+
+// convert checks a value against the variable's type. Strings, as given on
+// the command line and in the environment, are parsed into the type.
+func (v Variable) convert(value interface{}) (interface{}, error) {
+	s, isString := value.(string)
+
+	switch v.Type {
+	case VariableString:
+		switch value.(type) {
+		case string, int, float64, bool:
+			return fmt.Sprint(value), nil
+		}
+
+	case VariableNumber:
+		switch n := value.(type) {
+		case int:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) {
+				return int(n), nil
+			}
+			return n, nil
+		}
+		if isString {
+			n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a number, got %q", s)
+			}
+			return v.convert(n)
+		}
+
+	case VariableBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		if isString {
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("must be true or false, got %q", s)
+			}
+			return b, nil
+		}
+
+	case VariableList:
+		if list, ok := value.([]interface{}); ok {
+			return list, nil
+		}
+		if isString {
+			var list []interface{}
+			if err := json.Unmarshal([]byte(s), &list); err != nil {
+				return nil, fmt.Errorf("must be a list, such as [\"a\", \"b\"], got %q", s)
+			}
+			return list, nil
+		}
+
+	case VariableMap:
+		if m, ok := value.(map[string]interface{}); ok {
+			return m, nil
+		}
+		if isString {
+			var m map[string]interface{}
+			if err := json.Unmarshal([]byte(s), &m); err != nil {
+				return nil, fmt.Errorf("must be a map, such as {\"key\": \"value\"}, got %q", s)
+			}
+			return m, nil
+		}
+
+	default:
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("must be a %s, got %v", v.Type, value)
+}
+
+// interpolate replaces ${var.name} and ${env.NAME} in the string values of a
+// configuration tree. A value that is a single ${var.name} takes the
+// variable's value with its type, so numbers, booleans, lists and maps can be
+// set from variables. Other ${...} expressions are left for the planner.
+func interpolate(value interface{}, variables map[string]interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedNames(v) {
+			if path == "" && key == "variables" {
+				continue
+			}
+			resolved, err := interpolate(v[key], variables, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := interpolate(item, variables, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+
+	case string:
+		if match := interpolationPattern.FindStringSubmatch(v); match != nil && match[0] == v && match[1] == "var" {
+			return lookupVariable(variables, match[2], path)
+		}
+
+		var err error
+		result := interpolationPattern.ReplaceAllStringFunc(v, func(expr string) string {
+			match := interpolationPattern.FindStringSubmatch(expr)
+			if match[1] == "env" {
+				value, ok := os.LookupEnv(match[2])
+				if !ok && err == nil {
+					err = fmt.Errorf("%s: environment variable %s is not set", path, match[2])
+				}
+				return value
+			}
+
+			value, lookupErr := lookupVariable(variables, match[2], path)
+			if lookupErr != nil {
+				if err == nil {
+					err = lookupErr
+				}
+				return expr
+			}
+			switch value.(type) {
+			case []interface{}, map[string]interface{}:
+				if err == nil {
+					err = fmt.Errorf("%s: %s variable %s cannot be embedded in a string", path, typeName(value), match[2])
+				}
+			}
+			return fmt.Sprint(value)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	return value, nil
+}
+
+func lookupVariable(variables map[string]interface{}, name, path string) (interface{}, error) {
+	value, ok := variables[name]
+	if !ok {
+		return nil, fmt.Errorf("%s refers to undeclared variable %s", path, name)
+	}
+	return value, nil
+}
+
+func typeName(value interface{}) string {
+	if _, ok := value.([]interface{}); ok {
+		return VariableList
+	}
+	return VariableMap
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedNames[V any](values map[string]V) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
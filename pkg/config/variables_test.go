@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const variablesConfig = `
+provider: aws
+region: ${var.region}
+variables:
+  region:
+    type: string
+    default: us-east-1
+  stage:
+    type: string
+  web_count:
+    type: number
+    default: 1
+  versioned:
+    type: bool
+    default: true
+resources:
+  storage:
+    - name: assets-${var.stage}
+      type: bucket
+      versioning: ${var.versioned}
+      tags:
+        owner: ${env.GENESYS_TEST_OWNER}
+  compute:
+    - name: web
+      type: small
+      count: ${var.web_count}
+      subnet: ${network.main.subnets.public-a.id}
+`
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadConfigVariables(t *testing.T) {
+	defer func(options LoadOptions) { Options = options }(Options)
+	t.Setenv("GENESYS_TEST_OWNER", "platform")
+	t.Setenv(VariableEnvPrefix+"region", "eu-west-1")
+
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "app.yaml", variablesConfig)
+	varFile := writeConfig(t, dir, "prod.json", `{"stage": "prod", "web_count": 2}`)
+
+	Options = LoadOptions{VarFiles: []string{varFile}, Vars: []string{"web_count=3"}}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if cfg.Region != "eu-west-1" {
+		t.Errorf("Region = %s, want eu-west-1 from %sregion", cfg.Region, VariableEnvPrefix)
+	}
+	storage := cfg.Resources.Storage[0]
+	if storage.Name != "assets-prod" || storage.Tags["owner"] != "platform" || !storage.Versioning {
+		t.Errorf("storage = %+v, want assets-prod owned by platform", storage)
+	}
+	compute := cfg.Resources.Compute[0]
+	if compute.Count != 3 {
+		t.Errorf("Count = %d, want 3 from --var", compute.Count)
+	}
+	if compute.Subnet != "${network.main.subnets.public-a.id}" {
+		t.Errorf("Subnet = %s, want the reference left for the planner", compute.Subnet)
+	}
+
+	tests := []struct {
+		name    string
+		options LoadOptions
+		want    string
+	}{
+		{"missing value", LoadOptions{}, "no value for variables stage"},
+		{"wrong type", LoadOptions{Vars: []string{"stage=a", "web_count=many"}}, "must be a number"},
+		{"undeclared", LoadOptions{Vars: []string{"stage=a", "size=large"}}, "undeclared variable size"},
+		{"malformed", LoadOptions{Vars: []string{"stage"}}, "use name=value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Options = tt.options
+			if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	os.Unsetenv("GENESYS_TEST_OWNER")
+	Options = LoadOptions{Vars: []string{"stage=a"}}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "GENESYS_TEST_OWNER is not set") {
+		t.Errorf("LoadConfig() error = %v, want an unset environment variable error", err)
+	}
+}
+
+func TestLoadConfigOverlay(t *testing.T) {
+	defer func(options LoadOptions) { Options = options }(Options)
+
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "app.toml", `
+provider = "aws"
+region = "us-east-1"
+
+[[resources.compute]]
+name = "web"
+type = "small"
+count = 1
+
+[[resources.compute]]
+name = "worker"
+type = "small"
+
+[resources.compute.tags]
+team = "jobs"
+`)
+	writeConfig(t, dir, "app.prod.toml", `
+region = "us-west-2"
+
+[[resources.compute]]
+name = "web"
+type = "large"
+count = 4
+
+[[resources.compute]]
+name = "cron"
+type = "small"
+`)
+
+	Options = LoadOptions{Environment: "prod"}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if cfg.Region != "us-west-2" {
+		t.Errorf("Region = %s, want us-west-2", cfg.Region)
+	}
+	compute := make(map[string]ComputeResource)
+	for _, c := range cfg.Resources.Compute {
+		compute[c.Name] = c
+	}
+	if len(compute) != 3 {
+		t.Fatalf("compute resources = %v, want web, worker and cron", cfg.Resources.Compute)
+	}
+	if web := compute["web"]; web.Type != "large" || web.Count != 4 {
+		t.Errorf("web = %+v, want large with count 4", web)
+	}
+	if worker := compute["worker"]; worker.Type != "small" || worker.Tags["team"] != "jobs" {
+		t.Errorf("worker = %+v, want it unchanged", worker)
+	}
+
+	Options = LoadOptions{Environment: "staging"}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "app.staging.toml") {
+		t.Errorf("LoadConfig() error = %v, want a missing overlay error", err)
+	}
+}
@@ -0,0 +1,325 @@
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+// Status is the outcome of checking a single resource
+type Status string
+
+const (
+	// StatusInSync means the live resource matches its configuration
+	StatusInSync Status = "in-sync"
+	// StatusDrifted means the live resource was changed outside Genesys
+	StatusDrifted Status = "drifted"
+	// StatusMissing means the resource is recorded in state but no longer exists
+	StatusMissing Status = "missing"
+	// StatusUnmanaged means the resource exists but is not recorded in state
+	StatusUnmanaged Status = "unmanaged"
+	// StatusUnchecked means the provider cannot read the resource back
+	StatusUnchecked Status = "unchecked"
+	// StatusError means the resource could not be read
+	StatusError Status = "error"
+)
+
+// Resource is the drift status of a single resource. For drifted resources
+// each change runs from the live value (Old) to the configured value (New).
+type Resource struct {
+	Type       string                `json:"type"`
+	Name       string                `json:"name"`
+	ID         string                `json:"id"`
+	ConfigFile string                `json:"config_file,omitempty"`
+	Status     Status                `json:"status"`
+	Changes    []planner.FieldChange `json:"changes,omitempty"`
+	Message    string                `json:"message,omitempty"`
+}
+
+// Report is the result of a drift check
+type Report struct {
+	Provider  string     `json:"provider"`
+	Region    string     `json:"region"`
+	CheckedAt time.Time  `json:"checked_at"`
+	Resources []Resource `json:"resources"`
+}
+
+// DesiredFunc returns the step the originating configuration declares for a
+// recorded resource. It returns nil when the configuration does not declare
+// the resource or cannot be read, in which case the attributes recorded in
+// state are used instead.
+type DesiredFunc func(record state.ResourceRecord) (*planner.PlanStep, error)
+
+// Detector compares resources recorded in state with their live counterparts
+type Detector struct {
+	provider provider.Provider
+	planner  *planner.Planner
+	desired  DesiredFunc
+
+	// Unmanaged enables discovery of resources that are not recorded in
+	// state. It lists every resource in the account, so it is off by default.
+	Unmanaged bool
+}
+
+// NewDetector creates a drift detector. desired may be nil.
+func NewDetector(p provider.Provider, desired DesiredFunc) *Detector {
+	return &Detector{
+		provider: p,
+		planner:  planner.New(p),
+		desired:  desired,
+	}
+}
+
+// Detect checks every record and, when enabled, looks for unmanaged resources
+func (d *Detector) Detect(ctx context.Context, records []state.ResourceRecord) (*Report, error) {
+	report := &Report{
+		Provider:  d.provider.Name(),
+		Region:    d.provider.Region(),
+		CheckedAt: time.Now(),
+	}
+
+	for _, record := range records {
+		report.Resources = append(report.Resources, d.check(ctx, record))
+	}
+
+	if d.Unmanaged {
+		unmanaged, err := d.discoverUnmanaged(ctx, records)
+		if err != nil {
+			return nil, err
+		}
+		report.Resources = append(report.Resources, unmanaged...)
+	}
+
+	return report, nil
+}
+
+// check compares a single record with the live resource
+func (d *Detector) check(ctx context.Context, record state.ResourceRecord) Resource {
+	result := Resource{
+		Type:       record.Type,
+		Name:       record.Name,
+		ID:         record.ID,
+		ConfigFile: record.ConfigFile,
+	}
+
+	resource, ok := planner.ResourceForState(record.Type)
+	if !ok || !planner.HasLiveLookup(resource) {
+		result.Status = StatusUnchecked
+		result.Message = fmt.Sprintf("%s resources cannot be read back from the provider", record.Type)
+		return result
+	}
+
+	step, err := d.desiredStep(record, resource)
+	if err != nil {
+		result.Status = StatusError
+		result.Message = err.Error()
+		return result
+	}
+
+	exists, err := d.planner.DiffExisting(ctx, step, record.ID)
+	switch {
+	case err != nil:
+		result.Status = StatusError
+		result.Message = err.Error()
+	case !exists:
+		result.Status = StatusMissing
+		result.Message = "recorded in state but no longer exists"
+	case len(step.Changes) > 0:
+		result.Status = StatusDrifted
+		result.Changes = step.Changes
+	default:
+		result.Status = StatusInSync
+	}
+
+	return result
+}
+
+// desiredStep returns the step the record is compared against, falling back
+// to the attributes and tags recorded in state
+func (d *Detector) desiredStep(record state.ResourceRecord, resource string) (*planner.PlanStep, error) {
+	if d.desired != nil {
+		step, err := d.desired(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configuration %s: %w", record.ConfigFile, err)
+		}
+		if step != nil {
+			return step, nil
+		}
+	}
+
+	properties := make(map[string]string)
+	for key, value := range record.Attributes {
+		properties[key] = value
+	}
+
+	return &planner.PlanStep{
+		ID:         record.ID,
+		Action:     planner.ActionCreate,
+		Resource:   resource,
+		Target:     record.Name,
+		Properties: properties,
+		Tags:       record.Tags,
+	}, nil
+}
+
+// discoverUnmanaged lists the resources the provider knows about that are
+// not recorded in state
+func (d *Detector) discoverUnmanaged(ctx context.Context, records []state.ResourceRecord) ([]Resource, error) {
+	managed := make(map[string]bool)
+	for _, record := range records {
+		managed[record.Type+"/"+record.ID] = true
+		managed[record.Type+"/"+record.Name] = true
+	}
+
+	var found []Resource
+	add := func(stateType, id, name string) {
+		if managed[stateType+"/"+id] || managed[stateType+"/"+name] {
+			return
+		}
+		found = append(found, Resource{
+			Type:   stateType,
+			Name:   name,
+			ID:     id,
+			Status: StatusUnmanaged,
+		})
+	}
+
+	buckets, err := d.provider.Storage().DiscoverBuckets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover buckets: %w", err)
+	}
+	for _, bucket := range buckets {
+		add("s3", bucket.Name, bucket.Name)
+	}
+
+	instances, err := d.provider.Compute().DiscoverInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover instances: %w", err)
+	}
+	for _, instance := range instances {
+		if instance.State == "terminated" {
+			continue
+		}
+		add("ec2", instance.ID, instance.Name)
+	}
+
+	databases, err := d.provider.Database().DiscoverDatabases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover databases: %w", err)
+	}
+	for _, database := range databases {
+		add("rds", database.ID, database.Name)
+	}
+
+	functions, err := d.provider.Serverless().DiscoverFunctions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover functions: %w", err)
+	}
+	for _, function := range functions {
+		add("lambda", function.Name, function.Name)
+	}
+
+	networks, err := d.provider.Network().DiscoverNetworks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover networks: %w", err)
+	}
+	for _, network := range networks {
+		add("vpc", network.ID, network.Name)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Type != found[j].Type {
+			return found[i].Type < found[j].Type
+		}
+		return found[i].ID < found[j].ID
+	})
+	return found, nil
+}
+
+// Count returns the number of resources with the given status
+func (r *Report) Count(status Status) int {
+	count := 0
+	for _, resource := range r.Resources {
+		if resource.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// HasDrift reports whether any resource is drifted, missing or could not be
+// read. Unmanaged resources are reported but are not drift of the resources
+// Genesys manages.
+func (r *Report) HasDrift() bool {
+	return r.Count(StatusDrifted)+r.Count(StatusMissing)+r.Count(StatusError) > 0
+}
+
+// Summary returns a one-line count of the resources by status
+func (r *Report) Summary() string {
+	return fmt.Sprintf("%d in sync, %d drifted, %d missing, %d unmanaged, %d unchecked, %d failed",
+		r.Count(StatusInSync), r.Count(StatusDrifted), r.Count(StatusMissing),
+		r.Count(StatusUnmanaged), r.Count(StatusUnchecked), r.Count(StatusError))
+}
+
+// ToHumanReadable converts the report to a human-readable format
+func (r *Report) ToHumanReadable() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Drift report for %s (%s)\n", r.Provider, r.Region))
+	sb.WriteString("========================\n\n")
+
+	if len(r.Resources) == 0 {
+		sb.WriteString("No resources recorded in state.\n")
+		return sb.String()
+	}
+
+	for _, resource := range r.Resources {
+		sb.WriteString(fmt.Sprintf("%s %s %s (%s): %s\n", statusSymbol(resource.Status), resource.Type, resource.Name, resource.ID, resource.Status))
+		for _, change := range resource.Changes {
+			sb.WriteString(fmt.Sprintf("     %s: found %s, configured %s\n", change.Field, valueOrNone(change.Old), valueOrNone(change.New)))
+		}
+		if resource.Message != "" && resource.Status != StatusInSync {
+			sb.WriteString(fmt.Sprintf("     %s\n", resource.Message))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%s\n", r.Summary()))
+	return sb.String()
+}
+
+// ToJSON converts the report to JSON
+func (r *Report) ToJSON() string {
+	data, _ := json.MarshalIndent(r, "", "  ")
+	return string(data)
+}
+
+func statusSymbol(status Status) string {
+	switch status {
+	case StatusInSync:
+		return "✓"
+	case StatusDrifted:
+		return "~"
+	case StatusMissing:
+		return "-"
+	case StatusUnmanaged:
+		return "+"
+	case StatusUnchecked:
+		return "?"
+	default:
+		return "✗"
+	}
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
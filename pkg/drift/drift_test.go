@@ -0,0 +1,152 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+// driftProvider is a mock provider whose buckets are looked up in a map
+type driftProvider struct {
+	provider.Provider
+	buckets map[string]*provider.Bucket
+}
+
+func (p *driftProvider) Storage() provider.StorageService {
+	return &driftStorage{StorageService: p.Provider.Storage(), buckets: p.buckets}
+}
+
+type driftStorage struct {
+	provider.StorageService
+	buckets map[string]*provider.Bucket
+}
+
+func (s *driftStorage) GetBucket(ctx context.Context, name string) (*provider.Bucket, error) {
+	if bucket, ok := s.buckets[name]; ok {
+		return bucket, nil
+	}
+	return nil, fmt.Errorf("bucket %s not found", name)
+}
+
+func TestDetect(t *testing.T) {
+	p := &driftProvider{
+		Provider: provider.NewMockProvider("mock", "us-east-1"),
+		buckets: map[string]*provider.Bucket{
+			"logs":    {Name: "logs", Versioning: true, Encryption: true, Tags: map[string]string{"Team": "ops"}},
+			"assets":  {Name: "assets", Versioning: false, Encryption: true},
+			"configs": {Name: "configs", Versioning: true, Encryption: false},
+		},
+	}
+
+	records := []state.ResourceRecord{
+		{ID: "logs", Name: "logs", Type: "s3", ConfigFile: "app.yaml",
+			Attributes: map[string]string{"versioning": "true"}, Tags: map[string]string{"Team": "ops"}},
+		{ID: "assets", Name: "assets", Type: "s3", ConfigFile: "app.yaml",
+			Attributes: map[string]string{"versioning": "true", "encryption": "true"}},
+		{ID: "configs", Name: "configs", Type: "s3", ConfigFile: "app.yaml"},
+		{ID: "gone", Name: "gone", Type: "s3", ConfigFile: "app.yaml"},
+		{ID: "subnet-1", Name: "private", Type: "subnet", ConfigFile: "app.yaml"},
+	}
+
+	// The configuration declares encryption for configs, overriding state
+	desired := func(record state.ResourceRecord) (*planner.PlanStep, error) {
+		if record.Name != "configs" {
+			return nil, nil
+		}
+		return &planner.PlanStep{
+			Action:     planner.ActionCreate,
+			Resource:   "s3-bucket",
+			Target:     "configs",
+			Properties: map[string]string{"encryption": "true"},
+		}, nil
+	}
+
+	detector := NewDetector(p, desired)
+	detector.Unmanaged = true
+	report, err := detector.Detect(context.Background(), records)
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+
+	resources := make(map[string]Resource)
+	for _, resource := range report.Resources {
+		resources[resource.ID] = resource
+	}
+
+	tests := []struct {
+		id      string
+		status  Status
+		changes string
+	}{
+		{"logs", StatusInSync, ""},
+		{"assets", StatusDrifted, "versioning: false → true"},
+		{"configs", StatusDrifted, "encryption: false → true"},
+		{"gone", StatusMissing, ""},
+		{"subnet-1", StatusUnchecked, ""},
+		{"existing-bucket", StatusUnmanaged, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			resource, ok := resources[tt.id]
+			if !ok {
+				t.Fatalf("missing resource %s", tt.id)
+			}
+			if resource.Status != tt.status {
+				t.Errorf("status = %s, want %s", resource.Status, tt.status)
+			}
+
+			var changes []string
+			for _, change := range resource.Changes {
+				changes = append(changes, change.String())
+			}
+			if got := strings.Join(changes, "; "); got != tt.changes {
+				t.Errorf("changes = %q, want %q", got, tt.changes)
+			}
+		})
+	}
+
+	if !report.HasDrift() {
+		t.Error("HasDrift() = false, want true")
+	}
+
+	output := report.ToHumanReadable()
+	for _, want := range []string{"versioning: found false, configured true", "2 drifted, 1 missing"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("ToHumanReadable() is missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestDetectInSync(t *testing.T) {
+	p := &driftProvider{
+		Provider: provider.NewMockProvider("mock", "us-east-1"),
+		buckets:  map[string]*provider.Bucket{"logs": {Name: "logs", Versioning: true}},
+	}
+
+	records := []state.ResourceRecord{
+		{ID: "logs", Name: "logs", Type: "s3", Attributes: map[string]string{"versioning": "true"}},
+	}
+
+	// Unmanaged resources are only looked for on request, and are not drift
+	for _, unmanaged := range []bool{false, true} {
+		detector := NewDetector(p, nil)
+		detector.Unmanaged = unmanaged
+
+		report, err := detector.Detect(context.Background(), records)
+		if err != nil {
+			t.Fatalf("Detect() error = %v", err)
+		}
+		if got := report.Count(StatusUnmanaged) > 0; got != unmanaged {
+			t.Errorf("Unmanaged = %v reported %d unmanaged resources", unmanaged, report.Count(StatusUnmanaged))
+		}
+		if report.HasDrift() {
+			t.Errorf("HasDrift() = true, want false:\n%s", report.ToHumanReadable())
+		}
+	}
+}
@@ -0,0 +1,487 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+// Handler performs a single plan step against the provider
+type Handler func(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error)
+
+// Outcome describes what a handler did for a step
+type Outcome struct {
+	ResourceID   string            // Provider identifier of the affected resource
+	ResourceName string            // Human readable name of the affected resource
+	StateType    string            // Type recorded in local state ("s3", "ec2", ...); empty means not recorded
+	Message      string            // Short description of what happened
+	Outputs      map[string]string // Values later steps may depend on (e.g. network_id)
+	Tags         map[string]string
+
+	// Undo removes the resource again if the run is rolled back; nil means the
+	// resource cannot be removed automatically
+	Undo UndoFunc
+}
+
+// Executor applies plans by mapping each step onto provider services
+type Executor struct {
+	// State receives a record for every resource created or imported.
+	// When nil, nothing is recorded.
+	State *state.LocalState
+
+	// Source is stored as the ConfigFile of recorded resources
+	Source string
+
+	// Parallelism is the maximum number of steps run at the same time;
+	// zero means DefaultParallelism
+	Parallelism int
+
+	// AllowReplace lets plans replace stateful resources such as databases,
+	// deleting their data. Check rejects such plans otherwise.
+	AllowReplace bool
+
+	provider provider.Provider
+	handlers map[handlerKey]Handler
+	journal  *Journal
+
+	mu       sync.RWMutex
+	outcomes map[string]*Outcome
+	stateMu  sync.Mutex
+}
+
+// handlerKey identifies a handler; an empty action matches every action that
+// has no handler of its own, except updates and deletes
+type handlerKey struct {
+	action   string
+	resource string
+}
+
+// existingOutputs names the output that carries the identifier of an existing
+// resource, so that dependents of unchanged steps still get their inputs
+var existingOutputs = map[string]string{
+	"s3-bucket":       "bucket_name",
+	"vpc":             "network_id",
+	"subnet":          "subnet_id",
+	"security-group":  "security_group_id",
+	"instance":        "instance_id",
+	"lambda-function": "function_name",
+	"rds-instance":    "database_id",
+}
+
+// New creates a new executor with the default handlers registered
+func New(p provider.Provider) *Executor {
+	e := &Executor{
+		provider: p,
+		handlers: make(map[handlerKey]Handler),
+		outcomes: make(map[string]*Outcome),
+		journal:  NewJournal(),
+	}
+	registerDefaultHandlers(e)
+	return e
+}
+
+// Provider returns the provider the executor applies steps against
+func (e *Executor) Provider() provider.Provider {
+	return e.provider
+}
+
+// RegisterHandler sets the handler used for steps on the given resource type,
+// replacing any existing handler
+func (e *Executor) RegisterHandler(resource string, h Handler) {
+	e.handlers[handlerKey{resource: resource}] = h
+}
+
+// RegisterActionHandler sets the handler used for steps with the given action
+// on the given resource type. Update and delete steps are only run by handlers
+// registered this way; replace steps run the delete handler, then the create one.
+func (e *Executor) RegisterActionHandler(action, resource string, h Handler) {
+	e.handlers[handlerKey{action: action, resource: resource}] = h
+}
+
+// Journal returns the resources created by the executor so far
+func (e *Executor) Journal() *Journal {
+	return e.journal
+}
+
+// Rollback undoes the resources created by the executor in reverse order,
+// removing them from state as they are undone
+func (e *Executor) Rollback(ctx context.Context) *RollbackReport {
+	return e.journal.Rollback(ctx)
+}
+
+// Output returns an output value produced by one of the step's dependencies
+func (e *Executor) Output(step planner.PlanStep, key string) (string, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, dep := range step.DependsOn {
+		if outcome, ok := e.outcomes[dep]; ok {
+			if value, ok := outcome.Outputs[key]; ok {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Outcome returns the recorded outcome of a finished step
+func (e *Executor) Outcome(stepID string) (*Outcome, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	outcome, ok := e.outcomes[stepID]
+	return outcome, ok
+}
+
+// Execute runs the steps of the plan, starting each one once its dependencies
+// have succeeded and running up to Parallelism steps at a time. Step failures are
+// reported in the result; an error is only returned when the plan itself is invalid.
+func (e *Executor) Execute(ctx context.Context, plan *planner.Plan) (*Result, error) {
+	graph, err := NewGraph(plan.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	parallelism := e.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+
+	result := &Result{
+		PlanID:      plan.ID,
+		Parallelism: parallelism,
+		StartedAt:   time.Now(),
+	}
+	result.Steps = newScheduler(e, graph, parallelism).run(ctx)
+	result.FinishedAt = time.Now()
+
+	return result, nil
+}
+
+// Check reports the steps of a plan the executor has no handler for, optional
+// or not, and replacements of stateful resources that were not allowed, so
+// that a plan it cannot carry out is rejected before anything is applied
+func (e *Executor) Check(plan *planner.Plan) error {
+	var steps []string
+	for _, step := range plan.Steps {
+		if step.Action == planner.ActionReplace && planner.IsStateful(step.Resource) && !e.AllowReplace {
+			var changes []string
+			for _, change := range step.Changes {
+				if change.Replace {
+					changes = append(changes, change.String())
+				}
+			}
+			steps = append(steps, fmt.Sprintf("%s: replacing %s would delete its data and must be allowed explicitly (%s)",
+				step.ID, step.Target, strings.Join(changes, ", ")))
+			continue
+		}
+		if step.Action == planner.ActionNoOp {
+			continue
+		}
+		if _, ok := e.handler(step); !ok {
+			steps = append(steps, fmt.Sprintf("%s: %s", step.ID, unsupported(step)))
+		}
+	}
+
+	if len(steps) > 0 {
+		return fmt.Errorf("the plan cannot be applied:\n  %s", strings.Join(steps, "\n  "))
+	}
+	return nil
+}
+
+// unsupported describes a step the executor has no handler for
+func unsupported(step planner.PlanStep) string {
+	switch step.Action {
+	case planner.ActionUpdate, planner.ActionReplace, planner.ActionDelete:
+		return fmt.Sprintf("%s of %s is not supported by the executor yet", step.Action, step.Resource)
+	default:
+		return fmt.Sprintf("%s is not supported by the executor yet", step.Resource)
+	}
+}
+
+// runStep executes a single step and records its outcome
+func (e *Executor) runStep(ctx context.Context, step planner.PlanStep) StepResult {
+	stepResult := StepResult{
+		StepID:   step.ID,
+		Action:   step.Action,
+		Resource: step.Resource,
+		Target:   step.Target,
+		Optional: step.Optional,
+	}
+
+	if step.Action == planner.ActionNoOp {
+		e.mu.Lock()
+		e.outcomes[step.ID] = unchangedOutcome(step)
+		e.mu.Unlock()
+
+		stepResult.Status = StatusSucceeded
+		stepResult.ResourceID = step.ResourceID
+		stepResult.Message = "No changes"
+		return stepResult
+	}
+
+	// An optional step the executor cannot perform is left out; any other
+	// fails, so the plan is not reported as applied
+	handler, ok := e.handler(step)
+	if !ok && step.Optional {
+		stepResult.Status = StatusSkipped
+		stepResult.Message = unsupported(step)
+		return stepResult
+	}
+	if !ok {
+		stepResult.Status = StatusFailed
+		stepResult.Error = unsupported(step)
+		return stepResult
+	}
+
+	if ctx.Err() != nil {
+		return skippedResult(step, context.Cause(ctx))
+	}
+
+	step, err := e.resolveReferences(step)
+	if err != nil {
+		stepResult.Status = StatusFailed
+		stepResult.Error = err.Error()
+		return stepResult
+	}
+
+	started := time.Now()
+	outcome, err := handler(ctx, e, step)
+	stepResult.Duration = time.Since(started)
+	if err != nil {
+		stepResult.Status = StatusFailed
+		stepResult.Error = err.Error()
+		return stepResult
+	}
+
+	if outcome == nil {
+		outcome = &Outcome{}
+	}
+	e.mu.Lock()
+	e.outcomes[step.ID] = outcome
+	e.mu.Unlock()
+
+	stepResult.Status = StatusSucceeded
+	stepResult.ResourceID = outcome.ResourceID
+	stepResult.Message = outcome.Message
+
+	e.journalCreate(step, outcome)
+	if err := e.record(step, outcome); err != nil {
+		stepResult.Message = fmt.Sprintf("%s (warning: %v)", stepResult.Message, err)
+	}
+
+	return stepResult
+}
+
+// resolveReferences returns a copy of the step with its references replaced
+// by the outputs of the steps they name
+func (e *Executor) resolveReferences(step planner.PlanStep) (planner.PlanStep, error) {
+	if len(step.References) == 0 {
+		return step, nil
+	}
+
+	step.Properties = maps.Clone(step.Properties)
+	step.Tags = maps.Clone(step.Tags)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for expr, ref := range step.References {
+		outcome, ok := e.outcomes[ref.Step]
+		if !ok {
+			return step, fmt.Errorf("cannot resolve ${%s}: step %s has not run", expr, ref.Step)
+		}
+		value, ok := outcome.Outputs[ref.Output]
+		if !ok {
+			return step, fmt.Errorf("cannot resolve ${%s}: step %s did not report a %s output", expr, ref.Step, ref.Output)
+		}
+		planner.SubstituteReference(&step, expr, value)
+	}
+	return step, nil
+}
+
+// handler returns the handler that performs a step
+func (e *Executor) handler(step planner.PlanStep) (Handler, bool) {
+	if step.Action != planner.ActionReplace {
+		return e.lookup(step.Action, step.Resource)
+	}
+
+	remove, ok := e.lookup(planner.ActionDelete, step.Resource)
+	if !ok {
+		return nil, false
+	}
+	create, ok := e.lookup(planner.ActionCreate, step.Resource)
+	if !ok {
+		return nil, false
+	}
+
+	return func(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+		if _, err := remove(ctx, e, step); err != nil {
+			return nil, fmt.Errorf("failed to remove %s before replacing it: %w", step.ResourceID, err)
+		}
+		return create(ctx, e, step)
+	}, true
+}
+
+// lookup finds the handler registered for an action on a resource type
+func (e *Executor) lookup(action, resource string) (Handler, bool) {
+	if h, ok := e.handlers[handlerKey{action: action, resource: resource}]; ok {
+		return h, true
+	}
+	if action == planner.ActionUpdate || action == planner.ActionDelete {
+		return nil, false
+	}
+
+	h, ok := e.handlers[handlerKey{resource: resource}]
+	return h, ok
+}
+
+// unchangedOutcome describes an existing resource a no-op step leaves as it is
+func unchangedOutcome(step planner.PlanStep) *Outcome {
+	outcome := &Outcome{
+		ResourceID:   step.ResourceID,
+		ResourceName: step.Target,
+	}
+	if key, ok := existingOutputs[step.Resource]; ok && step.ResourceID != "" {
+		outcome.Outputs = map[string]string{key: step.ResourceID}
+	}
+	return outcome
+}
+
+// record stores the outcome in local state when it describes a tracked
+// resource, replacing the record of the resource the step acted on
+func (e *Executor) record(step planner.PlanStep, outcome *Outcome) error {
+	if e.State == nil {
+		return nil
+	}
+
+	if step.Action == planner.ActionDelete {
+		e.stateMu.Lock()
+		defer e.stateMu.Unlock()
+
+		if err := e.State.RemoveResource(step.ResourceID); err != nil {
+			return fmt.Errorf("failed to remove %s from state: %w", step.ResourceID, err)
+		}
+		return nil
+	}
+
+	if outcome.StateType == "" || outcome.ResourceID == "" {
+		return nil
+	}
+
+	name := outcome.ResourceName
+	if name == "" {
+		name = step.Target
+	}
+
+	record := state.ResourceRecord{
+		ID:         outcome.ResourceID,
+		Name:       name,
+		Type:       outcome.StateType,
+		Region:     e.provider.Region(),
+		Provider:   e.provider.Name(),
+		ConfigFile: e.Source,
+		CreatedAt:  time.Now(),
+		Tags:       outcome.Tags,
+		Attributes: step.Properties,
+		Outputs:    outcome.Outputs,
+	}
+
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	var err error
+	if step.ResourceID == "" {
+		err = e.State.AddResource(record)
+	} else {
+		if step.Action == planner.ActionUpdate {
+			for _, existing := range e.State.Resources {
+				if existing.ID == step.ResourceID {
+					record.CreatedAt = existing.CreatedAt
+					record.Outputs = mergeOutputs(existing.Outputs, outcome.Outputs)
+				}
+			}
+		}
+		err = e.State.ReplaceResource(step.ResourceID, record)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record %s in state: %w", name, err)
+	}
+
+	return nil
+}
+
+// mergeOutputs keeps the recorded outputs of an updated resource that the
+// update did not report again
+func mergeOutputs(recorded, reported map[string]string) map[string]string {
+	if len(recorded) == 0 {
+		return reported
+	}
+	merged := make(map[string]string, len(recorded)+len(reported))
+	for name, value := range recorded {
+		merged[name] = value
+	}
+	for name, value := range reported {
+		merged[name] = value
+	}
+	return merged
+}
+
+// journalCreate adds a resource created by a step, including the new
+// resource of a replacement, to the journal. Undoing it also drops the
+// record written to state.
+func (e *Executor) journalCreate(step planner.PlanStep, outcome *Outcome) {
+	if step.Action != planner.ActionCreate && step.Action != planner.ActionReplace {
+		return
+	}
+	if outcome.ResourceID == "" {
+		return
+	}
+	if outcome.StateType == "" && outcome.Undo == nil {
+		return
+	}
+
+	kind := outcome.StateType
+	if kind == "" {
+		kind = step.Resource
+	}
+	name := outcome.ResourceName
+	if name == "" {
+		name = step.Target
+	}
+
+	var undo UndoFunc
+	if outcome.Undo != nil {
+		undo = func(ctx context.Context) error {
+			if err := outcome.Undo(ctx); err != nil {
+				return err
+			}
+			return e.forget(outcome)
+		}
+	}
+
+	e.journal.Record(kind, outcome.ResourceID, name, undo)
+}
+
+// forget removes the state record of a resource that was rolled back
+func (e *Executor) forget(outcome *Outcome) error {
+	if e.State == nil || outcome.StateType == "" {
+		return nil
+	}
+
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	if err := e.State.RemoveResource(outcome.ResourceID); err != nil {
+		return fmt.Errorf("removed, but failed to update state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,442 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/intent"
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+func TestExecuteBucketPlan(t *testing.T) {
+	plan := planner.NewBucketPlan("test-bucket", map[string]string{
+		"versioning": "true",
+		"encryption": "true",
+		"public":     "false",
+	})
+
+	result, err := New(provider.NewMockProvider("mock", "us-east-1")).Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if result.Failed() {
+		t.Fatalf("Execute() reported failures:\n%s", result.ToHumanReadable())
+	}
+
+	if got := result.Count(StatusSucceeded); got != len(plan.Steps) {
+		t.Errorf("succeeded steps = %d, want %d", got, len(plan.Steps))
+	}
+
+	if result.Steps[0].ResourceID != "test-bucket" {
+		t.Errorf("bucket ResourceID = %s, want test-bucket", result.Steps[0].ResourceID)
+	}
+}
+
+func TestExecuteNetworkPlanPassesOutputs(t *testing.T) {
+	plan, err := planner.NewNetworkPlan("test-vpc", map[string]string{"cidr": "10.0.0.0/16"})
+	if err != nil {
+		t.Fatalf("NewNetworkPlan() error = %v", err)
+	}
+
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	statuses := make(map[string]StepStatus)
+	for _, step := range result.Steps {
+		statuses[step.StepID] = step.Status
+	}
+
+	tests := []struct {
+		step string
+		want StepStatus
+	}{
+		{"create-vpc", StatusSucceeded},
+		{"create-igw", StatusSucceeded},
+		{"create-public-subnet", StatusSucceeded},
+		{"create-private-subnet", StatusSucceeded},
+		{"create-route-tables", StatusSucceeded},
+	}
+
+	for _, tt := range tests {
+		if statuses[tt.step] != tt.want {
+			t.Errorf("%s status = %s, want %s", tt.step, statuses[tt.step], tt.want)
+		}
+	}
+
+	vpc, _ := exec.Outcome("create-vpc")
+	subnet, _ := exec.Outcome("create-public-subnet")
+	if vpc == nil || subnet == nil {
+		t.Fatal("expected outcomes for create-vpc and create-public-subnet")
+	}
+	if vpc.Outputs["network_id"] == "" {
+		t.Error("create-vpc did not produce a network_id output")
+	}
+}
+
+func TestExecuteAddsProviderDefaultTags(t *testing.T) {
+	plan, err := planner.NewNetworkPlan("test-vpc", map[string]string{"cidr": "10.0.0.0/16"})
+	if err != nil {
+		t.Fatalf("NewNetworkPlan() error = %v", err)
+	}
+
+	p, err := provider.Get("mock", map[string]string{
+		"region":                            "us-east-1",
+		provider.DefaultTagPrefix + "owner": "platform",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec := New(p)
+	if _, err := exec.Execute(context.Background(), plan); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	for _, step := range []string{"create-igw", "create-public-subnet", "create-private-subnet", "create-route-tables"} {
+		outcome, _ := exec.Outcome(step)
+		if outcome == nil || outcome.Tags["owner"] != "platform" || outcome.Tags["ManagedBy"] != "Genesys" {
+			t.Errorf("%s outcome = %+v, want the default owner tag", step, outcome)
+		}
+	}
+}
+
+func TestExecuteSkipsDependentsOfFailedSteps(t *testing.T) {
+	plan := &planner.Plan{
+		ID: "test",
+		Steps: []planner.PlanStep{
+			{ID: "first", Action: "create", Resource: "broken"},
+			{ID: "second", Action: "create", Resource: "s3-bucket", Target: "dependent", DependsOn: []string{"first"}},
+			{ID: "third", Action: "create", Resource: "s3-bucket", Target: "independent"},
+		},
+	}
+
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.RegisterHandler("broken", func(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+		return nil, fmt.Errorf("boom")
+	})
+
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := []StepStatus{StatusFailed, StatusSkipped, StatusSucceeded}
+	for i, status := range want {
+		if result.Steps[i].Status != status {
+			t.Errorf("step %s status = %s, want %s", result.Steps[i].StepID, result.Steps[i].Status, status)
+		}
+	}
+
+	if !result.Failed() {
+		t.Error("Failed() = false, want true")
+	}
+}
+
+func TestExecuteDiffActions(t *testing.T) {
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.State = state.NewLocalState(filepath.Join(t.TempDir(), "state.json"))
+	exec.State.Resources = []state.ResourceRecord{
+		{ID: "vpc-1", Name: "app", Type: "vpc"},
+		{ID: "logs", Name: "logs", Type: "s3"},
+		{ID: "i-old", Name: "web", Type: "ec2"},
+		{ID: "stale", Name: "stale", Type: "s3"},
+	}
+	if err := exec.State.SaveLocalState(); err != nil {
+		t.Fatalf("SaveLocalState() error = %v", err)
+	}
+
+	plan := &planner.Plan{
+		ID: "diff",
+		Steps: []planner.PlanStep{
+			{ID: "vpc", Action: planner.ActionNoOp, Resource: "vpc", Target: "app", ResourceID: "vpc-1"},
+			{ID: "subnet", Action: planner.ActionCreate, Resource: "subnet", Target: "public", DependsOn: []string{"vpc"}},
+			{ID: "logs", Action: planner.ActionUpdate, Resource: "s3-bucket", Target: "logs", ResourceID: "logs",
+				Properties: map[string]string{"versioning": "false"}},
+			{ID: "web", Action: planner.ActionReplace, Resource: "instance", Target: "web", ResourceID: "i-old",
+				Properties: map[string]string{"image": "debian"}},
+			{ID: "stale", Action: planner.ActionDelete, Resource: "s3-bucket", Target: "stale", ResourceID: "stale"},
+			{ID: "net", Action: planner.ActionUpdate, Resource: "vpc", Target: "app", ResourceID: "vpc-1"},
+			{ID: "dns", Action: planner.ActionCreate, Resource: "route53-record", Target: "app", Optional: true},
+		},
+	}
+
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := map[string]StepStatus{
+		"vpc":    StatusSucceeded,
+		"subnet": StatusSucceeded,
+		"logs":   StatusSucceeded,
+		"web":    StatusSucceeded,
+		"stale":  StatusSucceeded,
+		"net":    StatusFailed,
+		"dns":    StatusSkipped,
+	}
+	for _, step := range result.Steps {
+		if step.Status != want[step.StepID] {
+			t.Errorf("step %s status = %s (%s), want %s", step.StepID, step.Status, step.Message, want[step.StepID])
+		}
+	}
+
+	if msg := result.Steps[5].Error; !strings.Contains(msg, "update of vpc is not supported") {
+		t.Errorf("vpc update error = %q", msg)
+	}
+	if !result.Failed() {
+		t.Error("Failed() = false with an unsupported update, want true")
+	}
+	// Check also rejects the optional steps that Execute skips
+	if err := exec.Check(plan); err == nil || !strings.Contains(err.Error(), "net: update of vpc") ||
+		!strings.Contains(err.Error(), "dns: route53-record") || strings.Contains(err.Error(), "logs") {
+		t.Errorf("Check() error = %v, want the vpc update and the dns step rejected", err)
+	}
+
+	ids := make(map[string]state.ResourceRecord)
+	for _, record := range exec.State.Resources {
+		ids[record.ID] = record
+	}
+	if _, ok := ids["stale"]; ok {
+		t.Error("deleted bucket is still recorded in state")
+	}
+	if _, ok := ids["i-old"]; ok {
+		t.Error("replaced instance is still recorded in state")
+	}
+	if ids["logs"].Attributes["versioning"] != "false" {
+		t.Errorf("updated bucket attributes = %v, want versioning=false", ids["logs"].Attributes)
+	}
+	if exec.Journal().Len() != 2 {
+		t.Errorf("journaled creates = %d, want 2 (the subnet and the new instance)", exec.Journal().Len())
+	}
+}
+
+func TestExecuteRecordsOutputs(t *testing.T) {
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.State = state.NewLocalState(filepath.Join(t.TempDir(), "state.json"))
+	exec.State.Resources = []state.ResourceRecord{
+		{ID: "logs", Name: "logs", Type: "s3", Outputs: map[string]string{"bucket_arn": "arn:aws:s3:::logs"}},
+	}
+	if err := exec.State.SaveLocalState(); err != nil {
+		t.Fatalf("SaveLocalState() error = %v", err)
+	}
+
+	plan := &planner.Plan{
+		ID: "outputs",
+		Steps: []planner.PlanStep{
+			{ID: "assets", Action: planner.ActionCreate, Resource: "s3-bucket", Target: "assets"},
+			{ID: "web", Action: planner.ActionCreate, Resource: "instance", Target: "web"},
+			{ID: "db", Action: planner.ActionCreate, Resource: "rds-instance", Target: "db"},
+			{ID: "api", Action: planner.ActionCreate, Resource: "lambda-function", Target: "api"},
+			{ID: "logs", Action: planner.ActionUpdate, Resource: "s3-bucket", Target: "logs", ResourceID: "logs"},
+		},
+	}
+
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Failed() {
+		t.Fatalf("Execute() failed: %+v", result.Steps)
+	}
+
+	outputs := exec.State.Outputs()
+	tests := []struct {
+		resource, output, want string
+	}{
+		{"assets", "bucket_arn", "arn:aws:s3:::assets"},
+		{"web", "public_ip", "203.0.113.10"},
+		{"db", "endpoint", "db.mock.rds.amazonaws.com"},
+		{"db", "port", "5432"},
+		{"api", "function_url", "https://api.lambda-url.us-east-1.on.aws/"},
+		// Updates keep the outputs they do not report again
+		{"logs", "bucket_arn", "arn:aws:s3:::logs"},
+		{"logs", "bucket_name", "logs"},
+	}
+	for _, tt := range tests {
+		if got := outputs[tt.resource][tt.output]; got != tt.want {
+			t.Errorf("output %s.%s = %q, want %q", tt.resource, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteResolvesReferences(t *testing.T) {
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.State = state.NewLocalState(filepath.Join(t.TempDir(), "state.json"))
+
+	plan := &planner.Plan{
+		ID: "references",
+		Steps: []planner.PlanStep{
+			{ID: "storage-assets", Action: planner.ActionCreate, Resource: "s3-bucket", Target: "assets"},
+			{ID: "function-api", Action: planner.ActionCreate, Resource: "lambda-function", Target: "api",
+				DependsOn:  []string{"storage-assets"},
+				Properties: map[string]string{planner.EnvPropertyPrefix + "BUCKET": "${storage.assets.arn}"},
+				References: map[string]planner.Reference{
+					"storage.assets.arn": {Step: "storage-assets", Output: "bucket_arn"},
+				}},
+			{ID: "function-missing", Action: planner.ActionCreate, Resource: "lambda-function", Target: "missing",
+				DependsOn:  []string{"storage-assets"},
+				Properties: map[string]string{planner.EnvPropertyPrefix + "URL": "${storage.assets.url}"},
+				References: map[string]planner.Reference{
+					"storage.assets.url": {Step: "storage-assets", Output: "url"},
+				}},
+		},
+	}
+
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	for _, step := range result.Steps {
+		switch step.StepID {
+		case "function-api":
+			if step.Status != StatusSucceeded {
+				t.Errorf("function-api status = %s (%s)", step.Status, step.Error)
+			}
+		case "function-missing":
+			if step.Status != StatusFailed || !strings.Contains(step.Error, "did not report a url output") {
+				t.Errorf("function-missing status = %s, error = %q", step.Status, step.Error)
+			}
+		}
+	}
+
+	record, ok := exec.State.FindResource("lambda", "api")
+	if !ok {
+		t.Fatal("function api was not recorded")
+	}
+	if got := record.Attributes[planner.EnvPropertyPrefix+"BUCKET"]; got != "arn:aws:s3:::assets" {
+		t.Errorf("recorded BUCKET = %q, want the bucket ARN", got)
+	}
+	if got := plan.Steps[1].Properties[planner.EnvPropertyPrefix+"BUCKET"]; got != "${storage.assets.arn}" {
+		t.Errorf("plan step was modified: BUCKET = %q", got)
+	}
+}
+
+func TestCheckIntentPlans(t *testing.T) {
+	p := provider.NewMockProvider("mock", "us-east-1")
+
+	tests := []struct {
+		intent intent.Intent
+		want   string // part of the error; empty when the plan can be applied
+	}{
+		{intent: intent.Intent{Type: intent.IntentBucket, Name: "b"}},
+		{intent: intent.Intent{Type: intent.IntentNetwork, Name: "n"}},
+		{intent: intent.Intent{Type: intent.IntentStaticSite, Name: "site"}},
+		{intent: intent.Intent{Type: intent.IntentDatabase, Name: "db"}},
+		{intent: intent.Intent{Type: intent.IntentStaticSite, Parameters: map[string]string{"cdn": "true", "domain": "example.org"}}, want: "create-cloudfront: cloudfront-distribution is not supported"},
+		{intent: intent.Intent{Type: intent.IntentStaticSite, Parameters: map[string]string{"https": "true"}}, want: "request-certificate: acm-certificate is not supported"},
+		{intent: intent.Intent{Type: intent.IntentStaticSite, Parameters: map[string]string{"domain": "example.org"}}, want: "configure-dns: route53-records is not supported"},
+		{intent: intent.Intent{Type: intent.IntentAPI, Name: "api"}, want: "create-api-gateway: api-gateway is not supported"},
+		{intent: intent.Intent{Type: intent.IntentWebapp, Name: "web"}, want: "create-launch-template: launch-template is not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.intent.Type)+" "+tt.want, func(t *testing.T) {
+			if tt.intent.Parameters == nil {
+				tt.intent.Parameters = map[string]string{}
+			}
+			exec := New(p)
+			plan, err := planner.New(p).PlanFromIntent(context.Background(), &tt.intent)
+			if err == nil {
+				err = exec.Check(plan)
+			}
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("plan cannot be applied: %v", err)
+				}
+				result, err := exec.Execute(context.Background(), plan)
+				if err != nil || result.Failed() || result.Count(StatusSkipped) > 0 {
+					t.Errorf("Execute() = %v, want every step to succeed:\n%s", err, result.ToHumanReadable())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckStatefulReplace(t *testing.T) {
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	plan := &planner.Plan{
+		ID: "replace",
+		Steps: []planner.PlanStep{
+			{ID: "db", Action: planner.ActionReplace, Resource: "rds-instance", Target: "db", ResourceID: "db-1",
+				Changes: []planner.FieldChange{
+					{Field: "storage", Old: "20", New: "50"},
+					{Field: "version", Old: "15.4", New: "16", Replace: true},
+				}},
+			{ID: "web", Action: planner.ActionReplace, Resource: "instance", Target: "web", ResourceID: "i-old"},
+		},
+	}
+
+	err := exec.Check(plan)
+	if err == nil || !strings.Contains(err.Error(), "db: replacing db would delete its data") ||
+		!strings.Contains(err.Error(), "version: 15.4 → 16") || strings.Contains(err.Error(), "storage") ||
+		strings.Contains(err.Error(), "web:") {
+		t.Errorf("Check() error = %v, want only the database replacement rejected with its diff", err)
+	}
+
+	exec.AllowReplace = true
+	if err := exec.Check(plan); err != nil {
+		t.Errorf("Check() with AllowReplace error = %v", err)
+	}
+}
+
+// databaseRecorder is a mock provider that keeps the configuration of the
+// databases it creates
+type databaseRecorder struct {
+	provider.Provider
+	created []*provider.DatabaseConfig
+}
+
+func (p *databaseRecorder) Database() provider.DatabaseService {
+	return &recordingDatabases{DatabaseService: p.Provider.Database(), p: p}
+}
+
+type recordingDatabases struct {
+	provider.DatabaseService
+	p *databaseRecorder
+}
+
+func (d *recordingDatabases) CreateDatabase(ctx context.Context, config *provider.DatabaseConfig) (*provider.Database, error) {
+	d.p.created = append(d.p.created, config)
+	return d.DatabaseService.CreateDatabase(ctx, config)
+}
+
+func TestDatabaseUsesItsSecurityGroup(t *testing.T) {
+	p := &databaseRecorder{Provider: provider.NewMockProvider("mock", "us-east-1")}
+	plan, err := planner.New(p).PlanFromIntent(context.Background(), &intent.Intent{
+		Type: intent.IntentDatabase, Name: "db", Parameters: map[string]string{"engine": "mysql"},
+	})
+	if err != nil {
+		t.Fatalf("PlanFromIntent() error = %v", err)
+	}
+	if port := plan.Steps[0].Properties["ingress_port"]; port != "3306" {
+		t.Errorf("security group opens port %q, want 3306", port)
+	}
+
+	exec := New(p)
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil || result.Failed() {
+		t.Fatalf("Execute() = %v:\n%s", err, result.ToHumanReadable())
+	}
+
+	group, _ := exec.Outcome("create-security-group")
+	if len(p.created) != 1 {
+		t.Fatalf("created %d databases, want 1", len(p.created))
+	}
+	if groups := p.created[0].SecurityGroups; len(groups) != 1 || groups[0] != group.ResourceID {
+		t.Errorf("database created in %v, want security group %s", groups, group.ResourceID)
+	}
+}
@@ -0,0 +1,715 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+// registerDefaultHandlers registers the handlers backed by the generic provider services
+func registerDefaultHandlers(e *Executor) {
+	e.RegisterHandler("s3-bucket", createBucket)
+	e.RegisterHandler("s3-bucket-versioning", bucketSetting)
+	e.RegisterHandler("s3-bucket-encryption", bucketSetting)
+	e.RegisterHandler("s3-bucket-public-access", bucketSetting)
+	e.RegisterHandler("s3-bucket-website", configureWebsite)
+	e.RegisterHandler("vpc", createNetwork)
+	e.RegisterHandler("internet-gateway", createInternetGateway)
+	e.RegisterHandler("subnet", createSubnet)
+	e.RegisterHandler("route-table", createRouteTable)
+	e.RegisterHandler("security-group", createSecurityGroup)
+	e.RegisterHandler("instance", createInstance)
+	e.RegisterHandler("lambda-function", createFunction)
+	e.RegisterHandler("lambda-function-url", createFunctionURL)
+	e.RegisterHandler("rds-instance", createDatabase)
+	e.RegisterHandler("rds-backup", databaseBackup)
+	e.RegisterHandler("bucket", analyzeBucket)
+	e.RegisterHandler("state", importResource)
+
+	e.RegisterActionHandler(planner.ActionUpdate, "s3-bucket", updateBucket)
+	e.RegisterActionHandler(planner.ActionUpdate, "instance", updateInstance)
+	e.RegisterActionHandler(planner.ActionUpdate, "lambda-function", updateFunction)
+	e.RegisterActionHandler(planner.ActionUpdate, "rds-instance", updateDatabase)
+	e.RegisterActionHandler(planner.ActionDelete, "s3-bucket", deleteBucket)
+	e.RegisterActionHandler(planner.ActionDelete, "instance", deleteInstance)
+	e.RegisterActionHandler(planner.ActionDelete, "lambda-function", deleteFunction)
+	e.RegisterActionHandler(planner.ActionDelete, "rds-instance", deleteDatabase)
+}
+
+// createBucket creates an S3 bucket with the settings carried by the step
+func createBucket(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	if step.Target == "" {
+		return nil, fmt.Errorf("bucket name is required")
+	}
+
+	config, err := bucketConfig(step)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, err := e.provider.Storage().CreateBucket(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bucket %s: %w", step.Target, err)
+	}
+
+	return &Outcome{
+		ResourceID:   bucket.Name,
+		ResourceName: bucket.Name,
+		StateType:    "s3",
+		Message:      fmt.Sprintf("Created S3 bucket '%s'", bucket.Name),
+		Outputs:      BucketOutputs(bucket),
+		Tags:         config.Tags,
+		Undo: func(ctx context.Context) error {
+			return e.provider.Storage().DeleteBucket(ctx, bucket.Name)
+		},
+	}, nil
+}
+
+// updateBucket applies changed settings to an existing bucket
+func updateBucket(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	config, err := bucketConfig(step)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.provider.Storage().UpdateBucket(ctx, step.ResourceID, config); err != nil {
+		return nil, fmt.Errorf("failed to update bucket %s: %w", step.ResourceID, err)
+	}
+
+	return &Outcome{
+		ResourceID:   step.ResourceID,
+		ResourceName: step.Target,
+		StateType:    "s3",
+		Message:      fmt.Sprintf("Updated S3 bucket '%s'", step.ResourceID),
+		Outputs:      map[string]string{"bucket_name": step.ResourceID},
+		Tags:         config.Tags,
+	}, nil
+}
+
+// deleteBucket deletes a bucket that is no longer declared or is being replaced
+func deleteBucket(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	if err := e.provider.Storage().DeleteBucket(ctx, step.ResourceID); err != nil {
+		return nil, fmt.Errorf("failed to delete bucket %s: %w", step.ResourceID, err)
+	}
+
+	return &Outcome{
+		ResourceID: step.ResourceID,
+		StateType:  "s3",
+		Message:    fmt.Sprintf("Deleted S3 bucket '%s'", step.ResourceID),
+	}, nil
+}
+
+// bucketSetting confirms a bucket setting that is applied as part of bucket creation
+func bucketSetting(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	if _, ok := e.Output(step, "bucket_name"); !ok {
+		return nil, fmt.Errorf("bucket %s was not created by this plan", step.Target)
+	}
+
+	return &Outcome{
+		ResourceID: step.Target,
+		Message:    "Applied during bucket creation",
+	}, nil
+}
+
+// configureWebsite serves a bucket created by a dependency as a static website
+func configureWebsite(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	bucketName, ok := e.Output(step, "bucket_name")
+	if !ok {
+		return nil, fmt.Errorf("bucket %s was not created by this plan", step.Target)
+	}
+
+	website, err := e.provider.Storage().ConfigureWebsite(ctx, bucketName, &provider.WebsiteConfig{
+		IndexDocument: step.Properties["index"],
+		ErrorDocument: step.Properties["error"],
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure website hosting for %s: %w", bucketName, err)
+	}
+
+	return &Outcome{
+		ResourceID: bucketName,
+		Message:    fmt.Sprintf("Serving bucket '%s' at %s", bucketName, website.Endpoint),
+		Outputs:    map[string]string{"website_url": website.Endpoint},
+	}, nil
+}
+
+// createNetwork creates a VPC
+func createNetwork(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	tags := stepTags(step)
+	tags["Name"] = step.Target
+
+	network, err := e.provider.Network().CreateNetwork(ctx, &provider.NetworkConfig{
+		Name: step.Target,
+		CIDR: step.Properties["cidr"],
+		Tags: tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create network %s: %w", step.Target, err)
+	}
+
+	return &Outcome{
+		ResourceID:   network.ID,
+		ResourceName: step.Target,
+		StateType:    "vpc",
+		Message:      fmt.Sprintf("Created VPC %s (%s)", network.ID, network.CIDR),
+		Outputs:      map[string]string{"network_id": network.ID},
+		Tags:         tags,
+	}, nil
+}
+
+// createInternetGateway creates an internet gateway attached to the network
+// created by a dependency
+func createInternetGateway(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	networkID, ok := e.Output(step, "network_id")
+	if !ok {
+		return nil, fmt.Errorf("internet gateway has no network to be attached to")
+	}
+
+	tags := stepTags(step)
+	if step.Target != "" {
+		tags["Name"] = step.Target
+	}
+	gateway, err := e.provider.Network().CreateInternetGateway(ctx, networkID, tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create internet gateway: %w", err)
+	}
+
+	return &Outcome{
+		ResourceID:   gateway.ID,
+		ResourceName: step.Target,
+		Message:      fmt.Sprintf("Created internet gateway %s attached to %s", gateway.ID, networkID),
+		Outputs:      map[string]string{"gateway_id": gateway.ID, "network_id": networkID},
+		Tags:         gateway.Tags,
+	}, nil
+}
+
+// createRouteTable creates a route table sending the traffic of the subnets
+// created by dependencies through the gateway created by another
+func createRouteTable(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	networkID, ok := e.Output(step, "network_id")
+	if !ok {
+		return nil, fmt.Errorf("route table has no network to be created in")
+	}
+	gatewayID, _ := e.Output(step, "gateway_id")
+
+	var subnetIDs []string
+	for _, dep := range step.DependsOn {
+		if outcome, ok := e.Outcome(dep); ok && outcome.Outputs["subnet_id"] != "" {
+			subnetIDs = append(subnetIDs, outcome.Outputs["subnet_id"])
+		}
+	}
+
+	tags := stepTags(step)
+	if step.Target != "" {
+		tags["Name"] = step.Target
+	}
+	table, err := e.provider.Network().CreateRouteTable(ctx, networkID, &provider.RouteTableConfig{
+		GatewayID: gatewayID,
+		SubnetIDs: subnetIDs,
+		Tags:      tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create route table: %w", err)
+	}
+
+	return &Outcome{
+		ResourceID:   table.ID,
+		ResourceName: step.Target,
+		Message:      fmt.Sprintf("Created route table %s for %d subnet(s)", table.ID, len(subnetIDs)),
+		Outputs:      map[string]string{"route_table_id": table.ID},
+		Tags:         table.Tags,
+	}, nil
+}
+
+// createSubnet creates a subnet inside the network created by a dependency
+func createSubnet(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	networkID, ok := e.Output(step, "network_id")
+	if !ok {
+		return nil, fmt.Errorf("subnet %s has no network to be created in", step.Target)
+	}
+
+	tags := stepTags(step)
+	subnet, err := e.provider.Network().CreateSubnet(ctx, networkID, &provider.SubnetConfig{
+		Name:   step.Target,
+		CIDR:   step.Properties["cidr"],
+		Public: step.Properties["public"] == "true",
+		AZ:     step.Properties["az"],
+		Tags:   tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subnet %s: %w", step.Target, err)
+	}
+
+	return &Outcome{
+		ResourceID:   subnet.ID,
+		ResourceName: step.Target,
+		StateType:    "subnet",
+		Message:      fmt.Sprintf("Created subnet %s (%s) in %s", subnet.ID, subnet.CIDR, networkID),
+		Outputs:      map[string]string{"subnet_id": subnet.ID},
+		Tags:         subnet.Tags,
+	}, nil
+}
+
+// createSecurityGroup creates a security group in the network produced by a
+// dependency, or the default network when there is none. The ingress_port
+// and ingress_source properties open a TCP port to a CIDR block or group.
+func createSecurityGroup(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	description := step.Properties["description"]
+	if description == "" {
+		description = fmt.Sprintf("Managed by Genesys (%s)", step.Target)
+	}
+
+	var rules []provider.SecurityRule
+	if step.Properties["ingress_port"] != "" {
+		port, err := intProperty(step, "ingress_port", 0)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, provider.SecurityRule{
+			Direction: "ingress",
+			Protocol:  "tcp",
+			FromPort:  port,
+			ToPort:    port,
+			Source:    step.Properties["ingress_source"],
+		})
+	}
+
+	networkID, _ := e.Output(step, "network_id")
+	tags := stepTags(step)
+	group, err := e.provider.Network().CreateSecurityGroup(ctx, &provider.SecurityGroupConfig{
+		Name:        step.Target,
+		Description: description,
+		NetworkID:   networkID,
+		Rules:       rules,
+		Tags:        tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create security group %s: %w", step.Target, err)
+	}
+
+	return &Outcome{
+		ResourceID:   group.ID,
+		ResourceName: group.Name,
+		StateType:    "security-group",
+		Message:      fmt.Sprintf("Created security group %s", group.ID),
+		Outputs:      map[string]string{"security_group_id": group.ID},
+		Tags:         group.Tags,
+	}, nil
+}
+
+// createFunction creates a serverless function, using the role produced by a
+// dependency when there is one
+func createFunction(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	config, err := functionConfig(e, step)
+	if err != nil {
+		return nil, err
+	}
+
+	function, err := e.provider.Serverless().CreateFunction(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create function %s: %w", step.Target, err)
+	}
+
+	return &Outcome{
+		ResourceID:   function.Name,
+		ResourceName: function.Name,
+		StateType:    "lambda",
+		Message:      fmt.Sprintf("Created function '%s' (%s)", function.Name, function.Runtime),
+		Outputs:      FunctionOutputs(function),
+		Tags:         config.Tags,
+		Undo: func(ctx context.Context) error {
+			return e.provider.Serverless().DeleteFunction(ctx, function.Name)
+		},
+	}, nil
+}
+
+// createFunctionURL exposes the function created by a dependency over HTTPS
+func createFunctionURL(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	name, ok := e.Output(step, "function_name")
+	if !ok {
+		return nil, fmt.Errorf("function %s was not created by this plan", step.Target)
+	}
+
+	url, err := e.provider.Serverless().CreateFunctionURL(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create URL for function %s: %w", name, err)
+	}
+
+	return &Outcome{
+		ResourceID: name,
+		Message:    fmt.Sprintf("Function '%s' is served at %s", name, url),
+		Outputs:    map[string]string{"function_url": url},
+	}, nil
+}
+
+// updateFunction applies changed settings to an existing function
+func updateFunction(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	config, err := functionConfig(e, step)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.provider.Serverless().UpdateFunction(ctx, step.ResourceID, config); err != nil {
+		return nil, fmt.Errorf("failed to update function %s: %w", step.ResourceID, err)
+	}
+
+	return &Outcome{
+		ResourceID:   step.ResourceID,
+		ResourceName: step.Target,
+		StateType:    "lambda",
+		Message:      fmt.Sprintf("Updated function '%s'", step.ResourceID),
+		Outputs:      map[string]string{"function_name": step.ResourceID},
+		Tags:         config.Tags,
+	}, nil
+}
+
+// deleteFunction deletes a function that is no longer declared or is being replaced
+func deleteFunction(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	if err := e.provider.Serverless().DeleteFunction(ctx, step.ResourceID); err != nil {
+		return nil, fmt.Errorf("failed to delete function %s: %w", step.ResourceID, err)
+	}
+
+	return &Outcome{
+		ResourceID: step.ResourceID,
+		StateType:  "lambda",
+		Message:    fmt.Sprintf("Deleted function '%s'", step.ResourceID),
+	}, nil
+}
+
+// createInstance creates a compute instance, placing it in the network created
+// by a dependency when there is one
+func createInstance(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	config := instanceConfig(e, step)
+	instance, err := e.provider.Compute().CreateInstance(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create instance %s: %w", step.Target, err)
+	}
+
+	return &Outcome{
+		ResourceID:   instance.ID,
+		ResourceName: step.Target,
+		StateType:    "ec2",
+		Message:      fmt.Sprintf("Created %s instance %s", instance.Type, instance.ID),
+		Outputs:      InstanceOutputs(instance),
+		Tags:         config.Tags,
+		Undo: func(ctx context.Context) error {
+			return e.provider.Compute().DeleteInstance(ctx, instance.ID)
+		},
+	}, nil
+}
+
+// updateInstance applies changed tags to an existing instance; other changes
+// are planned as replacements
+func updateInstance(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	config := instanceConfig(e, step)
+	if err := e.provider.Compute().UpdateInstance(ctx, step.ResourceID, config); err != nil {
+		return nil, fmt.Errorf("failed to update instance %s: %w", step.ResourceID, err)
+	}
+
+	return &Outcome{
+		ResourceID:   step.ResourceID,
+		ResourceName: step.Target,
+		StateType:    "ec2",
+		Message:      fmt.Sprintf("Updated instance %s", step.ResourceID),
+		Outputs:      map[string]string{"instance_id": step.ResourceID},
+		Tags:         config.Tags,
+	}, nil
+}
+
+// deleteInstance terminates an instance that is no longer declared or is being replaced
+func deleteInstance(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	if err := e.provider.Compute().DeleteInstance(ctx, step.ResourceID); err != nil {
+		return nil, fmt.Errorf("failed to delete instance %s: %w", step.ResourceID, err)
+	}
+
+	return &Outcome{
+		ResourceID: step.ResourceID,
+		StateType:  "ec2",
+		Message:    fmt.Sprintf("Terminated instance %s", step.ResourceID),
+	}, nil
+}
+
+// createDatabase creates a managed database, placing it in the security
+// group produced by a dependency when there is one
+func createDatabase(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	config, err := databaseConfig(step)
+	if err != nil {
+		return nil, err
+	}
+	if group, ok := e.Output(step, "security_group_id"); ok {
+		config.SecurityGroups = []string{group}
+	}
+
+	database, err := e.provider.Database().CreateDatabase(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database %s: %w", step.Target, err)
+	}
+
+	return &Outcome{
+		ResourceID:   database.ID,
+		ResourceName: step.Target,
+		StateType:    "rds",
+		Message:      fmt.Sprintf("Created %s database %s", database.Engine, database.ID),
+		Outputs:      DatabaseOutputs(database),
+		Tags:         config.Tags,
+		Undo: func(ctx context.Context) error {
+			return e.provider.Database().DeleteDatabase(ctx, database.ID)
+		},
+	}, nil
+}
+
+// updateDatabase applies a changed size, storage, Multi-AZ setting or minor
+// engine version to an existing database. The version is only sent when it
+// changed, so that a configured major version does not downgrade the engine.
+func updateDatabase(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	config, err := databaseConfig(step)
+	if err != nil {
+		return nil, err
+	}
+	if !changed(step, "version") {
+		config.Version = ""
+	}
+
+	if err := e.provider.Database().UpdateDatabase(ctx, step.ResourceID, config); err != nil {
+		return nil, fmt.Errorf("failed to update database %s: %w", step.ResourceID, err)
+	}
+
+	return &Outcome{
+		ResourceID:   step.ResourceID,
+		ResourceName: step.Target,
+		StateType:    "rds",
+		Message:      fmt.Sprintf("Updated database %s", step.ResourceID),
+		Outputs:      map[string]string{"database_id": step.ResourceID},
+		Tags:         config.Tags,
+	}, nil
+}
+
+// deleteDatabase deletes a database that is no longer declared or is being replaced
+func deleteDatabase(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	if err := e.provider.Database().DeleteDatabase(ctx, step.ResourceID); err != nil {
+		return nil, fmt.Errorf("failed to delete database %s: %w", step.ResourceID, err)
+	}
+
+	return &Outcome{
+		ResourceID: step.ResourceID,
+		StateType:  "rds",
+		Message:    fmt.Sprintf("Deleted database %s", step.ResourceID),
+	}, nil
+}
+
+// databaseBackup confirms the backup settings applied when the database was created
+func databaseBackup(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	id, ok := e.Output(step, "database_id")
+	if !ok {
+		return nil, fmt.Errorf("database %s was not created by this plan", step.Target)
+	}
+
+	return &Outcome{
+		ResourceID: id,
+		Message:    "Backups configured during database creation",
+	}, nil
+}
+
+// analyzeBucket looks up an existing bucket that the plan adopts
+func analyzeBucket(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	if step.Action != "analyze" {
+		return &Outcome{Message: "No changes required"}, nil
+	}
+
+	bucket, err := e.provider.Storage().AdoptBucket(ctx, step.Target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze bucket %s: %w", step.Target, err)
+	}
+
+	return &Outcome{
+		ResourceID:   bucket.Name,
+		ResourceName: bucket.Name,
+		Message:      fmt.Sprintf("Found bucket '%s' (versioning: %t, encryption: %t)", bucket.Name, bucket.Versioning, bucket.Encryption),
+		Outputs: map[string]string{
+			"import_id":   bucket.Name,
+			"import_type": "s3",
+		},
+		Tags: bucket.Tags,
+	}, nil
+}
+
+// importResource records a resource analyzed by a dependency in state
+func importResource(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+	id, ok := e.Output(step, "import_id")
+	if !ok {
+		return nil, fmt.Errorf("nothing to import for %s", step.Target)
+	}
+	stateType, _ := e.Output(step, "import_type")
+
+	return &Outcome{
+		ResourceID:   id,
+		ResourceName: step.Target,
+		StateType:    stateType,
+		Message:      fmt.Sprintf("Imported %s into state", id),
+	}, nil
+}
+
+// bucketConfig builds the bucket settings carried by a step
+func bucketConfig(step planner.PlanStep) (*provider.BucketConfig, error) {
+	deleteAfter, err := intProperty(step, "delete_after_days", 0)
+	if err != nil {
+		return nil, err
+	}
+	archiveAfter, err := intProperty(step, "archive_after_days", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &provider.BucketConfig{
+		Name:         step.Target,
+		Versioning:   step.Properties["versioning"] == "true",
+		Encryption:   step.Properties["encryption"] == "true",
+		PublicAccess: step.Properties["public"] == "true",
+		Tags:         stepTags(step),
+	}
+	if deleteAfter > 0 || archiveAfter > 0 {
+		config.Lifecycle = &provider.LifecycleConfig{
+			DeleteAfterDays:  deleteAfter,
+			ArchiveAfterDays: archiveAfter,
+		}
+	}
+	return config, nil
+}
+
+// functionConfig builds the function settings carried by a step, using the
+// role produced by a dependency when there is one
+func functionConfig(e *Executor, step planner.PlanStep) (*provider.FunctionConfig, error) {
+	memory, err := intProperty(step, "memory", 256)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := intProperty(step, "timeout", 60)
+	if err != nil {
+		return nil, err
+	}
+
+	role := step.Properties["role"]
+	if arn, ok := e.Output(step, "role_arn"); ok {
+		role = arn
+	}
+
+	environment := make(map[string]string)
+	for key, value := range step.Properties {
+		if strings.HasPrefix(key, planner.EnvPropertyPrefix) {
+			environment[strings.TrimPrefix(key, planner.EnvPropertyPrefix)] = value
+		}
+	}
+
+	return &provider.FunctionConfig{
+		Name:        step.Target,
+		Runtime:     step.Properties["runtime"],
+		Handler:     step.Properties["handler"],
+		Memory:      memory,
+		Timeout:     timeout,
+		Environment: environment,
+		Role:        role,
+		Tags:        stepTags(step),
+	}, nil
+}
+
+// instanceConfig builds the instance settings carried by a step, placing the
+// instance in the network created by a dependency when there is one
+func instanceConfig(e *Executor, step planner.PlanStep) *provider.InstanceConfig {
+	network := step.Properties["network"]
+	if id, ok := e.Output(step, "network_id"); ok {
+		network = id
+	}
+
+	var securityGroups []string
+	for i := 0; ; i++ {
+		group, ok := step.Properties[fmt.Sprintf("security_group.%d", i)]
+		if !ok {
+			break
+		}
+		securityGroups = append(securityGroups, group)
+	}
+
+	return &provider.InstanceConfig{
+		Name:           step.Target,
+		Type:           provider.InstanceType(step.Properties["type"]),
+		Image:          step.Properties["image"],
+		Network:        network,
+		Subnet:         step.Properties["subnet"],
+		SecurityGroups: securityGroups,
+		KeyPair:        step.Properties["key_pair"],
+		Tags:           stepTags(step),
+	}
+}
+
+// databaseConfig builds the database settings carried by a step
+func databaseConfig(step planner.PlanStep) (*provider.DatabaseConfig, error) {
+	storage, err := intProperty(step, "storage", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &provider.DatabaseConfig{
+		Name:    step.Target,
+		Engine:  step.Properties["engine"],
+		Version: step.Properties["version"],
+		Size:    provider.DatabaseSize(step.Properties["size"]),
+		Storage: storage,
+		MultiAZ: step.Properties["multi_az"] == "true",
+		Tags:    stepTags(step),
+	}
+	if step.Properties["backup"] == "true" {
+		retention, err := intProperty(step, "backup_retention_days", 7)
+		if err != nil {
+			return nil, err
+		}
+		window := step.Properties["backup_window"]
+		if window == "" {
+			window = "03:00-04:00"
+		}
+		config.BackupConfig = &provider.BackupConfig{
+			RetentionDays: retention,
+			Window:        window,
+		}
+	}
+	return config, nil
+}
+
+// stepTags returns the tags for the resource a step creates: the step's own
+// tags plus the tags applied to every resource the executor creates
+func stepTags(step planner.PlanStep) map[string]string {
+	tags := map[string]string{
+		"ManagedBy": "Genesys",
+	}
+	for key, value := range step.Tags {
+		tags[key] = value
+	}
+	return tags
+}
+
+// intProperty parses an integer step property, falling back to def when unset
+func intProperty(step planner.PlanStep, key string, def int) (int, error) {
+	raw := step.Properties[key]
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q for step %s: %w", key, raw, step.ID, err)
+	}
+	return value, nil
+}
+
+// changed reports whether the diff of a step changes field
+func changed(step planner.PlanStep, field string) bool {
+	for _, change := range step.Changes {
+		if change.Field == field {
+			return true
+		}
+	}
+	return false
+}
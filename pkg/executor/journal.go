@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// UndoFunc reverses a single create
+type UndoFunc func(ctx context.Context) error
+
+// JournalEntry is a resource created during a run
+type JournalEntry struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+
+	undo UndoFunc
+}
+
+// Undoable reports whether the entry can be rolled back
+func (e JournalEntry) Undoable() bool {
+	return e.undo != nil
+}
+
+// String returns a short description of the entry
+func (e JournalEntry) String() string {
+	if e.Name != "" && e.Name != e.ID {
+		return fmt.Sprintf("%s %s (%s)", e.Kind, e.Name, e.ID)
+	}
+	return fmt.Sprintf("%s %s", e.Kind, e.ID)
+}
+
+// Journal records the resources created during a run so they can be undone
+type Journal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+}
+
+// NewJournal creates an empty journal
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Record adds a created resource to the journal. undo may be nil when the
+// resource cannot be removed automatically.
+func (j *Journal) Record(kind, id, name string, undo UndoFunc) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = append(j.entries, JournalEntry{
+		Kind: kind,
+		ID:   id,
+		Name: name,
+		undo: undo,
+	})
+}
+
+// Entries returns the recorded resources in creation order
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]JournalEntry{}, j.entries...)
+}
+
+// Len returns the number of recorded resources
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return len(j.entries)
+}
+
+// Rollback undoes every recorded create in reverse order. It keeps going when
+// an undo fails so that as much as possible is cleaned up, and reports what
+// was left behind. Undone entries are removed from the journal.
+func (j *Journal) Rollback(ctx context.Context) *RollbackReport {
+	j.mu.Lock()
+	entries := j.entries
+	j.entries = nil
+	j.mu.Unlock()
+
+	report := &RollbackReport{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+
+		if entry.undo == nil {
+			report.Failed = append(report.Failed, RollbackFailure{
+				Entry: entry,
+				Error: fmt.Sprintf("no delete operation available for %s", entry.Kind),
+			})
+			continue
+		}
+
+		if err := entry.undo(ctx); err != nil {
+			report.Failed = append(report.Failed, RollbackFailure{
+				Entry: entry,
+				Error: err.Error(),
+			})
+			continue
+		}
+
+		report.Undone = append(report.Undone, entry)
+	}
+
+	return report
+}
+
+// RollbackFailure is a resource that could not be undone
+type RollbackFailure struct {
+	Entry JournalEntry `json:"entry"`
+	Error string       `json:"error"`
+}
+
+// RollbackReport describes the outcome of a rollback
+type RollbackReport struct {
+	Undone []JournalEntry    `json:"undone"`
+	Failed []RollbackFailure `json:"failed,omitempty"`
+}
+
+// Complete reports whether every recorded create was undone
+func (r *RollbackReport) Complete() bool {
+	return len(r.Failed) == 0
+}
+
+// ToHumanReadable converts the report to a human-readable format
+func (r *RollbackReport) ToHumanReadable() string {
+	var output strings.Builder
+
+	output.WriteString("Rollback results:\n")
+	for _, entry := range r.Undone {
+		output.WriteString(fmt.Sprintf("✓ Removed %s\n", entry))
+	}
+	for _, failure := range r.Failed {
+		output.WriteString(fmt.Sprintf("✗ Could not remove %s\n", failure.Entry))
+		output.WriteString(fmt.Sprintf("     Error: %s\n", failure.Error))
+	}
+
+	if r.Complete() {
+		output.WriteString("\nAll created resources were removed.\n")
+	} else {
+		output.WriteString(fmt.Sprintf("\n%d resource(s) must be removed manually.\n", len(r.Failed)))
+	}
+
+	return output.String()
+}
+
+// ToJSON converts the report to JSON format
+func (r *RollbackReport) ToJSON() string {
+	data, _ := json.MarshalIndent(r, "", "  ")
+	return string(data)
+}
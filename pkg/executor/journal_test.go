@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+func TestJournalRollback(t *testing.T) {
+	var undone []string
+	undo := func(id string) UndoFunc {
+		return func(ctx context.Context) error {
+			undone = append(undone, id)
+			return nil
+		}
+	}
+
+	journal := NewJournal()
+	journal.Record("s3", "first", "", undo("first"))
+	journal.Record("vpc", "vpc-1", "app", nil)
+	journal.Record("ec2", "second", "", func(ctx context.Context) error {
+		return fmt.Errorf("instance is protected")
+	})
+	journal.Record("lambda", "third", "", undo("third"))
+
+	report := journal.Rollback(context.Background())
+
+	if got := strings.Join(undone, ","); got != "third,first" {
+		t.Errorf("undo order = %s, want third,first", got)
+	}
+	if report.Complete() {
+		t.Fatal("Complete() = true, want false")
+	}
+	if len(report.Failed) != 2 {
+		t.Fatalf("failed entries = %d, want 2", len(report.Failed))
+	}
+	if report.Failed[0].Entry.ID != "second" || report.Failed[0].Error != "instance is protected" {
+		t.Errorf("first failure = %+v, want the ec2 error", report.Failed[0])
+	}
+	if !strings.Contains(report.Failed[1].Error, "no delete operation") {
+		t.Errorf("vpc failure = %q, want it to say it cannot be deleted", report.Failed[1].Error)
+	}
+	if journal.Len() != 0 {
+		t.Errorf("journal has %d entries after rollback, want 0", journal.Len())
+	}
+
+	output := report.ToHumanReadable()
+	if !strings.Contains(output, "Could not remove vpc app (vpc-1)") {
+		t.Errorf("report does not name the vpc:\n%s", output)
+	}
+}
+
+func TestExecutorRollbackAfterFailure(t *testing.T) {
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.State = state.NewLocalState(filepath.Join(t.TempDir(), "state.json"))
+	exec.RegisterHandler("broken", func(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+		return nil, fmt.Errorf("boom")
+	})
+
+	plan := &planner.Plan{
+		ID: "rollback",
+		Steps: []planner.PlanStep{
+			{ID: "bucket", Action: "create", Resource: "s3-bucket", Target: "assets"},
+			{ID: "vpc", Action: "create", Resource: "vpc", Target: "app", Properties: map[string]string{"cidr": "10.0.0.0/16"}},
+			{ID: "broken", Action: "create", Resource: "broken", DependsOn: []string{"bucket", "vpc"}},
+		},
+	}
+
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Failed() {
+		t.Fatal("Execute() expected a failed step")
+	}
+	if got := exec.Journal().Len(); got != 2 {
+		t.Fatalf("journaled creates = %d, want 2", got)
+	}
+
+	report := exec.Rollback(context.Background())
+
+	if len(report.Undone) != 1 || report.Undone[0].ID != "assets" {
+		t.Errorf("undone = %v, want the assets bucket", report.Undone)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Entry.Kind != "vpc" {
+		t.Errorf("failed = %v, want the vpc", report.Failed)
+	}
+
+	for _, record := range exec.State.Resources {
+		if record.ID == "assets" {
+			t.Error("rolled back bucket is still recorded in state")
+		}
+	}
+}
+
+func TestExecutorRollbackAfterReplace(t *testing.T) {
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.State = state.NewLocalState(filepath.Join(t.TempDir(), "state.json"))
+	exec.State.Resources = []state.ResourceRecord{{ID: "i-old", Name: "web", Type: "ec2"}}
+	if err := exec.State.SaveLocalState(); err != nil {
+		t.Fatalf("SaveLocalState() error = %v", err)
+	}
+	exec.RegisterHandler("broken", func(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+		return nil, fmt.Errorf("boom")
+	})
+
+	plan := &planner.Plan{
+		ID: "replace",
+		Steps: []planner.PlanStep{
+			{ID: "web", Action: planner.ActionReplace, Resource: "instance", Target: "web", ResourceID: "i-old",
+				Properties: map[string]string{"image": "debian"}},
+			{ID: "broken", Action: planner.ActionCreate, Resource: "broken", DependsOn: []string{"web"}},
+		},
+	}
+
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Failed() {
+		t.Fatal("Execute() expected a failed step")
+	}
+
+	replaced, _ := exec.Outcome("web")
+	if replaced == nil {
+		t.Fatal("expected an outcome for the replaced instance")
+	}
+	entries := exec.Journal().Entries()
+	if len(entries) != 1 || entries[0].ID != replaced.ResourceID {
+		t.Fatalf("journal = %+v, want the new instance %s", entries, replaced.ResourceID)
+	}
+
+	report := exec.Rollback(context.Background())
+	if !report.Complete() || len(report.Undone) != 1 {
+		t.Errorf("rollback = %+v, want the new instance removed", report)
+	}
+	for _, record := range exec.State.Resources {
+		if record.ID == replaced.ResourceID {
+			t.Error("rolled back instance is still recorded in state")
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+// BucketOutputs returns the outputs recorded for a bucket
+func BucketOutputs(bucket *provider.Bucket) map[string]string {
+	return outputs(
+		"bucket_name", bucket.Name,
+		"bucket_arn", providerString(bucket.ProviderData, "arn"),
+		"region", bucket.Region,
+	)
+}
+
+// InstanceOutputs returns the outputs recorded for an instance
+func InstanceOutputs(instance *provider.Instance) map[string]string {
+	return outputs(
+		"instance_id", instance.ID,
+		"private_ip", instance.PrivateIP,
+		"public_ip", instance.PublicIP,
+	)
+}
+
+// DatabaseOutputs returns the outputs recorded for a database
+func DatabaseOutputs(database *provider.Database) map[string]string {
+	port := ""
+	if database.Port != 0 {
+		port = strconv.Itoa(database.Port)
+	}
+	return outputs(
+		"database_id", database.ID,
+		"endpoint", database.Endpoint,
+		"port", port,
+	)
+}
+
+// FunctionOutputs returns the outputs recorded for a function
+func FunctionOutputs(function *provider.Function) map[string]string {
+	return outputs(
+		"function_name", function.Name,
+		"function_arn", providerString(function.ProviderData, "arn"),
+		"function_url", function.URL,
+	)
+}
+
+// RefreshOutputs reads the current outputs of a recorded resource from the
+// provider, such as the public IP an instance was given after it started or
+// the endpoint of a database that has become available. Records of types
+// without outputs return nil.
+func RefreshOutputs(ctx context.Context, p provider.Provider, record state.ResourceRecord) (map[string]string, error) {
+	switch record.Type {
+	case "s3":
+		bucket, err := p.Storage().GetBucket(ctx, record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bucket %s: %w", record.ID, err)
+		}
+		return BucketOutputs(bucket), nil
+
+	case "ec2":
+		instance, err := p.Compute().GetInstance(ctx, record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get instance %s: %w", record.ID, err)
+		}
+		return InstanceOutputs(instance), nil
+
+	case "rds":
+		database, err := p.Database().GetDatabase(ctx, record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get database %s: %w", record.ID, err)
+		}
+		return DatabaseOutputs(database), nil
+
+	case "lambda":
+		function, err := p.Serverless().GetFunction(ctx, record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get function %s: %w", record.ID, err)
+		}
+		return FunctionOutputs(function), nil
+	}
+
+	return nil, nil
+}
+
+// outputs builds an output map from name/value pairs, leaving out values
+// the provider did not report
+func outputs(pairs ...string) map[string]string {
+	values := make(map[string]string)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			values[pairs[i]] = pairs[i+1]
+		}
+	}
+	return values
+}
+
+// providerString returns a string from provider-specific metadata
+func providerString(data map[string]interface{}, key string) string {
+	value, _ := data[key].(string)
+	return value
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StepStatus is the outcome of a single plan step
+type StepStatus string
+
+const (
+	StatusSucceeded StepStatus = "succeeded"
+	StatusFailed    StepStatus = "failed"
+	StatusSkipped   StepStatus = "skipped"
+)
+
+// Icon returns the symbol used for the status in human readable output
+func (s StepStatus) Icon() string {
+	switch s {
+	case StatusSucceeded:
+		return "✓"
+	case StatusFailed:
+		return "✗"
+	default:
+		return "-"
+	}
+}
+
+// StepResult records what happened to a single plan step
+type StepResult struct {
+	StepID     string        `json:"step_id"`
+	Action     string        `json:"action"`
+	Resource   string        `json:"resource"`
+	Target     string        `json:"target,omitempty"`
+	Status     StepStatus    `json:"status"`
+	ResourceID string        `json:"resource_id,omitempty"`
+	Message    string        `json:"message,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Optional   bool          `json:"optional,omitempty"`
+	Duration   time.Duration `json:"duration"`
+
+	cancelled bool
+}
+
+// Result is the outcome of executing a plan
+type Result struct {
+	PlanID      string       `json:"plan_id"`
+	Parallelism int          `json:"parallelism"`
+	Steps       []StepResult `json:"steps"`
+	StartedAt   time.Time    `json:"started_at"`
+	FinishedAt  time.Time    `json:"finished_at"`
+}
+
+// Count returns the number of steps with the given status
+func (r *Result) Count(status StepStatus) int {
+	count := 0
+	for _, step := range r.Steps {
+		if step.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Failed reports whether any required step failed
+func (r *Result) Failed() bool {
+	for _, step := range r.Steps {
+		if step.Status == StatusFailed && !step.Optional {
+			return true
+		}
+	}
+	return false
+}
+
+// ToHumanReadable converts the result to a human-readable format
+func (r *Result) ToHumanReadable() string {
+	var output strings.Builder
+
+	output.WriteString("Execution results:\n")
+	for _, step := range r.Steps {
+		output.WriteString(fmt.Sprintf("%s %s (%s %s)", step.Status.Icon(), step.StepID, step.Action, step.Resource))
+		if step.Target != "" {
+			output.WriteString(fmt.Sprintf(" %s", step.Target))
+		}
+		output.WriteString("\n")
+
+		if step.ResourceID != "" {
+			output.WriteString(fmt.Sprintf("     ID: %s\n", step.ResourceID))
+		}
+		if step.Error != "" {
+			output.WriteString(fmt.Sprintf("     Error: %s\n", step.Error))
+		} else if step.Message != "" {
+			output.WriteString(fmt.Sprintf("     → %s\n", step.Message))
+		}
+	}
+
+	output.WriteString("\nSummary:\n")
+	output.WriteString(fmt.Sprintf("- Succeeded: %d\n", r.Count(StatusSucceeded)))
+	output.WriteString(fmt.Sprintf("- Failed: %d\n", r.Count(StatusFailed)))
+	output.WriteString(fmt.Sprintf("- Skipped: %d\n", r.Count(StatusSkipped)))
+	if r.Parallelism > 0 {
+		output.WriteString(fmt.Sprintf("- Parallelism: %d\n", r.Parallelism))
+	}
+	output.WriteString(fmt.Sprintf("- Duration: %s\n", r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond)))
+
+	return output.String()
+}
+
+// ToJSON converts the result to JSON format
+func (r *Result) ToJSON() string {
+	data, _ := json.MarshalIndent(r, "", "  ")
+	return string(data)
+}
@@ -0,0 +1,263 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/planner"
+)
+
+// DefaultParallelism is the number of steps run concurrently when none is configured
+const DefaultParallelism = 4
+
+// Graph is the dependency graph of a plan's steps
+type Graph struct {
+	steps      []planner.PlanStep
+	index      map[string]int
+	dependents map[string][]string
+}
+
+// NewGraph builds the dependency graph for the given steps, rejecting
+// duplicate ids, unknown dependencies and cycles
+func NewGraph(steps []planner.PlanStep) (*Graph, error) {
+	g := &Graph{
+		steps:      steps,
+		index:      make(map[string]int, len(steps)),
+		dependents: make(map[string][]string),
+	}
+
+	for i, step := range steps {
+		if _, exists := g.index[step.ID]; exists {
+			return nil, fmt.Errorf("duplicate step id: %s", step.ID)
+		}
+		g.index[step.ID] = i
+	}
+
+	for _, step := range steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := g.index[dep]; !ok {
+				return nil, fmt.Errorf("step %s depends on unknown step %s", step.ID, dep)
+			}
+			g.dependents[dep] = append(g.dependents[dep], step.ID)
+		}
+	}
+
+	if cycle := g.findCycle(); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	return g, nil
+}
+
+// Step returns the step with the given id
+func (g *Graph) Step(id string) planner.PlanStep {
+	return g.steps[g.index[id]]
+}
+
+// Dependents returns every step that depends on id, directly or transitively
+func (g *Graph) Dependents(id string) []string {
+	seen := make(map[string]bool)
+	var result []string
+
+	queue := append([]string{}, g.dependents[id]...)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		result = append(result, current)
+		queue = append(queue, g.dependents[current]...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return g.index[result[i]] < g.index[result[j]]
+	})
+	return result
+}
+
+// findCycle returns the steps forming a dependency cycle, or nil when there is none
+func (g *Graph) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	color := make(map[string]int, len(g.steps))
+	var path []string
+	var cycle []string
+
+	var visit func(id string) bool
+	visit = func(id string) bool {
+		color[id] = visiting
+		path = append(path, id)
+
+		for _, dep := range g.Step(id).DependsOn {
+			switch color[dep] {
+			case visiting:
+				for i, p := range path {
+					if p == dep {
+						cycle = append(append([]string{}, path[i:]...), dep)
+						break
+					}
+				}
+				return true
+			case unvisited:
+				if visit(dep) {
+					return true
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		color[id] = visited
+		return false
+	}
+
+	for _, step := range g.steps {
+		if color[step.ID] == unvisited && visit(step.ID) {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// scheduler runs the steps of a graph concurrently once their dependencies have succeeded
+type scheduler struct {
+	executor    *Executor
+	graph       *Graph
+	parallelism int
+
+	contexts  map[string]context.Context
+	cancels   map[string]context.CancelCauseFunc
+	remaining map[string]int
+	ready     []string
+	results   map[string]StepResult
+}
+
+// newScheduler prepares a scheduler for the graph
+func newScheduler(e *Executor, g *Graph, parallelism int) *scheduler {
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+
+	return &scheduler{
+		executor:    e,
+		graph:       g,
+		parallelism: parallelism,
+		contexts:    make(map[string]context.Context, len(g.steps)),
+		cancels:     make(map[string]context.CancelCauseFunc, len(g.steps)),
+		remaining:   make(map[string]int, len(g.steps)),
+		results:     make(map[string]StepResult, len(g.steps)),
+	}
+}
+
+// run executes every step and returns the results in plan order
+func (s *scheduler) run(ctx context.Context) []StepResult {
+	for _, step := range s.graph.steps {
+		stepCtx, cancel := context.WithCancelCause(ctx)
+		s.contexts[step.ID] = stepCtx
+		s.cancels[step.ID] = cancel
+		s.remaining[step.ID] = len(step.DependsOn)
+		if len(step.DependsOn) == 0 {
+			s.ready = append(s.ready, step.ID)
+		}
+	}
+	defer func() {
+		for _, cancel := range s.cancels {
+			cancel(nil)
+		}
+	}()
+
+	done := make(chan StepResult)
+	running := 0
+
+	for len(s.results) < len(s.graph.steps) {
+		for running < s.parallelism && len(s.ready) > 0 {
+			id := s.ready[0]
+			s.ready = s.ready[1:]
+
+			step := s.graph.Step(id)
+			stepCtx := s.contexts[id]
+			if stepCtx.Err() != nil {
+				s.finish(skippedResult(step, context.Cause(stepCtx)))
+				continue
+			}
+
+			running++
+			go func() {
+				done <- s.executor.runStep(stepCtx, step)
+			}()
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-done
+		running--
+		s.finish(result)
+	}
+
+	results := make([]StepResult, 0, len(s.graph.steps))
+	for _, step := range s.graph.steps {
+		if result, ok := s.results[step.ID]; ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// finish records a step result, cancels its dependents when it did not
+// complete and queues the dependents that became ready
+func (s *scheduler) finish(result StepResult) {
+	s.results[result.StepID] = result
+
+	if blocksDependents(result) {
+		cause := fmt.Errorf("dependency %s %s", result.StepID, result.Status)
+		for _, id := range s.graph.Dependents(result.StepID) {
+			s.cancels[id](cause)
+		}
+	}
+
+	for _, id := range s.graph.dependents[result.StepID] {
+		s.remaining[id]--
+		if s.remaining[id] == 0 {
+			s.enqueue(id)
+		}
+	}
+}
+
+// enqueue adds a step to the ready queue, keeping the queue in plan order
+func (s *scheduler) enqueue(id string) {
+	pos := sort.Search(len(s.ready), func(i int) bool {
+		return s.graph.index[s.ready[i]] > s.graph.index[id]
+	})
+	s.ready = append(s.ready, "")
+	copy(s.ready[pos+1:], s.ready[pos:])
+	s.ready[pos] = id
+}
+
+// blocksDependents reports whether dependents of the step must not run
+func blocksDependents(result StepResult) bool {
+	return result.Status == StatusFailed || (result.Status == StatusSkipped && result.cancelled)
+}
+
+// skippedResult builds the result for a step that was cancelled before it started
+func skippedResult(step planner.PlanStep, cause error) StepResult {
+	return StepResult{
+		StepID:    step.ID,
+		Action:    step.Action,
+		Resource:  step.Resource,
+		Target:    step.Target,
+		Optional:  step.Optional,
+		Status:    StatusSkipped,
+		Message:   fmt.Sprintf("cancelled: %v", cause),
+		cancelled: true,
+	}
+}
@@ -0,0 +1,205 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/planner"
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+func TestNewGraph(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []planner.PlanStep
+		wantErr string
+	}{
+		{
+			name: "valid",
+			steps: []planner.PlanStep{
+				{ID: "b", DependsOn: []string{"a"}},
+				{ID: "a"},
+			},
+		},
+		{
+			name:    "duplicate id",
+			steps:   []planner.PlanStep{{ID: "a"}, {ID: "a"}},
+			wantErr: "duplicate step id",
+		},
+		{
+			name:    "unknown dependency",
+			steps:   []planner.PlanStep{{ID: "a", DependsOn: []string{"missing"}}},
+			wantErr: "unknown step missing",
+		},
+		{
+			name: "cycle",
+			steps: []planner.PlanStep{
+				{ID: "a", DependsOn: []string{"c"}},
+				{ID: "b", DependsOn: []string{"a"}},
+				{ID: "c", DependsOn: []string{"b"}},
+			},
+			wantErr: "dependency cycle detected: a -> c -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGraph(tt.steps)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewGraph() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewGraph() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGraphDependents(t *testing.T) {
+	g, err := NewGraph([]planner.PlanStep{
+		{ID: "a"},
+		{ID: "b", DependsOn: []string{"a"}},
+		{ID: "c", DependsOn: []string{"b"}},
+		{ID: "d"},
+	})
+	if err != nil {
+		t.Fatalf("NewGraph() error = %v", err)
+	}
+
+	if got := fmt.Sprint(g.Dependents("a")); got != "[b c]" {
+		t.Errorf("Dependents(a) = %s, want [b c]", got)
+	}
+	if got := g.Dependents("d"); len(got) != 0 {
+		t.Errorf("Dependents(d) = %v, want none", got)
+	}
+}
+
+func TestSchedulerRespectsParallelism(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.Parallelism = 2
+	exec.RegisterHandler("slow", func(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return &Outcome{}, nil
+	})
+
+	plan := &planner.Plan{ID: "parallel"}
+	for i := 0; i < 6; i++ {
+		plan.Steps = append(plan.Steps, planner.PlanStep{ID: fmt.Sprintf("step-%d", i), Resource: "slow"})
+	}
+
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := result.Count(StatusSucceeded); got != 6 {
+		t.Errorf("succeeded steps = %d, want 6", got)
+	}
+	if maxRunning != 2 {
+		t.Errorf("max concurrent steps = %d, want 2", maxRunning)
+	}
+}
+
+func TestSchedulerRunsDependenciesFirst(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.RegisterHandler("record", func(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+		mu.Lock()
+		order = append(order, step.ID)
+		mu.Unlock()
+		return &Outcome{}, nil
+	})
+
+	plan := &planner.Plan{
+		ID: "ordered",
+		Steps: []planner.PlanStep{
+			{ID: "c", Resource: "record", DependsOn: []string{"a", "b"}},
+			{ID: "b", Resource: "record", DependsOn: []string{"a"}},
+			{ID: "a", Resource: "record"},
+		},
+	}
+
+	if _, err := exec.Execute(context.Background(), plan); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := strings.Join(order, ","); got != "a,b,c" {
+		t.Errorf("execution order = %s, want a,b,c", got)
+	}
+}
+
+func TestSchedulerCancelsDependentsOnFailure(t *testing.T) {
+	exec := New(provider.NewMockProvider("mock", "us-east-1"))
+	exec.RegisterHandler("broken", func(ctx context.Context, e *Executor, step planner.PlanStep) (*Outcome, error) {
+		return nil, fmt.Errorf("boom")
+	})
+
+	plan := &planner.Plan{
+		ID: "failure",
+		Steps: []planner.PlanStep{
+			{ID: "vpc", Resource: "broken"},
+			{ID: "subnet", Resource: "subnet", DependsOn: []string{"vpc"}},
+			{ID: "sg", Resource: "security-group", Target: "web-sg", DependsOn: []string{"subnet"}},
+			{ID: "bucket", Resource: "s3-bucket", Target: "assets"},
+		},
+	}
+
+	result, err := exec.Execute(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := map[string]StepStatus{
+		"vpc":    StatusFailed,
+		"subnet": StatusSkipped,
+		"sg":     StatusSkipped,
+		"bucket": StatusSucceeded,
+	}
+	for _, step := range result.Steps {
+		if step.Status != want[step.StepID] {
+			t.Errorf("step %s status = %s, want %s", step.StepID, step.Status, want[step.StepID])
+		}
+	}
+
+	if msg := result.Steps[2].Message; !strings.Contains(msg, "dependency vpc failed") {
+		t.Errorf("sg message = %q, want it to name the failed dependency", msg)
+	}
+}
+
+func TestSchedulerStopsWhenContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	plan := planner.NewBucketPlan("cancelled-bucket", map[string]string{"versioning": "true"})
+	result, err := New(provider.NewMockProvider("mock", "us-east-1")).Execute(ctx, plan)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := result.Count(StatusSkipped); got != len(plan.Steps) {
+		t.Errorf("skipped steps = %d, want %d", got, len(plan.Steps))
+	}
+}
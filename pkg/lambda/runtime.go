@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 )
@@ -369,6 +370,49 @@ func (d *RuntimeDetector) detectJavaHandler() (string, error) {
 	return "com.example.Handler::handleRequest", nil
 }
 
+var (
+	scriptHandlerPattern = regexp.MustCompile(`^[A-Za-z0-9_./-]+\.[A-Za-z_$][A-Za-z0-9_$]*$`)
+	javaHandlerPattern   = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*(::[A-Za-z_$][A-Za-z0-9_$]*)?$`)
+)
+
+// ValidateHandler checks that a handler has the form its runtime expects:
+// file.function for Python and Node.js, package.Class::method for Java
+func ValidateHandler(runtime, handler string) error {
+	if handler == "" {
+		return fmt.Errorf("handler is required")
+	}
+
+	switch {
+	case strings.HasPrefix(runtime, "python"), strings.HasPrefix(runtime, "nodejs"):
+		if !scriptHandlerPattern.MatchString(handler) {
+			return fmt.Errorf("handler %q must be <file>.<function>, such as app.lambda_handler", handler)
+		}
+	case strings.HasPrefix(runtime, "java"):
+		if !javaHandlerPattern.MatchString(handler) {
+			return fmt.Errorf("handler %q must be <package>.<Class>::<method>, such as com.example.Handler::handleRequest", handler)
+		}
+	}
+	return nil
+}
+
+// HandlerFiles returns the source files, relative to the source path, that
+// may define a handler. One of them has to exist for the handler to load.
+func HandlerFiles(runtime, handler string) []string {
+	i := strings.LastIndex(handler, ".")
+	if i <= 0 {
+		return nil
+	}
+	module := handler[:i]
+
+	switch {
+	case strings.HasPrefix(runtime, "python"):
+		return []string{module + ".py"}
+	case strings.HasPrefix(runtime, "nodejs"):
+		return []string{module + ".js", module + ".mjs", module + ".cjs", module + ".ts"}
+	}
+	return nil
+}
+
 // GetRuntimeByName returns a runtime configuration by name
 func GetRuntimeByName(name string) (*Runtime, error) {
 	runtime, exists := SupportedRuntimes[name]
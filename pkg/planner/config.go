@@ -0,0 +1,214 @@
+package planner
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/config"
+)
+
+// EnvPropertyPrefix prefixes function environment variables stored in step properties
+const EnvPropertyPrefix = "env."
+
+// NewConfigPlan creates a plan for the resources declared in a configuration.
+// The configuration is expected to have defaults applied and to be validated.
+func NewConfigPlan(cfg *config.Config) (*Plan, error) {
+	plan := &Plan{
+		ID:          fmt.Sprintf("config-%d", time.Now().Unix()),
+		Title:       "Deploy Configured Resources",
+		Description: fmt.Sprintf("Create the resources declared in the configuration on %s (%s)", cfg.Provider, cfg.Region),
+		CreatedAt:   time.Now(),
+		Duration:    "varies by resource",
+	}
+
+	networkSteps := make(map[string]string)
+	for _, network := range cfg.Resources.Network {
+		stepID := "network-" + network.Name
+		networkSteps[network.Name] = stepID
+
+		plan.Steps = append(plan.Steps, PlanStep{
+			ID:          stepID,
+			Action:      "create",
+			Resource:    "vpc",
+			Description: fmt.Sprintf("Create network '%s' (%s)", network.Name, network.CIDR),
+			IAMActions:  []string{"ec2:CreateVpc", "ec2:CreateTags"},
+			Target:      network.Name,
+			Properties:  map[string]string{"cidr": network.CIDR},
+			Tags:        network.Tags,
+		})
+
+		for _, subnet := range network.Subnets {
+			plan.Steps = append(plan.Steps, PlanStep{
+				ID:          fmt.Sprintf("subnet-%s-%s", network.Name, subnet.Name),
+				Action:      "create",
+				Resource:    "subnet",
+				Description: fmt.Sprintf("Create subnet '%s' (%s) in '%s'", subnet.Name, subnet.CIDR, network.Name),
+				IAMActions:  []string{"ec2:CreateSubnet"},
+				DependsOn:   []string{stepID},
+				Target:      subnet.Name,
+				Properties: map[string]string{
+					"cidr":   subnet.CIDR,
+					"public": strconv.FormatBool(subnet.Public),
+					"az":     subnet.AZ,
+				},
+			})
+		}
+	}
+
+	for _, storage := range cfg.Resources.Storage {
+		step := PlanStep{
+			ID:          "storage-" + storage.Name,
+			Action:      "create",
+			Description: fmt.Sprintf("Create %s '%s'", storage.Type, storage.Name),
+			Target:      storage.Name,
+			Tags:        storage.Tags,
+		}
+
+		if storage.Type == "bucket" {
+			step.Resource = "s3-bucket"
+			step.IAMActions = []string{"s3:CreateBucket", "s3:PutBucketVersioning", "s3:PutBucketEncryption", "s3:PutBucketTagging"}
+			step.Properties = map[string]string{
+				"versioning": strconv.FormatBool(storage.Versioning),
+				"encryption": strconv.FormatBool(storage.Encryption),
+				"public":     strconv.FormatBool(storage.PublicAccess),
+			}
+			if storage.Lifecycle != nil {
+				step.Properties["delete_after_days"] = strconv.Itoa(storage.Lifecycle.DeleteAfterDays)
+				step.Properties["archive_after_days"] = strconv.Itoa(storage.Lifecycle.ArchiveAfterDays)
+			}
+		} else {
+			step.Resource = storage.Type
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	for _, database := range cfg.Resources.Database {
+		props := map[string]string{
+			"engine":   database.Engine,
+			"version":  database.Version,
+			"size":     database.Size,
+			"storage":  strconv.Itoa(database.Storage),
+			"multi_az": strconv.FormatBool(database.MultiAZ),
+		}
+		if database.Backup != nil {
+			props["backup"] = "true"
+			props["backup_retention_days"] = strconv.Itoa(database.Backup.RetentionDays)
+			props["backup_window"] = database.Backup.Window
+		}
+
+		plan.Steps = append(plan.Steps, PlanStep{
+			ID:          "database-" + database.Name,
+			Action:      "create",
+			Resource:    "rds-instance",
+			Description: fmt.Sprintf("Create %s %s database '%s' (%s, %dGB)", database.Engine, database.Version, database.Name, database.Size, database.Storage),
+			IAMActions:  []string{"rds:CreateDBInstance", "rds:AddTagsToResource"},
+			Target:      database.Name,
+			Properties:  props,
+			Tags:        database.Tags,
+		})
+	}
+
+	for _, compute := range cfg.Resources.Compute {
+		var dependsOn []string
+		if stepID, ok := networkSteps[compute.Network]; ok {
+			dependsOn = []string{stepID}
+		}
+
+		for _, name := range instanceNames(compute.Name, compute.Count) {
+			props := map[string]string{
+				"type":    compute.Type,
+				"image":   compute.Image,
+				"network": compute.Network,
+			}
+			if compute.Subnet != "" {
+				props["subnet"] = compute.Subnet
+			}
+			for i, group := range compute.SecurityGroups {
+				props[fmt.Sprintf("security_group.%d", i)] = group
+			}
+
+			plan.Steps = append(plan.Steps, PlanStep{
+				ID:          "compute-" + name,
+				Action:      "create",
+				Resource:    "instance",
+				Description: fmt.Sprintf("Create %s instance '%s' (%s)", compute.Type, name, compute.Image),
+				IAMActions:  []string{"ec2:RunInstances", "ec2:CreateTags"},
+				DependsOn:   dependsOn,
+				Target:      name,
+				Properties:  props,
+				Tags:        compute.Tags,
+			})
+		}
+	}
+
+	for _, function := range cfg.Resources.Serverless {
+		roleStep := "role-" + function.Name
+		plan.Steps = append(plan.Steps, PlanStep{
+			ID:          roleStep,
+			Action:      "create",
+			Resource:    "iam-role",
+			Description: fmt.Sprintf("Create execution role for function '%s'", function.Name),
+			IAMActions:  []string{"iam:CreateRole", "iam:AttachRolePolicy"},
+			Target:      fmt.Sprintf("genesys-lambda-%s", function.Name),
+		})
+
+		props := map[string]string{
+			"runtime": function.Runtime,
+			"handler": function.Handler,
+			"memory":  strconv.Itoa(function.Memory),
+			"timeout": strconv.Itoa(function.Timeout),
+		}
+		for key, value := range function.Environment {
+			props[EnvPropertyPrefix+key] = value
+		}
+
+		plan.Steps = append(plan.Steps, PlanStep{
+			ID:          "function-" + function.Name,
+			Action:      "create",
+			Resource:    "lambda-function",
+			Description: fmt.Sprintf("Create function '%s' (%s, %dMB)", function.Name, function.Runtime, function.Memory),
+			IAMActions:  []string{"lambda:CreateFunction"},
+			DependsOn:   []string{roleStep},
+			Target:      function.Name,
+			Properties:  props,
+			Tags:        function.Tags,
+		})
+	}
+
+	if len(plan.Steps) == 0 {
+		return nil, fmt.Errorf("configuration does not declare any resources")
+	}
+
+	if err := linkReferences(plan); err != nil {
+		return nil, err
+	}
+
+	var actions []string
+	for _, step := range plan.Steps {
+		actions = append(actions, step.IAMActions...)
+	}
+	plan.Permissions = IAMForecast{
+		Actions: removeDuplicates(actions),
+	}
+	plan.Cost = CostEstimate{
+		Currency:   "USD",
+		Confidence: "low",
+	}
+
+	return plan, nil
+}
+
+// instanceNames expands a compute resource into one name per instance
+func instanceNames(name string, count int) []string {
+	if count <= 1 {
+		return []string{name}
+	}
+
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", name, i+1)
+	}
+	return names
+}
@@ -0,0 +1,83 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/config"
+)
+
+func TestNewConfigPlan(t *testing.T) {
+	cfg := &config.Config{
+		Provider: "aws",
+		Resources: config.Resources{
+			Network: []config.NetworkResource{
+				{
+					Name: "app-vpc",
+					CIDR: "10.0.0.0/16",
+					Subnets: []config.SubnetConfig{
+						{Name: "public-1", CIDR: "10.0.1.0/24", Public: true},
+					},
+				},
+			},
+			Compute: []config.ComputeResource{
+				{Name: "web", Type: "medium", Count: 3, Network: "app-vpc"},
+			},
+			Storage: []config.StorageResource{
+				{Name: "assets", Type: "bucket"},
+			},
+			Serverless: []config.ServerlessResource{
+				{Name: "worker", Environment: map[string]string{"MODE": "batch"}},
+			},
+		},
+	}
+	config.ApplyDefaults(cfg)
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig() error = %v", err)
+	}
+
+	plan, err := NewConfigPlan(cfg)
+	if err != nil {
+		t.Fatalf("NewConfigPlan() error = %v", err)
+	}
+
+	steps := make(map[string]PlanStep)
+	for _, step := range plan.Steps {
+		steps[step.ID] = step
+	}
+
+	for _, id := range []string{
+		"network-app-vpc", "subnet-app-vpc-public-1", "storage-assets",
+		"compute-web-1", "compute-web-2", "compute-web-3",
+		"role-worker", "function-worker",
+	} {
+		if _, ok := steps[id]; !ok {
+			t.Errorf("missing step %s", id)
+		}
+	}
+
+	if len(plan.Steps) != 8 {
+		t.Errorf("Expected 8 steps, got %d", len(plan.Steps))
+	}
+
+	if deps := steps["compute-web-2"].DependsOn; len(deps) != 1 || deps[0] != "network-app-vpc" {
+		t.Errorf("compute-web-2 DependsOn = %v, want [network-app-vpc]", deps)
+	}
+
+	if deps := steps["function-worker"].DependsOn; len(deps) != 1 || deps[0] != "role-worker" {
+		t.Errorf("function-worker DependsOn = %v, want [role-worker]", deps)
+	}
+
+	if got := steps["function-worker"].Properties[EnvPropertyPrefix+"MODE"]; got != "batch" {
+		t.Errorf("function-worker env MODE = %q, want batch", got)
+	}
+
+	if got := steps["storage-assets"].Properties["versioning"]; got != "true" {
+		t.Errorf("storage-assets versioning = %q, want true (default)", got)
+	}
+}
+
+func TestNewConfigPlanRequiresResources(t *testing.T) {
+	if _, err := NewConfigPlan(&config.Config{Provider: "aws"}); err == nil {
+		t.Error("NewConfigPlan() expected error for a config without resources")
+	}
+}
@@ -0,0 +1,515 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+// Step actions. Diff turns create steps into one of the others when the
+// resource already exists.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionReplace = "replace"
+	ActionDelete  = "delete"
+	ActionNoOp    = "no-op"
+)
+
+// FieldChange is a difference between an existing resource and the configuration
+type FieldChange struct {
+	Field   string `json:"field"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	Replace bool   `json:"forces_replacement,omitempty"`
+}
+
+// String returns the change as "field: old → new"
+func (c FieldChange) String() string {
+	old, new := c.Old, c.New
+	if old == "" {
+		old = "(none)"
+	}
+	if new == "" {
+		new = "(none)"
+	}
+
+	change := fmt.Sprintf("%s: %s → %s", c.Field, old, new)
+	if c.Replace {
+		change += " (forces replacement)"
+	}
+	return change
+}
+
+// diffableResource describes how steps for a resource type are compared
+// against existing resources. Fields in replaceFields always force a
+// replacement; those in replaceWhen only for the changes it reports.
+// Fields in equivalent are compared with their function instead of as
+// strings. Replacing a stateful resource loses its data.
+type diffableResource struct {
+	stateType     string
+	label         string
+	live          bool
+	stateful      bool
+	replaceFields []string
+	replaceWhen   map[string]func(current, desired string) bool
+	equivalent    map[string]func(current, desired string) bool
+	updateActions []string
+	deleteActions []string
+}
+
+var diffableResources = map[string]diffableResource{
+	"s3-bucket": {
+		stateType:     "s3",
+		live:          true,
+		label:         "bucket",
+		updateActions: []string{"s3:PutBucketVersioning", "s3:PutBucketEncryption", "s3:PutBucketTagging"},
+		deleteActions: []string{"s3:DeleteBucket"},
+	},
+	"instance": {
+		stateType:     "ec2",
+		live:          true,
+		label:         "instance",
+		replaceFields: []string{"type", "image", "network", "key_pair", "security_group."},
+		updateActions: []string{"ec2:CreateTags"},
+		deleteActions: []string{"ec2:TerminateInstances"},
+	},
+	"rds-instance": {
+		stateType:     "rds",
+		live:          true,
+		label:         "database",
+		stateful:      true,
+		replaceFields: []string{"engine"},
+		replaceWhen:   map[string]func(current, desired string) bool{"version": majorVersionChanged},
+		equivalent:    map[string]func(current, desired string) bool{"version": sameVersion},
+		updateActions: []string{"rds:ModifyDBInstance"},
+		deleteActions: []string{"rds:DeleteDBInstance"},
+	},
+	"lambda-function": {
+		stateType:     "lambda",
+		live:          true,
+		label:         "function",
+		updateActions: []string{"lambda:UpdateFunctionConfiguration"},
+		deleteActions: []string{"lambda:DeleteFunction"},
+	},
+	"vpc": {
+		stateType:     "vpc",
+		live:          true,
+		label:         "network",
+		replaceFields: []string{"cidr"},
+	},
+	"subnet": {
+		stateType:     "subnet",
+		label:         "subnet",
+		replaceFields: []string{"cidr", "public", "az"},
+	},
+	"security-group": {
+		stateType:     "security-group",
+		label:         "security group",
+		replaceFields: []string{"description"},
+	},
+}
+
+// ResourceForState returns the plan resource type of resources recorded in
+// state as stateType
+func ResourceForState(stateType string) (string, bool) {
+	for name, resource := range diffableResources {
+		if resource.stateType == stateType {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// HasLiveLookup reports whether the settings of a resource type can be read
+// back from the provider and compared
+func HasLiveLookup(resource string) bool {
+	return diffableResources[resource].live
+}
+
+// IsStateful reports whether replacing a resource of the given type deletes
+// the data it holds, such as a database
+func IsStateful(resource string) bool {
+	return diffableResources[resource].stateful
+}
+
+// Diff compares the create steps of a plan with the resources recorded in
+// local state and their live counterparts. Steps for resources that already
+// exist become updates, replacements or no-ops carrying a field-level diff.
+// When source is set, resources recorded from that configuration that the
+// plan no longer declares get a delete step.
+func (p *Planner) Diff(ctx context.Context, plan *Plan, st *state.LocalState, source string) error {
+	declared := make(map[string]bool)
+
+	// Steps are compared after the steps they refer to, so references to
+	// resources that are kept can be resolved from state
+	order, cycle := referenceOrder(plan)
+	if cycle != nil {
+		return fmt.Errorf("references form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+	steps := make(map[string]*PlanStep)
+	for i := range plan.Steps {
+		steps[plan.Steps[i].ID] = &plan.Steps[i]
+	}
+
+	var unresolved []string
+	for _, i := range order {
+		step := &plan.Steps[i]
+		missing, err := resolveReferences(step, steps, st)
+		if err != nil {
+			return err
+		}
+		unresolved = append(unresolved, missing...)
+
+		resource, ok := diffableResources[step.Resource]
+		if !ok || step.Action != ActionCreate {
+			continue
+		}
+		declared[resource.stateType+"/"+step.Target] = true
+
+		record, ok := st.FindResource(resource.stateType, step.Target)
+		if !ok {
+			continue
+		}
+
+		// Whatever happens next acts on the recorded resource
+		step.ResourceID = record.ID
+
+		exists, err := p.diffStep(ctx, step, resource, record.Attributes)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			step.Reason = fmt.Sprintf("%s is recorded in state but no longer exists", record.ID)
+		}
+	}
+
+	if len(unresolved) > 0 {
+		return fmt.Errorf("unresolved references:\n  %s", strings.Join(unresolved, "\n  "))
+	}
+
+	if source != "" {
+		plan.Steps = append(plan.Steps, deleteSteps(st, source, declared)...)
+	}
+
+	var actions []string
+	for _, step := range plan.Steps {
+		actions = append(actions, step.IAMActions...)
+	}
+	plan.Permissions.Actions = removeDuplicates(actions)
+
+	return nil
+}
+
+// DiffExisting compares a create step with an existing resource that is not
+// necessarily recorded in state, turning the step into an update, replace or
+// no-op. It reports false, leaving the step unchanged, when the resource does
+// not exist.
+func (p *Planner) DiffExisting(ctx context.Context, step *PlanStep, id string) (bool, error) {
+	resource, ok := diffableResources[step.Resource]
+	if !ok {
+		return false, fmt.Errorf("cannot compare %s resources", step.Resource)
+	}
+
+	step.ResourceID = id
+	exists, err := p.diffStep(ctx, step, resource, nil)
+	if !exists {
+		step.ResourceID = ""
+	}
+	return exists, err
+}
+
+// diffStep compares a single step with the live resource identified by
+// step.ResourceID and the attributes it was last applied with
+func (p *Planner) diffStep(ctx context.Context, step *PlanStep, resource diffableResource, applied map[string]string) (bool, error) {
+	live, err := p.liveAttributes(ctx, step.Resource, step.ResourceID)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read %s %s: %w", resource.label, step.Target, err)
+	}
+
+	current := make(map[string]string)
+	for key, value := range applied {
+		current[key] = value
+	}
+	for key, value := range live {
+		current[key] = value
+	}
+
+	step.Changes = diffFields(step, resource, current)
+
+	switch {
+	case len(step.Changes) == 0:
+		step.Action = ActionNoOp
+		step.Description = fmt.Sprintf("No changes to %s '%s'", resource.label, step.Target)
+		step.IAMActions = nil
+	case forcesReplacement(step.Changes):
+		step.Action = ActionReplace
+		step.Description = fmt.Sprintf("Replace %s '%s' (%s)", resource.label, step.Target, pluralChanges(len(step.Changes)))
+		step.IAMActions = append(append([]string{}, resource.deleteActions...), step.IAMActions...)
+	default:
+		step.Action = ActionUpdate
+		step.Description = fmt.Sprintf("Update %s '%s' (%s)", resource.label, step.Target, pluralChanges(len(step.Changes)))
+		step.IAMActions = resource.updateActions
+	}
+
+	return true, nil
+}
+
+// liveAttributes reads the settings of an existing resource from the provider.
+// Resource types without a lookup return no attributes.
+func (p *Planner) liveAttributes(ctx context.Context, resource, id string) (map[string]string, error) {
+	switch resource {
+	case "s3-bucket":
+		bucket, err := p.provider.Storage().GetBucket(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return withTags(map[string]string{
+			"versioning": strconv.FormatBool(bucket.Versioning),
+			"encryption": strconv.FormatBool(bucket.Encryption),
+		}, bucket.Tags), nil
+
+	case "instance":
+		instance, err := p.provider.Compute().GetInstance(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if instance.State == "terminated" {
+			return nil, fmt.Errorf("instance %s not found (terminated)", id)
+		}
+		return withTags(map[string]string{
+			"type": string(instance.Type),
+		}, instance.Tags), nil
+
+	case "rds-instance":
+		database, err := p.provider.Database().GetDatabase(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return withTags(map[string]string{
+			"engine":   database.Engine,
+			"version":  database.Version,
+			"size":     string(database.Size),
+			"storage":  strconv.Itoa(database.Storage),
+			"multi_az": strconv.FormatBool(database.MultiAZ),
+		}, database.Tags), nil
+
+	case "lambda-function":
+		function, err := p.provider.Serverless().GetFunction(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		attributes := map[string]string{
+			"runtime": function.Runtime,
+			"handler": function.Handler,
+			"memory":  strconv.Itoa(function.Memory),
+			"timeout": strconv.Itoa(function.Timeout),
+		}
+		for key, value := range function.Environment {
+			attributes[EnvPropertyPrefix+key] = value
+		}
+		return withTags(attributes, function.Tags), nil
+
+	case "vpc":
+		network, err := p.provider.Network().GetNetwork(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"cidr": network.CIDR}, nil
+	}
+
+	return nil, nil
+}
+
+// diffFields compares the desired properties and tags of a step with the
+// current attributes. Attributes that are not known are not compared.
+func diffFields(step *PlanStep, resource diffableResource, current map[string]string) []FieldChange {
+	desired := make(map[string]string)
+	for key, value := range step.Properties {
+		desired[key] = value
+	}
+	for key, value := range step.Tags {
+		desired["tags."+key] = value
+	}
+
+	var changes []FieldChange
+	for key, value := range desired {
+		old, known := current[key]
+		if !known || old == value {
+			continue
+		}
+		if equivalent, ok := resource.equivalent[key]; ok && equivalent(old, value) {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field:   key,
+			Old:     old,
+			New:     value,
+			Replace: isReplaceChange(resource, key, old, value),
+		})
+	}
+
+	// Environment variables that are no longer configured are removed
+	for key, old := range current {
+		if _, ok := desired[key]; ok || !strings.HasPrefix(key, EnvPropertyPrefix) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: key, Old: old})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// deleteSteps creates steps removing the resources recorded from source that
+// are no longer declared
+func deleteSteps(st *state.LocalState, source string, declared map[string]bool) []PlanStep {
+	var steps []PlanStep
+	for _, record := range st.FindResourcesByConfigFile(source) {
+		if declared[record.Type+"/"+record.Name] {
+			continue
+		}
+
+		for name, resource := range diffableResources {
+			if resource.stateType != record.Type || len(resource.deleteActions) == 0 {
+				continue
+			}
+			steps = append(steps, PlanStep{
+				ID:          fmt.Sprintf("delete-%s-%s", record.Type, record.Name),
+				Action:      ActionDelete,
+				Resource:    name,
+				Description: fmt.Sprintf("Delete %s '%s'", resource.label, record.Name),
+				Reason:      "no longer declared in the configuration",
+				IAMActions:  resource.deleteActions,
+				Target:      record.Name,
+				ResourceID:  record.ID,
+			})
+		}
+	}
+
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].ID < steps[j].ID
+	})
+	return steps
+}
+
+// NewDestroyPlan creates a plan deleting the resources recorded in state from
+// source. The records of resources the executor cannot delete are returned
+// alongside the plan and left in place.
+func NewDestroyPlan(st *state.LocalState, source string) (*Plan, []state.ResourceRecord) {
+	plan := &Plan{
+		ID:          fmt.Sprintf("destroy-%d", time.Now().Unix()),
+		Title:       "Destroy Configured Resources",
+		Description: fmt.Sprintf("Delete the resources recorded in state for %s", source),
+		CreatedAt:   time.Now(),
+		Duration:    "varies by resource",
+	}
+
+	plan.Steps = deleteSteps(st, source, nil)
+	var actions []string
+	for i := range plan.Steps {
+		plan.Steps[i].Reason = "deletion requested"
+		actions = append(actions, plan.Steps[i].IAMActions...)
+	}
+	plan.Permissions.Actions = removeDuplicates(actions)
+	plan.Cost = CostEstimate{Currency: "USD", Confidence: "high"}
+
+	var kept []state.ResourceRecord
+	for _, record := range st.FindResourcesByConfigFile(source) {
+		if !deletable(record.Type) {
+			kept = append(kept, record)
+		}
+	}
+	return plan, kept
+}
+
+// deletable reports whether resources of a recorded type can be deleted
+func deletable(stateType string) bool {
+	for _, resource := range diffableResources {
+		if resource.stateType == stateType && len(resource.deleteActions) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// withTags adds tags to a set of attributes using "tags.<key>" names
+func withTags(attributes, tags map[string]string) map[string]string {
+	for key, value := range tags {
+		attributes["tags."+key] = value
+	}
+	return attributes
+}
+
+func isReplaceChange(resource diffableResource, field, current, desired string) bool {
+	if replace, ok := resource.replaceWhen[field]; ok {
+		return replace(current, desired)
+	}
+	for _, name := range resource.replaceFields {
+		if field == name || (strings.HasSuffix(name, ".") && strings.HasPrefix(field, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameVersion reports whether an engine version such as "15.4" satisfies
+// the configured one, which may name only the major version ("15") or be
+// left to the provider ("" or "latest")
+func sameVersion(current, desired string) bool {
+	if desired == "" || desired == "latest" {
+		return true
+	}
+	have, want := strings.Split(current, "."), strings.Split(desired, ".")
+	if len(want) > len(have) {
+		return false
+	}
+	for i := range want {
+		if want[i] != have[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// majorVersionChanged reports whether two engine versions differ in their
+// major version, which cannot be changed in place
+func majorVersionChanged(current, desired string) bool {
+	major := func(version string) string {
+		return strings.SplitN(version, ".", 2)[0]
+	}
+	return major(current) != major(desired)
+}
+
+func forcesReplacement(changes []FieldChange) bool {
+	for _, change := range changes {
+		if change.Replace {
+			return true
+		}
+	}
+	return false
+}
+
+func pluralChanges(n int) string {
+	if n == 1 {
+		return "1 change"
+	}
+	return fmt.Sprintf("%d changes", n)
+}
+
+// isNotFound reports whether a provider error means the resource does not exist
+func isNotFound(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "not found") || strings.Contains(message, "notfound") ||
+		strings.Contains(message, "does not exist")
+}
@@ -0,0 +1,188 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+// diffProvider is a mock provider whose buckets are looked up in a map
+type diffProvider struct {
+	provider.Provider
+	buckets map[string]*provider.Bucket
+}
+
+func (p *diffProvider) Storage() provider.StorageService {
+	return &diffStorage{StorageService: p.Provider.Storage(), buckets: p.buckets}
+}
+
+type diffStorage struct {
+	provider.StorageService
+	buckets map[string]*provider.Bucket
+}
+
+func (s *diffStorage) GetBucket(ctx context.Context, name string) (*provider.Bucket, error) {
+	if bucket, ok := s.buckets[name]; ok {
+		return bucket, nil
+	}
+	return nil, fmt.Errorf("bucket %s not found", name)
+}
+
+func TestDiff(t *testing.T) {
+	p := &diffProvider{
+		Provider: provider.NewMockProvider("mock", "us-east-1"),
+		buckets: map[string]*provider.Bucket{
+			"logs":   {Name: "logs", Versioning: true, Encryption: true},
+			"assets": {Name: "assets", Versioning: true, Encryption: true},
+		},
+	}
+
+	st := &state.LocalState{Resources: []state.ResourceRecord{
+		{ID: "logs", Name: "logs", Type: "s3", ConfigFile: "app.yaml"},
+		{ID: "assets", Name: "assets", Type: "s3", ConfigFile: "app.yaml",
+			Attributes: map[string]string{"public": "false"}},
+		{ID: "gone", Name: "gone", Type: "s3", ConfigFile: "app.yaml"},
+		{ID: "i-123", Name: "web", Type: "ec2", ConfigFile: "app.yaml",
+			Attributes: map[string]string{"image": "ubuntu-lts"}},
+		{ID: "old-fn", Name: "old-fn", Type: "lambda", ConfigFile: "app.yaml"},
+		{ID: "other", Name: "other", Type: "s3", ConfigFile: "other.yaml"},
+	}}
+
+	plan := &Plan{Steps: []PlanStep{
+		{ID: "logs", Action: ActionCreate, Resource: "s3-bucket", Target: "logs",
+			Properties: map[string]string{"versioning": "false", "encryption": "true"}},
+		{ID: "assets", Action: ActionCreate, Resource: "s3-bucket", Target: "assets",
+			Properties: map[string]string{"versioning": "true", "encryption": "true", "public": "false"}},
+		{ID: "gone", Action: ActionCreate, Resource: "s3-bucket", Target: "gone",
+			Properties: map[string]string{"versioning": "true"}},
+		{ID: "web", Action: ActionCreate, Resource: "instance", Target: "web",
+			Properties: map[string]string{"type": "medium", "image": "debian"}},
+		{ID: "new", Action: ActionCreate, Resource: "s3-bucket", Target: "new"},
+	}}
+
+	if err := New(p).Diff(context.Background(), plan, st, "app.yaml"); err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	steps := make(map[string]PlanStep)
+	for _, step := range plan.Steps {
+		steps[step.ID] = step
+	}
+
+	tests := []struct {
+		step    string
+		action  string
+		changes string
+	}{
+		{"logs", ActionUpdate, "versioning: true → false"},
+		{"assets", ActionNoOp, ""},
+		{"gone", ActionCreate, ""},
+		{"web", ActionReplace, "image: ubuntu-lts → debian (forces replacement)"},
+		{"new", ActionCreate, ""},
+		{"delete-lambda-old-fn", ActionDelete, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.step, func(t *testing.T) {
+			step, ok := steps[tt.step]
+			if !ok {
+				t.Fatalf("missing step %s", tt.step)
+			}
+			if step.Action != tt.action {
+				t.Errorf("action = %s, want %s", step.Action, tt.action)
+			}
+
+			var changes []string
+			for _, change := range step.Changes {
+				changes = append(changes, change.String())
+			}
+			if got := strings.Join(changes, "; "); got != tt.changes {
+				t.Errorf("changes = %q, want %q", got, tt.changes)
+			}
+		})
+	}
+
+	if reason := steps["gone"].Reason; !strings.Contains(reason, "no longer exists") {
+		t.Errorf("gone reason = %q, want it to explain the bucket is missing", reason)
+	}
+	if _, ok := steps["delete-s3-other"]; ok {
+		t.Error("Diff() deleted a resource recorded from another configuration")
+	}
+	if len(plan.Steps) != 6 {
+		t.Errorf("Expected 6 steps, got %d", len(plan.Steps))
+	}
+
+	output := plan.ToHumanReadable()
+	if !strings.Contains(output, "Changes: 2 to create, 1 to update, 1 to replace, 1 to delete, 1 unchanged") {
+		t.Errorf("ToHumanReadable() is missing the change summary:\n%s", output)
+	}
+}
+
+func TestDiffDatabase(t *testing.T) {
+	// The mock database runs PostgreSQL 15.4 on a medium instance without Multi-AZ
+	p := New(provider.NewMockProvider("mock", "us-east-1"))
+
+	tests := []struct {
+		name       string
+		properties map[string]string
+		action     string
+		changes    string
+	}{
+		{"major version", map[string]string{"engine": "postgres", "version": "15", "multi_az": "false"}, ActionNoOp, ""},
+		{"latest", map[string]string{"engine": "postgres", "version": "latest"}, ActionNoOp, ""},
+		{"multi-az", map[string]string{"version": "15", "multi_az": "true"}, ActionUpdate, "multi_az: false → true"},
+		{"minor upgrade", map[string]string{"version": "15.5"}, ActionUpdate, "version: 15.4 → 15.5"},
+		{"major upgrade", map[string]string{"version": "16"}, ActionReplace, "version: 15.4 → 16 (forces replacement)"},
+		{"engine", map[string]string{"engine": "mysql", "version": "8.0"}, ActionReplace,
+			"engine: postgres → mysql (forces replacement); version: 15.4 → 8.0 (forces replacement)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := PlanStep{ID: "db", Action: ActionCreate, Resource: "rds-instance", Target: "db", Properties: tt.properties}
+			if _, err := p.DiffExisting(context.Background(), &step, "db-1"); err != nil {
+				t.Fatalf("DiffExisting() error = %v", err)
+			}
+			if step.Action != tt.action {
+				t.Errorf("action = %s, want %s", step.Action, tt.action)
+			}
+
+			var changes []string
+			for _, change := range step.Changes {
+				changes = append(changes, change.String())
+			}
+			if got := strings.Join(changes, "; "); got != tt.changes {
+				t.Errorf("changes = %q, want %q", got, tt.changes)
+			}
+		})
+	}
+}
+
+func TestNewDestroyPlan(t *testing.T) {
+	st := &state.LocalState{Resources: []state.ResourceRecord{
+		{ID: "logs", Name: "logs", Type: "s3", ConfigFile: "app.yaml"},
+		{ID: "i-123", Name: "web", Type: "ec2", ConfigFile: "app.yaml"},
+		{ID: "vpc-123", Name: "main", Type: "vpc", ConfigFile: "app.yaml"},
+		{ID: "other", Name: "other", Type: "s3", ConfigFile: "other.yaml"},
+	}}
+
+	plan, kept := NewDestroyPlan(st, "app.yaml")
+
+	var ids []string
+	for _, step := range plan.Steps {
+		if step.Action != ActionDelete {
+			t.Errorf("step %s has action %s, want delete", step.ID, step.Action)
+		}
+		ids = append(ids, step.ID+"="+step.ResourceID)
+	}
+	if got, want := strings.Join(ids, ","), "delete-ec2-web=i-123,delete-s3-logs=logs"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+	if len(kept) != 1 || kept[0].ID != "vpc-123" {
+		t.Errorf("kept = %+v, want the vpc", kept)
+	}
+}
@@ -1,8 +1,10 @@
 package planner
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 )
@@ -29,6 +31,21 @@ type PlanStep struct {
 	IAMActions  []string `json:"iam_actions,omitempty"`
 	DependsOn   []string `json:"depends_on,omitempty"`
 	Optional    bool     `json:"optional,omitempty"`
+
+	// Target is the name of the resource the step acts on
+	Target string `json:"target,omitempty"`
+	// Properties carries the settings the executor needs to perform the step
+	Properties map[string]string `json:"properties,omitempty"`
+	// Tags are applied to the resource the step creates
+	Tags map[string]string `json:"tags,omitempty"`
+	// ResourceID identifies the existing resource an update, replace, delete
+	// or no-op step acts on
+	ResourceID string `json:"resource_id,omitempty"`
+	// Changes lists the fields an update or replace step changes
+	Changes []FieldChange `json:"changes,omitempty"`
+	// References maps the ${...} expressions left in Properties and Tags to
+	// the outputs of the steps that replace them when the step runs
+	References map[string]Reference `json:"references,omitempty"`
 }
 
 // IAMForecast represents required IAM permissions
@@ -72,6 +89,13 @@ func (p *Plan) ToHumanReadable() string {
 		if step.Reason != "" {
 			output.WriteString(fmt.Sprintf("     → %s\n", step.Reason))
 		}
+		for _, change := range step.Changes {
+			output.WriteString(fmt.Sprintf("     ~ %s\n", change))
+		}
+	}
+
+	if summary := p.changeSummary(); summary != "" {
+		output.WriteString(fmt.Sprintf("\n%s\n", summary))
 	}
 
 	// Permissions
@@ -107,6 +131,21 @@ func (p *Plan) ToHumanReadable() string {
 	return output.String()
 }
 
+// changeSummary counts the steps per action once the plan has been compared
+// with existing resources
+func (p *Plan) changeSummary() string {
+	counts := make(map[string]int)
+	for _, step := range p.Steps {
+		counts[step.Action]++
+	}
+	if counts[ActionUpdate]+counts[ActionReplace]+counts[ActionDelete]+counts[ActionNoOp] == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("Changes: %d to create, %d to update, %d to replace, %d to delete, %d unchanged",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionReplace], counts[ActionDelete], counts[ActionNoOp])
+}
+
 // ToJSON converts the plan to JSON format
 func (p *Plan) ToJSON() string {
 	data, _ := json.MarshalIndent(p, "", "  ")
@@ -135,6 +174,12 @@ func NewBucketPlan(name string, params map[string]string) *Plan {
 			Description: fmt.Sprintf("Create S3 bucket '%s'", name),
 			Reason:      "Store your application data securely",
 			IAMActions:  []string{"s3:CreateBucket"},
+			Target:      name,
+			Properties: map[string]string{
+				"versioning": params["versioning"],
+				"encryption": params["encryption"],
+				"public":     params["public"],
+			},
 		},
 	}
 
@@ -147,6 +192,7 @@ func NewBucketPlan(name string, params map[string]string) *Plan {
 			Reason:      "Protect against accidental deletion or modification",
 			IAMActions:  []string{"s3:PutBucketVersioning"},
 			DependsOn:   []string{"create-bucket"},
+			Target:      name,
 		})
 	}
 
@@ -159,6 +205,7 @@ func NewBucketPlan(name string, params map[string]string) *Plan {
 			Reason:      "Secure data at rest",
 			IAMActions:  []string{"s3:PutBucketEncryption"},
 			DependsOn:   []string{"create-bucket"},
+			Target:      name,
 		})
 	}
 
@@ -171,6 +218,7 @@ func NewBucketPlan(name string, params map[string]string) *Plan {
 			Reason:      "Prevent data exposure",
 			IAMActions:  []string{"s3:PutBucketPublicAccessBlock"},
 			DependsOn:   []string{"create-bucket"},
+			Target:      name,
 		})
 	}
 
@@ -202,14 +250,20 @@ func NewBucketPlan(name string, params map[string]string) *Plan {
 	return plan
 }
 
-// NewNetworkPlan creates a plan for network deployment
-func NewNetworkPlan(name string, params map[string]string) *Plan {
+// NewNetworkPlan creates a plan for network deployment. The public and private
+// subnets are the first two /24 blocks of the VPC's CIDR, or its two halves
+// when it is smaller than a /23.
+func NewNetworkPlan(name string, params map[string]string) (*Plan, error) {
 	planID := fmt.Sprintf("network-%d", time.Now().Unix())
 	cidr := params["cidr"]
 	if cidr == "" {
 		cidr = "10.0.0.0/16"
 	}
-	
+	publicCIDR, privateCIDR, err := subnetCIDRs(cidr)
+	if err != nil {
+		return nil, err
+	}
+
 	plan := &Plan{
 		ID:          planID,
 		Title:       fmt.Sprintf("Deploy Network '%s'", name),
@@ -226,6 +280,8 @@ func NewNetworkPlan(name string, params map[string]string) *Plan {
 			Description: fmt.Sprintf("Create VPC with CIDR %s", cidr),
 			Reason:      "Isolated network environment for your resources",
 			IAMActions:  []string{"ec2:CreateVpc"},
+			Target:      name,
+			Properties:  map[string]string{"cidr": cidr},
 		},
 		{
 			ID:          "create-igw",
@@ -235,33 +291,39 @@ func NewNetworkPlan(name string, params map[string]string) *Plan {
 			Reason:      "Enable internet access for public subnets",
 			IAMActions:  []string{"ec2:CreateInternetGateway", "ec2:AttachInternetGateway"},
 			DependsOn:   []string{"create-vpc"},
+			Target:      name + "-igw",
 		},
 		{
 			ID:          "create-public-subnet",
 			Action:      "create",
 			Resource:    "subnet",
-			Description: "Create public subnet (10.0.1.0/24)",
+			Description: fmt.Sprintf("Create public subnet (%s)", publicCIDR),
 			Reason:      "Host resources that need direct internet access",
 			IAMActions:  []string{"ec2:CreateSubnet"},
 			DependsOn:   []string{"create-vpc"},
+			Target:      name + "-public",
+			Properties:  map[string]string{"cidr": publicCIDR, "public": "true"},
 		},
 		{
 			ID:          "create-private-subnet",
 			Action:      "create",
 			Resource:    "subnet",
-			Description: "Create private subnet (10.0.2.0/24)",
+			Description: fmt.Sprintf("Create private subnet (%s)", privateCIDR),
 			Reason:      "Host resources that don't need direct internet access",
 			IAMActions:  []string{"ec2:CreateSubnet"},
 			DependsOn:   []string{"create-vpc"},
+			Target:      name + "-private",
+			Properties:  map[string]string{"cidr": privateCIDR, "public": "false"},
 		},
 		{
 			ID:          "create-route-tables",
 			Action:      "create",
 			Resource:    "route-table",
-			Description: "Create and configure route tables",
-			Reason:      "Control traffic routing between subnets",
+			Description: "Route the public subnet through the Internet Gateway",
+			Reason:      "The private subnet keeps the VPC's main route table, without internet access",
 			IAMActions:  []string{"ec2:CreateRouteTable", "ec2:CreateRoute", "ec2:AssociateRouteTable"},
-			DependsOn:   []string{"create-public-subnet", "create-private-subnet", "create-igw"},
+			DependsOn:   []string{"create-public-subnet", "create-igw"},
+			Target:      name + "-public",
 		},
 	}
 
@@ -289,7 +351,36 @@ func NewNetworkPlan(name string, params map[string]string) *Plan {
 		},
 	}
 
-	return plan
+	return plan, nil
+}
+
+// subnetCIDRs splits an IPv4 VPC CIDR into the blocks of its public and
+// private subnets
+func subnetCIDRs(cidr string) (string, string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid VPC CIDR %q: %w", cidr, err)
+	}
+	ones, bits := network.Mask.Size()
+	if bits != 32 {
+		return "", "", fmt.Errorf("invalid VPC CIDR %q: must be an IPv4 block", cidr)
+	}
+	// AWS subnets are at least a /28
+	if ones > 27 {
+		return "", "", fmt.Errorf("VPC CIDR %q is too small for two subnets; use a /27 or larger", cidr)
+	}
+
+	size := 24
+	if ones >= size {
+		size = ones + 1
+	}
+	base := binary.BigEndian.Uint32(network.IP.To4())
+	block := func(i uint32) string {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+i<<(32-size))
+		return fmt.Sprintf("%s/%d", ip, size)
+	}
+	return block(0), block(1), nil
 }
 
 // NewFunctionPlan creates a plan for function deployment
@@ -316,6 +407,7 @@ func NewFunctionPlan(name string, params map[string]string) *Plan {
 			Description: "Create Lambda execution role",
 			Reason:      "Allow function to write logs and access AWS services",
 			IAMActions:  []string{"iam:CreateRole", "iam:AttachRolePolicy"},
+			Target:      fmt.Sprintf("genesys-lambda-%s", name),
 		},
 		{
 			ID:          "create-function",
@@ -325,15 +417,8 @@ func NewFunctionPlan(name string, params map[string]string) *Plan {
 			Reason:      "Deploy your serverless code",
 			IAMActions:  []string{"lambda:CreateFunction"},
 			DependsOn:   []string{"create-execution-role"},
-		},
-		{
-			ID:          "create-log-group",
-			Action:      "create",
-			Resource:    "cloudwatch-log-group",
-			Description: "Create CloudWatch log group",
-			Reason:      "Store function execution logs",
-			IAMActions:  []string{"logs:CreateLogGroup"},
-			DependsOn:   []string{"create-function"},
+			Target:      name,
+			Properties:  functionProperties(runtime, params),
 		},
 	}
 
@@ -346,6 +431,7 @@ func NewFunctionPlan(name string, params map[string]string) *Plan {
 			Reason:      "Enable direct HTTP invocation",
 			IAMActions:  []string{"lambda:CreateFunctionUrlConfig"},
 			DependsOn:   []string{"create-function"},
+			Target:      name,
 		})
 	}
 
@@ -381,6 +467,26 @@ func NewFunctionPlan(name string, params map[string]string) *Plan {
 	return plan
 }
 
+// functionProperties collects the function settings the executor passes to the provider
+func functionProperties(runtime string, params map[string]string) map[string]string {
+	props := map[string]string{
+		"runtime": runtime,
+		"handler": params["handler"],
+		"memory":  params["memory"],
+		"timeout": params["timeout"],
+	}
+	if props["handler"] == "" {
+		props["handler"] = "main.handler"
+	}
+	if props["memory"] == "" {
+		props["memory"] = "256"
+	}
+	if props["timeout"] == "" {
+		props["timeout"] = "60"
+	}
+	return props
+}
+
 // removeDuplicates removes duplicate strings from slice
 func removeDuplicates(slice []string) []string {
 	keys := make(map[string]bool)
@@ -73,7 +73,10 @@ func TestNewNetworkPlan(t *testing.T) {
 		"cidr": "10.0.0.0/16",
 	}
 
-	plan := NewNetworkPlan("test-vpc", params)
+	plan, err := NewNetworkPlan("test-vpc", params)
+	if err != nil {
+		t.Fatalf("NewNetworkPlan() error = %v", err)
+	}
 
 	if plan.Title != "Deploy Network 'test-vpc'" {
 		t.Errorf("Expected title 'Deploy Network 'test-vpc'', got %s", plan.Title)
@@ -109,6 +112,50 @@ func TestNewNetworkPlan(t *testing.T) {
 	}
 }
 
+func TestNewNetworkPlanSubnets(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		public  string
+		private string
+		wantErr string
+	}{
+		{cidr: "10.0.0.0/16", public: "10.0.0.0/24", private: "10.0.1.0/24"},
+		{cidr: "172.31.0.0/16", public: "172.31.0.0/24", private: "172.31.1.0/24"},
+		{cidr: "192.168.4.0/22", public: "192.168.4.0/24", private: "192.168.5.0/24"},
+		{cidr: "10.1.2.0/24", public: "10.1.2.0/25", private: "10.1.2.128/25"},
+		{cidr: "10.1.2.64/27", public: "10.1.2.64/28", private: "10.1.2.80/28"},
+		{cidr: "10.1.2.0/28", wantErr: "too small for two subnets"},
+		{cidr: "10.0.0.0", wantErr: "invalid VPC CIDR"},
+		{cidr: "fd00::/56", wantErr: "must be an IPv4 block"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			plan, err := NewNetworkPlan("vpc", map[string]string{"cidr": tt.cidr})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("NewNetworkPlan() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewNetworkPlan() error = %v", err)
+			}
+
+			subnets := make(map[string]PlanStep)
+			for _, step := range plan.Steps {
+				subnets[step.ID] = step
+			}
+			for id, want := range map[string]string{"create-public-subnet": tt.public, "create-private-subnet": tt.private} {
+				step := subnets[id]
+				if step.Properties["cidr"] != want || !strings.Contains(step.Description, want) {
+					t.Errorf("%s cidr = %s (%q), want %s", id, step.Properties["cidr"], step.Description, want)
+				}
+			}
+		})
+	}
+}
+
 func TestNewFunctionPlan(t *testing.T) {
 	params := map[string]string{
 		"runtime": "python3.11",
@@ -0,0 +1,103 @@
+package planner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/config"
+)
+
+// PlanFileVersion is the version of the plan file format written by SavePlanFile
+const PlanFileVersion = 2
+
+// PlanFile is a plan saved for a later apply, together with what it was made from
+type PlanFile struct {
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	ConfigFile string    `json:"config_file"`
+	// Inputs are the other files the configuration was loaded from: the
+	// environment overlay, var files and modules. Their contents are part of
+	// ConfigHash.
+	Inputs      []string `json:"inputs,omitempty"`
+	ConfigHash  string   `json:"config_hash"`
+	StateSerial int64    `json:"state_serial"`
+	Workspace   string   `json:"workspace,omitempty"`
+	Provider    string   `json:"provider"`
+	Region      string   `json:"region"`
+	// Policies are the configuration's policies, checked again by apply
+	Policies config.Policies `json:"policies"`
+	Plan     *Plan           `json:"plan"`
+}
+
+// NewPlanFile wraps a plan made from the given configuration file contents
+// and state serial
+func NewPlanFile(plan *Plan, configFile string, configData []byte, stateSerial int64) *PlanFile {
+	return &PlanFile{
+		Version:     PlanFileVersion,
+		CreatedAt:   time.Now(),
+		ConfigFile:  configFile,
+		ConfigHash:  HashConfig(configData),
+		StateSerial: stateSerial,
+		Plan:        plan,
+	}
+}
+
+// HashConfig returns the hash stored in plan files for a configuration
+func HashConfig(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Save writes the plan file to disk
+func (f *PlanFile) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan file: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write plan file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPlanFile reads a plan file written by Save
+func LoadPlanFile(path string) (*PlanFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	var f PlanFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %w", err)
+	}
+
+	if f.Version != PlanFileVersion {
+		return nil, fmt.Errorf("unsupported plan file version %d (expected %d)", f.Version, PlanFileVersion)
+	}
+	if f.Plan == nil || len(f.Plan.Steps) == 0 {
+		return nil, fmt.Errorf("plan file %s does not contain any steps", path)
+	}
+
+	return &f, nil
+}
+
+// Verify checks that neither the configuration nor the state changed since
+// the plan was made
+func (f *PlanFile) Verify(configData []byte, stateSerial int64) error {
+	if hash := HashConfig(configData); hash != f.ConfigHash {
+		return fmt.Errorf("configuration %s has changed since the plan was created; run 'genesys plan' again", f.ConfigFile)
+	}
+
+	if stateSerial != f.StateSerial {
+		return fmt.Errorf("state has changed since the plan was created (serial %d, now %d); run 'genesys plan' again", f.StateSerial, stateSerial)
+	}
+
+	return nil
+}
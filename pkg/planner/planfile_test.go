@@ -0,0 +1,55 @@
+package planner
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	config := []byte("provider: mock\n")
+	plan := NewBucketPlan("saved-bucket", map[string]string{"versioning": "true"})
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := NewPlanFile(plan, "genesys.yaml", config, 3).Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadPlanFile(path)
+	if err != nil {
+		t.Fatalf("LoadPlanFile() error = %v", err)
+	}
+
+	if len(loaded.Plan.Steps) != len(plan.Steps) {
+		t.Errorf("loaded %d steps, want %d", len(loaded.Plan.Steps), len(plan.Steps))
+	}
+	if loaded.Plan.Steps[0].Target != "saved-bucket" {
+		t.Errorf("first step target = %q, want saved-bucket", loaded.Plan.Steps[0].Target)
+	}
+
+	tests := []struct {
+		name    string
+		config  []byte
+		serial  int64
+		wantErr string
+	}{
+		{name: "unchanged", config: config, serial: 3},
+		{name: "config changed", config: []byte("provider: aws\n"), serial: 3, wantErr: "configuration genesys.yaml has changed"},
+		{name: "state changed", config: config, serial: 4, wantErr: "state has changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loaded.Verify(tt.config, tt.serial)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Verify() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
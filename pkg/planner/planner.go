@@ -67,7 +67,7 @@ func (p *Planner) planNetwork(ctx context.Context, i *intent.Intent) (*Plan, err
 		name = fmt.Sprintf("genesys-vpc-%d", time.Now().Unix())
 	}
 
-	return NewNetworkPlan(name, i.Parameters), nil
+	return NewNetworkPlan(name, i.Parameters)
 }
 
 // planFunction creates a plan for function deployment
@@ -82,17 +82,23 @@ func (p *Planner) planFunction(ctx context.Context, i *intent.Intent) (*Plan, er
 
 // planStaticSite creates a plan for static site deployment
 func (p *Planner) planStaticSite(ctx context.Context, i *intent.Intent) (*Plan, error) {
+	bucketName := i.Name
+	if bucketName == "" {
+		bucketName = fmt.Sprintf("genesys-site-%d", time.Now().Unix())
+	}
 	domain := i.Parameters["domain"]
-	if domain == "" {
-		domain = "example.com"
+
+	description := fmt.Sprintf("Create a static website hosted in S3 bucket %s", bucketName)
+	if domain != "" {
+		description = fmt.Sprintf("Create a static website for %s hosted in S3 bucket %s", domain, bucketName)
 	}
 
 	plan := &Plan{
 		ID:          fmt.Sprintf("static-site-%d", time.Now().Unix()),
 		Title:       "Deploy Static Website",
-		Description: fmt.Sprintf("Create a static website hosted on %s with CDN", domain),
+		Description: description,
 		CreatedAt:   time.Now(),
-		Duration:    "3-5 minutes",
+		Duration:    "1 minute",
 	}
 
 	steps := []PlanStep{
@@ -102,7 +108,13 @@ func (p *Planner) planStaticSite(ctx context.Context, i *intent.Intent) (*Plan,
 			Resource:    "s3-bucket",
 			Description: "Create S3 bucket for website files",
 			Reason:      "Store your website content",
-			IAMActions:  []string{"s3:CreateBucket", "s3:PutBucketWebsite"},
+			IAMActions:  []string{"s3:CreateBucket"},
+			Target:      bucketName,
+			Properties: map[string]string{
+				"versioning": "false",
+				"encryption": "true",
+				"public":     "true",
+			},
 		},
 		{
 			ID:          "configure-hosting",
@@ -110,11 +122,25 @@ func (p *Planner) planStaticSite(ctx context.Context, i *intent.Intent) (*Plan,
 			Resource:    "s3-bucket-website",
 			Description: "Configure bucket for static website hosting",
 			Reason:      "Enable web access to your content",
-			IAMActions:  []string{"s3:PutBucketWebsite", "s3:PutBucketPolicy"},
+			IAMActions:  []string{"s3:PutBucketWebsite", "s3:PutBucketPublicAccessBlock", "s3:PutBucketPolicy"},
 			DependsOn:   []string{"create-bucket"},
+			Target:      bucketName,
+			Properties:  map[string]string{"index": i.Parameters["index"], "error": i.Parameters["error"]},
 		},
 	}
 
+	cost := CostEstimate{
+		Monthly:    6.50,
+		Currency:   "USD",
+		Confidence: "medium",
+		Breakdown: map[string]float64{
+			"S3 hosting":    2.00,
+			"Data transfer": 4.50,
+		},
+	}
+
+	// The executor cannot set up a CDN, certificates or DNS yet; these steps
+	// are shown in previews and rejected when the plan is applied
 	if i.Parameters["cdn"] == "true" {
 		steps = append(steps, PlanStep{
 			ID:          "create-cloudfront",
@@ -124,7 +150,10 @@ func (p *Planner) planStaticSite(ctx context.Context, i *intent.Intent) (*Plan,
 			Reason:      "Improve performance worldwide",
 			IAMActions:  []string{"cloudfront:CreateDistribution"},
 			DependsOn:   []string{"configure-hosting"},
+			Target:      bucketName,
 		})
+		cost.Monthly += 8.00
+		cost.Breakdown["CloudFront"] = 8.00
 	}
 
 	if i.Parameters["https"] == "true" {
@@ -136,10 +165,11 @@ func (p *Planner) planStaticSite(ctx context.Context, i *intent.Intent) (*Plan,
 			Reason:      "Secure your website with encryption",
 			IAMActions:  []string{"acm:RequestCertificate"},
 			Optional:    true,
+			Target:      domain,
 		})
 	}
 
-	if domain != "" && domain != "example.com" {
+	if domain != "" {
 		steps = append(steps, PlanStep{
 			ID:          "configure-dns",
 			Action:      "configure",
@@ -148,7 +178,10 @@ func (p *Planner) planStaticSite(ctx context.Context, i *intent.Intent) (*Plan,
 			Reason:      "Point your domain to the website",
 			IAMActions:  []string{"route53:ChangeResourceRecordSets"},
 			Optional:    true,
+			Target:      domain,
 		})
+		cost.Monthly += 0.50
+		cost.Breakdown["Route53"] = 0.50
 	}
 
 	plan.Steps = steps
@@ -161,19 +194,7 @@ func (p *Planner) planStaticSite(ctx context.Context, i *intent.Intent) (*Plan,
 	plan.Permissions = IAMForecast{
 		Actions: removeDuplicates(actions),
 	}
-
-	// Set cost estimate
-	plan.Cost = CostEstimate{
-		Monthly:    15.00,
-		Currency:   "USD",
-		Confidence: "medium",
-		Breakdown: map[string]float64{
-			"S3 hosting":         2.00,
-			"CloudFront":         8.00,
-			"Route53":            0.50,
-			"Data transfer":      4.50,
-		},
-	}
+	plan.Cost = cost
 
 	return plan, nil
 }
@@ -203,22 +224,22 @@ func (p *Planner) planDatabase(ctx context.Context, i *intent.Intent) (*Plan, er
 		Duration:    "10-15 minutes",
 	}
 
+	// The database is placed in the default VPC's subnets and can be reached
+	// from anywhere in that VPC
 	steps := []PlanStep{
-		{
-			ID:          "create-subnet-group",
-			Action:      "create",
-			Resource:    "db-subnet-group",
-			Description: "Create database subnet group",
-			Reason:      "Define network placement for database",
-			IAMActions:  []string{"rds:CreateDBSubnetGroup"},
-		},
 		{
 			ID:          "create-security-group",
 			Action:      "create",
 			Resource:    "security-group",
-			Description: "Create database security group",
+			Description: fmt.Sprintf("Create database security group (port %s from the default VPC)", databasePort(engine)),
 			Reason:      "Control network access to database",
 			IAMActions:  []string{"ec2:CreateSecurityGroup", "ec2:AuthorizeSecurityGroupIngress"},
+			Target:      name + "-sg",
+			Properties: map[string]string{
+				"description":    fmt.Sprintf("Database access for %s", name),
+				"ingress_port":   databasePort(engine),
+				"ingress_source": defaultVPCCIDR,
+			},
 		},
 		{
 			ID:          "create-database",
@@ -227,7 +248,15 @@ func (p *Planner) planDatabase(ctx context.Context, i *intent.Intent) (*Plan, er
 			Description: fmt.Sprintf("Create %s database (%s)", engine, size),
 			Reason:      "Deploy your managed database",
 			IAMActions:  []string{"rds:CreateDBInstance"},
-			DependsOn:   []string{"create-subnet-group", "create-security-group"},
+			DependsOn:   []string{"create-security-group"},
+			Target:      name,
+			Properties: map[string]string{
+				"engine":  engine,
+				"version": i.Parameters["version"],
+				"size":    size,
+				"storage": i.Parameters["storage"],
+				"backup":  i.Parameters["backup"],
+			},
 		},
 	}
 
@@ -240,6 +269,7 @@ func (p *Planner) planDatabase(ctx context.Context, i *intent.Intent) (*Plan, er
 			Reason:      "Protect your data with regular backups",
 			IAMActions:  []string{"rds:ModifyDBInstance"},
 			DependsOn:   []string{"create-database"},
+			Target:      name,
 		})
 	}
 
@@ -297,6 +327,15 @@ func (p *Planner) planAPI(ctx context.Context, i *intent.Intent) (*Plan, error)
 	}
 
 	steps := []PlanStep{
+		{
+			ID:          "create-execution-role",
+			Action:      "create",
+			Resource:    "iam-role",
+			Description: "Create Lambda execution role",
+			Reason:      "Allow the API function to write logs",
+			IAMActions:  []string{"iam:CreateRole", "iam:AttachRolePolicy"},
+			Target:      fmt.Sprintf("genesys-lambda-%s", name),
+		},
 		{
 			ID:          "create-lambda",
 			Action:      "create",
@@ -304,6 +343,9 @@ func (p *Planner) planAPI(ctx context.Context, i *intent.Intent) (*Plan, error)
 			Description: "Create Lambda function for API logic",
 			Reason:      "Handle API requests serverlessly",
 			IAMActions:  []string{"lambda:CreateFunction", "iam:CreateRole"},
+			DependsOn:   []string{"create-execution-role"},
+			Target:      name,
+			Properties:  functionProperties("python3.11", i.Parameters),
 		},
 		{
 			ID:          "create-api-gateway",
@@ -313,6 +355,7 @@ func (p *Planner) planAPI(ctx context.Context, i *intent.Intent) (*Plan, error)
 			Reason:      "Expose Lambda function as HTTP API",
 			IAMActions:  []string{"apigateway:CreateRestApi", "apigateway:CreateResource"},
 			DependsOn:   []string{"create-lambda"},
+			Target:      name,
 		},
 		{
 			ID:          "deploy-api",
@@ -322,6 +365,7 @@ func (p *Planner) planAPI(ctx context.Context, i *intent.Intent) (*Plan, error)
 			Reason:      "Make API publicly accessible",
 			IAMActions:  []string{"apigateway:CreateDeployment"},
 			DependsOn:   []string{"create-api-gateway"},
+			Target:      name,
 		},
 	}
 
@@ -378,6 +422,8 @@ func (p *Planner) planWebapp(ctx context.Context, i *intent.Intent) (*Plan, erro
 			Description: "Create security group for web servers",
 			Reason:      "Control access to your application",
 			IAMActions:  []string{"ec2:CreateSecurityGroup", "ec2:AuthorizeSecurityGroupIngress"},
+			Target:      name + "-sg",
+			Properties:  map[string]string{"description": fmt.Sprintf("Web access for %s", name)},
 		},
 		{
 			ID:          "create-launch-template",
@@ -387,6 +433,8 @@ func (p *Planner) planWebapp(ctx context.Context, i *intent.Intent) (*Plan, erro
 			Reason:      "Define instance configuration",
 			IAMActions:  []string{"ec2:CreateLaunchTemplate"},
 			DependsOn:   []string{"create-security-group"},
+			Target:      name,
+			Properties:  map[string]string{"type": instanceType},
 		},
 	}
 
@@ -469,6 +517,7 @@ func (p *Planner) createAdoptionPlan(ctx context.Context, resourceType, name str
 			Description: fmt.Sprintf("Analyze existing %s configuration", resourceType),
 			Reason:      "Understand current resource state",
 			IAMActions:  []string{fmt.Sprintf("%s:Describe*", getServicePrefix(resourceType))},
+			Target:      name,
 		},
 		{
 			ID:          "import-state",
@@ -477,6 +526,7 @@ func (p *Planner) createAdoptionPlan(ctx context.Context, resourceType, name str
 			Description: "Import resource into Genesys state",
 			Reason:      "Track resource in Genesys management",
 			DependsOn:   []string{"analyze-resource"},
+			Target:      name,
 		},
 		{
 			ID:          "apply-best-practices",
@@ -486,6 +536,7 @@ func (p *Planner) createAdoptionPlan(ctx context.Context, resourceType, name str
 			Reason:      "Ensure resource follows security guidelines",
 			Optional:    true,
 			DependsOn:   []string{"import-state"},
+			Target:      name,
 		},
 	}
 
@@ -516,4 +567,16 @@ func getServicePrefix(resourceType string) string {
 	default:
 		return "ec2"
 	}
-}
\ No newline at end of file
+}
+// defaultVPCCIDR is the address range of every AWS default VPC
+const defaultVPCCIDR = "172.31.0.0/16"
+
+// databasePort returns the port a database engine listens on
+func databasePort(engine string) string {
+	switch engine {
+	case "mysql", "mariadb", "aurora-mysql":
+		return "3306"
+	default:
+		return "5432"
+	}
+}
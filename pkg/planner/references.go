@@ -0,0 +1,346 @@
+package planner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+// Configuration values can refer to attributes of other resources with
+// ${<kind>.<name>.<attribute>}, for example ${storage.assets.name} or
+// ${network.main.subnets.public-a.id}. References to resources declared in
+// the same configuration make the step depend on the referenced step and are
+// replaced with its outputs when the step runs. Other references are
+// resolved from the outputs recorded in state.
+
+// Reference is an output of another step that replaces a ${...} expression
+// in a step's properties and tags when the step runs
+type Reference struct {
+	Step   string `json:"step"`
+	Output string `json:"output"`
+}
+
+// referenceKind describes the resources a reference kind names: the plan
+// resource and state type they are created as, and the outputs their
+// attributes stand for
+type referenceKind struct {
+	resource   string
+	stateType  string
+	attributes map[string]string
+}
+
+var referenceKinds = map[string]referenceKind{
+	"storage": {
+		resource:   "s3-bucket",
+		stateType:  "s3",
+		attributes: map[string]string{"name": "bucket_name", "arn": "bucket_arn", "region": "region"},
+	},
+	"network": {
+		resource:   "vpc",
+		stateType:  "vpc",
+		attributes: map[string]string{"id": "network_id"},
+	},
+	"subnet": {
+		resource:   "subnet",
+		stateType:  "subnet",
+		attributes: map[string]string{"id": "subnet_id"},
+	},
+	"compute": {
+		resource:   "instance",
+		stateType:  "ec2",
+		attributes: map[string]string{"id": "instance_id", "private_ip": "private_ip", "public_ip": "public_ip"},
+	},
+	"database": {
+		resource:   "rds-instance",
+		stateType:  "rds",
+		attributes: map[string]string{"id": "database_id", "endpoint": "endpoint", "port": "port"},
+	},
+	"serverless": {
+		resource:   "lambda-function",
+		stateType:  "lambda",
+		attributes: map[string]string{"name": "function_name", "arn": "function_arn", "url": "function_url"},
+	},
+}
+
+var referencePattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// reference is a parsed ${...} expression
+type reference struct {
+	expr    string
+	kind    string
+	network string // the network of a subnet
+	name    string
+	output  string
+}
+
+// parseReference parses the inside of a ${...} expression
+func parseReference(expr string) (reference, error) {
+	ref := reference{expr: expr}
+	parts := strings.Split(strings.TrimSpace(expr), ".")
+
+	if len(parts) == 5 && parts[0] == "network" && parts[2] == "subnets" {
+		ref.kind, ref.network, ref.name = "subnet", parts[1], parts[3]
+		parts = []string{"subnet", parts[3], parts[4]}
+	} else if len(parts) == 3 {
+		ref.kind, ref.name = parts[0], parts[1]
+	} else {
+		return ref, fmt.Errorf("invalid reference ${%s}: use ${<kind>.<name>.<attribute>} or ${network.<name>.subnets.<subnet>.id}", expr)
+	}
+
+	kind, ok := referenceKinds[ref.kind]
+	if !ok || ref.kind == "subnet" && ref.network == "" {
+		return ref, fmt.Errorf("invalid reference ${%s}: unknown resource kind %q (use storage, network, compute, database or serverless)", expr, parts[0])
+	}
+	if ref.name == "" {
+		return ref, fmt.Errorf("invalid reference ${%s}: missing resource name", expr)
+	}
+
+	attribute := parts[2]
+	if output, ok := kind.attributes[attribute]; ok {
+		ref.output = output
+	} else {
+		// Output names can be used as they are recorded
+		for _, output := range kind.attributes {
+			if output == attribute {
+				ref.output = output
+			}
+		}
+	}
+	if ref.output == "" {
+		return ref, fmt.Errorf("invalid reference ${%s}: %s resources have no attribute %q (attributes: %s)",
+			expr, ref.kind, attribute, strings.Join(sortedKeys(kind.attributes), ", "))
+	}
+	return ref, nil
+}
+
+// stepReferences returns the references in the properties and tags of a step
+func stepReferences(step *PlanStep) ([]reference, error) {
+	seen := make(map[string]bool)
+	var refs []reference
+
+	collect := func(values map[string]string) error {
+		for _, key := range sortedKeys(values) {
+			for _, match := range referencePattern.FindAllStringSubmatch(values[key], -1) {
+				if seen[match[1]] {
+					continue
+				}
+				seen[match[1]] = true
+
+				ref, err := parseReference(match[1])
+				if err != nil {
+					return fmt.Errorf("%s of %s: %w", key, step.ID, err)
+				}
+				refs = append(refs, ref)
+			}
+		}
+		return nil
+	}
+
+	if err := collect(step.Properties); err != nil {
+		return nil, err
+	}
+	if err := collect(step.Tags); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// linkReferences checks the references of every step and links the ones
+// naming resources declared in the plan: the step depends on the referenced
+// step, whose output replaces the reference when the step runs. References
+// to other resources are resolved from state when the plan is compared with it.
+func linkReferences(plan *Plan) error {
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		refs, err := stepReferences(step)
+		if err != nil {
+			return err
+		}
+
+		for _, ref := range refs {
+			target := findReferencedStep(plan, ref)
+			if target == nil {
+				continue
+			}
+			if target.ID == step.ID {
+				return fmt.Errorf("%s refers to itself with ${%s}", step.ID, ref.expr)
+			}
+
+			if step.References == nil {
+				step.References = make(map[string]Reference)
+			}
+			step.References[ref.expr] = Reference{Step: target.ID, Output: ref.output}
+			if !containsString(step.DependsOn, target.ID) {
+				step.DependsOn = append(step.DependsOn, target.ID)
+			}
+		}
+	}
+
+	if _, cycle := referenceOrder(plan); cycle != nil {
+		return fmt.Errorf("references form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// findReferencedStep returns the create step of the resource a reference
+// names, or nil when the plan does not declare it
+func findReferencedStep(plan *Plan, ref reference) *PlanStep {
+	kind := referenceKinds[ref.kind]
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		if step.Resource != kind.resource || step.Target != ref.name || step.Action == ActionDelete {
+			continue
+		}
+		if ref.kind == "subnet" && step.ID != fmt.Sprintf("subnet-%s-%s", ref.network, ref.name) {
+			continue
+		}
+		return step
+	}
+	return nil
+}
+
+// resolveReferences replaces the references of a step with the outputs
+// recorded in state: those naming resources the plan does not declare, and
+// those naming declared resources that already exist and are kept. It
+// returns a description of each reference that cannot be resolved. The steps
+// a step refers to must have been compared with state first.
+func resolveReferences(step *PlanStep, steps map[string]*PlanStep, st *state.LocalState) ([]string, error) {
+	refs, err := stepReferences(step)
+	if err != nil {
+		return nil, err
+	}
+
+	var unresolved []string
+	for _, ref := range refs {
+		var record state.ResourceRecord
+		if link, ok := step.References[ref.expr]; ok {
+			// Resources created or replaced by this plan only get their
+			// outputs when the referenced step runs
+			target := steps[link.Step]
+			if target.ResourceID == "" || (target.Action != ActionNoOp && target.Action != ActionUpdate) {
+				continue
+			}
+			if record, ok = st.FindResourceByID(target.ResourceID); !ok {
+				continue
+			}
+		} else {
+			var ok bool
+			if record, ok = st.FindResource(referenceKinds[ref.kind].stateType, ref.name); !ok {
+				unresolved = append(unresolved, fmt.Sprintf("${%s} in %s: no %s named %s is declared in the configuration or recorded in state",
+					ref.expr, step.ID, referenceLabel(ref), ref.name))
+				continue
+			}
+		}
+
+		value, ok := record.Outputs[ref.output]
+		if !ok {
+			unresolved = append(unresolved, fmt.Sprintf("${%s} in %s: %s %s has no recorded %s output (try 'genesys output --refresh')",
+				ref.expr, step.ID, referenceLabel(ref), ref.name, ref.output))
+			continue
+		}
+		SubstituteReference(step, ref.expr, value)
+		delete(step.References, ref.expr)
+	}
+	if len(step.References) == 0 {
+		step.References = nil
+	}
+
+	return unresolved, nil
+}
+
+// SubstituteReference replaces a ${...} expression in the properties and tags
+// of a step with a value
+func SubstituteReference(step *PlanStep, expr, value string) {
+	placeholder := "${" + expr + "}"
+	for key, current := range step.Properties {
+		step.Properties[key] = strings.ReplaceAll(current, placeholder, value)
+	}
+	for key, current := range step.Tags {
+		step.Tags[key] = strings.ReplaceAll(current, placeholder, value)
+	}
+}
+
+func referenceLabel(ref reference) string {
+	if ref.kind == "subnet" {
+		return "subnet of network " + ref.network
+	}
+	return ref.kind + " resource"
+}
+
+// referenceOrder returns the indexes of the plan's steps ordered so that
+// every step comes after the steps it refers to. It also returns a cycle
+// formed by the references, or nil when there is none.
+func referenceOrder(plan *Plan) ([]int, []string) {
+	index := make(map[string]int)
+	for i, step := range plan.Steps {
+		index[step.ID] = i
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	marks := make(map[string]int)
+	var order []int
+	var path []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch marks[id] {
+		case visiting:
+			for i, step := range path {
+				if step == id {
+					return append(append([]string{}, path[i:]...), id)
+				}
+			}
+		case done:
+			return nil
+		}
+
+		marks[id] = visiting
+		path = append(path, id)
+		step := plan.Steps[index[id]]
+		var targets []string
+		for _, ref := range step.References {
+			targets = append(targets, ref.Step)
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			if cycle := visit(target); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		marks[id] = done
+		order = append(order, index[id])
+		return nil
+	}
+
+	for _, step := range plan.Steps {
+		if cycle := visit(step.ID); cycle != nil {
+			return nil, cycle
+		}
+	}
+	return order, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
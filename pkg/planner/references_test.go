@@ -0,0 +1,153 @@
+package planner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/provider"
+	"github.com/javanhut/genesys/pkg/state"
+)
+
+func TestReferences(t *testing.T) {
+	cfg := &config.Config{
+		Provider: "aws",
+		Resources: config.Resources{
+			Network: []config.NetworkResource{
+				{
+					Name: "main",
+					CIDR: "10.0.0.0/16",
+					Subnets: []config.SubnetConfig{
+						{Name: "public-a", CIDR: "10.0.1.0/24", Public: true},
+					},
+				},
+			},
+			Compute: []config.ComputeResource{
+				{Name: "web", Type: "small", Subnet: "${network.main.subnets.public-a.id}",
+					Tags: map[string]string{"logs": "${storage.logs.name}"}},
+			},
+			Storage: []config.StorageResource{
+				{Name: "assets", Type: "bucket"},
+			},
+			Serverless: []config.ServerlessResource{
+				{Name: "worker", Environment: map[string]string{
+					"BUCKET": "${storage.assets.name}",
+					"ARN":    "arn=${storage.assets.arn}",
+				}},
+			},
+		},
+	}
+	config.ApplyDefaults(cfg)
+
+	plan, err := NewConfigPlan(cfg)
+	if err != nil {
+		t.Fatalf("NewConfigPlan() error = %v", err)
+	}
+
+	steps := make(map[string]*PlanStep)
+	for i := range plan.Steps {
+		steps[plan.Steps[i].ID] = &plan.Steps[i]
+	}
+
+	web := steps["compute-web"]
+	if !containsString(web.DependsOn, "subnet-main-public-a") {
+		t.Errorf("compute-web DependsOn = %v, want subnet-main-public-a", web.DependsOn)
+	}
+	if ref := web.References["network.main.subnets.public-a.id"]; ref != (Reference{Step: "subnet-main-public-a", Output: "subnet_id"}) {
+		t.Errorf("compute-web subnet reference = %+v", ref)
+	}
+	if _, ok := web.References["storage.logs.name"]; ok {
+		t.Error("reference to an undeclared bucket was linked to a step")
+	}
+
+	worker := steps["function-worker"]
+	if !containsString(worker.DependsOn, "storage-assets") || len(worker.References) != 2 {
+		t.Errorf("function-worker DependsOn = %v, References = %v", worker.DependsOn, worker.References)
+	}
+
+	// The kept bucket and the bucket declared elsewhere resolve from state
+	p := &diffProvider{
+		Provider: provider.NewMockProvider("mock", "us-east-1"),
+		buckets:  map[string]*provider.Bucket{"assets": {Name: "assets"}},
+	}
+	st := &state.LocalState{Resources: []state.ResourceRecord{
+		{ID: "assets", Name: "assets", Type: "s3", ConfigFile: "app.yaml",
+			Attributes: map[string]string{"versioning": "false", "encryption": "false", "public": "false"},
+			Outputs:    map[string]string{"bucket_name": "assets", "bucket_arn": "arn:aws:s3:::assets"}},
+		{ID: "logs-123", Name: "logs", Type: "s3", ConfigFile: "shared.yaml",
+			Outputs: map[string]string{"bucket_name": "logs-123"}},
+	}}
+
+	if err := New(p).Diff(context.Background(), plan, st, ""); err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	if got := worker.Properties[EnvPropertyPrefix+"ARN"]; got != "arn=arn:aws:s3:::assets" {
+		t.Errorf("worker ARN = %q, want the recorded bucket ARN", got)
+	}
+	if len(worker.References) != 0 {
+		t.Errorf("worker References = %v, want none left", worker.References)
+	}
+	if got := web.Tags["logs"]; got != "logs-123" {
+		t.Errorf("web logs tag = %q, want logs-123", got)
+	}
+	if _, ok := web.References["network.main.subnets.public-a.id"]; !ok {
+		t.Error("reference to a subnet being created was resolved at plan time")
+	}
+}
+
+func TestReferenceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage []config.StorageResource
+		want    string
+	}{
+		{
+			name:    "unknown kind",
+			storage: []config.StorageResource{{Name: "a", Type: "bucket", Tags: map[string]string{"x": "${queue.jobs.url}"}}},
+			want:    `unknown resource kind "queue"`,
+		},
+		{
+			name:    "unknown attribute",
+			storage: []config.StorageResource{{Name: "a", Type: "bucket", Tags: map[string]string{"x": "${storage.b.size}"}}},
+			want:    `no attribute "size"`,
+		},
+		{
+			name:    "malformed",
+			storage: []config.StorageResource{{Name: "a", Type: "bucket", Tags: map[string]string{"x": "${storage.b}"}}},
+			want:    "invalid reference ${storage.b}",
+		},
+		{
+			name: "cycle",
+			storage: []config.StorageResource{
+				{Name: "a", Type: "bucket", Tags: map[string]string{"peer": "${storage.b.name}"}},
+				{Name: "b", Type: "bucket", Tags: map[string]string{"peer": "${storage.a.name}"}},
+			},
+			want: "references form a cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Provider: "aws", Resources: config.Resources{Storage: tt.storage}}
+			_, err := NewConfigPlan(cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewConfigPlan() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	cfg := &config.Config{Provider: "aws", Resources: config.Resources{Storage: []config.StorageResource{
+		{Name: "a", Type: "bucket", Tags: map[string]string{"db": "${database.orders.endpoint}"}},
+	}}}
+	plan, err := NewConfigPlan(cfg)
+	if err != nil {
+		t.Fatalf("NewConfigPlan() error = %v", err)
+	}
+	p := &diffProvider{Provider: provider.NewMockProvider("mock", "us-east-1")}
+	err = New(p).Diff(context.Background(), plan, &state.LocalState{}, "")
+	if err == nil || !strings.Contains(err.Error(), "no database resource named orders") {
+		t.Errorf("Diff() error = %v, want an unresolved reference error", err)
+	}
+}
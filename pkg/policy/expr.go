@@ -0,0 +1,528 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a compiled rule expression. Expressions compare the attributes of
+// a resource with literals:
+//
+//	properties.type in ["small", "medium"]
+//	properties.public != true && has(tags.owner)
+//	name matches "^prod-" || tags["cost-center"] == "1234"
+//
+// Attributes are dotted paths, or indexed with a string for keys that are not
+// identifiers. Literals are strings, numbers, booleans and lists of them.
+// The operators are ==, !=, <, <=, >, >=, in, matches (a regular
+// expression), !, && and ||, with parentheses for grouping, and has(path)
+// reports whether an attribute is set.
+type Expr struct {
+	source string
+	root   node
+}
+
+// Env looks up the value of an attribute path. Values are strings, numbers
+// or booleans.
+type Env func(path []string) (interface{}, bool)
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression to a boolean
+func (e *Expr) Eval(env Env) (bool, error) {
+	value, err := e.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	return truth(value, e.source)
+}
+
+// Compile parses an expression
+func Compile(source string) (*Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{source: source, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(source) && rune(source[end]) != c {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, fmt.Errorf("unterminated string at column %d in %q", i+1, source)
+			}
+			text := source[i : end+1]
+			value := text[1 : len(text)-1]
+			if c == '"' {
+				var err error
+				if value, err = strconv.Unquote(text); err != nil {
+					return nil, fmt.Errorf("invalid string %s at column %d in %q", text, i+1, source)
+				}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, value: value, pos: i})
+			i = end + 1
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(source) && source[i+1] >= '0' && source[i+1] <= '9':
+			end := i + 1
+			for end < len(source) && (source[end] >= '0' && source[end] <= '9' || source[end] == '.') {
+				end++
+			}
+			number, err := strconv.ParseFloat(source[i:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %s at column %d in %q", source[i:end], i+1, source)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[i:end], value: number, pos: i})
+			i = end
+		case c == '_' || unicode.IsLetter(c):
+			end := i + 1
+			for end < len(source) && (source[end] == '_' || source[end] == '-' || unicode.IsLetter(rune(source[end])) || unicode.IsDigit(rune(source[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[i:end], pos: i})
+			i = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at column %d in %q", c, i+1, source)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+type parser struct {
+	source string
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+// accept takes the next token if it is the given operator or keyword
+func (p *parser) accept(text string) bool {
+	if tok := p.peek(); (tok.kind == tokenOperator || tok.kind == tokenIdent) && tok.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf(p.peek(), "expected %q, found %s", text, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return fmt.Errorf("%s at column %d in %q", fmt.Sprintf(format, args...), tok.pos+1, p.source)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenOperator && (tok.text == "==" || tok.text == "!=" || tok.text == "<" || tok.text == "<=" || tok.text == ">" || tok.text == ">="),
+		tok.kind == tokenIdent && tok.text == "in":
+		p.take()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: tok.text, left: left, right: right}, nil
+	case tok.kind == tokenIdent && tok.text == "matches":
+		p.take()
+		pattern := p.take()
+		if pattern.kind != tokenString {
+			return nil, p.errorf(pattern, "matches needs a string pattern, found %s", pattern)
+		}
+		re, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return nil, p.errorf(pattern, "invalid pattern: %v", err)
+		}
+		return &matchNode{operand: left, pattern: re}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.take()
+	switch tok.kind {
+	case tokenString, tokenNumber:
+		return &literalNode{value: tok.value}, nil
+	case tokenOperator:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			list := &listNode{}
+			for !p.accept("]") {
+				if len(list.items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				item, err := p.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+			}
+			return list, nil
+		}
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{value: tok.text == "true"}, nil
+		case "has":
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			path, err := p.parsePath(p.take())
+			if err != nil {
+				return nil, err
+			}
+			return &hasNode{path: path}, p.expect(")")
+		}
+		return p.parsePath(tok)
+	}
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+// parsePath parses an attribute path starting at an identifier
+func (p *parser) parsePath(first token) (*pathNode, error) {
+	if first.kind != tokenIdent {
+		return nil, p.errorf(first, "expected an attribute, found %s", first)
+	}
+	path := &pathNode{path: []string{first.text}}
+	for {
+		switch {
+		case p.accept("."):
+			tok := p.take()
+			if tok.kind != tokenIdent {
+				return nil, p.errorf(tok, "expected an attribute name after '.', found %s", tok)
+			}
+			path.path = append(path.path, tok.text)
+		case p.accept("["):
+			tok := p.take()
+			if tok.kind != tokenString {
+				return nil, p.errorf(tok, "expected a string key, found %s", tok)
+			}
+			path.path = append(path.path, tok.value.(string))
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return path, nil
+		}
+	}
+}
+
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+func (n *literalNode) eval(Env) (interface{}, error) { return n.value, nil }
+
+type listNode struct{ items []node }
+
+func (n *listNode) eval(env Env) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// pathNode evaluates to an attribute, or nil when it is not set
+type pathNode struct{ path []string }
+
+func (n *pathNode) eval(env Env) (interface{}, error) {
+	value, _ := env(n.path)
+	return value, nil
+}
+
+func (n *pathNode) String() string { return strings.Join(n.path, ".") }
+
+type hasNode struct{ path *pathNode }
+
+func (n *hasNode) eval(env Env) (interface{}, error) {
+	_, ok := env(n.path.path)
+	return ok, nil
+}
+
+type notNode struct{ operand node }
+
+func (n *notNode) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, err := truth(value, "!")
+	return !b, err
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(env Env) (interface{}, error) {
+	value, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	left, err := truth(value, n.op)
+	if err != nil {
+		return nil, err
+	}
+	// Short-circuit so the right side may rely on the left, as in
+	// has(tags.owner) && tags.owner != ""
+	if n.op == "&&" && !left || n.op == "||" && left {
+		return left, nil
+	}
+	value, err = n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return truth(value, n.op)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(env Env) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("the right side of in must be a list")
+		}
+		for _, item := range list {
+			if equal(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// Ordering needs numbers; an unset attribute is never in range
+	a, aok := number(left)
+	b, bok := number(right)
+	if left == nil || right == nil {
+		return false, nil
+	}
+	if !aok || !bok {
+		return nil, fmt.Errorf("%s needs numbers, got %v and %v", n.op, left, right)
+	}
+	switch n.op {
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	}
+	return a >= b, nil
+}
+
+type matchNode struct {
+	operand node
+	pattern *regexp.Regexp
+}
+
+func (n *matchNode) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil || value == nil {
+		return false, err
+	}
+	return n.pattern.MatchString(fmt.Sprint(value)), nil
+}
+
+// truth converts a value to a boolean; attributes hold "true" and "false"
+func truth(value interface{}, context string) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case nil:
+		return false, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("%s needs a boolean, got %v", context, value)
+}
+
+// number converts a value to a number, parsing attribute strings
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equal compares values, comparing attribute strings with number and
+// boolean literals by their value
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	switch bv := b.(type) {
+	case bool:
+		av, err := truth(a, "")
+		return err == nil && av == bv
+	case float64:
+		av, ok := number(a)
+		return ok && av == bv
+	}
+	switch av := a.(type) {
+	case bool, float64:
+		return equal(b, av)
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExprEval(t *testing.T) {
+	attributes := map[string]interface{}{
+		"name":                    "prod-web",
+		"properties.type":         "medium",
+		"properties.public":       "false",
+		"properties.memory":       "512",
+		"tags.cost-center":        "1234",
+		"tags.Team":               "web",
+		"properties.architecture": "",
+	}
+	env := func(path []string) (interface{}, bool) {
+		value, ok := attributes[strings.Join(path, ".")]
+		return value, ok
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`properties.type in ["small", "medium"]`, true},
+		{`properties.type in ['large']`, false},
+		{`properties.public != true`, true},
+		{`properties.public == false`, true},
+		{`properties.public`, false},
+		{`!properties.public`, true},
+		{`properties.memory >= 256 && properties.memory < 1024`, true},
+		{`properties.memory > 512`, false},
+		{`properties.memory == 512`, true},
+		{`properties.missing > 1`, false},
+		{`name matches "^prod-"`, true},
+		{`name matches "^dev-" || tags["cost-center"] == "1234"`, true},
+		{`tags.cost-center == "1234"`, true},
+		{`has(tags.Team) && tags.Team != ""`, true},
+		{`has(tags.Owner)`, false},
+		{`has(properties.architecture)`, true},
+		{`tags.Owner == "alice"`, false},
+		{`!(tags.Team == "web" && name == "other")`, true},
+		{`true && !false`, true},
+		{`properties.memory > -1`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := expr.Eval(env)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	env := func(path []string) (interface{}, bool) {
+		return "web", true
+	}
+
+	compileErrors := []struct {
+		expr string
+		want string
+	}{
+		{`tags.Team ==`, "unexpected end of expression at column 13"},
+		{`tags.Team == "web`, "unterminated string at column 14"},
+		{`name matches name`, "matches needs a string pattern"},
+		{`name matches "("`, "invalid pattern"},
+		{`has(tags.Team`, `expected ")"`},
+		{`tags.Team = "web"`, `unexpected '=' at column 11`},
+		{`tags. == 1`, "expected an attribute name"},
+		{`name name`, `unexpected "name"`},
+	}
+	for _, tt := range compileErrors {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	evalErrors := []string{
+		`name`,
+		`name > 1`,
+		`name in "web"`,
+		`!name`,
+	}
+	for _, source := range evalErrors {
+		t.Run(source, func(t *testing.T) {
+			expr, err := Compile(source)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if _, err := expr.Eval(env); err == nil {
+				t.Errorf("Eval() expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/state"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDir is the directory of a project that policy files are read from
+const DefaultDir = "policies"
+
+// Dir is the directory policy files are read from instead of the project's
+// policies directory, as set with --policy-dir
+var Dir string
+
+// GlobalFileName is the name of the organization-wide policy file in
+// ~/.genesys, with a .yaml, .yml or .toml extension
+const GlobalFileName = "policy"
+
+// Settings are the contents of policy files: policies that add to those of
+// every configuration, tags added to every resource created and rules:
+//
+//	policies:
+//	  require_tags: [owner, cost-center]
+//	default_tags:
+//	  cost-center: "1234"
+//	rules:
+//	  - name: small-instances
+//	    resources: [instance]
+//	    assert: properties.type in ["small", "medium"]
+type Settings struct {
+	Policies    config.Policies   `yaml:"policies,omitempty" toml:"policies,omitempty"`
+	DefaultTags map[string]string `yaml:"default_tags,omitempty" toml:"default_tags,omitempty"`
+	Rules       []Rule            `yaml:"rules,omitempty" toml:"rules,omitempty"`
+}
+
+// merge adds the settings of a later file. Policies only get stricter, and
+// later default tags replace earlier ones with the same key.
+func (s *Settings) merge(other *Settings) {
+	s.Policies = Strictest(s.Policies, other.Policies)
+	for key, value := range other.DefaultTags {
+		if s.DefaultTags == nil {
+			s.DefaultTags = make(map[string]string)
+		}
+		s.DefaultTags[key] = value
+	}
+	s.Rules = append(s.Rules, other.Rules...)
+}
+
+// Strictest combines two sets of policies so that neither is weakened: a
+// policy turned on by either stays on, required tags add up and the lower
+// cost limit applies
+func Strictest(a, b config.Policies) config.Policies {
+	merged := config.Policies{
+		NoPublicBuckets:   a.NoPublicBuckets || b.NoPublicBuckets,
+		RequireEncryption: a.RequireEncryption || b.RequireEncryption,
+		MaxCostPerMonth:   a.MaxCostPerMonth,
+	}
+	for _, tag := range append(append([]string(nil), a.RequireTags...), b.RequireTags...) {
+		if !contains(merged.RequireTags, tag) {
+			merged.RequireTags = append(merged.RequireTags, tag)
+		}
+	}
+	if b.MaxCostPerMonth > 0 && (merged.MaxCostPerMonth == 0 || b.MaxCostPerMonth < merged.MaxCostPerMonth) {
+		merged.MaxCostPerMonth = b.MaxCostPerMonth
+	}
+	return merged
+}
+
+// GlobalFile returns the path of the organization-wide policy file in
+// ~/.genesys, or an empty string when there is none
+func GlobalFile() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	for _, ext := range []string{".yaml", ".yml", ".toml"} {
+		path := filepath.Join(homeDir, ".genesys", GlobalFileName+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadSettings reads the organization-wide policy file and then the policy
+// files of the project a configuration belongs to
+func LoadSettings(configPath string) (*Settings, error) {
+	settings := &Settings{}
+	if path := GlobalFile(); path != "" {
+		global, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		settings.merge(global)
+	}
+
+	dir, err := ProjectDir(configPath)
+	if err != nil {
+		return nil, err
+	}
+	project, err := LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	settings.merge(project)
+	return settings, nil
+}
+
+// ProjectDir returns the directory holding the policy files of the project a
+// configuration belongs to: Dir when set, otherwise the policies directory
+// next to the project's .genesys directory or configuration
+func ProjectDir(configPath string) (string, error) {
+	if Dir != "" {
+		return Dir, nil
+	}
+	if configPath == "" {
+		configPath = "."
+	}
+	projectDir, err := state.FindProjectDir(configPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(projectDir, DefaultDir), nil
+}
+
+// LoadDir reads the YAML and TOML files in a directory, in file name order.
+// A directory that does not exist holds no settings.
+func LoadDir(dir string) (*Settings, error) {
+	settings := &Settings{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) && Dir == "" {
+			return settings, nil
+		}
+		return nil, fmt.Errorf("failed to read policy directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".toml":
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file, err := LoadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		settings.merge(file)
+	}
+	return settings, nil
+}
+
+// LoadFile reads a policy file, rejecting fields it does not know
+func LoadFile(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	settings := &Settings{}
+	if filepath.Ext(path) == ".toml" {
+		meta, err := toml.Decode(string(data), settings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("%s: unknown field %s", path, undecoded[0])
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(settings); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+		}
+	}
+
+	for i := range settings.Rules {
+		settings.Rules[i].Source = path
+	}
+	return settings, nil
+}
+
+// Load returns an engine with the built-in rules of a configuration's
+// policies, made stricter by the policy files, and the rules of the files
+func Load(configPath string, policies config.Policies) (*Engine, error) {
+	settings, err := LoadSettings(configPath)
+	if err != nil {
+		return nil, err
+	}
+	policies = Strictest(policies, settings.Policies)
+	return NewEngine(append(BuiltinRules(policies), settings.Rules...))
+}
@@ -0,0 +1,353 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/planner"
+)
+
+// Rule severities
+const (
+	// SeverityWarn reports a violation without stopping the apply
+	SeverityWarn = "warn"
+	// SeverityDeny refuses to apply a plan with a violation
+	SeverityDeny = "deny"
+)
+
+// ScopePlan is the resource type of rules checked once against the whole
+// plan, whose attributes are cost.monthly, cost.hourly and steps
+const ScopePlan = "plan"
+
+// BuiltinSource is the source of rules made from a configuration's policies
+const BuiltinSource = "built-in"
+
+// Rule is a check the steps of a plan must pass
+type Rule struct {
+	Name        string `yaml:"name" toml:"name"`
+	Description string `yaml:"description,omitempty" toml:"description,omitempty"`
+	// Severity is warn or deny; rules deny by default
+	Severity string `yaml:"severity,omitempty" toml:"severity,omitempty"`
+	// Resources are the plan resource types the rule applies to, such as
+	// s3-bucket or instance, or plan; every resource when empty
+	Resources []string `yaml:"resources,omitempty" toml:"resources,omitempty"`
+	// Actions are the step actions the rule applies to; create, update and
+	// replace when empty
+	Actions []string `yaml:"actions,omitempty" toml:"actions,omitempty"`
+	// When limits the rule to resources the expression holds for
+	When string `yaml:"when,omitempty" toml:"when,omitempty"`
+	// Assert is the expression every resource the rule applies to must satisfy
+	Assert  string `yaml:"assert" toml:"assert"`
+	Message string `yaml:"message,omitempty" toml:"message,omitempty"`
+
+	// Source is the file the rule was loaded from, or BuiltinSource
+	Source string `yaml:"-" toml:"-"`
+
+	when, assert *Expr
+}
+
+// Compile checks the rule's fields and compiles its expressions
+func (r *Rule) Compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule has no name")
+	}
+	if r.Severity == "" {
+		r.Severity = SeverityDeny
+	}
+	if r.Severity != SeverityWarn && r.Severity != SeverityDeny {
+		return fmt.Errorf("rule %s: invalid severity %q, must be warn or deny", r.Name, r.Severity)
+	}
+	if r.Assert == "" {
+		return fmt.Errorf("rule %s: assert is required", r.Name)
+	}
+
+	var err error
+	if r.assert, err = Compile(r.Assert); err != nil {
+		return fmt.Errorf("rule %s: assert: %w", r.Name, err)
+	}
+	if r.When != "" {
+		if r.when, err = Compile(r.When); err != nil {
+			return fmt.Errorf("rule %s: when: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule covers a step by resource type and action
+func (r *Rule) appliesTo(step *planner.PlanStep) bool {
+	if len(r.Resources) > 0 && !contains(r.Resources, step.Resource) {
+		return false
+	}
+	if len(r.Actions) > 0 {
+		return contains(r.Actions, step.Action)
+	}
+	return step.Action == planner.ActionCreate || step.Action == planner.ActionUpdate || step.Action == planner.ActionReplace
+}
+
+// planScoped reports whether the rule is checked once against the plan
+func (r *Rule) planScoped() bool {
+	return len(r.Resources) == 1 && r.Resources[0] == ScopePlan
+}
+
+// message describes a violation of the rule
+func (r *Rule) message() string {
+	if r.Message != "" {
+		return r.Message
+	}
+	if r.Description != "" {
+		return r.Description
+	}
+	return "assert " + r.Assert + " does not hold"
+}
+
+// Violation is a resource, or the plan, failing a rule
+type Violation struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Source   string `json:"source"`
+	Step     string `json:"step,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	Target   string `json:"target,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (v Violation) String() string {
+	subject := "plan"
+	if v.Step != "" {
+		subject = fmt.Sprintf("%s '%s'", v.Resource, v.Target)
+	}
+	return fmt.Sprintf("%s: %s violates %s (%s): %s", v.Severity, subject, v.Rule, v.Source, v.Message)
+}
+
+// Report is the outcome of checking a plan against a set of rules
+type Report struct {
+	Rules      int         `json:"rules"`
+	Violations []Violation `json:"violations"`
+}
+
+// Denied reports whether a deny rule was violated
+func (r *Report) Denied() bool {
+	return r.Count(SeverityDeny) > 0
+}
+
+// Count returns the number of violations with a severity
+func (r *Report) Count(severity string) int {
+	count := 0
+	for _, v := range r.Violations {
+		if v.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// ToHumanReadable lists the violations of the report
+func (r *Report) ToHumanReadable() string {
+	var output strings.Builder
+	if len(r.Violations) == 0 {
+		output.WriteString(fmt.Sprintf("Policy check: %d rule(s) passed\n", r.Rules))
+		return output.String()
+	}
+
+	output.WriteString(fmt.Sprintf("Policy check: %d rule(s), %d denied, %d warning(s)\n",
+		r.Rules, r.Count(SeverityDeny), r.Count(SeverityWarn)))
+	for _, v := range r.Violations {
+		icon := "✗"
+		if v.Severity == SeverityWarn {
+			icon = "!"
+		}
+		output.WriteString(fmt.Sprintf("%s %s\n", icon, v))
+	}
+	return output.String()
+}
+
+// Engine checks plans against rules
+type Engine struct {
+	Rules []Rule
+}
+
+// NewEngine compiles rules into an engine
+func NewEngine(rules []Rule) (*Engine, error) {
+	engine := &Engine{}
+	for _, rule := range rules {
+		if err := rule.Compile(); err != nil {
+			if rule.Source != "" {
+				return nil, fmt.Errorf("%s: %w", rule.Source, err)
+			}
+			return nil, err
+		}
+		engine.Rules = append(engine.Rules, rule)
+	}
+	return engine, nil
+}
+
+// Evaluate checks every step of a plan against the rules that apply to it.
+// A rule whose expressions cannot be evaluated for a resource counts as
+// violated.
+func (e *Engine) Evaluate(plan *planner.Plan) *Report {
+	report := &Report{Rules: len(e.Rules)}
+	for i := range e.Rules {
+		rule := &e.Rules[i]
+		if rule.planScoped() {
+			if v := check(rule, planEnv(plan)); v != nil {
+				report.Violations = append(report.Violations, *v)
+			}
+			continue
+		}
+
+		for j := range plan.Steps {
+			step := &plan.Steps[j]
+			if !rule.appliesTo(step) {
+				continue
+			}
+			if v := check(rule, stepEnv(step)); v != nil {
+				v.Step = step.ID
+				v.Resource = step.Resource
+				v.Target = step.Target
+				report.Violations = append(report.Violations, *v)
+			}
+		}
+	}
+
+	// Denials first, then in plan order
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		return report.Violations[i].Severity == SeverityDeny && report.Violations[j].Severity != SeverityDeny
+	})
+	return report
+}
+
+// check evaluates a rule in an environment, returning its violation if any
+func check(rule *Rule, env Env) *Violation {
+	violation := &Violation{Rule: rule.Name, Severity: rule.Severity, Source: rule.Source, Message: rule.message()}
+	if rule.when != nil {
+		applies, err := rule.when.Eval(env)
+		if err != nil {
+			violation.Message = fmt.Sprintf("when cannot be evaluated: %v", err)
+			return violation
+		}
+		if !applies {
+			return nil
+		}
+	}
+
+	ok, err := rule.assert.Eval(env)
+	if err != nil {
+		violation.Message = fmt.Sprintf("assert cannot be evaluated: %v", err)
+		return violation
+	}
+	if ok {
+		return nil
+	}
+	return violation
+}
+
+// stepEnv exposes a plan step to expressions as id, type, name, action,
+// properties.<key> and tags.<key>
+func stepEnv(step *planner.PlanStep) Env {
+	return func(path []string) (interface{}, bool) {
+		if len(path) == 1 {
+			switch path[0] {
+			case "id":
+				return step.ID, true
+			case "type":
+				return step.Resource, true
+			case "name":
+				return step.Target, step.Target != ""
+			case "action":
+				return step.Action, true
+			}
+			return nil, false
+		}
+		if len(path) != 2 {
+			return nil, false
+		}
+		var values map[string]string
+		switch path[0] {
+		case "properties":
+			values = step.Properties
+		case "tags":
+			values = step.Tags
+		}
+		value, ok := values[path[1]]
+		return value, ok
+	}
+}
+
+// planEnv exposes a plan to expressions as cost.monthly, cost.hourly and
+// steps, the number of steps that change something
+func planEnv(plan *planner.Plan) Env {
+	return func(path []string) (interface{}, bool) {
+		switch strings.Join(path, ".") {
+		case "cost.monthly":
+			return plan.Cost.Monthly, true
+		case "cost.hourly":
+			return plan.Cost.Hourly, true
+		case "steps":
+			changes := 0
+			for _, step := range plan.Steps {
+				if step.Action != planner.ActionNoOp {
+					changes++
+				}
+			}
+			return float64(changes), true
+		}
+		return nil, false
+	}
+}
+
+// taggedResources are the plan resource types that carry tags
+var taggedResources = []string{"s3-bucket", "rds-instance", "instance", "lambda-function"}
+
+// BuiltinRules returns the rules a configuration's policies block sets
+func BuiltinRules(policies config.Policies) []Rule {
+	var rules []Rule
+	if policies.NoPublicBuckets {
+		rules = append(rules, Rule{
+			Name:      "no_public_buckets",
+			Resources: []string{"s3-bucket"},
+			Assert:    "properties.public != true",
+			Message:   "buckets must not allow public access",
+		})
+	}
+	if policies.RequireEncryption {
+		rules = append(rules, Rule{
+			Name:      "require_encryption",
+			Resources: []string{"s3-bucket"},
+			Assert:    "properties.encryption == true",
+			Message:   "buckets must be encrypted",
+		})
+	}
+	for _, tag := range policies.RequireTags {
+		rules = append(rules, Rule{
+			Name:      "require_tags",
+			Resources: taggedResources,
+			Assert:    fmt.Sprintf("has(tags[%s])", strconv.Quote(tag)),
+			Message:   fmt.Sprintf("resources must have the %s tag", tag),
+		})
+	}
+	if policies.MaxCostPerMonth > 0 {
+		rules = append(rules, Rule{
+			Name:      "max_cost_per_month",
+			Resources: []string{ScopePlan},
+			Assert:    fmt.Sprintf("cost.monthly <= %s", strconv.FormatFloat(policies.MaxCostPerMonth, 'f', -1, 64)),
+			Message:   fmt.Sprintf("the estimated monthly cost must not exceed $%.2f", policies.MaxCostPerMonth),
+		})
+	}
+
+	for i := range rules {
+		rules[i].Source = BuiltinSource
+	}
+	return rules
+}
+
+func contains(list []string, item string) bool {
+	for _, s := range list {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
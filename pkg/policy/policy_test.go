@@ -0,0 +1,233 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/config"
+	"github.com/javanhut/genesys/pkg/planner"
+)
+
+func testPlan(t *testing.T) *planner.Plan {
+	cfg := &config.Config{
+		Provider: "aws",
+		Resources: config.Resources{
+			Compute: []config.ComputeResource{
+				{Name: "web", Type: "large", Tags: map[string]string{"Team": "web"}},
+			},
+			Storage: []config.StorageResource{
+				{Name: "assets", Type: "bucket", PublicAccess: true},
+				{Name: "logs", Type: "bucket", Tags: map[string]string{"Team": "ops"}},
+			},
+		},
+	}
+	config.ApplyDefaults(cfg)
+
+	plan, err := planner.NewConfigPlan(cfg)
+	if err != nil {
+		t.Fatalf("NewConfigPlan() error = %v", err)
+	}
+	plan.Cost.Monthly = 120
+	return plan
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies config.Policies
+		rules    []Rule
+		want     []string // severity rule target
+	}{
+		{
+			name:     "no rules",
+			policies: config.Policies{},
+		},
+		{
+			name:     "built-in",
+			policies: config.Policies{NoPublicBuckets: true, RequireEncryption: true, RequireTags: []string{"ManagedBy", "Team"}, MaxCostPerMonth: 100},
+			want: []string{
+				"deny no_public_buckets assets",
+				"deny require_tags assets",
+				"deny max_cost_per_month ",
+			},
+		},
+		{
+			name:     "cost within budget",
+			policies: config.Policies{MaxCostPerMonth: 500},
+		},
+		{
+			name: "user rules",
+			rules: []Rule{
+				{Name: "small", Severity: SeverityWarn, Resources: []string{"instance"}, Assert: `properties.type in ["small", "medium"]`},
+				{Name: "ops-logs", Resources: []string{"s3-bucket"}, When: `name matches "logs$"`, Assert: `tags.Team == "web"`},
+				{Name: "deletes-only", Actions: []string{planner.ActionDelete}, Assert: "false"},
+				{Name: "broken", Resources: []string{"instance"}, Assert: "tags.Team > 1"},
+			},
+			want: []string{
+				"deny ops-logs logs",
+				"deny broken web",
+				"warn small web",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewEngine(append(BuiltinRules(tt.policies), tt.rules...))
+			if err != nil {
+				t.Fatalf("NewEngine() error = %v", err)
+			}
+			report := engine.Evaluate(testPlan(t))
+
+			var got []string
+			for _, v := range report.Violations {
+				got = append(got, fmt.Sprintf("%s %s %s", v.Severity, v.Rule, v.Target))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+			if report.Denied() != (report.Count(SeverityDeny) > 0) {
+				t.Errorf("Denied() = %v with %d denials", report.Denied(), report.Count(SeverityDeny))
+			}
+		})
+	}
+}
+
+func TestNewEngineErrors(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{Assert: "true"}, "rule has no name"},
+		{Rule{Name: "r"}, "assert is required"},
+		{Rule{Name: "r", Severity: "block", Assert: "true"}, "invalid severity"},
+		{Rule{Name: "r", Assert: "true", When: "(", Source: "p.yaml"}, "p.yaml: rule r: when"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			_, err := NewEngine([]Rule{tt.rule})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewEngine() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictest(t *testing.T) {
+	org := config.Policies{NoPublicBuckets: true, RequireTags: []string{"owner", "cost-center"}, MaxCostPerMonth: 1000}
+	project := config.Policies{RequireEncryption: true, RequireTags: []string{"owner", "team"}, MaxCostPerMonth: 5000}
+
+	got := Strictest(Strictest(config.Policies{}, org), project)
+	if !got.NoPublicBuckets || !got.RequireEncryption {
+		t.Errorf("Strictest() turned off a policy: %+v", got)
+	}
+	if strings.Join(got.RequireTags, ",") != "owner,cost-center,team" {
+		t.Errorf("RequireTags = %v, want owner, cost-center and team", got.RequireTags)
+	}
+	if got.MaxCostPerMonth != 1000 {
+		t.Errorf("MaxCostPerMonth = %v, want the lower limit 1000", got.MaxCostPerMonth)
+	}
+	if got := Strictest(config.Policies{}, project); got.MaxCostPerMonth != 5000 {
+		t.Errorf("MaxCostPerMonth = %v, want 5000 when only one sets a limit", got.MaxCostPerMonth)
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeFile(t, filepath.Join(home, ".genesys", "policy.yaml"), `policies:
+  no_public_buckets: true
+  require_tags: [owner]
+  max_cost_per_month: 100
+default_tags:
+  owner: platform
+  cost-center: "1234"
+rules:
+  - name: org-rule
+    assert: "true"
+`)
+
+	project := t.TempDir()
+	if err := os.Mkdir(filepath.Join(project, ".genesys"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(project, "policies", "b.yaml"), "rules:\n  - name: yaml-rule\n    severity: warn\n    assert: has(tags.Team)\n")
+	writeFile(t, filepath.Join(project, "policies", "a.toml"), `[policies]
+no_public_buckets = false
+max_cost_per_month = 500
+
+[default_tags]
+owner = "web-team"
+
+[[rules]]
+name = "toml-rule"
+resources = ["instance"]
+assert = "properties.type == 'small'"
+`)
+	writeFile(t, filepath.Join(project, "policies", "README"), "not a policy file")
+
+	configPath := filepath.Join(project, "app.yaml")
+	settings, err := LoadSettings(configPath)
+	if err != nil {
+		t.Fatalf("LoadSettings() error = %v", err)
+	}
+	if !settings.Policies.NoPublicBuckets || settings.Policies.MaxCostPerMonth != 100 {
+		t.Errorf("Policies = %+v, the project weakened the organization's policies", settings.Policies)
+	}
+	if settings.DefaultTags["owner"] != "web-team" || settings.DefaultTags["cost-center"] != "1234" {
+		t.Errorf("DefaultTags = %v, want the project owner and the organization cost-center", settings.DefaultTags)
+	}
+
+	var names []string
+	for _, rule := range settings.Rules {
+		names = append(names, rule.Name)
+	}
+	if strings.Join(names, ",") != "org-rule,toml-rule,yaml-rule" {
+		t.Errorf("Rules = %v, want the organization's rules, then the project's in file order", names)
+	}
+	if source := settings.Rules[2].Source; source != filepath.Join(project, "policies", "b.yaml") {
+		t.Errorf("Source = %s, want the file the rule was read from", source)
+	}
+
+	// A configuration may turn a policy off, but the policy files keep it on
+	engine, err := Load(configPath, config.Policies{NoPublicBuckets: false})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(engine.Rules) != 6 || engine.Rules[0].Name != "no_public_buckets" {
+		t.Errorf("Load() rules = %d, want the built-in rules of the policy files and their rules", len(engine.Rules))
+	}
+
+	// Without a policies directory only the organization's file applies
+	settings, err = LoadSettings(filepath.Join(t.TempDir(), "app.yaml"))
+	if err != nil || len(settings.Rules) != 1 {
+		t.Errorf("LoadSettings() = %+v, %v, want only the organization's settings", settings, err)
+	}
+}
+
+func TestLoadFileUnknownFields(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown.yaml": "rules:\n  - name: r\n    asert: true\n",
+		"unknown.toml": "[[rules]]\nname = \"r\"\nasert = \"true\"\n",
+	} {
+		path := filepath.Join(t.TempDir(), name)
+		writeFile(t, path, content)
+		if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), "asert") {
+			t.Errorf("LoadFile(%s) error = %v, want the unknown field", name, err)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -22,7 +22,7 @@ type AMIResolver struct {
 
 // AWSProviderInterface defines the interface needed by AMI resolver
 type AWSProviderInterface interface {
-	CreateClient(service string) (*AWSClient, error)
+	CreateClient(ctx context.Context, service string) (*AWSClient, error)
 }
 
 // AMIResolverConfig holds configuration options for the AMI resolver
@@ -155,7 +155,7 @@ func (r *AMIResolver) lookupFromSSM(ctx context.Context, image string) (string,
 	}
 
 	// Create SSM client (different service endpoint)
-	ssmClient, err := r.provider.CreateClient("ssm")
+	ssmClient, err := r.provider.CreateClient(ctx, "ssm")
 	if err != nil {
 		return "", fmt.Errorf("failed to create SSM client: %w", err)
 	}
@@ -239,7 +239,7 @@ func (r *AMIResolver) lookupFromDescribeImages(ctx context.Context, image string
 	params[fmt.Sprintf("Filter.%d.Value.1", filterIndex)] = "available"
 
 	// Create EC2 client for this request
-	ec2Client, err := r.provider.CreateClient("ec2")
+	ec2Client, err := r.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return "", fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -2,6 +2,7 @@ package aws
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha256"
@@ -26,6 +27,37 @@ type AWSClient struct {
 	Region       string
 	Service      string
 	HTTPClient   *http.Client
+	// Retry is the retry policy of the client's requests; DefaultRetryPolicy when nil
+	Retry *RetryPolicy
+	// Limiter spaces out the client's requests; when nil the client shares
+	// the limiter of its service and region
+	Limiter *RateLimiter
+	// Context ends the client's requests and the waits between their
+	// retries; context.Background() when nil
+	Context context.Context
+}
+
+// WithRetry returns a copy of the client that retries its requests with
+// another policy, for calls that need more patience or none
+func (c *AWSClient) WithRetry(policy RetryPolicy) *AWSClient {
+	clone := *c
+	clone.Retry = &policy
+	return &clone
+}
+
+// WithContext returns a copy of the client whose requests end when ctx is done
+func (c *AWSClient) WithContext(ctx context.Context) *AWSClient {
+	clone := *c
+	clone.Context = ctx
+	return &clone
+}
+
+// requestContext returns the context of the client's requests
+func (c *AWSClient) requestContext() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
 }
 
 // ProviderCredentials represents stored credentials (matching config package)
@@ -320,16 +352,22 @@ func (c *AWSClient) getSigningRegion() string {
 
 // RequestWithMD5 makes an authenticated AWS API request with Content-MD5 header
 func (c *AWSClient) RequestWithMD5(method, endpoint string, params map[string]string, body []byte) (*http.Response, error) {
-	return c.requestInternal(method, endpoint, params, body, true)
+	return c.requestInternal(method, endpoint, params, nil, body, true)
 }
 
 // Request makes an authenticated AWS API request
 func (c *AWSClient) Request(method, endpoint string, params map[string]string, body []byte) (*http.Response, error) {
-	return c.requestInternal(method, endpoint, params, body, false)
+	return c.requestInternal(method, endpoint, params, nil, body, false)
+}
+
+// RequestWithHeaders makes an authenticated AWS API request with extra
+// headers, such as conditional request headers or X-Amz-Target
+func (c *AWSClient) RequestWithHeaders(method, endpoint string, params, headers map[string]string, body []byte) (*http.Response, error) {
+	return c.requestInternal(method, endpoint, params, headers, body, false)
 }
 
 // requestInternal is the internal request method
-func (c *AWSClient) requestInternal(method, endpoint string, params map[string]string, body []byte, includeMD5 bool) (*http.Response, error) {
+func (c *AWSClient) requestInternal(method, endpoint string, params, headers map[string]string, body []byte, includeMD5 bool) (*http.Response, error) {
 	// Build URL - handle global services that don't use regional endpoints
 	var baseURL string
 	if isGlobalService(c.Service) {
@@ -364,8 +402,65 @@ func (c *AWSClient) requestInternal(method, endpoint string, params map[string]s
 		requestBody = body
 	}
 
+	policy := DefaultRetryPolicy
+	if c.Retry != nil {
+		policy = *c.Retry
+	}
+	limiter := c.Limiter
+	if limiter == nil {
+		limiter = limiterFor(c.Service, c.Region)
+	}
+
+	ctx := c.requestContext()
+	// A request that failed without a response may still have been carried
+	// out, so only those that can safely run twice are sent again
+	idempotent := isIdempotent(method, params)
+
+	for attempt := 1; ; attempt++ {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := c.send(ctx, method, baseURL, requestBody, body, headers, includeMD5)
+		if err != nil {
+			if attempt >= policy.MaxAttempts || !idempotent || !isRetryableNetworkError(err) {
+				if attempt > 1 {
+					return nil, fmt.Errorf("%w (after %d attempts)", err, attempt)
+				}
+				return nil, err
+			}
+			if err := policy.wait(ctx, attempt, nil, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode < 400 || attempt >= policy.MaxAttempts {
+			return resp, nil
+		}
+
+		// Read the error to classify it, leaving it readable for the caller
+		responseBody, readErr := ReadResponse(resp)
+		resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+		if readErr != nil {
+			return resp, nil
+		}
+		apiErr := ParseAPIError(resp, responseBody)
+		if !IsRetryable(apiErr) && (policy.Retryable == nil || !policy.Retryable(apiErr)) {
+			return resp, nil
+		}
+		if err := policy.wait(ctx, attempt, resp, apiErr); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+}
+
+// send makes one signed attempt at a request
+func (c *AWSClient) send(ctx context.Context, method, baseURL string, requestBody, body []byte, headers map[string]string, includeMD5 bool) (*http.Response, error) {
 	// Create request
-	req, err := http.NewRequest(method, baseURL, bytes.NewReader(requestBody))
+	req, err := http.NewRequestWithContext(ctx, method, baseURL, bytes.NewReader(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -393,6 +488,10 @@ func (c *AWSClient) requestInternal(method, endpoint string, params map[string]s
 		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
 	}
 
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
 	if c.SessionToken != "" {
 		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
 	}
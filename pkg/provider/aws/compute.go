@@ -2,6 +2,8 @@ package aws
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"strings"
@@ -59,6 +61,7 @@ type EC2Instance struct {
 		Name string `xml:"name"`
 	} `xml:"state"`
 	PrivateIpAddress string `xml:"privateIpAddress"`
+	PublicIpAddress  string `xml:"ipAddress"`
 	LaunchTime       string `xml:"launchTime"`
 	Tags             struct {
 		Items []struct {
@@ -122,9 +125,16 @@ func isValidAMIID(amiID string) bool {
 	return true
 }
 
+// newClientToken returns a unique idempotency token for an EC2 request
+func newClientToken() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
 // CreateInstance creates a new EC2 instance
 func (c *ComputeService) CreateInstance(ctx context.Context, config *provider.InstanceConfig) (*provider.Instance, error) {
-	client, err := c.provider.CreateClient("ec2")
+	client, err := c.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -151,6 +161,12 @@ func (c *ComputeService) CreateInstance(ctx context.Context, config *provider.In
 		"MinCount":     "1",
 		"MaxCount":     "1",
 		"InstanceType": instanceType,
+		// The token lets a retried request return the instance the first
+		// attempt launched instead of launching another
+		"ClientToken": newClientToken(),
+	}
+	if config.Subnet != "" {
+		params["SubnetId"] = config.Subnet
 	}
 
 	// Add tags (always include Name tag, so we always have at least one tag)
@@ -158,7 +174,7 @@ func (c *ComputeService) CreateInstance(ctx context.Context, config *provider.In
 	tagIndex := 1
 	
 	// Add user-defined tags
-	for key, value := range config.Tags {
+	for key, value := range provider.WithDefaultTags(c.provider.defaultTags, config.Tags) {
 		params[fmt.Sprintf("TagSpecification.1.Tag.%d.Key", tagIndex)] = key
 		params[fmt.Sprintf("TagSpecification.1.Tag.%d.Value", tagIndex)] = value
 		tagIndex++
@@ -207,7 +223,7 @@ func (c *ComputeService) CreateInstance(ctx context.Context, config *provider.In
 
 // GetInstance retrieves an instance by ID
 func (c *ComputeService) GetInstance(ctx context.Context, id string) (*provider.Instance, error) {
-	client, err := c.provider.CreateClient("ec2")
+	client, err := c.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -254,7 +270,7 @@ func (c *ComputeService) GetInstance(ctx context.Context, id string) (*provider.
 // UpdateInstance updates an instance configuration
 func (c *ComputeService) UpdateInstance(ctx context.Context, id string, config *provider.InstanceConfig) error {
 	// For now, we only support updating tags
-	client, err := c.provider.CreateClient("ec2")
+	client, err := c.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -266,7 +282,7 @@ func (c *ComputeService) UpdateInstance(ctx context.Context, id string, config *
 	}
 
 	tagIndex := 1
-	for key, value := range config.Tags {
+	for key, value := range provider.WithDefaultTags(c.provider.defaultTags, config.Tags) {
 		params[fmt.Sprintf("Tag.%d.Key", tagIndex)] = key
 		params[fmt.Sprintf("Tag.%d.Value", tagIndex)] = value
 		tagIndex++
@@ -289,7 +305,7 @@ func (c *ComputeService) UpdateInstance(ctx context.Context, id string, config *
 
 // DeleteInstance terminates an instance
 func (c *ComputeService) DeleteInstance(ctx context.Context, id string) error {
-	client, err := c.provider.CreateClient("ec2")
+	client, err := c.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -317,7 +333,7 @@ func (c *ComputeService) DeleteInstance(ctx context.Context, id string) error {
 
 // ListInstances lists instances with optional filters
 func (c *ComputeService) ListInstances(ctx context.Context, filters map[string]string) ([]*provider.Instance, error) {
-	client, err := c.provider.CreateClient("ec2")
+	client, err := c.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -449,11 +465,19 @@ func (c *ComputeService) convertToProviderInstance(ec2Instance EC2Instance) *pro
 		Type:      provider.InstanceType(c.reverseMapInstanceType(ec2Instance.InstanceType)),
 		State:     ec2Instance.State.Name,
 		PrivateIP: ec2Instance.PrivateIpAddress,
+		PublicIP:  ec2Instance.PublicIpAddress,
 		Tags:      tags,
 		CreatedAt: createdAt,
 	}
 }
 
+// ReportedInstanceType returns the type GetInstance reports for an instance
+// launched with a configured type, such as "small" for "t3.small"
+func ReportedInstanceType(instanceType string) string {
+	c := &ComputeService{}
+	return c.reverseMapInstanceType(c.mapInstanceType(instanceType))
+}
+
 func (c *ComputeService) reverseMapInstanceType(awsType string) string {
 	switch awsType {
 	case "t3.small":
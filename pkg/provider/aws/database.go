@@ -53,7 +53,7 @@ type RDSInstance struct {
 
 // CreateDatabase creates a new RDS instance
 func (d *DatabaseService) CreateDatabase(ctx context.Context, config *provider.DatabaseConfig) (*provider.Database, error) {
-	client, err := d.provider.CreateClient("rds")
+	client, err := d.provider.CreateClient(ctx, "rds")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create RDS client: %w", err)
 	}
@@ -75,6 +75,11 @@ func (d *DatabaseService) CreateDatabase(ctx context.Context, config *provider.D
 		"MasterUserPassword":   "TempPassword123!", // Should be configurable
 	}
 
+	// Place the instance in the given security groups
+	for i, group := range config.SecurityGroups {
+		params[fmt.Sprintf("VpcSecurityGroupIds.member.%d", i+1)] = group
+	}
+
 	// Add backup configuration
 	if config.BackupConfig != nil {
 		params["BackupRetentionPeriod"] = fmt.Sprintf("%d", config.BackupConfig.RetentionDays)
@@ -82,8 +87,9 @@ func (d *DatabaseService) CreateDatabase(ctx context.Context, config *provider.D
 	}
 
 	// Add tags
+	tags := provider.WithDefaultTags(d.provider.defaultTags, config.Tags)
 	tagIndex := 1
-	for key, value := range config.Tags {
+	for key, value := range tags {
 		params[fmt.Sprintf("Tags.member.%d.Key", tagIndex)] = key
 		params[fmt.Sprintf("Tags.member.%d.Value", tagIndex)] = value
 		tagIndex++
@@ -113,12 +119,12 @@ func (d *DatabaseService) CreateDatabase(ctx context.Context, config *provider.D
 	}
 
 	// Convert to provider database
-	return d.convertToProviderDatabase(createResp.DBInstance, config.Tags), nil
+	return d.convertToProviderDatabase(createResp.DBInstance, tags), nil
 }
 
 // GetDatabase retrieves a database by identifier
 func (d *DatabaseService) GetDatabase(ctx context.Context, id string) (*provider.Database, error) {
-	client, err := d.provider.CreateClient("rds")
+	client, err := d.provider.CreateClient(ctx, "rds")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create RDS client: %w", err)
 	}
@@ -161,7 +167,7 @@ func (d *DatabaseService) GetDatabase(ctx context.Context, id string) (*provider
 
 // UpdateDatabase updates a database configuration
 func (d *DatabaseService) UpdateDatabase(ctx context.Context, id string, config *provider.DatabaseConfig) error {
-	client, err := d.provider.CreateClient("rds")
+	client, err := d.provider.CreateClient(ctx, "rds")
 	if err != nil {
 		return fmt.Errorf("failed to create RDS client: %w", err)
 	}
@@ -183,6 +189,14 @@ func (d *DatabaseService) UpdateDatabase(ctx context.Context, id string, config
 		params["AllocatedStorage"] = fmt.Sprintf("%d", config.Storage)
 	}
 
+	// Only update the engine version if provided; major upgrades are
+	// planned as replacements
+	if config.Version != "" {
+		params["EngineVersion"] = config.Version
+	}
+
+	params["MultiAZ"] = fmt.Sprintf("%t", config.MultiAZ)
+
 	resp, err := client.Request("POST", "/", params, nil)
 	if err != nil {
 		return fmt.Errorf("failed to modify database: %w", err)
@@ -199,7 +213,7 @@ func (d *DatabaseService) UpdateDatabase(ctx context.Context, id string, config
 
 // DeleteDatabase deletes a database instance
 func (d *DatabaseService) DeleteDatabase(ctx context.Context, id string) error {
-	client, err := d.provider.CreateClient("rds")
+	client, err := d.provider.CreateClient(ctx, "rds")
 	if err != nil {
 		return fmt.Errorf("failed to create RDS client: %w", err)
 	}
@@ -227,7 +241,7 @@ func (d *DatabaseService) DeleteDatabase(ctx context.Context, id string) error {
 
 // DiscoverDatabases discovers existing database instances
 func (d *DatabaseService) DiscoverDatabases(ctx context.Context) ([]*provider.Database, error) {
-	client, err := d.provider.CreateClient("rds")
+	client, err := d.provider.CreateClient(ctx, "rds")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create RDS client: %w", err)
 	}
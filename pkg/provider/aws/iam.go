@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/javanhut/genesys/pkg/provider"
 )
 
 // IAMService implements AWS IAM operations
@@ -100,7 +102,7 @@ type ListRoleTagsResponse struct {
 
 // CreateRole creates a new IAM role
 func (s *IAMService) CreateRole(ctx context.Context, config *RoleConfig) (*Role, error) {
-	client, err := s.provider.CreateClient("iam")
+	client, err := s.provider.CreateClient(ctx, "iam")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create IAM client: %w", err)
 	}
@@ -117,8 +119,9 @@ func (s *IAMService) CreateRole(ctx context.Context, config *RoleConfig) (*Role,
 	}
 
 	// Add tags if provided
+	tags := provider.WithDefaultTags(s.provider.defaultTags, config.Tags)
 	tagIndex := 1
-	for key, value := range config.Tags {
+	for key, value := range tags {
 		params[fmt.Sprintf("Tags.member.%d.Key", tagIndex)] = key
 		params[fmt.Sprintf("Tags.member.%d.Value", tagIndex)] = value
 		tagIndex++
@@ -156,14 +159,14 @@ func (s *IAMService) CreateRole(ctx context.Context, config *RoleConfig) (*Role,
 		ARN:              createResp.Result.Role.Arn,
 		AssumeRolePolicy: config.TrustPolicy,
 		Description:      config.Description,
-		Tags:             config.Tags,
+		Tags:             tags,
 		CreatedAt:        createdAt,
 	}, nil
 }
 
 // GetRole retrieves an existing IAM role
 func (s *IAMService) GetRole(ctx context.Context, roleName string) (*Role, error) {
-	client, err := s.provider.CreateClient("iam")
+	client, err := s.provider.CreateClient(ctx, "iam")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create IAM client: %w", err)
 	}
@@ -230,7 +233,7 @@ func (s *IAMService) GetRole(ctx context.Context, roleName string) (*Role, error
 
 // DeleteRole deletes an IAM role
 func (s *IAMService) DeleteRole(ctx context.Context, roleName string) error {
-	client, err := s.provider.CreateClient("iam")
+	client, err := s.provider.CreateClient(ctx, "iam")
 	if err != nil {
 		return fmt.Errorf("failed to create IAM client: %w", err)
 	}
@@ -257,10 +260,19 @@ func (s *IAMService) DeleteRole(ctx context.Context, roleName string) error {
 
 // AttachPolicy attaches a managed policy to a role
 func (s *IAMService) AttachPolicy(ctx context.Context, roleName, policyArn string) error {
-	client, err := s.provider.CreateClient("iam")
+	return s.attachPolicy(ctx, roleName, policyArn, nil)
+}
+
+// attachPolicy attaches a managed policy to a role, retrying with a policy
+// other than the client's when one is given
+func (s *IAMService) attachPolicy(ctx context.Context, roleName, policyArn string, retry *RetryPolicy) error {
+	client, err := s.provider.CreateClient(ctx, "iam")
 	if err != nil {
 		return fmt.Errorf("failed to create IAM client: %w", err)
 	}
+	if retry != nil {
+		client = client.WithRetry(*retry)
+	}
 
 	params := map[string]string{
 		"Action":    "AttachRolePolicy",
@@ -285,7 +297,7 @@ func (s *IAMService) AttachPolicy(ctx context.Context, roleName, policyArn strin
 
 // DetachPolicy detaches a managed policy from a role
 func (s *IAMService) DetachPolicy(ctx context.Context, roleName, policyArn string) error {
-	client, err := s.provider.CreateClient("iam")
+	client, err := s.provider.CreateClient(ctx, "iam")
 	if err != nil {
 		return fmt.Errorf("failed to create IAM client: %w", err)
 	}
@@ -313,7 +325,7 @@ func (s *IAMService) DetachPolicy(ctx context.Context, roleName, policyArn strin
 
 // ListAttachedPolicies lists policies attached to a role
 func (s *IAMService) ListAttachedPolicies(ctx context.Context, roleName string) ([]*Policy, error) {
-	client, err := s.provider.CreateClient("iam")
+	client, err := s.provider.CreateClient(ctx, "iam")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create IAM client: %w", err)
 	}
@@ -359,7 +371,7 @@ func (s *IAMService) ListAttachedPolicies(ctx context.Context, roleName string)
 
 // ListRoleTags lists tags for a role
 func (s *IAMService) ListRoleTags(ctx context.Context, roleName string) (map[string]string, error) {
-	client, err := s.provider.CreateClient("iam")
+	client, err := s.provider.CreateClient(ctx, "iam")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create IAM client: %w", err)
 	}
@@ -511,27 +523,14 @@ func (s *IAMService) CreateRoleWithPolicies(ctx context.Context, config *RoleCon
 	return role, nil
 }
 
-// AttachPolicyWithRetry attaches a policy with retry logic for eventual consistency
+// AttachPolicyWithRetry attaches a policy, also retrying while IAM does not
+// see a role or policy that was just created yet (eventual consistency)
 func (s *IAMService) AttachPolicyWithRetry(ctx context.Context, roleName, policyArn string) error {
-	maxRetries := 3
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		err := s.AttachPolicy(ctx, roleName, policyArn)
-		if err == nil {
-			return nil
-		}
-
-		// If it's a temporary error, retry
-		if attempt < maxRetries-1 && (strings.Contains(err.Error(), "throttling") ||
-			strings.Contains(err.Error(), "temporarily unavailable")) {
-			waitTime := time.Duration(1<<uint(attempt)) * time.Second
-			time.Sleep(waitTime)
-			continue
-		}
-
-		return err
+	policy := DefaultRetryPolicy
+	policy.Retryable = func(apiErr *APIError) bool {
+		return apiErr.Code == "NoSuchEntity"
 	}
-
-	return fmt.Errorf("failed to attach policy after %d attempts", maxRetries)
+	return s.attachPolicy(ctx, roleName, policyArn, &policy)
 }
 
 // waitForRolePropagation waits for the role to be propagated across AWS regions
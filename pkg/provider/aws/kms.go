@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// kmsEncryptionContext binds data keys wrapped by KMS to Genesys state, so
+// they cannot be unwrapped for other purposes
+var kmsEncryptionContext = map[string]string{"genesys": "state"}
+
+// KMSKeyWrapper wraps the data keys state is encrypted with using a KMS key
+type KMSKeyWrapper struct {
+	provider *AWSProvider
+	keyID    string
+}
+
+// NewKMSKeyWrapper creates a key wrapper for a KMS key ID, ARN or alias
+func NewKMSKeyWrapper(p *AWSProvider, keyID string) *KMSKeyWrapper {
+	return &KMSKeyWrapper{provider: p, keyID: keyID}
+}
+
+// ID identifies the KMS key
+func (k *KMSKeyWrapper) ID() string {
+	return "kms:" + k.keyID
+}
+
+// Wrap encrypts a data key with the KMS key
+func (k *KMSKeyWrapper) Wrap(dataKey []byte) ([]byte, error) {
+	var output struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+	err := k.kmsRequest("Encrypt", map[string]interface{}{
+		"KeyId":             k.keyID,
+		"Plaintext":         dataKey,
+		"EncryptionContext": kmsEncryptionContext,
+	}, &output)
+	if err != nil {
+		return nil, err
+	}
+	return output.CiphertextBlob, nil
+}
+
+// Unwrap decrypts a data key wrapped by Wrap. The key is named so that KMS
+// rejects data keys wrapped by other keys.
+func (k *KMSKeyWrapper) Unwrap(wrapped []byte) ([]byte, error) {
+	var output struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	err := k.kmsRequest("Decrypt", map[string]interface{}{
+		"KeyId":             k.keyID,
+		"CiphertextBlob":    wrapped,
+		"EncryptionContext": kmsEncryptionContext,
+	}, &output)
+	if err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
+
+// kmsRequest calls a KMS JSON API action and decodes its output. Byte
+// slices are sent and received base64 encoded, as KMS expects.
+func (k *KMSKeyWrapper) kmsRequest(action string, input map[string]interface{}, output interface{}) error {
+	client, err := k.provider.CreateClient(context.Background(), "kms")
+	if err != nil {
+		return fmt.Errorf("failed to create KMS client: %w", err)
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", action, err)
+	}
+
+	headers := map[string]string{
+		"X-Amz-Target": "TrentService." + action,
+	}
+	resp, err := client.RequestWithHeaders("POST", "/", nil, headers, body)
+	if err != nil {
+		return fmt.Errorf("failed to call KMS %s: %w", action, err)
+	}
+
+	responseBody, err := ReadResponse(resp)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("KMS %s with key %s failed with status %d: %s", action, k.keyID, resp.StatusCode, string(responseBody))
+	}
+
+	if err := json.Unmarshal(responseBody, output); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", action, err)
+	}
+	return nil
+}
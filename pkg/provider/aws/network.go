@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/javanhut/genesys/pkg/provider"
@@ -34,6 +36,32 @@ type DescribeVpcsResponse struct {
 	} `xml:"vpcSet"`
 }
 
+type CreateSubnetResponse struct {
+	XMLName xml.Name `xml:"CreateSubnetResponse"`
+	Subnet  struct {
+		SubnetId string `xml:"subnetId"`
+	} `xml:"subnet"`
+}
+
+type CreateSecurityGroupResponse struct {
+	XMLName xml.Name `xml:"CreateSecurityGroupResponse"`
+	GroupId string   `xml:"groupId"`
+}
+
+type CreateInternetGatewayResponse struct {
+	XMLName         xml.Name `xml:"CreateInternetGatewayResponse"`
+	InternetGateway struct {
+		InternetGatewayId string `xml:"internetGatewayId"`
+	} `xml:"internetGateway"`
+}
+
+type CreateRouteTableResponse struct {
+	XMLName    xml.Name `xml:"CreateRouteTableResponse"`
+	RouteTable struct {
+		RouteTableId string `xml:"routeTableId"`
+	} `xml:"routeTable"`
+}
+
 type VPC struct {
 	VpcId     string `xml:"vpcId"`
 	CidrBlock string `xml:"cidrBlock"`
@@ -48,7 +76,7 @@ type VPC struct {
 
 // CreateNetwork creates a new VPC
 func (n *NetworkService) CreateNetwork(ctx context.Context, config *provider.NetworkConfig) (*provider.Network, error) {
-	client, err := n.provider.CreateClient("ec2")
+	client, err := n.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -84,16 +112,17 @@ func (n *NetworkService) CreateNetwork(ctx context.Context, config *provider.Net
 	}
 
 	// Add tags if provided
-	if len(config.Tags) > 0 {
-		if err := n.createTags(client, createResp.VPC.VpcId, config.Tags); err != nil {
+	tags := provider.WithDefaultTags(n.provider.defaultTags, config.Tags)
+	if len(tags) > 0 {
+		if err := n.createTags(client, createResp.VPC.VpcId, tags); err != nil {
 			return nil, fmt.Errorf("failed to add tags: %w", err)
 		}
 	}
 
 	// Add Name tag
 	if config.Name != "" {
-		tags := map[string]string{"Name": config.Name}
-		if err := n.createTags(client, createResp.VPC.VpcId, tags); err != nil {
+		nameTag := map[string]string{"Name": config.Name}
+		if err := n.createTags(client, createResp.VPC.VpcId, nameTag); err != nil {
 			return nil, fmt.Errorf("failed to add name tag: %w", err)
 		}
 	}
@@ -103,14 +132,14 @@ func (n *NetworkService) CreateNetwork(ctx context.Context, config *provider.Net
 		ID:        createResp.VPC.VpcId,
 		Name:      config.Name,
 		CIDR:      createResp.VPC.CidrBlock,
-		Tags:      config.Tags,
+		Tags:      tags,
 		CreatedAt: time.Now(),
 	}, nil
 }
 
 // GetNetwork retrieves a network by ID
 func (n *NetworkService) GetNetwork(ctx context.Context, id string) (*provider.Network, error) {
-	client, err := n.provider.CreateClient("ec2")
+	client, err := n.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -153,7 +182,7 @@ func (n *NetworkService) GetNetwork(ctx context.Context, id string) (*provider.N
 
 // CreateSubnet creates a subnet in a VPC
 func (n *NetworkService) CreateSubnet(ctx context.Context, networkID string, config *provider.SubnetConfig) (*provider.Subnet, error) {
-	client, err := n.provider.CreateClient("ec2")
+	client, err := n.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -173,27 +202,60 @@ func (n *NetworkService) CreateSubnet(ctx context.Context, networkID string, con
 	if err != nil {
 		return nil, fmt.Errorf("failed to create subnet: %w", err)
 	}
-	defer resp.Body.Close()
 
+	body, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
 	if resp.StatusCode != 200 {
-		body, _ := ReadResponse(resp)
 		return nil, fmt.Errorf("CreateSubnet failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	// For simplicity, return a basic subnet structure
+	var createResp CreateSubnetResponse
+	if err := xml.Unmarshal(body, &createResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	subnetID := createResp.Subnet.SubnetId
+
+	// Instances in public subnets get a public address to be reached through
+	// the internet gateway
+	if config.Public {
+		if err := n.ec2Action(client, map[string]string{
+			"Action":                    "ModifySubnetAttribute",
+			"SubnetId":                  subnetID,
+			"MapPublicIpOnLaunch.Value": "true",
+		}); err != nil {
+			return nil, fmt.Errorf("failed to enable public addresses: %w", err)
+		}
+	}
+
+	tags := provider.WithDefaultTags(n.provider.defaultTags, config.Tags)
+	if len(tags) > 0 {
+		if err := n.createTags(client, subnetID, tags); err != nil {
+			return nil, fmt.Errorf("failed to add tags: %w", err)
+		}
+	}
+
+	if config.Name != "" {
+		if err := n.createTags(client, subnetID, map[string]string{"Name": config.Name}); err != nil {
+			return nil, fmt.Errorf("failed to add name tag: %w", err)
+		}
+	}
+
 	return &provider.Subnet{
-		ID:        fmt.Sprintf("subnet-%d", time.Now().Unix()),
+		ID:        subnetID,
 		Name:      config.Name,
 		CIDR:      config.CIDR,
 		NetworkID: networkID,
 		Public:    config.Public,
 		AZ:        config.AZ,
+		Tags:      tags,
 	}, nil
 }
 
 // CreateSecurityGroup creates a security group
 func (n *NetworkService) CreateSecurityGroup(ctx context.Context, config *provider.SecurityGroupConfig) (*provider.SecurityGroup, error) {
-	client, err := n.provider.CreateClient("ec2")
+	client, err := n.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -205,30 +267,203 @@ func (n *NetworkService) CreateSecurityGroup(ctx context.Context, config *provid
 		"GroupDescription": config.Description,
 	}
 
+	if config.NetworkID != "" {
+		params["VpcId"] = config.NetworkID
+	}
+
 	resp, err := client.Request("POST", "/", params, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create security group: %w", err)
 	}
-	defer resp.Body.Close()
 
+	body, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
 	if resp.StatusCode != 200 {
-		body, _ := ReadResponse(resp)
 		return nil, fmt.Errorf("CreateSecurityGroup failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	// For simplicity, return a basic security group structure
+	var createResp CreateSecurityGroupResponse
+	if err := xml.Unmarshal(body, &createResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	groupID := createResp.GroupId
+	if groupID == "" {
+		return nil, fmt.Errorf("CreateSecurityGroup returned no group ID")
+	}
+
+	tags := provider.WithDefaultTags(n.provider.defaultTags, config.Tags)
+	if len(tags) > 0 {
+		if err := n.createTags(client, groupID, tags); err != nil {
+			return nil, fmt.Errorf("failed to add tags: %w", err)
+		}
+	}
+
+	for _, rule := range config.Rules {
+		if err := n.ec2Action(client, securityRuleParams(groupID, rule)); err != nil {
+			return nil, fmt.Errorf("failed to add %s rule to security group %s: %w", rule.Direction, groupID, err)
+		}
+	}
+
 	return &provider.SecurityGroup{
-		ID:          fmt.Sprintf("sg-%d", time.Now().Unix()),
+		ID:          groupID,
 		Name:        config.Name,
 		Description: config.Description,
 		Rules:       config.Rules,
-		Tags:        config.Tags,
+		Tags:        tags,
+	}, nil
+}
+
+// securityRuleParams builds the request authorizing a rule on a security
+// group. Sources starting with "sg-" name another security group; anything
+// else is a CIDR block.
+func securityRuleParams(groupID string, rule provider.SecurityRule) map[string]string {
+	action := "AuthorizeSecurityGroupIngress"
+	if rule.Direction == "egress" {
+		action = "AuthorizeSecurityGroupEgress"
+	}
+
+	protocol := rule.Protocol
+	if protocol == "" || protocol == "all" {
+		protocol = "-1"
+	}
+
+	params := map[string]string{
+		"Action":                     action,
+		"GroupId":                    groupID,
+		"IpPermissions.1.IpProtocol": protocol,
+	}
+	if protocol != "-1" {
+		params["IpPermissions.1.FromPort"] = strconv.Itoa(rule.FromPort)
+		params["IpPermissions.1.ToPort"] = strconv.Itoa(rule.ToPort)
+	}
+	if strings.HasPrefix(rule.Source, "sg-") {
+		params["IpPermissions.1.Groups.1.GroupId"] = rule.Source
+	} else {
+		params["IpPermissions.1.IpRanges.1.CidrIp"] = rule.Source
+	}
+	return params
+}
+
+// CreateInternetGateway creates an internet gateway and attaches it to a VPC
+func (n *NetworkService) CreateInternetGateway(ctx context.Context, networkID string, tags map[string]string) (*provider.InternetGateway, error) {
+	client, err := n.provider.CreateClient(ctx, "ec2")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
+	}
+
+	resp, err := client.Request("POST", "/", map[string]string{
+		"Action":  "CreateInternetGateway",
+		"Version": "2016-11-15",
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create internet gateway: %w", err)
+	}
+
+	body, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("CreateInternetGateway failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var createResp CreateInternetGatewayResponse
+	if err := xml.Unmarshal(body, &createResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	gatewayID := createResp.InternetGateway.InternetGatewayId
+
+	tags = provider.WithDefaultTags(n.provider.defaultTags, tags)
+	if err := n.createTags(client, gatewayID, tags); err != nil {
+		return nil, fmt.Errorf("failed to add tags: %w", err)
+	}
+
+	if err := n.ec2Action(client, map[string]string{
+		"Action":            "AttachInternetGateway",
+		"InternetGatewayId": gatewayID,
+		"VpcId":             networkID,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to attach internet gateway %s: %w", gatewayID, err)
+	}
+
+	return &provider.InternetGateway{
+		ID:        gatewayID,
+		NetworkID: networkID,
+		Tags:      tags,
+	}, nil
+}
+
+// CreateRouteTable creates a route table in a VPC, routes traffic leaving
+// the VPC through the gateway and associates the subnets with it
+func (n *NetworkService) CreateRouteTable(ctx context.Context, networkID string, config *provider.RouteTableConfig) (*provider.RouteTable, error) {
+	client, err := n.provider.CreateClient(ctx, "ec2")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
+	}
+
+	resp, err := client.Request("POST", "/", map[string]string{
+		"Action":  "CreateRouteTable",
+		"Version": "2016-11-15",
+		"VpcId":   networkID,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create route table: %w", err)
+	}
+
+	body, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("CreateRouteTable failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var createResp CreateRouteTableResponse
+	if err := xml.Unmarshal(body, &createResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	tableID := createResp.RouteTable.RouteTableId
+
+	tags := provider.WithDefaultTags(n.provider.defaultTags, config.Tags)
+	if err := n.createTags(client, tableID, tags); err != nil {
+		return nil, fmt.Errorf("failed to add tags: %w", err)
+	}
+
+	if config.GatewayID != "" {
+		if err := n.ec2Action(client, map[string]string{
+			"Action":               "CreateRoute",
+			"RouteTableId":         tableID,
+			"DestinationCidrBlock": "0.0.0.0/0",
+			"GatewayId":            config.GatewayID,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to add default route: %w", err)
+		}
+	}
+
+	for _, subnetID := range config.SubnetIDs {
+		if err := n.ec2Action(client, map[string]string{
+			"Action":       "AssociateRouteTable",
+			"RouteTableId": tableID,
+			"SubnetId":     subnetID,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to associate subnet %s: %w", subnetID, err)
+		}
+	}
+
+	return &provider.RouteTable{
+		ID:        tableID,
+		NetworkID: networkID,
+		GatewayID: config.GatewayID,
+		SubnetIDs: config.SubnetIDs,
+		Tags:      tags,
 	}, nil
 }
 
 // DiscoverNetworks discovers existing VPCs
 func (n *NetworkService) DiscoverNetworks(ctx context.Context) ([]*provider.Network, error) {
-	client, err := n.provider.CreateClient("ec2")
+	client, err := n.provider.CreateClient(ctx, "ec2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 client: %w", err)
 	}
@@ -306,6 +541,24 @@ func (n *NetworkService) createTags(client *AWSClient, resourceId string, tags m
 	return nil
 }
 
+// ec2Action sends an EC2 request whose response carries nothing but success
+func (n *NetworkService) ec2Action(client *AWSClient, params map[string]string) error {
+	params["Version"] = "2016-11-15"
+
+	resp, err := client.Request("POST", "/", params, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := ReadResponse(resp)
+		return fmt.Errorf("%s failed with status %d: %s", params["Action"], resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
 func (n *NetworkService) convertToProviderNetwork(vpc VPC) *provider.Network {
 	tags := make(map[string]string)
 	var name string
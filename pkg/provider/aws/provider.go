@@ -3,6 +3,8 @@ package aws
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/javanhut/genesys/pkg/provider"
 )
@@ -17,6 +19,52 @@ type AWSProvider struct {
 	serverless provider.ServerlessService
 	state      provider.StateBackend
 	iam        *IAMService
+
+	// Static credentials supplied through the provider registry. When empty,
+	// clients fall back to environment variables and ~/.genesys/aws.json.
+	accessKey    string
+	secretKey    string
+	sessionToken string
+
+	// defaultTags are added to every resource the provider creates
+	defaultTags map[string]string
+}
+
+func init() {
+	provider.Register("aws", NewFactory)
+}
+
+// NewFactory creates an AWS provider from a registry configuration map.
+// Recognized keys are region, access_key_id, secret_access_key,
+// session_token and default_tag.<key>; credential keys are optional and
+// override the defaults.
+func NewFactory(config map[string]string) (provider.Provider, error) {
+	region := config["region"]
+	if region == "" {
+		// Use the region stored by 'genesys config setup' when none is given
+		if creds, err := loadAWSCredentialsFromConfig(); err == nil && creds.Region != "" {
+			region = creds.Region
+		}
+	}
+
+	accessKey := config["access_key_id"]
+	secretKey := config["secret_access_key"]
+	if accessKey == "" && secretKey == "" {
+		awsProvider, err := NewAWSProvider(region)
+		if err != nil {
+			return nil, err
+		}
+		awsProvider.defaultTags = provider.DefaultTags(config)
+		return awsProvider, nil
+	}
+
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("both access_key_id and secret_access_key must be provided")
+	}
+
+	awsProvider := newAWSProviderWithCredentials(region, accessKey, secretKey, config["session_token"])
+	awsProvider.defaultTags = provider.DefaultTags(config)
+	return awsProvider, nil
 }
 
 // NewAWSProvider creates a new AWS provider instance
@@ -34,19 +82,40 @@ func NewAWSProvider(region string) (*AWSProvider, error) {
 	awsProvider := &AWSProvider{
 		region: region,
 	}
-
-	// Initialize services
-	awsProvider.compute = NewComputeService(awsProvider)
-	awsProvider.storage = NewStorageService(awsProvider)
-	awsProvider.network = NewNetworkService(awsProvider)
-	awsProvider.database = NewDatabaseService(awsProvider)
-	awsProvider.serverless = NewServerlessService(awsProvider)
-	awsProvider.state = NewStateBackend(awsProvider)
-	awsProvider.iam = NewIAMService(awsProvider)
+	awsProvider.initServices()
 
 	return awsProvider, nil
 }
 
+// newAWSProviderWithCredentials creates a provider that signs requests with
+// the given static credentials instead of the environment or config file
+func newAWSProviderWithCredentials(region, accessKey, secretKey, sessionToken string) *AWSProvider {
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	awsProvider := &AWSProvider{
+		region:       region,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: sessionToken,
+	}
+	awsProvider.initServices()
+
+	return awsProvider
+}
+
+// initServices initializes the service implementations
+func (p *AWSProvider) initServices() {
+	p.compute = NewComputeService(p)
+	p.storage = NewStorageService(p)
+	p.network = NewNetworkService(p)
+	p.database = NewDatabaseService(p)
+	p.serverless = NewServerlessService(p)
+	p.state = NewStateBackend(p)
+	p.iam = NewIAMService(p)
+}
+
 // Name returns the provider name
 func (p *AWSProvider) Name() string {
 	return "aws"
@@ -60,7 +129,7 @@ func (p *AWSProvider) Region() string {
 // Validate validates the provider configuration
 func (p *AWSProvider) Validate() error {
 	// Test connectivity by making a simple STS call
-	client, err := NewAWSClient(p.region, "sts")
+	client, err := p.CreateClient(context.Background(), "sts")
 	if err != nil {
 		return fmt.Errorf("failed to create STS client: %w", err)
 	}
@@ -122,9 +191,25 @@ func (p *AWSProvider) GetRegion() string {
 	return p.region
 }
 
-// CreateClient creates a new AWS client for the specified service
-func (p *AWSProvider) CreateClient(service string) (*AWSClient, error) {
-	return NewAWSClient(p.region, service)
+// CreateClient creates a new AWS client for the specified service whose
+// requests, and the waits between their retries, end when ctx is done
+func (p *AWSProvider) CreateClient(ctx context.Context, service string) (*AWSClient, error) {
+	if p.accessKey != "" && p.secretKey != "" {
+		return &AWSClient{
+			AccessKey:    p.accessKey,
+			SecretKey:    p.secretKey,
+			SessionToken: p.sessionToken,
+			Region:       p.region,
+			Service:      service,
+			HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+			Context:      ctx,
+		}, nil
+	}
+	client, err := NewAWSClient(p.region, service)
+	if err != nil {
+		return nil, err
+	}
+	return client.WithContext(ctx), nil
 }
 
 // IAM returns the IAM service
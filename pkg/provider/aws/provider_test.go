@@ -1,7 +1,15 @@
 package aws
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/javanhut/genesys/pkg/provider"
 )
 
 func TestNewAWSProvider(t *testing.T) {
@@ -107,6 +115,26 @@ func TestInstanceTypeMapping(t *testing.T) {
 	}
 }
 
+func TestReportedInstanceType(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"small", "small"},
+		{"t3.micro", "micro"},
+		{"t2.micro", "t2.micro"},
+		{"m7i-flex.large", "m7i-flex.large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := ReportedInstanceType(tt.input); got != tt.want {
+				t.Errorf("ReportedInstanceType(%s) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestDatabaseSizeMapping(t *testing.T) {
 	provider := &AWSProvider{region: "us-east-1"}
 	database := NewDatabaseService(provider)
@@ -130,4 +158,187 @@ func TestDatabaseSizeMapping(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestNewFactoryWithStaticCredentials(t *testing.T) {
+	p, err := NewFactory(map[string]string{
+		"region":            "eu-west-1",
+		"access_key_id":     "AKIDEXAMPLE",
+		"secret_access_key": "secret",
+		"session_token":     "token",
+	})
+	if err != nil {
+		t.Fatalf("NewFactory() error = %v", err)
+	}
+
+	if p.Region() != "eu-west-1" {
+		t.Errorf("Region() = %v, want eu-west-1", p.Region())
+	}
+
+	client, err := p.(*AWSProvider).CreateClient(context.Background(), "s3")
+	if err != nil {
+		t.Fatalf("CreateClient() error = %v", err)
+	}
+
+	if client.AccessKey != "AKIDEXAMPLE" || client.SecretKey != "secret" || client.SessionToken != "token" {
+		t.Errorf("CreateClient() did not use the static credentials")
+	}
+
+	if client.Region != "eu-west-1" {
+		t.Errorf("client Region = %v, want eu-west-1", client.Region)
+	}
+}
+
+func TestNewFactoryRequiresBothKeys(t *testing.T) {
+	_, err := NewFactory(map[string]string{
+		"region":        "us-east-1",
+		"access_key_id": "AKIDEXAMPLE",
+	})
+	if err == nil {
+		t.Error("NewFactory() expected error when secret_access_key is missing")
+	}
+}
+
+// recordingTransport answers every request with success and records them.
+// Responses carry response as their body, or "{}" when it is empty.
+type recordingTransport struct {
+	requests []string
+	bodies   map[string]string
+	response string
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request := req.Method + " " + req.URL.Path + "?" + req.URL.RawQuery
+	r.requests = append(r.requests, request)
+	if req.Body != nil {
+		body, _ := io.ReadAll(req.Body)
+		r.bodies[request] = string(body)
+	}
+
+	status := http.StatusOK
+	if req.Method == "DELETE" {
+		status = http.StatusNoContent
+	}
+	response := r.response
+	if response == "" {
+		response = "{}"
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(response))}, nil
+}
+
+// recordRequests sends the requests of clients without their own transport
+// to a recordingTransport for the rest of the test
+func recordRequests(t *testing.T) *recordingTransport {
+	t.Helper()
+	transport := &recordingTransport{bodies: make(map[string]string)}
+	original := http.DefaultTransport
+	http.DefaultTransport = transport
+	t.Cleanup(func() { http.DefaultTransport = original })
+	return transport
+}
+
+func newTestProvider(t *testing.T) *AWSProvider {
+	t.Helper()
+	p, err := NewFactory(map[string]string{
+		"region":            "us-east-1",
+		"access_key_id":     "AKIDEXAMPLE",
+		"secret_access_key": "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewFactory() error = %v", err)
+	}
+	return p.(*AWSProvider)
+}
+
+func TestUpdateBucketPublicAccess(t *testing.T) {
+	p := newTestProvider(t)
+
+	for _, tt := range []struct {
+		public bool
+		want   string
+	}{
+		{public: true, want: "DELETE /logs?publicAccessBlock="},
+		{public: false, want: "PUT /logs?publicAccessBlock="},
+	} {
+		transport := recordRequests(t)
+		config := &provider.BucketConfig{Name: "logs", PublicAccess: tt.public}
+		if err := p.Storage().UpdateBucket(context.Background(), "logs", config); err != nil {
+			t.Fatalf("UpdateBucket() error = %v", err)
+		}
+		if !slices.Contains(transport.requests, tt.want) {
+			t.Errorf("UpdateBucket(public=%v) sent %v, want %s", tt.public, transport.requests, tt.want)
+		}
+	}
+}
+
+func TestUpdateFunctionEnvironment(t *testing.T) {
+	p := newTestProvider(t)
+	request := "PUT /2015-03-31/functions/api/configuration?"
+
+	for _, tt := range []struct {
+		name        string
+		environment map[string]string
+		want        bool
+	}{
+		{name: "unset", environment: nil, want: false},
+		{name: "empty", environment: map[string]string{}, want: true},
+		{name: "set", environment: map[string]string{"STAGE": "prod"}, want: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := recordRequests(t)
+			config := &provider.FunctionConfig{Name: "api", Runtime: "python3.11", Environment: tt.environment}
+			if err := p.Serverless().UpdateFunction(context.Background(), "api", config); err != nil {
+				t.Fatalf("UpdateFunction() error = %v", err)
+			}
+
+			body, ok := transport.bodies[request]
+			if !ok {
+				t.Fatalf("UpdateFunction() sent %v, want %s", transport.requests, request)
+			}
+			if got := strings.Contains(body, `"Environment"`); got != tt.want {
+				t.Errorf("configuration update %s, sends Environment = %v, want %v", body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSecurityGroup(t *testing.T) {
+	p := newTestProvider(t)
+	transport := recordRequests(t)
+	transport.response = `<CreateSecurityGroupResponse><requestId>1</requestId><return>true</return><groupId>sg-0123456789abcdef0</groupId></CreateSecurityGroupResponse>`
+
+	group, err := p.Network().CreateSecurityGroup(context.Background(), &provider.SecurityGroupConfig{
+		Name:        "db-sg",
+		Description: "Database access",
+		NetworkID:   "vpc-1",
+		Rules:       []provider.SecurityRule{{Direction: "ingress", Protocol: "tcp", FromPort: 5432, ToPort: 5432, Source: "172.31.0.0/16"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateSecurityGroup() error = %v", err)
+	}
+	if group.ID != "sg-0123456789abcdef0" {
+		t.Errorf("group ID = %s, want the groupId of the response", group.ID)
+	}
+
+	actions := make(map[string]url.Values)
+	for _, request := range transport.requests {
+		_, query, _ := strings.Cut(request, "?")
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", request, err)
+		}
+		actions[values.Get("Action")] = values
+	}
+
+	if got := actions["CreateSecurityGroup"].Get("VpcId"); got != "vpc-1" {
+		t.Errorf("CreateSecurityGroup VpcId = %q, want vpc-1", got)
+	}
+	ingress, ok := actions["AuthorizeSecurityGroupIngress"]
+	if !ok {
+		t.Fatalf("CreateSecurityGroup() sent %v, want the ingress rule authorized", transport.requests)
+	}
+	if ingress.Get("GroupId") != group.ID || ingress.Get("IpPermissions.1.FromPort") != "5432" ||
+		ingress.Get("IpPermissions.1.IpRanges.1.CidrIp") != "172.31.0.0/16" {
+		t.Errorf("AuthorizeSecurityGroupIngress = %v", ingress)
+	}
+}
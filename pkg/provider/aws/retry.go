@@ -0,0 +1,323 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how AWSClient retries requests that fail with
+// throttling, server or transient network errors
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first; 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the longest wait before the first retry; it doubles with
+	// every retry, up to MaxDelay, and the actual wait is a random fraction
+	// of it (full jitter)
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retryable retries error responses besides those IsRetryable accepts,
+	// such as errors caused by eventual consistency
+	Retryable func(apiErr *APIError) bool
+	// OnRetry is called before waiting for a retry
+	OnRetry func(attempt int, wait time.Duration, reason error)
+}
+
+// DefaultRetryPolicy is the policy of clients without one of their own
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    20 * time.Second,
+}
+
+// RateLimit is the number of requests per second sent at most to each service
+// in each region, with bursts of as many requests; 0 disables rate limiting
+var RateLimit = 20.0
+
+// sleep waits between attempts, returning early with the context's error
+// when ctx is done; tests replace it
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// idempotencyTokens are the parameters that make a repeated request return
+// the result of the first instead of acting again
+var idempotencyTokens = []string{"ClientToken"}
+
+// retryableCodes are the error codes of throttling and transient failures in
+// the query (XML) and JSON protocols
+var retryableCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottled":                       true,
+	"RequestThrottledException":              true,
+	"TooManyRequestsException":               true,
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"BandwidthLimitExceeded":                 true,
+	"EC2ThrottledException":                  true,
+	"SlowDown":                               true,
+	"PriorRequestNotComplete":                true,
+	"TransactionInProgressException":         true,
+	"RequestTimeout":                         true,
+	"RequestTimeoutException":                true,
+	"InternalError":                          true,
+	"InternalFailure":                        true,
+	"ServiceUnavailable":                     true,
+	"ServiceUnavailableException":            true,
+}
+
+// retryableStatuses are the HTTP statuses of throttled and unavailable services
+var retryableStatuses = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// APIError is an error response of an AWS API
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s (status %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// ParseAPIError reads the error code and message of a failed response. The
+// code comes from the X-Amzn-ErrorType header or the body: <Code> in the
+// query and REST-XML protocols, __type or code in the JSON protocols.
+func ParseAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '<' {
+		var xmlErr struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+			Error   struct {
+				Code    string `xml:"Code"`
+				Message string `xml:"Message"`
+			} `xml:"Error"`
+			Errors struct {
+				Error struct {
+					Code    string `xml:"Code"`
+					Message string `xml:"Message"`
+				} `xml:"Error"`
+			} `xml:"Errors"`
+		}
+		if xml.Unmarshal(trimmed, &xmlErr) == nil {
+			// S3 puts the code at the top, the query protocol in Error, and
+			// EC2 in Errors/Error
+			for _, candidate := range [][2]string{
+				{xmlErr.Code, xmlErr.Message},
+				{xmlErr.Error.Code, xmlErr.Error.Message},
+				{xmlErr.Errors.Error.Code, xmlErr.Errors.Error.Message},
+			} {
+				if candidate[0] != "" {
+					apiErr.Code, apiErr.Message = candidate[0], candidate[1]
+					break
+				}
+			}
+		}
+	} else if len(trimmed) > 0 && trimmed[0] == '{' {
+		var jsonErr struct {
+			Type         string `json:"__type"`
+			Code         string `json:"code"`
+			Message      string `json:"message"`
+			MessageUpper string `json:"Message"`
+		}
+		if json.Unmarshal(trimmed, &jsonErr) == nil {
+			apiErr.Code = jsonErr.Type
+			if apiErr.Code == "" {
+				apiErr.Code = jsonErr.Code
+			}
+			apiErr.Message = jsonErr.Message
+			if apiErr.Message == "" {
+				apiErr.Message = jsonErr.MessageUpper
+			}
+		}
+	}
+
+	// The header names the error of REST-JSON services such as Lambda
+	if header := resp.Header.Get("X-Amzn-ErrorType"); header != "" {
+		apiErr.Code = header
+	}
+	// Codes may be qualified (aws.protocol#Code) or carry a URL (Code:http://...)
+	apiErr.Code = apiErr.Code[strings.LastIndex(apiErr.Code, "#")+1:]
+	if i := strings.Index(apiErr.Code, ":"); i >= 0 {
+		apiErr.Code = apiErr.Code[:i]
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = string(trimmed)
+	}
+	return apiErr
+}
+
+// IsRetryable reports whether an error response is worth retrying: throttling
+// and transient service errors, or HTTP 429, 500, 502, 503 and 504
+func IsRetryable(apiErr *APIError) bool {
+	return retryableCodes[apiErr.Code] || retryableStatuses[apiErr.StatusCode]
+}
+
+// isRetryableNetworkError reports whether a request failed before a response
+// for a reason that may go away: timeouts, reset or refused connections and
+// connections closed early
+func isRetryableNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// isIdempotent reports whether a request can be sent again after it failed
+// without a response: reads and deletions, and requests carrying an
+// idempotency token
+func isIdempotent(method string, params map[string]string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	for _, token := range idempotencyTokens {
+		if params[token] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before a retry: a random duration up to BaseDelay
+// doubled for each earlier retry, capped at MaxDelay. A longer Retry-After
+// from the service is honoured up to MaxDelay.
+func (p RetryPolicy) backoff(retry int, resp *http.Response) time.Duration {
+	if retry > 30 {
+		retry = 30
+	}
+	ceiling := p.BaseDelay << uint(retry)
+	if p.MaxDelay > 0 && (ceiling > p.MaxDelay || ceiling < 0) {
+		ceiling = p.MaxDelay
+	}
+	wait := time.Duration(0)
+	if ceiling > 0 {
+		wait = time.Duration(rand.Int63n(int64(ceiling) + 1))
+	}
+
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if after := time.Duration(seconds) * time.Second; after > wait {
+				wait = after
+			}
+		}
+	}
+	if p.MaxDelay > 0 && wait > p.MaxDelay {
+		wait = p.MaxDelay
+	}
+	return wait
+}
+
+// wait waits before another attempt at a request that failed, returning the
+// context's error when ctx is done first
+func (p RetryPolicy) wait(ctx context.Context, attempt int, resp *http.Response, reason error) error {
+	wait := p.backoff(attempt-1, resp)
+	if p.OnRetry != nil {
+		p.OnRetry(attempt, wait, reason)
+	}
+	return sleep(ctx, wait)
+}
+
+// RateLimiter spaces requests out to at most a number per second, allowing
+// bursts of as many requests after a quiet period (a token bucket)
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter of rate requests per second
+func NewRateLimiter(rate float64) *RateLimiter {
+	return &RateLimiter{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// Wait blocks until a request may be sent or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	// Take the token now and wait until it would have been there
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		return sleep(ctx, wait)
+	}
+	return nil
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*RateLimiter)
+)
+
+// limiterFor returns the limiter shared by the clients of a service in a
+// region, or nil when rate limiting is disabled
+func limiterFor(service, region string) *RateLimiter {
+	if RateLimit <= 0 {
+		return nil
+	}
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	key := service + "/" + region
+	if limiters[key] == nil {
+		limiters[key] = NewRateLimiter(RateLimit)
+	}
+	return limiters[key]
+}
@@ -0,0 +1,290 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header string
+		body   string
+		want   string
+		retry  bool
+	}{
+		{"query protocol", 400, "", `<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code><Message>Rate exceeded</Message></Error></ErrorResponse>`, "Throttling", true},
+		{"ec2", 503, "", `<Response><Errors><Error><Code>RequestLimitExceeded</Code><Message>Request limit exceeded.</Message></Error></Errors></Response>`, "RequestLimitExceeded", true},
+		{"s3", 503, "", `<?xml version="1.0" encoding="UTF-8"?><Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`, "SlowDown", true},
+		{"json protocol", 400, "", `{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"Rate exceeded"}`, "ProvisionedThroughputExceededException", true},
+		{"rest json", 429, "TooManyRequestsException:http://internal.amazon.com/coral/", `{"Type":"User","message":"Rate Exceeded."}`, "TooManyRequestsException", true},
+		{"status only", 503, "", ``, "", true},
+		{"not retryable", 400, "", `<ErrorResponse><Error><Code>NoSuchEntity</Code><Message>Role not found</Message></Error></ErrorResponse>`, "NoSuchEntity", false},
+		{"conflict", 409, "", `{"__type":"ResourceConflictException","message":"update in progress"}`, "ResourceConflictException", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("X-Amzn-ErrorType", tt.header)
+			}
+			apiErr := ParseAPIError(resp, []byte(tt.body))
+			if apiErr.Code != tt.want {
+				t.Errorf("Code = %q, want %q", apiErr.Code, tt.want)
+			}
+			if IsRetryable(apiErr) != tt.retry {
+				t.Errorf("IsRetryable() = %v, want %v", IsRetryable(apiErr), tt.retry)
+			}
+		})
+	}
+}
+
+// fakeTransport answers requests with canned responses or errors in order
+type fakeTransport struct {
+	responses []func() (*http.Response, error)
+	calls     int
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	respond := f.responses[len(f.responses)-1]
+	if f.calls < len(f.responses) {
+		respond = f.responses[f.calls]
+	}
+	f.calls++
+	return respond()
+}
+
+func respond(status int, body string) func() (*http.Response, error) {
+	return func() (*http.Response, error) {
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+}
+
+func fail(err error) func() (*http.Response, error) {
+	return func() (*http.Response, error) { return nil, err }
+}
+
+func TestRequestRetries(t *testing.T) {
+	var waits []time.Duration
+	defer func(original func(context.Context, time.Duration) error, rate float64) {
+		sleep, RateLimit = original, rate
+	}(sleep, RateLimit)
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	RateLimit = 0
+
+	throttled := `<ErrorResponse><Error><Code>Throttling</Code><Message>Rate exceeded</Message></Error></ErrorResponse>`
+	noSuchEntity := `<ErrorResponse><Error><Code>NoSuchEntity</Code><Message>not found</Message></Error></ErrorResponse>`
+
+	tests := []struct {
+		name      string
+		method    string
+		params    map[string]string
+		responses []func() (*http.Response, error)
+		retry     *RetryPolicy
+		calls     int
+		status    int
+		body      string
+		wantErr   bool
+	}{
+		{
+			name:      "throttled then ok",
+			responses: []func() (*http.Response, error){respond(400, throttled), respond(503, ""), respond(200, "ok")},
+			calls:     3,
+			status:    200,
+			body:      "ok",
+		},
+		{
+			name:      "not retryable",
+			responses: []func() (*http.Response, error){respond(400, noSuchEntity)},
+			calls:     1,
+			status:    400,
+			body:      noSuchEntity,
+		},
+		{
+			name:      "gives up",
+			responses: []func() (*http.Response, error){respond(400, throttled)},
+			calls:     DefaultRetryPolicy.MaxAttempts,
+			status:    400,
+			body:      throttled,
+		},
+		{
+			name:      "network error",
+			method:    "GET",
+			responses: []func() (*http.Response, error){fail(syscall.ECONNRESET), respond(200, "ok")},
+			calls:     2,
+			status:    200,
+			body:      "ok",
+		},
+		{
+			name:      "network error on a non-idempotent call",
+			responses: []func() (*http.Response, error){fail(syscall.ECONNRESET), respond(200, "ok")},
+			calls:     1,
+			wantErr:   true,
+		},
+		{
+			name:      "network error with a client token",
+			params:    map[string]string{"Action": "RunInstances", "ClientToken": "token"},
+			responses: []func() (*http.Response, error){fail(io.EOF), respond(200, "ok")},
+			calls:     2,
+			status:    200,
+			body:      "ok",
+		},
+		{
+			name:      "permanent network error",
+			method:    "GET",
+			responses: []func() (*http.Response, error){fail(errors.New("certificate signed by unknown authority"))},
+			calls:     1,
+			wantErr:   true,
+		},
+		{
+			name:      "per-call retryable",
+			responses: []func() (*http.Response, error){respond(400, noSuchEntity), respond(200, "ok")},
+			retry:     &RetryPolicy{MaxAttempts: 3, Retryable: func(apiErr *APIError) bool { return apiErr.Code == "NoSuchEntity" }},
+			calls:     2,
+			status:    200,
+			body:      "ok",
+		},
+		{
+			name:      "retries disabled",
+			responses: []func() (*http.Response, error){respond(400, throttled), respond(200, "ok")},
+			retry:     &RetryPolicy{MaxAttempts: 1},
+			calls:     1,
+			status:    400,
+			body:      throttled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits = nil
+			transport := &fakeTransport{responses: tt.responses}
+			client := &AWSClient{
+				AccessKey:  "AKIDEXAMPLE",
+				SecretKey:  "secret",
+				Region:     "us-east-1",
+				Service:    "iam",
+				HTTPClient: &http.Client{Transport: transport},
+			}
+			if tt.retry != nil {
+				client = client.WithRetry(*tt.retry)
+			}
+
+			method, params := tt.method, tt.params
+			if method == "" {
+				method = "POST"
+			}
+			if params == nil {
+				params = map[string]string{"Action": "GetRole"}
+			}
+
+			resp, err := client.Request(method, "/", params, nil)
+			if transport.calls != tt.calls {
+				t.Errorf("made %d calls, want %d", transport.calls, tt.calls)
+			}
+			if len(waits) != tt.calls-1 {
+				t.Errorf("waited %d times, want %d", len(waits), tt.calls-1)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Request() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Request() error = %v", err)
+			}
+
+			body, _ := ReadResponse(resp)
+			if resp.StatusCode != tt.status || string(body) != tt.body {
+				t.Errorf("Request() = %d %q, want %d %q", resp.StatusCode, body, tt.status, tt.body)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry, ceiling := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 20; i++ {
+			if wait := policy.backoff(retry, nil); wait < 0 || wait > ceiling {
+				t.Fatalf("backoff(%d) = %v, want at most %v", retry, wait, ceiling)
+			}
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	if wait := policy.backoff(0, resp); wait != time.Second {
+		t.Errorf("backoff() with Retry-After = %v, want MaxDelay", wait)
+	}
+	if wait := (RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}).backoff(0, resp); wait != 3*time.Second {
+		t.Errorf("backoff() with Retry-After = %v, want 3s", wait)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	var waited time.Duration
+	defer func(original func(context.Context, time.Duration) error) { sleep = original }(sleep)
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waited += d
+		return nil
+	}
+
+	ctx := context.Background()
+	limiter := NewRateLimiter(4)
+	for i := 0; i < 4; i++ {
+		limiter.Wait(ctx)
+	}
+	if waited != 0 {
+		t.Errorf("a burst of 4 requests waited %v, want no wait", waited)
+	}
+
+	limiter.Wait(ctx)
+	limiter.Wait(ctx)
+	if waited < 400*time.Millisecond || waited > 800*time.Millisecond {
+		t.Errorf("2 requests over the burst waited %v, want about 0.25s and 0.5s", waited)
+	}
+}
+
+func TestRequestCancelled(t *testing.T) {
+	defer func(rate float64) { RateLimit = rate }(RateLimit)
+	RateLimit = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	throttled := `<ErrorResponse><Error><Code>Throttling</Code><Message>Rate exceeded</Message></Error></ErrorResponse>`
+	transport := &fakeTransport{responses: []func() (*http.Response, error){
+		func() (*http.Response, error) {
+			cancel()
+			return respond(400, throttled)()
+		},
+	}}
+	client := (&AWSClient{
+		AccessKey:  "AKIDEXAMPLE",
+		SecretKey:  "secret",
+		Region:     "us-east-1",
+		Service:    "iam",
+		HTTPClient: &http.Client{Transport: transport},
+	}).WithRetry(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}).WithContext(ctx)
+
+	start := time.Now()
+	_, err := client.Request("POST", "/", map[string]string{"Action": "GetRole"}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Request() error = %v, want context.Canceled", err)
+	}
+	if transport.calls != 1 {
+		t.Errorf("made %d calls, want 1", transport.calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request() waited %v after the context was cancelled", elapsed)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -51,7 +50,7 @@ type ListFunctionsResponse struct {
 
 // CreateFunction creates a new Lambda function with enhanced error handling and role validation
 func (s *ServerlessService) CreateFunction(ctx context.Context, config *provider.FunctionConfig) (*provider.Function, error) {
-	client, err := s.provider.CreateClient("lambda")
+	client, err := s.provider.CreateClient(ctx, "lambda")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Lambda client: %w", err)
 	}
@@ -116,8 +115,12 @@ func (s *ServerlessService) CreateFunction(ctx context.Context, config *provider
 		requestBody["Layers"] = config.Code.Layers
 	}
 
+	if tags := provider.WithDefaultTags(s.provider.defaultTags, config.Tags); len(tags) > 0 {
+		requestBody["Tags"] = tags
+	}
+
 	// Create function with retry logic for IAM propagation delays
-	return s.createFunctionWithRetry(ctx, client, requestBody, 3)
+	return s.createFunctionWithRetry(ctx, client, requestBody, 5)
 }
 
 // createDummyZip creates a minimal Lambda deployment package for testing
@@ -194,7 +197,16 @@ func (s *ServerlessService) waitForRoleReady(ctx context.Context, roleArn string
 	return fmt.Errorf("role policies not ready after %d attempts", maxAttempts)
 }
 
-// createFunctionWithRetry creates a Lambda function with retry logic for IAM propagation delays
+// roleNotReady reports whether Lambda rejected a role because IAM has not
+// propagated it yet
+func roleNotReady(apiErr *APIError) bool {
+	return apiErr.StatusCode == 400 && (strings.Contains(apiErr.Message, "cannot be assumed") ||
+		strings.Contains(apiErr.Message, "Invalid role") ||
+		strings.Contains(apiErr.Message, "role is not authorized"))
+}
+
+// createFunctionWithRetry creates a function, retrying for as long as IAM
+// has not propagated its role yet on top of the client's usual retries
 func (s *ServerlessService) createFunctionWithRetry(ctx context.Context, client *AWSClient, requestBody map[string]interface{}, maxRetries int) (*provider.Function, error) {
 	// Convert to JSON
 	body, err := json.Marshal(requestBody)
@@ -202,62 +214,28 @@ func (s *ServerlessService) createFunctionWithRetry(ctx context.Context, client
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	var resp *http.Response
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		resp, err = client.Request("POST", "/2015-03-31/functions", nil, body)
-		if err != nil {
-			lastErr = fmt.Errorf("request failed: %w", err)
-			continue
-		}
-
-		responseBody, err := ReadResponse(resp)
-		if err != nil {
-			resp.Body.Close()
-			lastErr = fmt.Errorf("failed to read response: %w", err)
-			continue
+	policy := DefaultRetryPolicy
+	policy.MaxAttempts = maxRetries
+	policy.BaseDelay = 2 * time.Second
+	policy.MaxDelay = 30 * time.Second
+	policy.Retryable = roleNotReady
+	policy.OnRetry = func(attempt int, wait time.Duration, reason error) {
+		if apiErr, ok := reason.(*APIError); ok && roleNotReady(apiErr) {
+			fmt.Printf("IAM role not ready, waiting %v before retry %d/%d...\n", wait.Round(time.Second), attempt, maxRetries-1)
 		}
+	}
 
-		if resp.StatusCode == 201 {
-			// Success - parse response
-			var lambdaFunc LambdaFunction
-			if err := json.Unmarshal(responseBody, &lambdaFunc); err != nil {
-				resp.Body.Close()
-				return nil, fmt.Errorf("failed to parse response: %w", err)
-			}
-
-			resp.Body.Close()
-			return &provider.Function{
-				Name:        lambdaFunc.FunctionName,
-				Runtime:     lambdaFunc.Runtime,
-				Handler:     lambdaFunc.Handler,
-				Memory:      lambdaFunc.MemorySize,
-				Timeout:     lambdaFunc.Timeout,
-				Environment: lambdaFunc.Environment.Variables,
-			}, nil
-		}
-
-		responseStr := string(responseBody)
-		resp.Body.Close()
-
-		// Check for IAM role assumption errors
-		if resp.StatusCode == 400 && (strings.Contains(responseStr, "cannot be assumed") ||
-			strings.Contains(responseStr, "Invalid role") ||
-			strings.Contains(responseStr, "role is not authorized")) {
+	resp, err := client.WithRetry(policy).Request("POST", "/2015-03-31/functions", nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create function: %w", err)
+	}
 
-			if attempt < maxRetries-1 {
-				// Wait with exponential backoff for IAM propagation
-				waitTime := time.Duration(2<<uint(attempt)) * time.Second
-				if waitTime > 30*time.Second {
-					waitTime = 30 * time.Second
-				}
-				fmt.Printf("IAM role not ready, waiting %v before retry %d/%d...\n", waitTime, attempt+1, maxRetries)
-				time.Sleep(waitTime)
-				continue
-			}
-		}
+	responseBody, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
 
+	if resp.StatusCode != 201 {
 		// Parse AWS error for better messaging
 		var errorResp map[string]interface{}
 		if json.Unmarshal(responseBody, &errorResp) == nil {
@@ -267,15 +245,22 @@ func (s *ServerlessService) createFunctionWithRetry(ctx context.Context, client
 				}
 			}
 		}
-
-		return nil, fmt.Errorf("CreateFunction failed with status %d: %s", resp.StatusCode, responseStr)
+		return nil, fmt.Errorf("CreateFunction failed with status %d: %s", resp.StatusCode, string(responseBody))
 	}
 
-	if lastErr != nil {
-		return nil, fmt.Errorf("failed to create function after %d attempts: %w", maxRetries, lastErr)
+	var lambdaFunc LambdaFunction
+	if err := json.Unmarshal(responseBody, &lambdaFunc); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return nil, fmt.Errorf("failed to create function after %d attempts", maxRetries)
+	return &provider.Function{
+		Name:        lambdaFunc.FunctionName,
+		Runtime:     lambdaFunc.Runtime,
+		Handler:     lambdaFunc.Handler,
+		Memory:      lambdaFunc.MemorySize,
+		Timeout:     lambdaFunc.Timeout,
+		Environment: lambdaFunc.Environment.Variables,
+	}, nil
 }
 
 func (s *ServerlessService) convertToProviderFunction(lambdaFunc *LambdaFunction, tags map[string]string) *provider.Function {
@@ -317,15 +302,134 @@ func (s *ServerlessService) convertToProviderFunction(lambdaFunc *LambdaFunction
 	}
 }
 
-// UpdateFunction updates an existing Lambda function
+// GetFunction retrieves the configuration of a Lambda function
+func (s *ServerlessService) GetFunction(ctx context.Context, id string) (*provider.Function, error) {
+	client, err := s.provider.CreateClient(ctx, "lambda")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Lambda client: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("/2015-03-31/functions/%s/configuration", id)
+	resp, err := client.Request("GET", endpoint, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get function: %w", err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == 404 {
+		return nil, fmt.Errorf("function %s not found", id)
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("get function failed with status %d: %s", resp.StatusCode, string(responseBody))
+	}
+
+	var lambdaFunc LambdaFunction
+	if err := json.Unmarshal(responseBody, &lambdaFunc); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return s.convertToProviderFunction(&lambdaFunc, nil), nil
+}
+
+// UpdateFunction updates the configuration of an existing Lambda function and,
+// when new code is provided, its code
 func (s *ServerlessService) UpdateFunction(ctx context.Context, id string, config *provider.FunctionConfig) error {
-	// TODO: Implement function update
-	return fmt.Errorf("UpdateFunction not yet implemented")
+	client, err := s.provider.CreateClient(ctx, "lambda")
+	if err != nil {
+		return fmt.Errorf("failed to create Lambda client: %w", err)
+	}
+
+	requestBody := map[string]interface{}{
+		"Runtime":    config.Runtime,
+		"Handler":    config.Handler,
+		"MemorySize": config.Memory,
+		"Timeout":    config.Timeout,
+	}
+	// Sending no environment keeps the function's; an empty one clears it
+	if config.Environment != nil {
+		requestBody["Environment"] = map[string]interface{}{
+			"Variables": config.Environment,
+		}
+	}
+	if config.Role != "" {
+		roleArn, err := s.resolveRoleArn(ctx, config.Role)
+		if err != nil {
+			return fmt.Errorf("failed to resolve IAM role: %w", err)
+		}
+		requestBody["Role"] = roleArn
+	}
+	if len(config.Code.Layers) > 0 {
+		requestBody["Layers"] = config.Code.Layers
+	}
+
+	endpoint := fmt.Sprintf("/2015-03-31/functions/%s/configuration", id)
+	if err := s.putFunctionUpdate(client, endpoint, requestBody); err != nil {
+		return fmt.Errorf("failed to update function configuration: %w", err)
+	}
+
+	var code map[string]interface{}
+	if config.Code.LocalPath != "" {
+		zipData, err := os.ReadFile(config.Code.LocalPath)
+		if err != nil {
+			return fmt.Errorf("failed to read function ZIP: %w", err)
+		}
+		code = map[string]interface{}{"ZipFile": base64.StdEncoding.EncodeToString(zipData)}
+	} else if config.Code.S3Bucket != "" && config.Code.S3Key != "" {
+		code = map[string]interface{}{"S3Bucket": config.Code.S3Bucket, "S3Key": config.Code.S3Key}
+	} else if len(config.Code.ZipFile) > 0 {
+		code = map[string]interface{}{"ZipFile": base64.StdEncoding.EncodeToString(config.Code.ZipFile)}
+	}
+
+	if code != nil {
+		endpoint := fmt.Sprintf("/2015-03-31/functions/%s/code", id)
+		if err := s.putFunctionUpdate(client, endpoint, code); err != nil {
+			return fmt.Errorf("failed to update function code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// putFunctionUpdate sends an update request, waiting while a previous update
+// of the same function is still in progress
+func (s *ServerlessService) putFunctionUpdate(client *AWSClient, endpoint string, requestBody map[string]interface{}) error {
+	body, err := json.Marshal(requestBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Lambda rejects updates while the previous one is being applied
+	policy := DefaultRetryPolicy
+	policy.BaseDelay = 2 * time.Second
+	policy.Retryable = func(apiErr *APIError) bool {
+		return apiErr.StatusCode == 409
+	}
+
+	resp, err := client.WithRetry(policy).Request("PUT", endpoint, nil, body)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	responseBody, err := ReadResponse(resp)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("update failed with status %d: %s", resp.StatusCode, string(responseBody))
+	}
+	return nil
 }
 
 // DeleteFunction deletes a Lambda function
 func (s *ServerlessService) DeleteFunction(ctx context.Context, id string) error {
-	client, err := s.provider.CreateClient("lambda")
+	client, err := s.provider.CreateClient(ctx, "lambda")
 	if err != nil {
 		return fmt.Errorf("failed to create Lambda client: %w", err)
 	}
@@ -347,7 +451,7 @@ func (s *ServerlessService) DeleteFunction(ctx context.Context, id string) error
 
 // InvokeFunction invokes a Lambda function
 func (s *ServerlessService) InvokeFunction(ctx context.Context, id string, payload []byte) ([]byte, error) {
-	client, err := s.provider.CreateClient("lambda")
+	client, err := s.provider.CreateClient(ctx, "lambda")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Lambda client: %w", err)
 	}
@@ -371,53 +475,74 @@ func (s *ServerlessService) InvokeFunction(ctx context.Context, id string, paylo
 	return responseBody, nil
 }
 
-// DiscoverFunctions discovers existing Lambda functions
-func (s *ServerlessService) DiscoverFunctions(ctx context.Context) ([]*provider.Function, error) {
-	client, err := s.provider.CreateClient("lambda")
+// CreateFunctionURL exposes a Lambda function over HTTPS without
+// authentication and returns its URL
+func (s *ServerlessService) CreateFunctionURL(ctx context.Context, id string) (string, error) {
+	client, err := s.provider.CreateClient(ctx, "lambda")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Lambda client: %w", err)
+		return "", fmt.Errorf("failed to create Lambda client: %w", err)
 	}
 
-	resp, err := client.Request("GET", "/2015-03-31/functions", nil, nil)
+	body, err := json.Marshal(map[string]string{"AuthType": "NONE"})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list functions: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	endpoint := fmt.Sprintf("/2021-10-31/functions/%s/url", id)
+	resp, err := client.Request("POST", endpoint, nil, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create function URL: %w", err)
 	}
-	defer resp.Body.Close()
 
 	responseBody, err := ReadResponse(resp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != 201 {
+		return "", fmt.Errorf("CreateFunctionUrlConfig failed with status %d: %s", resp.StatusCode, string(responseBody))
 	}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("list functions failed with status %d: %s", resp.StatusCode, string(responseBody))
+	var urlConfig struct {
+		FunctionUrl string `json:"FunctionUrl"`
+	}
+	if err := json.Unmarshal(responseBody, &urlConfig); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	var listResp ListFunctionsResponse
-	if err := json.Unmarshal(responseBody, &listResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	// A URL without authentication still needs a policy allowing anyone to call it
+	body, err = json.Marshal(map[string]string{
+		"StatementId":         "FunctionURLAllowPublicAccess",
+		"Action":              "lambda:InvokeFunctionUrl",
+		"Principal":           "*",
+		"FunctionUrlAuthType": "NONE",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
+	endpoint = fmt.Sprintf("/2015-03-31/functions/%s/policy", id)
+	resp, err = client.Request("POST", endpoint, nil, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to allow public access to function URL: %w", err)
+	}
+	defer resp.Body.Close()
 
-	var functions []*provider.Function
-	for _, lambdaFunc := range listResp.Functions {
-		function := s.convertToProviderFunction(&lambdaFunc, nil)
-		functions = append(functions, function)
+	if resp.StatusCode != 201 && resp.StatusCode != 409 {
+		responseBody, _ := ReadResponse(resp)
+		return "", fmt.Errorf("AddPermission failed with status %d: %s", resp.StatusCode, string(responseBody))
 	}
 
-	return functions, nil
+	return urlConfig.FunctionUrl, nil
 }
 
-// AdoptFunction adopts an existing Lambda function into Genesys management
-func (s *ServerlessService) AdoptFunction(ctx context.Context, id string) (*provider.Function, error) {
-	client, err := s.provider.CreateClient("lambda")
+// DiscoverFunctions discovers existing Lambda functions
+func (s *ServerlessService) DiscoverFunctions(ctx context.Context) ([]*provider.Function, error) {
+	client, err := s.provider.CreateClient(ctx, "lambda")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Lambda client: %w", err)
 	}
 
-	endpoint := fmt.Sprintf("/2015-03-31/functions/%s", id)
-	resp, err := client.Request("GET", endpoint, nil, nil)
+	resp, err := client.Request("GET", "/2015-03-31/functions", nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get function: %w", err)
+		return nil, fmt.Errorf("failed to list functions: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -427,15 +552,26 @@ func (s *ServerlessService) AdoptFunction(ctx context.Context, id string) (*prov
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("get function failed with status %d: %s", resp.StatusCode, string(responseBody))
+		return nil, fmt.Errorf("list functions failed with status %d: %s", resp.StatusCode, string(responseBody))
 	}
 
-	var lambdaFunc LambdaFunction
-	if err := json.Unmarshal(responseBody, &lambdaFunc); err != nil {
+	var listResp ListFunctionsResponse
+	if err := json.Unmarshal(responseBody, &listResp); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return s.convertToProviderFunction(&lambdaFunc, nil), nil
+	var functions []*provider.Function
+	for _, lambdaFunc := range listResp.Functions {
+		function := s.convertToProviderFunction(&lambdaFunc, nil)
+		functions = append(functions, function)
+	}
+
+	return functions, nil
+}
+
+// AdoptFunction adopts an existing Lambda function into Genesys management
+func (s *ServerlessService) AdoptFunction(ctx context.Context, id string) (*provider.Function, error) {
+	return s.GetFunction(ctx, id)
 }
 
 // CreateLayer creates a Lambda layer (placeholder for missing method in commands)
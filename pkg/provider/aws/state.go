@@ -3,7 +3,9 @@ package aws
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/javanhut/genesys/pkg/provider"
@@ -13,20 +15,43 @@ import (
 type StateBackend struct {
 	provider   *AWSProvider
 	bucketName string
+	lockTable  string
 }
 
+// lockObjectSuffix is appended to the state key for the S3 lock object
+const lockObjectSuffix = ".lock"
+
+// lockRetryInterval is how often a held lock is tried again
+const lockRetryInterval = time.Second
+
 // NewStateBackend creates a new state backend
 func NewStateBackend(p *AWSProvider) *StateBackend {
+	return NewS3StateBackend(p, "", "")
+}
+
+// NewS3StateBackend creates a state backend storing state in the given
+// bucket, or in genesys-state-<region> when bucket is empty. When lockTable
+// is set, locks are kept in that DynamoDB table instead of the bucket.
+func NewS3StateBackend(p *AWSProvider, bucket, lockTable string) *StateBackend {
+	if bucket == "" {
+		bucket = "genesys-state-" + p.region // Default bucket name
+	}
 	return &StateBackend{
 		provider:   p,
-		bucketName: "genesys-state-" + p.region, // Default bucket name
+		bucketName: bucket,
+		lockTable:  lockTable,
 	}
 }
 
+// Location returns the S3 URL of a state key
+func (s *StateBackend) Location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucketName, key)
+}
+
 // Init initializes the state backend
 func (s *StateBackend) Init(ctx context.Context) error {
 	// Check if state bucket exists, create if not
-	client, err := s.provider.CreateClient("s3")
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return fmt.Errorf("failed to create S3 client: %w", err)
 	}
@@ -49,68 +74,153 @@ func (s *StateBackend) Init(ctx context.Context) error {
 	return nil
 }
 
-// Lock locks the state for exclusive access
-func (s *StateBackend) Lock(ctx context.Context, key string) error {
-	// Simple implementation - in production you'd want DynamoDB-based locking
-	client, err := s.provider.CreateClient("s3")
+// Lock takes the state lock, retrying until ctx is done while another
+// process holds it. The lock is an item in the DynamoDB lock table when one
+// is configured, and otherwise a <key>.lock object created with a
+// conditional PUT, so only one writer can succeed.
+func (s *StateBackend) Lock(ctx context.Context, key string, info *provider.LockInfo) error {
+	data, err := json.Marshal(info)
 	if err != nil {
-		return fmt.Errorf("failed to create S3 client: %w", err)
+		return fmt.Errorf("failed to marshal lock info: %w", err)
 	}
 
-	lockKey := key + ".lock"
-	lockData := map[string]interface{}{
-		"locked_at": time.Now().Format(time.RFC3339),
-		"locked_by": "genesys",
+	for {
+		var acquired bool
+		if s.lockTable != "" {
+			acquired, err = s.putLockItem(ctx, key, info.ID, data)
+		} else {
+			acquired, err = s.putLockObject(ctx, key, data)
+		}
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			holder, err := s.ReadLock(context.Background(), key)
+			if err != nil {
+				return err
+			}
+			return &provider.LockHeldError{Info: holder}
+		case <-time.After(lockRetryInterval):
+		}
 	}
+}
 
-	data, err := json.Marshal(lockData)
+// Unlock releases the state lock if it is held under id
+func (s *StateBackend) Unlock(ctx context.Context, key, id string) error {
+	if s.lockTable != "" {
+		return s.deleteLockItem(ctx, key, id)
+	}
+
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
-		return fmt.Errorf("failed to marshal lock data: %w", err)
+		return fmt.Errorf("failed to create S3 client: %w", err)
 	}
 
-	endpoint := fmt.Sprintf("/%s/%s", s.bucketName, lockKey)
-	resp, err := client.Request("PUT", endpoint, nil, data)
+	holder, etag, err := s.getLockObject(client, key)
 	if err != nil {
-		return fmt.Errorf("failed to create lock: %w", err)
+		return err
+	}
+	if holder == nil {
+		return fmt.Errorf("state %s is not locked", s.Location(key))
+	}
+	if holder.ID != id {
+		return fmt.Errorf("state %s is locked under ID %s, not %s", s.Location(key), holder.ID, id)
+	}
+
+	// Only delete the lock object that was read, not one taken since
+	endpoint := fmt.Sprintf("/%s/%s%s", s.bucketName, key, lockObjectSuffix)
+	resp, err := client.RequestWithHeaders("DELETE", endpoint, nil, map[string]string{"If-Match": etag}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete lock: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
+	switch resp.StatusCode {
+	case 200, 204, 404:
+		return nil
+	case 412:
+		return fmt.Errorf("the lock on state %s changed while releasing it", s.Location(key))
+	default:
 		responseBody, _ := ReadResponse(resp)
-		return fmt.Errorf("failed to create lock with status %d: %s", resp.StatusCode, string(responseBody))
+		return fmt.Errorf("failed to delete lock with status %d: %s", resp.StatusCode, string(responseBody))
 	}
-
-	return nil
 }
 
-// Unlock releases the state lock
-func (s *StateBackend) Unlock(ctx context.Context, key string) error {
-	client, err := s.provider.CreateClient("s3")
+// ReadLock returns the holder of the state lock, or nil if it is free
+func (s *StateBackend) ReadLock(ctx context.Context, key string) (*provider.LockInfo, error) {
+	if s.lockTable != "" {
+		return s.getLockItem(ctx, key)
+	}
+
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
-		return fmt.Errorf("failed to create S3 client: %w", err)
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
 	}
+	holder, _, err := s.getLockObject(client, key)
+	return holder, err
+}
 
-	lockKey := key + ".lock"
-	endpoint := fmt.Sprintf("/%s/%s", s.bucketName, lockKey)
+// putLockObject creates the lock object unless it already exists
+func (s *StateBackend) putLockObject(ctx context.Context, key string, data []byte) (bool, error) {
+	client, err := s.provider.CreateClient(ctx, "s3")
+	if err != nil {
+		return false, fmt.Errorf("failed to create S3 client: %w", err)
+	}
 
-	resp, err := client.Request("DELETE", endpoint, nil, nil)
+	endpoint := fmt.Sprintf("/%s/%s%s", s.bucketName, key, lockObjectSuffix)
+	resp, err := client.RequestWithHeaders("PUT", endpoint, nil, map[string]string{"If-None-Match": "*"}, data)
 	if err != nil {
-		return fmt.Errorf("failed to delete lock: %w", err)
+		return false, fmt.Errorf("failed to create lock: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 404 is OK - lock might not exist
-	if resp.StatusCode != 204 && resp.StatusCode != 404 {
+	switch resp.StatusCode {
+	case 200:
+		return true, nil
+	case 412, 409:
+		// 412: the lock exists; 409: another conditional write is in flight
+		return false, nil
+	default:
 		responseBody, _ := ReadResponse(resp)
-		return fmt.Errorf("failed to delete lock with status %d: %s", resp.StatusCode, string(responseBody))
+		return false, fmt.Errorf("failed to create lock with status %d: %s", resp.StatusCode, string(responseBody))
 	}
+}
 
-	return nil
+// getLockObject reads the lock object and its ETag, returning nil when the
+// state is not locked
+func (s *StateBackend) getLockObject(client *AWSClient, key string) (*provider.LockInfo, string, error) {
+	endpoint := fmt.Sprintf("/%s/%s%s", s.bucketName, key, lockObjectSuffix)
+	resp, err := client.Request("GET", endpoint, nil, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read lock: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, "", nil
+	}
+	responseBody, err := ReadResponse(resp)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("failed to read lock with status %d: %s", resp.StatusCode, string(responseBody))
+	}
+
+	// Locks written by older versions carry no details
+	info := &provider.LockInfo{}
+	json.Unmarshal(responseBody, info)
+	return info, resp.Header.Get("ETag"), nil
 }
 
-// Read reads state from storage
-func (s *StateBackend) Read(ctx context.Context, key string) (*provider.State, error) {
-	client, err := s.provider.CreateClient("s3")
+// Read reads state from storage, returning nil when there is none
+func (s *StateBackend) Read(ctx context.Context, key string) ([]byte, error) {
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 client: %w", err)
 	}
@@ -123,13 +233,8 @@ func (s *StateBackend) Read(ctx context.Context, key string) (*provider.State, e
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 404 {
-		// State doesn't exist, return empty state
-		return &provider.State{
-			Version:   1,
-			Resources: make(map[string]interface{}),
-			Outputs:   make(map[string]interface{}),
-			UpdatedAt: time.Now(),
-		}, nil
+		// State doesn't exist yet
+		return nil, nil
 	}
 
 	if resp.StatusCode != 200 {
@@ -142,28 +247,16 @@ func (s *StateBackend) Read(ctx context.Context, key string) (*provider.State, e
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	var state provider.State
-	if err := json.Unmarshal(responseBody, &state); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
-	}
-
-	return &state, nil
+	return responseBody, nil
 }
 
 // Write writes state to storage
-func (s *StateBackend) Write(ctx context.Context, key string, state *provider.State) error {
-	client, err := s.provider.CreateClient("s3")
+func (s *StateBackend) Write(ctx context.Context, key string, data []byte) error {
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return fmt.Errorf("failed to create S3 client: %w", err)
 	}
 
-	state.UpdatedAt = time.Now()
-
-	data, err := json.Marshal(state)
-	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
-	}
-
 	endpoint := fmt.Sprintf("/%s/%s", s.bucketName, key)
 	resp, err := client.Request("PUT", endpoint, nil, data)
 	if err != nil {
@@ -216,18 +309,112 @@ func (s *StateBackend) createStateBucket(client *AWSClient) error {
 		return fmt.Errorf("failed to enable versioning with status %d: %s", versioningResp.StatusCode, string(responseBody))
 	}
 
+	// Keep a bounded history of earlier state versions
+	lifecycleXML := fmt.Sprintf(`<LifecycleConfiguration><Rule><ID>genesys-state-history</ID><Filter><Prefix></Prefix></Filter><Status>Enabled</Status><NoncurrentVersionExpiration><NoncurrentDays>1</NoncurrentDays><NewerNoncurrentVersions>%d</NewerNoncurrentVersions></NoncurrentVersionExpiration></Rule></LifecycleConfiguration>`,
+		provider.StateVersionsKept-1)
+	lifecycleResp, err := client.RequestWithMD5("PUT", fmt.Sprintf("/%s", s.bucketName), map[string]string{"lifecycle": ""}, []byte(lifecycleXML))
+	if err != nil {
+		return fmt.Errorf("failed to configure state history: %w", err)
+	}
+	defer lifecycleResp.Body.Close()
+
+	if lifecycleResp.StatusCode != 200 {
+		responseBody, _ := ReadResponse(lifecycleResp)
+		return fmt.Errorf("failed to configure state history with status %d: %s", lifecycleResp.StatusCode, string(responseBody))
+	}
+
 	return nil
 }
 
+// listVersionsResult is the S3 ListObjectVersions response
+type listVersionsResult struct {
+	XMLName  xml.Name `xml:"ListVersionsResult"`
+	Versions []struct {
+		Key          string    `xml:"Key"`
+		VersionID    string    `xml:"VersionId"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Version"`
+}
+
+// Versions lists the stored versions of a state object, newest first. The
+// state bucket keeps them through object versioning.
+func (s *StateBackend) Versions(ctx context.Context, key string) ([]provider.StateVersion, error) {
+	client, err := s.provider.CreateClient(ctx, "s3")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	params := map[string]string{"versions": "", "prefix": key}
+	resp, err := client.Request("GET", fmt.Sprintf("/%s", s.bucketName), params, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state versions: %w", err)
+	}
+
+	responseBody, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to list state versions with status %d: %s", resp.StatusCode, string(responseBody))
+	}
+
+	return parseStateVersions(responseBody, key)
+}
+
+// parseStateVersions returns the versions of key in a ListObjectVersions
+// response, newest first and at most provider.StateVersionsKept
+func parseStateVersions(body []byte, key string) ([]provider.StateVersion, error) {
+	var result listVersionsResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse state versions: %w", err)
+	}
+
+	var versions []provider.StateVersion
+	for _, v := range result.Versions {
+		if v.Key == key {
+			versions = append(versions, provider.StateVersion{ID: v.VersionID, Modified: v.LastModified})
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Modified.After(versions[j].Modified) })
+	if len(versions) > provider.StateVersionsKept {
+		versions = versions[:provider.StateVersionsKept]
+	}
+	return versions, nil
+}
+
+// ReadVersion reads a stored version of a state object
+func (s *StateBackend) ReadVersion(ctx context.Context, key, versionID string) ([]byte, error) {
+	client, err := s.provider.CreateClient(ctx, "s3")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("/%s/%s", s.bucketName, key)
+	resp, err := client.Request("GET", endpoint, map[string]string{"versionId": versionID}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state version: %w", err)
+	}
+
+	responseBody, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to read state version %s with status %d: %s", versionID, resp.StatusCode, string(responseBody))
+	}
+	return responseBody, nil
+}
+
 // Refresh forces a refresh of the state from the remote storage
-func (s *StateBackend) Refresh(ctx context.Context, key string) (*provider.State, error) {
+func (s *StateBackend) Refresh(ctx context.Context, key string) ([]byte, error) {
 	// This is essentially the same as Read, but we ensure no local caching
 	return s.Read(ctx, key)
 }
 
 // ListStates lists all available state keys in the bucket
 func (s *StateBackend) ListStates(ctx context.Context) ([]string, error) {
-	client, err := s.provider.CreateClient("s3")
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 client: %w", err)
 	}
@@ -252,17 +439,18 @@ func (s *StateBackend) ListStates(ctx context.Context) ([]string, error) {
 
 // ValidateState checks if the state is consistent and valid
 func (s *StateBackend) ValidateState(ctx context.Context, key string) error {
-	state, err := s.Read(ctx, key)
+	data, err := s.Read(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to read state for validation: %w", err)
 	}
 
-	if state == nil {
-		return fmt.Errorf("state is nil")
+	if data == nil {
+		return fmt.Errorf("state %s does not exist", s.Location(key))
 	}
 
-	if state.Version == 0 {
-		return fmt.Errorf("state version is invalid")
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("state %s is not valid JSON: %w", s.Location(key), err)
 	}
 
 	return nil
@@ -0,0 +1,142 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+// The DynamoDB lock table needs a string partition key named LockID. Each
+// lock is an item holding the lock ID and the holder's details.
+
+// dynamoDBRequest calls a DynamoDB JSON API action
+func (s *StateBackend) dynamoDBRequest(ctx context.Context, action string, input map[string]interface{}) ([]byte, error) {
+	client, err := s.provider.CreateClient(ctx, "dynamodb")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DynamoDB client: %w", err)
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %w", action, err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/x-amz-json-1.0",
+		"X-Amz-Target": "DynamoDB_20120810." + action,
+	}
+	resp, err := client.RequestWithHeaders("POST", "/", nil, headers, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call DynamoDB %s: %w", action, err)
+	}
+
+	responseBody, err := ReadResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return responseBody, &dynamoDBError{Action: action, Status: resp.StatusCode, Body: responseBody}
+	}
+	return responseBody, nil
+}
+
+// dynamoDBError is a failed DynamoDB call
+type dynamoDBError struct {
+	Action string
+	Status int
+	Body   []byte
+}
+
+func (e *dynamoDBError) Error() string {
+	return fmt.Sprintf("DynamoDB %s failed with status %d: %s", e.Action, e.Status, string(e.Body))
+}
+
+// code returns the exception name, such as ConditionalCheckFailedException
+func (e *dynamoDBError) code() string {
+	var body struct {
+		Type string `json:"__type"`
+	}
+	json.Unmarshal(e.Body, &body)
+	return body.Type[strings.LastIndex(body.Type, "#")+1:]
+}
+
+// putLockItem creates the lock item unless it already exists
+func (s *StateBackend) putLockItem(ctx context.Context, key, id string, data []byte) (bool, error) {
+	_, err := s.dynamoDBRequest(ctx, "PutItem", map[string]interface{}{
+		"TableName": s.lockTable,
+		"Item": map[string]interface{}{
+			"LockID": map[string]string{"S": s.Location(key)},
+			"ID":     map[string]string{"S": id},
+			"Info":   map[string]string{"S": string(data)},
+		},
+		"ConditionExpression": "attribute_not_exists(LockID)",
+	})
+	if ddbErr, ok := err.(*dynamoDBError); ok {
+		switch ddbErr.code() {
+		case "ConditionalCheckFailedException":
+			return false, nil
+		case "ResourceNotFoundException":
+			return false, fmt.Errorf("lock table %s does not exist; create it with a string partition key named LockID", s.lockTable)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create lock: %w", err)
+	}
+	return true, nil
+}
+
+// getLockItem reads the lock item, returning nil when the state is not locked
+func (s *StateBackend) getLockItem(ctx context.Context, key string) (*provider.LockInfo, error) {
+	responseBody, err := s.dynamoDBRequest(ctx, "GetItem", map[string]interface{}{
+		"TableName":      s.lockTable,
+		"Key":            map[string]interface{}{"LockID": map[string]string{"S": s.Location(key)}},
+		"ConsistentRead": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock: %w", err)
+	}
+
+	var output struct {
+		Item map[string]struct {
+			S string `json:"S"`
+		} `json:"Item"`
+	}
+	if err := json.Unmarshal(responseBody, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse lock: %w", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	info := &provider.LockInfo{}
+	json.Unmarshal([]byte(output.Item["Info"].S), info)
+	info.ID = output.Item["ID"].S
+	return info, nil
+}
+
+// deleteLockItem deletes the lock item if it is held under id
+func (s *StateBackend) deleteLockItem(ctx context.Context, key, id string) error {
+	_, err := s.dynamoDBRequest(ctx, "DeleteItem", map[string]interface{}{
+		"TableName":                 s.lockTable,
+		"Key":                       map[string]interface{}{"LockID": map[string]string{"S": s.Location(key)}},
+		"ConditionExpression":       "ID = :id",
+		"ExpressionAttributeValues": map[string]interface{}{":id": map[string]string{"S": id}},
+	})
+	if ddbErr, ok := err.(*dynamoDBError); ok && ddbErr.code() == "ConditionalCheckFailedException" {
+		holder, readErr := s.getLockItem(ctx, key)
+		if readErr != nil {
+			return readErr
+		}
+		if holder == nil {
+			return fmt.Errorf("state %s is not locked", s.Location(key))
+		}
+		return fmt.Errorf("state %s is locked under ID %s, not %s", s.Location(key), holder.ID, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package aws
+
+import "testing"
+
+func TestDynamoDBErrorCode(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`, "ConditionalCheckFailedException"},
+		{`{"__type":"ResourceNotFoundException"}`, "ResourceNotFoundException"},
+		{`not json`, ""},
+	}
+
+	for _, tt := range tests {
+		err := &dynamoDBError{Action: "PutItem", Status: 400, Body: []byte(tt.body)}
+		if got := err.code(); got != tt.want {
+			t.Errorf("code() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package aws
+
+import "testing"
+
+func TestParseStateVersions(t *testing.T) {
+	body := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>genesys-state-us-east-1</Name>
+  <Version><Key>shop/state.json</Key><VersionId>old</VersionId><IsLatest>false</IsLatest><LastModified>2026-10-01T10:00:00.000Z</LastModified></Version>
+  <Version><Key>shop/state.json</Key><VersionId>new</VersionId><IsLatest>true</IsLatest><LastModified>2026-10-02T10:00:00.000Z</LastModified></Version>
+  <Version><Key>shop/state.json.lock</Key><VersionId>lock</VersionId><IsLatest>true</IsLatest><LastModified>2026-10-03T10:00:00.000Z</LastModified></Version>
+</ListVersionsResult>`)
+
+	versions, err := parseStateVersions(body, "shop/state.json")
+	if err != nil {
+		t.Fatalf("parseStateVersions() error = %v", err)
+	}
+	if len(versions) != 2 || versions[0].ID != "new" || versions[1].ID != "old" {
+		t.Errorf("parseStateVersions() = %+v, want new then old", versions)
+	}
+}
@@ -42,7 +42,7 @@ type BucketLocationConstraint struct {
 
 // CreateBucket creates a new S3 bucket
 func (s *StorageService) CreateBucket(ctx context.Context, config *provider.BucketConfig) (*provider.Bucket, error) {
-	client, err := s.provider.CreateClient("s3")
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 client: %w", err)
 	}
@@ -82,9 +82,17 @@ func (s *StorageService) CreateBucket(ctx context.Context, config *provider.Buck
 		}
 	}
 
+	// New buckets block public access, so only public ones need a change
+	if config.PublicAccess {
+		if err := s.setPublicAccessBlock(client, config.Name, false); err != nil {
+			return nil, fmt.Errorf("failed to allow public access: %w", err)
+		}
+	}
+
 	// Set bucket tags
-	if len(config.Tags) > 0 {
-		if err := s.setBucketTags(client, config.Name, config.Tags); err != nil {
+	tags := provider.WithDefaultTags(s.provider.defaultTags, config.Tags)
+	if len(tags) > 0 {
+		if err := s.setBucketTags(client, config.Name, tags); err != nil {
 			return nil, fmt.Errorf("failed to set bucket tags: %w", err)
 		}
 	}
@@ -96,14 +104,15 @@ func (s *StorageService) CreateBucket(ctx context.Context, config *provider.Buck
 		Versioning:   config.Versioning,
 		Encryption:   config.Encryption,
 		PublicAccess: config.PublicAccess,
-		Tags:         config.Tags,
+		Tags:         tags,
 		CreatedAt:    time.Now(),
+		ProviderData: map[string]interface{}{"arn": "arn:aws:s3:::" + config.Name},
 	}, nil
 }
 
 // GetBucket retrieves information about a bucket
 func (s *StorageService) GetBucket(ctx context.Context, name string) (*provider.Bucket, error) {
-	client, err := s.provider.CreateClient("s3")
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 client: %w", err)
 	}
@@ -152,9 +161,41 @@ func (s *StorageService) GetBucket(ctx context.Context, name string) (*provider.
 		PublicAccess: false, // Default to private
 		Tags:         tags,
 		CreatedAt:    time.Now(), // We don't have creation time from basic API
+		ProviderData: map[string]interface{}{"arn": "arn:aws:s3:::" + name},
 	}, nil
 }
 
+// UpdateBucket applies versioning, encryption and tag settings to an existing bucket
+func (s *StorageService) UpdateBucket(ctx context.Context, name string, config *provider.BucketConfig) error {
+	client, err := s.provider.CreateClient(ctx, "s3")
+	if err != nil {
+		return fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	if err := s.setBucketVersioning(client, name, config.Versioning); err != nil {
+		return fmt.Errorf("failed to update versioning: %w", err)
+	}
+
+	// Default encryption cannot be removed from a bucket, only enabled
+	if config.Encryption {
+		if err := s.setBucketEncryption(client, name); err != nil {
+			return fmt.Errorf("failed to enable encryption: %w", err)
+		}
+	}
+
+	if err := s.setPublicAccessBlock(client, name, !config.PublicAccess); err != nil {
+		return fmt.Errorf("failed to update public access: %w", err)
+	}
+
+	if tags := provider.WithDefaultTags(s.provider.defaultTags, config.Tags); len(tags) > 0 {
+		if err := s.setBucketTags(client, name, tags); err != nil {
+			return fmt.Errorf("failed to update tags: %w", err)
+		}
+	}
+
+	return nil
+}
+
 // DeleteBucket deletes a bucket, automatically emptying it first if necessary
 func (s *StorageService) DeleteBucket(ctx context.Context, name string) error {
 	return s.DeleteBucketWithOptions(ctx, name, false)
@@ -162,7 +203,7 @@ func (s *StorageService) DeleteBucket(ctx context.Context, name string) error {
 
 // DeleteBucketWithOptions deletes a bucket with advanced options
 func (s *StorageService) DeleteBucketWithOptions(ctx context.Context, name string, forceDelete bool) error {
-	client, err := s.provider.CreateClient("s3")
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return fmt.Errorf("failed to create S3 client: %w", err)
 	}
@@ -216,9 +257,77 @@ func (s *StorageService) DeleteBucketWithOptions(ctx context.Context, name strin
 	return fmt.Errorf("bucket deletion failed: %s", cleanError)
 }
 
+// ConfigureWebsite serves a bucket as a static website: it turns on website
+// hosting, lifts the bucket's public access block and allows anyone to read
+// its objects
+func (s *StorageService) ConfigureWebsite(ctx context.Context, name string, config *provider.WebsiteConfig) (*provider.Website, error) {
+	client, err := s.provider.CreateClient(ctx, "s3")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	index := config.IndexDocument
+	if index == "" {
+		index = "index.html"
+	}
+	websiteXML := fmt.Sprintf(`<WebsiteConfiguration><IndexDocument><Suffix>%s</Suffix></IndexDocument>`, index)
+	if config.ErrorDocument != "" {
+		websiteXML += fmt.Sprintf(`<ErrorDocument><Key>%s</Key></ErrorDocument>`, config.ErrorDocument)
+	}
+	websiteXML += `</WebsiteConfiguration>`
+
+	endpoint := fmt.Sprintf("/%s", name)
+	resp, err := client.Request("PUT", endpoint, map[string]string{"website": ""}, []byte(websiteXML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure website hosting: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		responseBody, _ := ReadResponse(resp)
+		return nil, fmt.Errorf("PutBucketWebsite failed with status %d: %s", resp.StatusCode, parseS3Error(responseBody))
+	}
+	resp.Body.Close()
+
+	// The bucket policy is rejected while the public access block is in place
+	if err := s.setPublicAccessBlock(client, name, false); err != nil {
+		return nil, fmt.Errorf("failed to allow public access: %w", err)
+	}
+
+	policy := fmt.Sprintf(`{"Version":"2012-10-17","Statement":[{"Sid":"PublicReadGetObject","Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::%s/*"}]}`, name)
+	resp, err = client.Request("PUT", endpoint, map[string]string{"policy": ""}, []byte(policy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set bucket policy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 && resp.StatusCode != 204 {
+		responseBody, _ := ReadResponse(resp)
+		return nil, fmt.Errorf("PutBucketPolicy failed with status %d: %s", resp.StatusCode, parseS3Error(responseBody))
+	}
+
+	return &provider.Website{
+		Bucket:   name,
+		Endpoint: websiteEndpoint(name, s.provider.region),
+	}, nil
+}
+
+// websiteEndpointDash are the regions whose website endpoints separate the
+// region with a dash rather than a dot
+var websiteEndpointDash = map[string]bool{
+	"us-east-1": true, "us-west-1": true, "us-west-2": true, "eu-west-1": true,
+	"ap-southeast-1": true, "ap-southeast-2": true, "ap-northeast-1": true, "sa-east-1": true,
+}
+
+// websiteEndpoint returns the address a bucket is served at as a website
+func websiteEndpoint(bucket, region string) string {
+	if websiteEndpointDash[region] {
+		return fmt.Sprintf("http://%s.s3-website-%s.amazonaws.com", bucket, region)
+	}
+	return fmt.Sprintf("http://%s.s3-website.%s.amazonaws.com", bucket, region)
+}
+
 // ListBuckets lists all buckets
 func (s *StorageService) ListBuckets(ctx context.Context) ([]*provider.Bucket, error) {
-	client, err := s.provider.CreateClient("s3")
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 client: %w", err)
 	}
@@ -299,6 +408,45 @@ func (s *StorageService) setBucketVersioning(client *AWSClient, bucketName strin
 	return nil
 }
 
+func (s *StorageService) setPublicAccessBlock(client *AWSClient, bucketName string, block bool) error {
+	endpoint := fmt.Sprintf("/%s", bucketName)
+	params := map[string]string{"publicAccessBlock": ""}
+
+	if !block {
+		resp, err := client.Request("DELETE", endpoint, params, nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 204 && resp.StatusCode != 200 && resp.StatusCode != 404 {
+			responseBody, _ := ReadResponse(resp)
+			return fmt.Errorf("failed to remove public access block with status %d: %s", resp.StatusCode, parseS3Error(responseBody))
+		}
+		return nil
+	}
+
+	blockXML := `<PublicAccessBlockConfiguration>
+		<BlockPublicAcls>true</BlockPublicAcls>
+		<IgnorePublicAcls>true</IgnorePublicAcls>
+		<BlockPublicPolicy>true</BlockPublicPolicy>
+		<RestrictPublicBuckets>true</RestrictPublicBuckets>
+	</PublicAccessBlockConfiguration>`
+
+	resp, err := client.RequestWithMD5("PUT", endpoint, params, []byte(blockXML))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		responseBody, _ := ReadResponse(resp)
+		return fmt.Errorf("failed to block public access with status %d: %s", resp.StatusCode, parseS3Error(responseBody))
+	}
+
+	return nil
+}
+
 func (s *StorageService) getBucketVersioning(client *AWSClient, bucketName string) (bool, error) {
 	endpoint := fmt.Sprintf("/%s", bucketName)
 	params := map[string]string{"versioning": ""}
@@ -529,7 +677,7 @@ func (s *StorageService) EmptyBucket(ctx context.Context, bucketName string) err
 
 // EmptyBucketWithOptions removes all objects and versions from a bucket with advanced options
 func (s *StorageService) EmptyBucketWithOptions(ctx context.Context, bucketName string, forceDelete bool) error {
-	client, err := s.provider.CreateClient("s3")
+	client, err := s.provider.CreateClient(ctx, "s3")
 	if err != nil {
 		return fmt.Errorf("failed to create S3 client: %w", err)
 	}
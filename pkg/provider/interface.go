@@ -42,11 +42,13 @@ type ComputeService interface {
 type StorageService interface {
 	CreateBucket(ctx context.Context, config *BucketConfig) (*Bucket, error)
 	GetBucket(ctx context.Context, name string) (*Bucket, error)
+	UpdateBucket(ctx context.Context, name string, config *BucketConfig) error
 	DeleteBucket(ctx context.Context, name string) error
 	DeleteBucketWithOptions(ctx context.Context, name string, forceDelete bool) error
 	EmptyBucket(ctx context.Context, name string) error
 	EmptyBucketWithOptions(ctx context.Context, name string, forceDelete bool) error
 	ListBuckets(ctx context.Context) ([]*Bucket, error)
+	ConfigureWebsite(ctx context.Context, name string, config *WebsiteConfig) (*Website, error)
 
 	// Discovery
 	DiscoverBuckets(ctx context.Context) ([]*Bucket, error)
@@ -59,6 +61,8 @@ type NetworkService interface {
 	GetNetwork(ctx context.Context, id string) (*Network, error)
 	CreateSubnet(ctx context.Context, networkID string, config *SubnetConfig) (*Subnet, error)
 	CreateSecurityGroup(ctx context.Context, config *SecurityGroupConfig) (*SecurityGroup, error)
+	CreateInternetGateway(ctx context.Context, networkID string, tags map[string]string) (*InternetGateway, error)
+	CreateRouteTable(ctx context.Context, networkID string, config *RouteTableConfig) (*RouteTable, error)
 
 	// Discovery
 	DiscoverNetworks(ctx context.Context) ([]*Network, error)
@@ -80,20 +84,40 @@ type DatabaseService interface {
 // ServerlessService handles serverless resources
 type ServerlessService interface {
 	CreateFunction(ctx context.Context, config *FunctionConfig) (*Function, error)
+	GetFunction(ctx context.Context, id string) (*Function, error)
 	UpdateFunction(ctx context.Context, id string, config *FunctionConfig) error
 	DeleteFunction(ctx context.Context, id string) error
 	InvokeFunction(ctx context.Context, id string, payload []byte) ([]byte, error)
+	CreateFunctionURL(ctx context.Context, id string) (string, error)
 
 	// Discovery
 	DiscoverFunctions(ctx context.Context) ([]*Function, error)
 	AdoptFunction(ctx context.Context, id string) (*Function, error)
 }
 
-// StateBackend handles state storage
+// StateBackend stores state documents. The documents themselves are encoded
+// and decoded by the state package, so every backend holds the same records.
 type StateBackend interface {
 	Init(ctx context.Context) error
-	Lock(ctx context.Context, key string) error
-	Unlock(ctx context.Context, key string) error
-	Read(ctx context.Context, key string) (*State, error)
-	Write(ctx context.Context, key string, state *State) error
-}
\ No newline at end of file
+	// Lock takes the lock on key under info.ID, waiting until ctx is done
+	// if another process holds it; it then returns a *LockHeldError
+	Lock(ctx context.Context, key string, info *LockInfo) error
+	// Unlock releases the lock on key if it is held under id
+	Unlock(ctx context.Context, key, id string) error
+	// ReadLock returns the holder of the lock on key, or nil if it is free
+	ReadLock(ctx context.Context, key string) (*LockInfo, error)
+	// Read returns the document stored under key, or nil if there is none
+	Read(ctx context.Context, key string) ([]byte, error)
+	Write(ctx context.Context, key string, data []byte) error
+	// Location describes where key is stored, for messages
+	Location(key string) string
+}
+
+// StateHistory is implemented by state backends that keep earlier versions
+// of state documents
+type StateHistory interface {
+	// Versions lists the stored versions of key, newest first
+	Versions(ctx context.Context, key string) ([]StateVersion, error)
+	// ReadVersion returns a stored version of key
+	ReadVersion(ctx context.Context, key, versionID string) ([]byte, error)
+}
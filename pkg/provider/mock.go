@@ -9,8 +9,19 @@ import (
 
 // MockProvider is a mock implementation for testing
 type MockProvider struct {
-	name   string
-	region string
+	name        string
+	region      string
+	defaultTags map[string]string
+}
+
+func init() {
+	Register("mock", func(config map[string]string) (Provider, error) {
+		return &MockProvider{
+			name:        "mock",
+			region:      config["region"],
+			defaultTags: DefaultTags(config),
+		}, nil
+	})
 }
 
 // NewMockProvider creates a new mock provider
@@ -34,27 +45,27 @@ func (m *MockProvider) Validate() error {
 }
 
 func (m *MockProvider) Compute() ComputeService {
-	return &MockComputeService{}
+	return &MockComputeService{defaultTags: m.defaultTags}
 }
 
 func (m *MockProvider) Storage() StorageService {
-	return &MockStorageService{}
+	return &MockStorageService{defaultTags: m.defaultTags}
 }
 
 func (m *MockProvider) Network() NetworkService {
-	return &MockNetworkService{}
+	return &MockNetworkService{defaultTags: m.defaultTags}
 }
 
 func (m *MockProvider) Database() DatabaseService {
-	return &MockDatabaseService{}
+	return &MockDatabaseService{defaultTags: m.defaultTags}
 }
 
 func (m *MockProvider) Serverless() ServerlessService {
-	return &MockServerlessService{}
+	return &MockServerlessService{defaultTags: m.defaultTags}
 }
 
 func (m *MockProvider) StateBackend() StateBackend {
-	return &MockStateBackend{}
+	return NewMockStateBackend()
 }
 
 func (m *MockProvider) Authenticate(ctx context.Context) error {
@@ -62,7 +73,9 @@ func (m *MockProvider) Authenticate(ctx context.Context) error {
 }
 
 // MockComputeService mock implementation
-type MockComputeService struct{}
+type MockComputeService struct {
+	defaultTags map[string]string
+}
 
 func (m *MockComputeService) CreateInstance(ctx context.Context, config *InstanceConfig) (*Instance, error) {
 	return &Instance{
@@ -71,7 +84,8 @@ func (m *MockComputeService) CreateInstance(ctx context.Context, config *Instanc
 		Type:      config.Type,
 		State:     "running",
 		PrivateIP: "10.0.1.10",
-		Tags:      config.Tags,
+		PublicIP:  "203.0.113.10",
+		Tags:      WithDefaultTags(m.defaultTags, config.Tags),
 		CreatedAt: time.Now(),
 	}, nil
 }
@@ -83,6 +97,7 @@ func (m *MockComputeService) GetInstance(ctx context.Context, id string) (*Insta
 		Type:      InstanceTypeMedium,
 		State:     "running",
 		PrivateIP: "10.0.1.10",
+		PublicIP:  "203.0.113.10",
 		CreatedAt: time.Now(),
 	}, nil
 }
@@ -117,7 +132,9 @@ func (m *MockComputeService) AdoptInstance(ctx context.Context, id string) (*Ins
 }
 
 // MockStorageService mock implementation
-type MockStorageService struct{}
+type MockStorageService struct {
+	defaultTags map[string]string
+}
 
 func (m *MockStorageService) CreateBucket(ctx context.Context, config *BucketConfig) (*Bucket, error) {
 	return &Bucket{
@@ -125,20 +142,26 @@ func (m *MockStorageService) CreateBucket(ctx context.Context, config *BucketCon
 		Versioning:   config.Versioning,
 		Encryption:   config.Encryption,
 		PublicAccess: config.PublicAccess,
-		Tags:         config.Tags,
+		Tags:         WithDefaultTags(m.defaultTags, config.Tags),
 		CreatedAt:    time.Now(),
+		ProviderData: map[string]interface{}{"arn": "arn:aws:s3:::" + config.Name},
 	}, nil
 }
 
 func (m *MockStorageService) GetBucket(ctx context.Context, name string) (*Bucket, error) {
 	return &Bucket{
-		Name:       name,
-		Versioning: true,
-		Encryption: true,
-		CreatedAt:  time.Now(),
+		Name:         name,
+		Versioning:   true,
+		Encryption:   true,
+		CreatedAt:    time.Now(),
+		ProviderData: map[string]interface{}{"arn": "arn:aws:s3:::" + name},
 	}, nil
 }
 
+func (m *MockStorageService) UpdateBucket(ctx context.Context, name string, config *BucketConfig) error {
+	return nil
+}
+
 func (m *MockStorageService) DeleteBucket(ctx context.Context, name string) error {
 	return nil
 }
@@ -159,6 +182,13 @@ func (m *MockStorageService) ListBuckets(ctx context.Context) ([]*Bucket, error)
 	return []*Bucket{}, nil
 }
 
+func (m *MockStorageService) ConfigureWebsite(ctx context.Context, name string, config *WebsiteConfig) (*Website, error) {
+	return &Website{
+		Bucket:   name,
+		Endpoint: fmt.Sprintf("http://%s.s3-website-us-east-1.amazonaws.com", name),
+	}, nil
+}
+
 func (m *MockStorageService) DiscoverBuckets(ctx context.Context) ([]*Bucket, error) {
 	return []*Bucket{
 		{
@@ -175,14 +205,16 @@ func (m *MockStorageService) AdoptBucket(ctx context.Context, name string) (*Buc
 }
 
 // MockNetworkService mock implementation
-type MockNetworkService struct{}
+type MockNetworkService struct {
+	defaultTags map[string]string
+}
 
 func (m *MockNetworkService) CreateNetwork(ctx context.Context, config *NetworkConfig) (*Network, error) {
 	return &Network{
 		ID:        fmt.Sprintf("vpc-%d", time.Now().Unix()),
 		Name:      config.Name,
 		CIDR:      config.CIDR,
-		Tags:      config.Tags,
+		Tags:      WithDefaultTags(m.defaultTags, config.Tags),
 		CreatedAt: time.Now(),
 	}, nil
 }
@@ -204,6 +236,7 @@ func (m *MockNetworkService) CreateSubnet(ctx context.Context, networkID string,
 		NetworkID: networkID,
 		Public:    config.Public,
 		AZ:        config.AZ,
+		Tags:      WithDefaultTags(m.defaultTags, config.Tags),
 	}, nil
 }
 
@@ -213,7 +246,25 @@ func (m *MockNetworkService) CreateSecurityGroup(ctx context.Context, config *Se
 		Name:        config.Name,
 		Description: config.Description,
 		Rules:       config.Rules,
-		Tags:        config.Tags,
+		Tags:        WithDefaultTags(m.defaultTags, config.Tags),
+	}, nil
+}
+
+func (m *MockNetworkService) CreateInternetGateway(ctx context.Context, networkID string, tags map[string]string) (*InternetGateway, error) {
+	return &InternetGateway{
+		ID:        fmt.Sprintf("igw-%d", time.Now().Unix()),
+		NetworkID: networkID,
+		Tags:      WithDefaultTags(m.defaultTags, tags),
+	}, nil
+}
+
+func (m *MockNetworkService) CreateRouteTable(ctx context.Context, networkID string, config *RouteTableConfig) (*RouteTable, error) {
+	return &RouteTable{
+		ID:        fmt.Sprintf("rtb-%d", time.Now().Unix()),
+		NetworkID: networkID,
+		GatewayID: config.GatewayID,
+		SubnetIDs: config.SubnetIDs,
+		Tags:      WithDefaultTags(m.defaultTags, config.Tags),
 	}, nil
 }
 
@@ -233,7 +284,9 @@ func (m *MockNetworkService) AdoptNetwork(ctx context.Context, id string) (*Netw
 }
 
 // MockDatabaseService mock implementation
-type MockDatabaseService struct{}
+type MockDatabaseService struct {
+	defaultTags map[string]string
+}
 
 func (m *MockDatabaseService) CreateDatabase(ctx context.Context, config *DatabaseConfig) (*Database, error) {
 	return &Database{
@@ -246,7 +299,7 @@ func (m *MockDatabaseService) CreateDatabase(ctx context.Context, config *Databa
 		MultiAZ:   config.MultiAZ,
 		Endpoint:  fmt.Sprintf("%s.mock.rds.amazonaws.com", config.Name),
 		Port:      5432,
-		Tags:      config.Tags,
+		Tags:      WithDefaultTags(m.defaultTags, config.Tags),
 		CreatedAt: time.Now(),
 	}, nil
 }
@@ -256,7 +309,7 @@ func (m *MockDatabaseService) GetDatabase(ctx context.Context, id string) (*Data
 		ID:        id,
 		Name:      "mock-database",
 		Engine:    "postgres",
-		Version:   "14",
+		Version:   "15.4",
 		Size:      DatabaseSizeMedium,
 		Storage:   100,
 		Endpoint:  "mock-db.mock.rds.amazonaws.com",
@@ -294,7 +347,9 @@ func (m *MockDatabaseService) AdoptDatabase(ctx context.Context, id string) (*Da
 }
 
 // MockServerlessService mock implementation
-type MockServerlessService struct{}
+type MockServerlessService struct {
+	defaultTags map[string]string
+}
 
 func (m *MockServerlessService) CreateFunction(ctx context.Context, config *FunctionConfig) (*Function, error) {
 	return &Function{
@@ -306,11 +361,23 @@ func (m *MockServerlessService) CreateFunction(ctx context.Context, config *Func
 		Timeout:     config.Timeout,
 		Environment: config.Environment,
 		URL:         fmt.Sprintf("https://%s.lambda-url.us-east-1.on.aws/", config.Name),
-		Tags:        config.Tags,
+		Tags:        WithDefaultTags(m.defaultTags, config.Tags),
 		CreatedAt:   time.Now(),
 	}, nil
 }
 
+func (m *MockServerlessService) GetFunction(ctx context.Context, id string) (*Function, error) {
+	return &Function{
+		ID:        id,
+		Name:      id,
+		Runtime:   "python3.11",
+		Handler:   "main.handler",
+		Memory:    256,
+		Timeout:   60,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
 func (m *MockServerlessService) UpdateFunction(ctx context.Context, id string, config *FunctionConfig) error {
 	return nil
 }
@@ -323,6 +390,10 @@ func (m *MockServerlessService) InvokeFunction(ctx context.Context, id string, p
 	return []byte(`{"status": "success", "message": "Hello from mock function"}`), nil
 }
 
+func (m *MockServerlessService) CreateFunctionURL(ctx context.Context, id string) (string, error) {
+	return fmt.Sprintf("https://%s.lambda-url.us-east-1.on.aws/", id), nil
+}
+
 func (m *MockServerlessService) DiscoverFunctions(ctx context.Context) ([]*Function, error) {
 	return []*Function{
 		{
@@ -349,45 +420,136 @@ func (m *MockServerlessService) AdoptFunction(ctx context.Context, id string) (*
 	}, nil
 }
 
-// MockStateBackend mock implementation
+// MockStateBackend keeps state documents in memory
 type MockStateBackend struct {
-	state map[string]*State
-	mu    sync.RWMutex
+	state    map[string][]byte
+	versions map[string][]mockStateVersion
+	locked   map[string]*LockInfo
+	mu       sync.Mutex
+
+	nextVersion int
+}
+
+type mockStateVersion struct {
+	version StateVersion
+	data    []byte
+}
+
+// NewMockStateBackend creates an empty in-memory state backend
+func NewMockStateBackend() *MockStateBackend {
+	return &MockStateBackend{
+		state:  make(map[string][]byte),
+		locked: make(map[string]*LockInfo),
+	}
 }
 
 func (m *MockStateBackend) Init(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.state == nil {
-		m.state = make(map[string]*State)
+		m.state = make(map[string][]byte)
+		m.locked = make(map[string]*LockInfo)
 	}
 	return nil
 }
 
-func (m *MockStateBackend) Lock(ctx context.Context, key string) error {
+func (m *MockStateBackend) Lock(ctx context.Context, key string, info *LockInfo) error {
+	for {
+		m.mu.Lock()
+		if m.locked == nil {
+			m.locked = make(map[string]*LockInfo)
+		}
+		holder := m.locked[key]
+		if holder == nil {
+			m.locked[key] = info
+			m.mu.Unlock()
+			return nil
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return &LockHeldError{Info: holder}
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (m *MockStateBackend) Unlock(ctx context.Context, key, id string) error {
 	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	holder := m.locked[key]
+	if holder == nil {
+		return fmt.Errorf("state %s is not locked", key)
+	}
+	if holder.ID != id {
+		return fmt.Errorf("state %s is locked under ID %s, not %s", key, holder.ID, id)
+	}
+	delete(m.locked, key)
 	return nil
 }
 
-func (m *MockStateBackend) Unlock(ctx context.Context, key string) error {
-	m.mu.Unlock()
-	return nil
+func (m *MockStateBackend) ReadLock(ctx context.Context, key string) (*LockInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.locked[key], nil
 }
 
-func (m *MockStateBackend) Read(ctx context.Context, key string) (*State, error) {
-	if s, ok := m.state[key]; ok {
-		return s, nil
-	}
-	return &State{
-		Version:   1,
-		Resources: make(map[string]interface{}),
-		Outputs:   make(map[string]interface{}),
-		UpdatedAt: time.Now(),
-	}, nil
+func (m *MockStateBackend) Read(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state[key], nil
 }
 
-func (m *MockStateBackend) Write(ctx context.Context, key string, state *State) error {
+func (m *MockStateBackend) Write(ctx context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.state == nil {
-		m.state = make(map[string]*State)
+		m.state = make(map[string][]byte)
+	}
+	m.state[key] = append([]byte(nil), data...)
+
+	if m.versions == nil {
+		m.versions = make(map[string][]mockStateVersion)
+	}
+	m.nextVersion++
+	version := StateVersion{ID: fmt.Sprintf("v%d", m.nextVersion), Modified: time.Now()}
+	versions := append(m.versions[key], mockStateVersion{version: version, data: m.state[key]})
+	if len(versions) > StateVersionsKept {
+		versions = versions[len(versions)-StateVersionsKept:]
 	}
-	m.state[key] = state
+	m.versions[key] = versions
 	return nil
-}
\ No newline at end of file
+}
+
+func (m *MockStateBackend) Versions(ctx context.Context, key string) ([]StateVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var versions []StateVersion
+	for i := len(m.versions[key]) - 1; i >= 0; i-- {
+		versions = append(versions, m.versions[key][i].version)
+	}
+	return versions, nil
+}
+
+func (m *MockStateBackend) ReadVersion(ctx context.Context, key, versionID string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, v := range m.versions[key] {
+		if v.version.ID == versionID {
+			return v.data, nil
+		}
+	}
+	return nil, fmt.Errorf("state %s has no version %s", key, versionID)
+}
+
+func (m *MockStateBackend) Location(key string) string {
+	return "memory:" + key
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -47,5 +48,6 @@ func ListProviders() []string {
 	for name := range globalRegistry.providers {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
\ No newline at end of file
@@ -1,6 +1,9 @@
 package provider
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // InstanceType represents abstracted compute sizing
 type InstanceType string
@@ -75,6 +78,18 @@ type LifecycleConfig struct {
 	ArchiveAfterDays int
 }
 
+// WebsiteConfig for serving a bucket as a static website
+type WebsiteConfig struct {
+	IndexDocument string
+	ErrorDocument string
+}
+
+// Website is a bucket served as a static website
+type Website struct {
+	Bucket   string
+	Endpoint string
+}
+
 // Network represents a virtual network
 type Network struct {
 	ID           string
@@ -101,6 +116,7 @@ type Subnet struct {
 	NetworkID    string
 	Public       bool
 	AZ           string // Availability Zone
+	Tags         map[string]string
 	ProviderData map[string]interface{}
 }
 
@@ -110,6 +126,31 @@ type SubnetConfig struct {
 	CIDR   string
 	Public bool
 	AZ     string
+	Tags   map[string]string
+}
+
+// InternetGateway connects a network to the internet
+type InternetGateway struct {
+	ID        string
+	NetworkID string
+	Tags      map[string]string
+}
+
+// RouteTableConfig for creating route tables. Traffic leaving the network
+// goes through GatewayID when it is set.
+type RouteTableConfig struct {
+	GatewayID string
+	SubnetIDs []string
+	Tags      map[string]string
+}
+
+// RouteTable routes the traffic of the subnets associated with it
+type RouteTable struct {
+	ID        string
+	NetworkID string
+	GatewayID string
+	SubnetIDs []string
+	Tags      map[string]string
 }
 
 // SecurityGroup represents firewall rules
@@ -126,6 +167,7 @@ type SecurityGroup struct {
 type SecurityGroupConfig struct {
 	Name        string
 	Description string
+	NetworkID   string // Network to create the group in; empty means the default network
 	Rules       []SecurityRule
 	Tags        map[string]string
 }
@@ -164,6 +206,7 @@ type DatabaseConfig struct {
 	Storage        int
 	MultiAZ        bool
 	BackupConfig   *BackupConfig
+	SecurityGroups []string
 	Tags           map[string]string
 	MasterUser     string
 	MasterPassword string
@@ -221,14 +264,6 @@ type TriggerConfig struct {
 	Config map[string]interface{}
 }
 
-// State represents infrastructure state
-type State struct {
-	Version   int
-	Resources map[string]interface{}
-	Outputs   map[string]interface{}
-	UpdatedAt time.Time
-}
-
 // LambdaLayer represents a Lambda layer
 type LambdaLayer struct {
 	ID                 string
@@ -257,3 +292,36 @@ type LayerContent struct {
 	S3Key     string
 	LocalPath string // Local path to ZIP file
 }
+
+// LockInfo describes the holder of a state lock
+type LockInfo struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation,omitempty"`
+	Who       string    `json:"who,omitempty"`
+	Host      string    `json:"host"`
+	PID       int       `json:"pid"`
+	Acquired  time.Time `json:"acquired"`
+}
+
+// LockHeldError is returned by StateBackend.Lock when another process still
+// holds the lock
+type LockHeldError struct {
+	Info *LockInfo
+}
+
+func (e *LockHeldError) Error() string {
+	if e.Info == nil {
+		return "state is locked by another process"
+	}
+	return fmt.Sprintf("state is locked by %s (lock ID %s)", e.Info.Who, e.Info.ID)
+}
+
+// StateVersionsKept is how many versions of a state document backends with
+// history keep, including the current one
+const StateVersionsKept = 20
+
+// StateVersion is a stored version of a state document
+type StateVersion struct {
+	ID       string
+	Modified time.Time
+}
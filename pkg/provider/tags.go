@@ -0,0 +1,42 @@
+package provider
+
+import "strings"
+
+// DefaultTagPrefix marks the keys of a provider configuration that set
+// default tags: "default_tag.owner" set to "platform" adds owner=platform to
+// every resource the provider creates, such as the owner and cost-center tags
+// of an organization's policy files.
+const DefaultTagPrefix = "default_tag."
+
+// DefaultTags returns the default tags set in a provider configuration
+func DefaultTags(config map[string]string) map[string]string {
+	var tags map[string]string
+	for key, value := range config {
+		name, ok := strings.CutPrefix(key, DefaultTagPrefix)
+		if !ok || name == "" {
+			continue
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[name] = value
+	}
+	return tags
+}
+
+// WithDefaultTags returns a resource's tags with the default tags it does
+// not set. Tags set on a resource take precedence.
+func WithDefaultTags(defaults, tags map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return tags
+	}
+
+	merged := make(map[string]string, len(tags)+len(defaults))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return merged
+}
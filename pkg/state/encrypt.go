@@ -0,0 +1,487 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+const (
+	// PassphraseEnvVar holds the passphrase state is encrypted with
+	PassphraseEnvVar = "GENESYS_STATE_PASSPHRASE"
+
+	// KeyFileEnvVar names a key file state is encrypted with
+	KeyFileEnvVar = "GENESYS_STATE_KEY_FILE"
+
+	// PreviousPassphraseEnvVar and PreviousKeyFileEnvVar hold keys that are
+	// only used to decrypt, while rotating to a new key
+	PreviousPassphraseEnvVar = "GENESYS_STATE_PREVIOUS_PASSPHRASE"
+	PreviousKeyFileEnvVar    = "GENESYS_STATE_PREVIOUS_KEY_FILE"
+
+	// envelopeFormat marks encrypted state documents
+	envelopeFormat = "genesys-aes-256-gcm-v1"
+
+	// minKeyFileSize is the smallest accepted key file
+	minKeyFileSize = 32
+)
+
+// Keys encrypts local state files. Commands set it from the environment and
+// the configuration; without it, local state is stored unencrypted.
+var Keys *Keyring
+
+// passphraseIterations is the PBKDF2 iteration count for new passphrase keys
+var passphraseIterations = 600000
+
+// KeyWrapper protects the data keys state documents are encrypted with.
+// Implementations derive a key from a passphrase or key file, or call a key
+// management service.
+type KeyWrapper interface {
+	// ID identifies the key in encrypted documents and messages
+	ID() string
+	Wrap(dataKey []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+// Keyring holds the keys state is encrypted with. Primary encrypts new
+// writes; every key, including Previous ones kept while rotating, is tried
+// when decrypting.
+type Keyring struct {
+	Primary  KeyWrapper
+	Previous []KeyWrapper
+}
+
+// envelope is an encrypted state document. The document is encrypted with a
+// random data key using AES-256-GCM, and the data key is wrapped by the key
+// in the keyring.
+type envelope struct {
+	Format     string `json:"genesys_encrypted"`
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EnvKeyring returns the keyring configured with the GENESYS_STATE_*
+// environment variables. It has no primary key when none is set.
+func EnvKeyring() (*Keyring, error) {
+	keyring := &Keyring{}
+
+	if path := os.Getenv(KeyFileEnvVar); path != "" {
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keyring.Primary = key
+	} else if passphrase := os.Getenv(PassphraseEnvVar); passphrase != "" {
+		keyring.Primary = NewPassphraseKey(passphrase)
+	}
+
+	if path := os.Getenv(PreviousKeyFileEnvVar); path != "" {
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keyring.Previous = append(keyring.Previous, key)
+	}
+	if passphrase := os.Getenv(PreviousPassphraseEnvVar); passphrase != "" {
+		keyring.Previous = append(keyring.Previous, NewPassphraseKey(passphrase))
+	}
+
+	return keyring, nil
+}
+
+// keys returns every key of the keyring, primary first
+func (k *Keyring) keys() []KeyWrapper {
+	var keys []KeyWrapper
+	if k.Primary != nil {
+		keys = append(keys, k.Primary)
+	}
+	return append(keys, k.Previous...)
+}
+
+// Encrypt seals a state document with a new data key wrapped by the primary key
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	if k.Primary == nil {
+		return nil, fmt.Errorf("no state encryption key is configured")
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	nonce, ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := k.Primary.Wrap(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key with %s: %w", k.Primary.ID(), err)
+	}
+
+	return json.MarshalIndent(envelope{
+		Format:     envelopeFormat,
+		KeyID:      k.Primary.ID(),
+		WrappedKey: wrapped,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}, "", "  ")
+}
+
+// Decrypt opens an encrypted state document. Documents that are not
+// encrypted are returned unchanged, so existing state can be read and is
+// encrypted on its next write.
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	env, ok := parseEnvelope(data)
+	if !ok {
+		return data, nil
+	}
+
+	keys := k.keys()
+	if len(keys) == 0 {
+		return nil, noKeyError("", env.KeyID)
+	}
+
+	// Try the key the document names first
+	sorted := make([]KeyWrapper, 0, len(keys))
+	for _, key := range keys {
+		if key.ID() == env.KeyID {
+			sorted = append(sorted, key)
+		}
+	}
+	for _, key := range keys {
+		if key.ID() != env.KeyID {
+			sorted = append(sorted, key)
+		}
+	}
+
+	for _, key := range sorted {
+		dataKey, err := key.Unwrap(env.WrappedKey)
+		if err != nil {
+			continue
+		}
+		plaintext, err := open(dataKey, env.Nonce, env.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt state: %w", err)
+		}
+		return plaintext, nil
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, key.ID())
+	}
+	return nil, fmt.Errorf("state is encrypted with key %s, which none of the configured keys (%s) can unwrap; after rotating keys, set %s or %s to the previous key",
+		env.KeyID, strings.Join(ids, ", "), PreviousPassphraseEnvVar, PreviousKeyFileEnvVar)
+}
+
+// noKeyError explains how to configure the key a state is encrypted with
+func noKeyError(location, keyID string) error {
+	state := "state"
+	if location != "" {
+		state += " " + location
+	}
+	return fmt.Errorf("%s is encrypted with key %s but no state key is configured; set %s or %s, or state.key_file or state.kms_key_id in the configuration",
+		state, keyID, PassphraseEnvVar, KeyFileEnvVar)
+}
+
+// IsEncrypted reports whether a state document is encrypted
+func IsEncrypted(data []byte) bool {
+	_, ok := parseEnvelope(data)
+	return ok
+}
+
+func parseEnvelope(data []byte) (*envelope, bool) {
+	if !bytes.Contains(data, []byte(`"genesys_encrypted"`)) {
+		return nil, false
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Format != envelopeFormat {
+		return nil, false
+	}
+	return &env, true
+}
+
+// seal encrypts plaintext with AES-256-GCM under a random nonce
+func seal(key, plaintext []byte) ([]byte, []byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+// open decrypts and authenticates a sealed message
+func open(key, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// PassphraseKey derives key-encryption keys from a passphrase with
+// PBKDF2-SHA256. The salt and iteration count are stored with each wrapped
+// key; derived keys are cached per salt.
+type PassphraseKey struct {
+	passphrase string
+
+	mu      sync.Mutex
+	salt    []byte
+	derived map[string][]byte
+}
+
+// NewPassphraseKey creates a key derived from a passphrase
+func NewPassphraseKey(passphrase string) *PassphraseKey {
+	return &PassphraseKey{passphrase: passphrase, derived: make(map[string][]byte)}
+}
+
+// ID identifies passphrase keys; no fingerprint of the passphrase is stored
+func (k *PassphraseKey) ID() string {
+	return "passphrase"
+}
+
+// Wrap encrypts a data key as iterations || salt || nonce || ciphertext
+func (k *PassphraseKey) Wrap(dataKey []byte) ([]byte, error) {
+	k.mu.Lock()
+	if k.salt == nil {
+		k.salt = make([]byte, 16)
+		if _, err := rand.Read(k.salt); err != nil {
+			k.mu.Unlock()
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+	salt := k.salt
+	k.mu.Unlock()
+
+	kek, err := k.derive(salt, passphraseIterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := seal(kek, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := binary.BigEndian.AppendUint32(nil, uint32(passphraseIterations))
+	wrapped = append(wrapped, salt...)
+	wrapped = append(wrapped, nonce...)
+	return append(wrapped, ciphertext...), nil
+}
+
+// Unwrap decrypts a data key wrapped by Wrap
+func (k *PassphraseKey) Unwrap(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 4+16+12 {
+		return nil, errors.New("wrapped key is too short")
+	}
+	iterations := int(binary.BigEndian.Uint32(wrapped))
+	salt, rest := wrapped[4:20], wrapped[20:]
+
+	kek, err := k.derive(salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	return open(kek, rest[:12], rest[12:])
+}
+
+func (k *PassphraseKey) derive(salt []byte, iterations int) ([]byte, error) {
+	cacheKey := fmt.Sprintf("%x/%d", salt, iterations)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if kek, ok := k.derived[cacheKey]; ok {
+		return kek, nil
+	}
+
+	kek, err := pbkdf2.Key(sha256.New, k.passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key from passphrase: %w", err)
+	}
+	k.derived[cacheKey] = kek
+	return kek, nil
+}
+
+// FileKey derives a key-encryption key from the contents of a key file with
+// HKDF-SHA256
+type FileKey struct {
+	kek []byte
+}
+
+// LoadKeyFile reads a key file of at least 32 bytes, such as one created
+// with: head -c 32 /dev/urandom | base64 > state.key
+func LoadKeyFile(path string) (*FileKey, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state key file: %w", err)
+	}
+
+	secret := bytes.TrimSpace(data)
+	if len(secret) < minKeyFileSize {
+		return nil, fmt.Errorf("state key file %s is too short: it needs at least %d bytes", path, minKeyFileSize)
+	}
+
+	kek, err := hkdf.Key(sha256.New, secret, nil, "genesys state key", 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key from %s: %w", path, err)
+	}
+	return &FileKey{kek: kek}, nil
+}
+
+// ID identifies the key by a fingerprint of the derived key
+func (k *FileKey) ID() string {
+	sum := sha256.Sum256(k.kek)
+	return "key-file:" + hex.EncodeToString(sum[:8])
+}
+
+// Wrap encrypts a data key as nonce || ciphertext
+func (k *FileKey) Wrap(dataKey []byte) ([]byte, error) {
+	nonce, ciphertext, err := seal(k.kek, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+// Unwrap decrypts a data key wrapped by Wrap
+func (k *FileKey) Unwrap(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 12 {
+		return nil, errors.New("wrapped key is too short")
+	}
+	return open(k.kek, wrapped[:12], wrapped[12:])
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + "/" + rest
+		}
+	}
+	return path
+}
+
+// RotateKey rewrites the state encrypted with the primary key, reading it
+// with any configured key. Versions kept in the history stay encrypted with
+// the key they were written with.
+func (s *LocalState) RotateKey() error {
+	backend, _, err := s.target()
+	if err != nil {
+		return err
+	}
+	encrypted, ok := backend.(*EncryptedBackend)
+	if !ok || encrypted.Keyring.Primary == nil {
+		return fmt.Errorf("no state key is configured; set %s or %s, or state.key_file or state.kms_key_id in the configuration",
+			PassphraseEnvVar, KeyFileEnvVar)
+	}
+	return s.update(func() {})
+}
+
+// EncryptedBackend encrypts the state documents of another backend.
+// Locks, locations and history are those of the wrapped backend.
+type EncryptedBackend struct {
+	Backend provider.StateBackend
+	Keyring *Keyring
+}
+
+// WithEncryption wraps backend so that documents are encrypted with the
+// keyring's primary key and decrypted with any of its keys. Without any
+// keys, backend is returned unchanged.
+func WithEncryption(backend provider.StateBackend, keyring *Keyring) provider.StateBackend {
+	if keyring == nil || len(keyring.keys()) == 0 {
+		return backend
+	}
+	return &EncryptedBackend{Backend: backend, Keyring: keyring}
+}
+
+func (b *EncryptedBackend) Init(ctx context.Context) error {
+	return b.Backend.Init(ctx)
+}
+
+func (b *EncryptedBackend) Lock(ctx context.Context, key string, info *LockInfo) error {
+	return b.Backend.Lock(ctx, key, info)
+}
+
+func (b *EncryptedBackend) Unlock(ctx context.Context, key, id string) error {
+	return b.Backend.Unlock(ctx, key, id)
+}
+
+func (b *EncryptedBackend) ReadLock(ctx context.Context, key string) (*LockInfo, error) {
+	return b.Backend.ReadLock(ctx, key)
+}
+
+func (b *EncryptedBackend) Location(key string) string {
+	return b.Backend.Location(key)
+}
+
+// Read reads and decrypts a document
+func (b *EncryptedBackend) Read(ctx context.Context, key string) ([]byte, error) {
+	data, err := b.Backend.Read(ctx, key)
+	if err != nil || data == nil {
+		return data, err
+	}
+	return b.Keyring.Decrypt(data)
+}
+
+// Write encrypts a document with the primary key; without one, documents
+// are written as they are
+func (b *EncryptedBackend) Write(ctx context.Context, key string, data []byte) error {
+	if b.Keyring.Primary != nil {
+		encrypted, err := b.Keyring.Encrypt(data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt state: %w", err)
+		}
+		data = encrypted
+	}
+	return b.Backend.Write(ctx, key, data)
+}
+
+// Versions lists the versions kept by the wrapped backend
+func (b *EncryptedBackend) Versions(ctx context.Context, key string) ([]provider.StateVersion, error) {
+	history, ok := b.Backend.(provider.StateHistory)
+	if !ok {
+		return nil, fmt.Errorf("the backend of state %s does not keep history", b.Location(key))
+	}
+	return history.Versions(ctx, key)
+}
+
+// ReadVersion reads and decrypts a version kept by the wrapped backend
+func (b *EncryptedBackend) ReadVersion(ctx context.Context, key, versionID string) ([]byte, error) {
+	history, ok := b.Backend.(provider.StateHistory)
+	if !ok {
+		return nil, fmt.Errorf("the backend of state %s does not keep history", b.Location(key))
+	}
+	data, err := history.ReadVersion(ctx, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return b.Keyring.Decrypt(data)
+}
@@ -0,0 +1,103 @@
+package state
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedState(t *testing.T) {
+	defer func(iterations int) { passphraseIterations = iterations }(passphraseIterations)
+	passphraseIterations = 1000
+	defer func(keys *Keyring) { Keys = keys }(Keys)
+
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	keyPath := filepath.Join(dir, "state.key")
+	if err := os.WriteFile(keyPath, []byte("0123456789abcdef0123456789abcdef\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fileKey, err := LoadKeyFile(keyPath)
+	if err != nil {
+		t.Fatalf("LoadKeyFile() error = %v", err)
+	}
+
+	// Existing unencrypted state is read and encrypted on its next write
+	Keys = nil
+	plain := NewLocalState(statePath)
+	if err := plain.AddResource(ResourceRecord{ID: "b", Name: "secret-bucket", Type: "s3"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+
+	Keys = &Keyring{Primary: NewPassphraseKey("first")}
+	st, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if err := st.AddResource(ResourceRecord{ID: "c", Name: "c", Type: "s3"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(data) || bytes.Contains(data, []byte("secret-bucket")) {
+		t.Fatalf("state file is not encrypted:\n%s", data)
+	}
+
+	// Reading without a key names the key and how to configure one
+	Keys = nil
+	_, err = LoadState(statePath)
+	if err == nil || !strings.Contains(err.Error(), "encrypted with key passphrase") || !strings.Contains(err.Error(), PassphraseEnvVar) {
+		t.Errorf("LoadState() without a key error = %v", err)
+	}
+
+	Keys = &Keyring{Primary: NewPassphraseKey("wrong")}
+	if _, err := LoadState(statePath); err == nil || !strings.Contains(err.Error(), PreviousPassphraseEnvVar) {
+		t.Errorf("LoadState() with the wrong key error = %v", err)
+	}
+
+	// Rotate to the key file, reading with the old passphrase
+	Keys = &Keyring{Primary: fileKey, Previous: []KeyWrapper{NewPassphraseKey("first")}}
+	st, err = LoadState(statePath)
+	if err != nil {
+		t.Fatalf("LoadState() during rotation error = %v", err)
+	}
+	if err := st.RotateKey(); err != nil {
+		t.Fatalf("RotateKey() error = %v", err)
+	}
+
+	Keys = &Keyring{Primary: fileKey}
+	st, err = LoadState(statePath)
+	if err != nil {
+		t.Fatalf("LoadState() after rotation error = %v", err)
+	}
+	if len(st.Resources) != 2 || st.Serial != 3 {
+		t.Errorf("rotated state has %d resources at serial %d, want 2 at serial 3", len(st.Resources), st.Serial)
+	}
+
+	// Older versions keep the key they were written with
+	if _, err := st.AtSerial(2); err == nil {
+		t.Error("AtSerial() expected error reading a version written with the old key")
+	}
+	if snapshot, err := st.AtSerial(1); err != nil || len(snapshot.Resources) != 1 {
+		t.Errorf("AtSerial(1) of unencrypted version = %v, %v", snapshot, err)
+	}
+
+	Keys = nil
+	if err := NewLocalState(filepath.Join(dir, "other.json")).RotateKey(); err == nil {
+		t.Error("RotateKey() expected error without a state key")
+	}
+}
+
+func TestLoadKeyFileTooShort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.key")
+	if err := os.WriteFile(path, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyFile(path); err == nil {
+		t.Error("LoadKeyFile() expected error for a short key file")
+	}
+}
@@ -0,0 +1,277 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+// FileBackend stores state in local files. Keys are file paths; the previous
+// version of a file is kept next to it as a backup, writes replace the file
+// atomically, and the last versions are kept in a history directory beside it.
+type FileBackend struct {
+	mu    sync.Mutex
+	locks map[string]*fileLock
+}
+
+// fileLock is a lock file held by this process
+type fileLock struct {
+	file *os.File
+	info *LockInfo
+}
+
+// fileBackend is the backend used for local state
+var fileBackend = NewFileBackend()
+
+// NewFileBackend creates a backend storing state in local files
+func NewFileBackend() *FileBackend {
+	return &FileBackend{locks: make(map[string]*fileLock)}
+}
+
+// Init initializes the backend; directories are created on first write
+func (b *FileBackend) Init(ctx context.Context) error {
+	return nil
+}
+
+// Lock locks the lock file next to the state file, retrying until ctx is
+// done while another process holds it
+func (b *FileBackend) Lock(ctx context.Context, key string, info *LockInfo) error {
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	file, err := acquireLockFile(key, timeout, info)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.locks[key] = &fileLock{file: file, info: info}
+	b.mu.Unlock()
+	return nil
+}
+
+// Unlock releases the lock taken by Lock. File locks held by other
+// processes are released by the operating system when the process exits, so
+// they cannot be broken from here.
+func (b *FileBackend) Unlock(ctx context.Context, key, id string) error {
+	b.mu.Lock()
+	held, ok := b.locks[key]
+	if ok && held.info.ID == id {
+		delete(b.locks, key)
+	}
+	b.mu.Unlock()
+
+	if ok && held.info.ID == id {
+		unlockFile(held.file)
+		return held.file.Close()
+	}
+
+	holder, err := b.ReadLock(ctx, key)
+	if err != nil {
+		return err
+	}
+	if holder == nil {
+		return fmt.Errorf("state %s is not locked", key)
+	}
+	if holder.ID != id {
+		return fmt.Errorf("state %s is locked under ID %s, not %s", key, holder.ID, id)
+	}
+	return fmt.Errorf("state %s is locked by running process %d; local locks are released when the process exits", key, holder.PID)
+}
+
+// ReadLock returns the holder of the lock file, or nil when no process holds it
+func (b *FileBackend) ReadLock(ctx context.Context, key string) (*LockInfo, error) {
+	b.mu.Lock()
+	held, ok := b.locks[key]
+	b.mu.Unlock()
+	if ok {
+		return held.info, nil
+	}
+
+	path := key + lockSuffix
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state lock file: %w", err)
+	}
+	defer file.Close()
+
+	err = tryLockFile(file)
+	if err == nil {
+		unlockFile(file)
+		return nil, nil
+	}
+	if !errors.Is(err, errWouldBlock) {
+		return nil, fmt.Errorf("failed to check state lock: %w", err)
+	}
+	return readLockInfo(path), nil
+}
+
+// Read reads a state file, returning nil when it does not exist yet
+func (b *FileBackend) Read(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(key)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	return data, nil
+}
+
+// Write backs up the existing state file and replaces it atomically
+func (b *FileBackend) Write(ctx context.Context, key string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(key), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	previous, err := os.ReadFile(key)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err == nil {
+		if err := writeFileAtomic(key+backupSuffix, previous); err != nil {
+			return fmt.Errorf("failed to back up state file: %w", err)
+		}
+	}
+
+	if err := writeFileAtomic(key, data); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := b.saveVersion(key, data); err != nil {
+		return fmt.Errorf("failed to record state history: %w", err)
+	}
+	return nil
+}
+
+// historyDirName is the directory next to a state file holding its versions
+const historyDirName = "history"
+
+// saveVersion copies a written state into the history directory, named by
+// the time it was written, and removes the oldest versions beyond
+// provider.StateVersionsKept
+func (b *FileBackend) saveVersion(key string, data []byte) error {
+	dir := filepath.Join(filepath.Dir(key), historyDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Versions are named by write time; coarse clocks can repeat a time
+	nanos := time.Now().UnixNano()
+	for {
+		if _, err := os.Stat(b.versionPath(key, fmt.Sprintf("%019d", nanos))); os.IsNotExist(err) {
+			break
+		}
+		nanos++
+	}
+	if err := writeFileAtomic(b.versionPath(key, fmt.Sprintf("%019d", nanos)), data); err != nil {
+		return err
+	}
+
+	versions, err := b.Versions(context.Background(), key)
+	if err != nil {
+		return err
+	}
+	for i := provider.StateVersionsKept; i < len(versions); i++ {
+		os.Remove(b.versionPath(key, versions[i].ID))
+	}
+	return nil
+}
+
+// Versions lists the versions kept in the history directory, newest first
+func (b *FileBackend) Versions(ctx context.Context, key string) ([]provider.StateVersion, error) {
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(key), historyDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state history: %w", err)
+	}
+
+	prefix := filepath.Base(key) + "."
+	var versions []provider.StateVersion
+	for _, entry := range entries {
+		id, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || strings.Contains(id, ".") {
+			continue
+		}
+		nanos, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, provider.StateVersion{ID: id, Modified: time.Unix(0, nanos)})
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ID > versions[j].ID })
+	return versions, nil
+}
+
+// ReadVersion reads a version from the history directory
+func (b *FileBackend) ReadVersion(ctx context.Context, key, versionID string) ([]byte, error) {
+	data, err := os.ReadFile(b.versionPath(key, versionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state version %s: %w", versionID, err)
+	}
+	return data, nil
+}
+
+func (b *FileBackend) versionPath(key, versionID string) string {
+	return filepath.Join(filepath.Dir(key), historyDirName, filepath.Base(key)+"."+versionID)
+}
+
+// Location returns the state file path
+func (b *FileBackend) Location(key string) string {
+	return key
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory,
+// syncs it and renames it over path, so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Persist the rename; not every platform can sync a directory
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+// Snapshot describes a stored version of the state
+type Snapshot struct {
+	Serial    int64     `json:"serial"`
+	VersionID string    `json:"version_id"`
+	Modified  time.Time `json:"modified"`
+	Resources int       `json:"resources"`
+
+	// Error explains why a version could not be read, such as one encrypted
+	// with a key that is no longer configured
+	Error string `json:"error,omitempty"`
+}
+
+// History returns the versions of the state kept by its backend, newest
+// first. The local file backend and S3 keep the last
+// provider.StateVersionsKept versions.
+func (s *LocalState) History() ([]Snapshot, error) {
+	history, key, err := s.history()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	versions, err := history.Versions(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state history: %w", err)
+	}
+
+	snapshots := make([]Snapshot, 0, len(versions))
+	for _, version := range versions {
+		st, err := s.readVersion(history, key, version.ID)
+		if err != nil {
+			snapshots = append(snapshots, Snapshot{VersionID: version.ID, Modified: version.Modified, Error: err.Error()})
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{
+			Serial:    st.Serial,
+			VersionID: version.ID,
+			Modified:  version.Modified,
+			Resources: len(st.Resources),
+		})
+	}
+	return snapshots, nil
+}
+
+// AtSerial returns the state as it was when it was written with serial
+func (s *LocalState) AtSerial(serial int64) (*LocalState, error) {
+	snapshots, err := s.History()
+	if err != nil {
+		return nil, err
+	}
+
+	var readable []Snapshot
+	for _, snapshot := range snapshots {
+		if snapshot.Error != "" {
+			continue
+		}
+		if snapshot.Serial == serial {
+			history, key, _ := s.history()
+			return s.readVersion(history, key, snapshot.VersionID)
+		}
+		readable = append(readable, snapshot)
+	}
+
+	if len(readable) == 0 {
+		return nil, fmt.Errorf("no readable history is recorded for state %s", s.Location())
+	}
+	unreadable := ""
+	if len(readable) < len(snapshots) {
+		unreadable = fmt.Sprintf("; %d version(s) could not be read", len(snapshots)-len(readable))
+	}
+	return nil, fmt.Errorf("serial %d is not in the history of state %s (kept: %d to %d%s)",
+		serial, s.Location(), readable[len(readable)-1].Serial, readable[0].Serial, unreadable)
+}
+
+// Rollback restores the records of the state written with serial. The
+// restored state is written as a new version with the next serial, so the
+// rollback itself can be undone and saved plans made since are refused.
+func (s *LocalState) Rollback(serial int64) error {
+	target, err := s.AtSerial(serial)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func() {
+		s.Resources = target.Resources
+	})
+}
+
+// history returns the backend of the state as a provider.StateHistory
+func (s *LocalState) history() (provider.StateHistory, string, error) {
+	backend, key, err := s.target()
+	if err != nil {
+		return nil, "", err
+	}
+	history, ok := backend.(provider.StateHistory)
+	if !ok {
+		return nil, "", fmt.Errorf("the backend of state %s does not keep history", backend.Location(key))
+	}
+	return history, key, nil
+}
+
+// readVersion decodes a stored version of the state
+func (s *LocalState) readVersion(history provider.StateHistory, key, versionID string) (*LocalState, error) {
+	data, err := history.ReadVersion(context.Background(), key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if IsEncrypted(data) {
+		return nil, fmt.Errorf("state version %s is encrypted but no state key is configured", versionID)
+	}
+
+	var st LocalState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse state version %s: %w", versionID, err)
+	}
+	if st.Resources == nil {
+		st.Resources = []ResourceRecord{}
+	}
+	return &st, nil
+}
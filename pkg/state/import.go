@@ -0,0 +1,115 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+// ImportTypes are the resource types that can be brought under management
+// with Adopt
+var ImportTypes = []string{"ec2", "lambda", "rds", "s3", "vpc"}
+
+// Adopt looks up an existing resource through the provider's Adopt* methods
+// and returns a record for it. The record's name is the resource's name, or
+// its ID when it has none.
+func Adopt(ctx context.Context, p provider.Provider, resourceType, id string) (ResourceRecord, error) {
+	record := ResourceRecord{
+		Type:     resourceType,
+		Region:   p.Region(),
+		Provider: p.Name(),
+	}
+
+	var created time.Time
+	switch resourceType {
+	case "s3":
+		bucket, err := p.Storage().AdoptBucket(ctx, id)
+		if err != nil {
+			return ResourceRecord{}, fmt.Errorf("failed to adopt bucket %s: %w", id, err)
+		}
+		record.ID, record.Name, record.Tags, created = bucket.Name, bucket.Name, bucket.Tags, bucket.CreatedAt
+
+	case "ec2":
+		instance, err := p.Compute().AdoptInstance(ctx, id)
+		if err != nil {
+			return ResourceRecord{}, fmt.Errorf("failed to adopt instance %s: %w", id, err)
+		}
+		record.ID, record.Name, record.Tags, created = instance.ID, instance.Name, instance.Tags, instance.CreatedAt
+
+	case "rds":
+		database, err := p.Database().AdoptDatabase(ctx, id)
+		if err != nil {
+			return ResourceRecord{}, fmt.Errorf("failed to adopt database %s: %w", id, err)
+		}
+		record.ID, record.Name, record.Tags, created = database.ID, database.Name, database.Tags, database.CreatedAt
+
+	case "lambda":
+		function, err := p.Serverless().AdoptFunction(ctx, id)
+		if err != nil {
+			return ResourceRecord{}, fmt.Errorf("failed to adopt function %s: %w", id, err)
+		}
+		// Functions are recorded by name
+		record.ID, record.Name, record.Tags, created = function.Name, function.Name, function.Tags, function.CreatedAt
+
+	case "vpc":
+		network, err := p.Network().AdoptNetwork(ctx, id)
+		if err != nil {
+			return ResourceRecord{}, fmt.Errorf("failed to adopt network %s: %w", id, err)
+		}
+		record.ID, record.Name, record.Tags, created = network.ID, network.Name, network.Tags, network.CreatedAt
+
+	default:
+		return ResourceRecord{}, fmt.Errorf("cannot import resources of type %q (supported: %s)", resourceType, strings.Join(ImportTypes, ", "))
+	}
+
+	if record.ID == "" {
+		record.ID = id
+	}
+	if record.Name == "" {
+		record.Name = record.ID
+	}
+	record.CreatedAt = created
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	return record, nil
+}
+
+// ImportResource records an adopted resource, refusing resources that are
+// already recorded
+func (s *LocalState) ImportResource(record ResourceRecord) error {
+	var existing *ResourceRecord
+	find := func() {
+		for i, resource := range s.Resources {
+			if resource.ID == record.ID && resource.Type == record.Type {
+				existing = &s.Resources[i]
+				return
+			}
+		}
+	}
+
+	// Check first so that a refused import does not write a new version
+	if err := s.reload(); err != nil {
+		return err
+	}
+	find()
+
+	if existing == nil {
+		err := s.update(func() {
+			if find(); existing == nil {
+				s.Resources = append(s.Resources, record)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if existing != nil {
+		return fmt.Errorf("%s %s is already recorded in state as %s", record.Type, record.ID, existing.Name)
+	}
+	return nil
+}
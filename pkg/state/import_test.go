@@ -0,0 +1,44 @@
+package state
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+func TestAdoptAndImport(t *testing.T) {
+	ctx := context.Background()
+	p := provider.NewMockProvider("mock", "us-east-1")
+	st := NewLocalState(filepath.Join(t.TempDir(), "state.json"))
+
+	for _, resourceType := range ImportTypes {
+		record, err := Adopt(ctx, p, resourceType, "existing-"+resourceType)
+		if err != nil {
+			t.Fatalf("Adopt(%s) error = %v", resourceType, err)
+		}
+		if record.ID == "" || record.Name == "" || record.Type != resourceType || record.Provider != "mock" {
+			t.Errorf("Adopt(%s) = %+v", resourceType, record)
+		}
+		if err := st.ImportResource(record); err != nil {
+			t.Fatalf("ImportResource(%s) error = %v", resourceType, err)
+		}
+	}
+
+	if _, err := Adopt(ctx, p, "subnet", "subnet-1"); err == nil {
+		t.Error("Adopt() expected error for an unsupported type")
+	}
+
+	record, _ := Adopt(ctx, p, "s3", "existing-s3")
+	serial := st.Serial
+	if err := st.ImportResource(record); err == nil {
+		t.Error("ImportResource() expected error for a recorded resource")
+	}
+	if st.Serial != serial {
+		t.Errorf("refused import wrote serial %d, want %d", st.Serial, serial)
+	}
+	if len(st.Resources) != len(ImportTypes) {
+		t.Errorf("state has %d resources, want %d", len(st.Resources), len(ImportTypes))
+	}
+}
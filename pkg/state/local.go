@@ -1,16 +1,33 @@
 package state
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
+
+	"github.com/javanhut/genesys/pkg/provider"
 )
 
 // LocalState represents the local state tracking for genesys
 type LocalState struct {
+	// Serial is incremented every time the state is written, so a saved plan
+	// can tell whether the state changed since it was made
+	Serial    int64            `json:"serial"`
 	Resources []ResourceRecord `json:"resources"`
+
+	// Migrated is the number of records copied from the old global state
+	// file when the project state was first created
+	Migrated int `json:"-"`
+
+	// backend and key locate the stored state; a nil backend means the
+	// state file of the current project's workspace
+	backend   provider.StateBackend
+	key       string
+	workspace string
 }
 
 // ResourceRecord represents a created resource
@@ -23,69 +40,304 @@ type ResourceRecord struct {
 	ConfigFile string            `json:"config_file"`
 	CreatedAt  time.Time         `json:"created_at"`
 	Tags       map[string]string `json:"tags,omitempty"`
+
+	// Attributes are the settings the resource was last applied with
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// Outputs are values reported by the provider, such as a bucket ARN,
+	// an instance's public IP or a database endpoint
+	Outputs map[string]string `json:"outputs,omitempty"`
 }
 
-const stateFileName = ".genesys-state.json"
+// writeMu serializes state writes within this process; the state lock only
+// keeps other processes out
+var writeMu sync.Mutex
+
+const (
+	legacyStateFileName = ".genesys-state.json"
+	backupSuffix        = ".backup"
+	lockSuffix          = ".lock"
+)
 
-// getStateFilePath returns the path to the state file
-func getStateFilePath() string {
-	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, stateFileName)
+// NewLocalState creates an empty state stored in the file at path
+func NewLocalState(path string) *LocalState {
+	return &LocalState{Resources: []ResourceRecord{}, backend: localBackend(), key: path}
 }
 
-// LoadLocalState loads the local state from disk
+// LoadLocalState loads the state of the current workspace of the project
+// containing the working directory
 func LoadLocalState() (*LocalState, error) {
-	statePath := getStateFilePath()
+	return LoadProjectState(".")
+}
 
-	// If file doesn't exist, return empty state
-	if _, err := os.Stat(statePath); os.IsNotExist(err) {
-		return &LocalState{Resources: []ResourceRecord{}}, nil
+// LoadProjectState loads the state of the current workspace of the project
+// containing start, a configuration file or directory, from the project's
+// .genesys directory. The first time the default workspace is used, the
+// records of the old global state file that belong to the project are
+// copied into it.
+func LoadProjectState(start string) (*LocalState, error) {
+	projectDir, workspace, err := projectWorkspace(start)
+	if err != nil {
+		return nil, err
 	}
 
-	data, err := os.ReadFile(statePath)
+	statePath := StatePath(projectDir, workspace)
+	st, err := LoadState(statePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read state file: %w", err)
+		return nil, err
+	}
+	st.workspace = workspace
+
+	if workspace == DefaultWorkspace {
+		if _, err := os.Stat(statePath); os.IsNotExist(err) {
+			if st.Migrated, err = migrateLegacyState(projectDir, st); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return st, nil
+}
+
+// LoadState loads the state stored in a specific file
+func LoadState(path string) (*LocalState, error) {
+	return Open(localBackend(), path)
+}
+
+// localBackend returns the backend for local state files, encrypting them
+// with Keys when any are configured
+func localBackend() provider.StateBackend {
+	return WithEncryption(fileBackend, Keys)
+}
+
+// Open loads the state stored under key in a backend, initializing the
+// backend first
+func Open(backend provider.StateBackend, key string) (*LocalState, error) {
+	if err := backend.Init(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to initialize state backend: %w", err)
+	}
+
+	st, err := readState(backend, key)
+	if err != nil {
+		return nil, err
+	}
+	st.backend = backend
+	st.key = key
+	return st, nil
+}
+
+// Location describes where the state is stored, such as a file path or an
+// S3 URL
+func (s *LocalState) Location() string {
+	backend, key, err := s.target()
+	if err != nil {
+		return ""
+	}
+	return backend.Location(key)
+}
+
+// Workspace returns the workspace the state belongs to, or an empty string
+// for a state loaded from a specific key
+func (s *LocalState) Workspace() string {
+	return s.workspace
+}
+
+// target returns the backend and key of the state, resolving the current
+// project's workspace file for states that were not loaded from a backend
+func (s *LocalState) target() (provider.StateBackend, string, error) {
+	if s.backend == nil {
+		projectDir, err := FindProjectDir(".")
+		if err != nil {
+			projectDir = "."
+		}
+		workspace, err := CurrentWorkspace(projectDir)
+		if err != nil {
+			return nil, "", err
+		}
+		s.backend = localBackend()
+		s.key = StatePath(projectDir, workspace)
+	}
+	return s.backend, s.key, nil
+}
+
+// readState reads the state stored under key, returning an empty state when
+// there is none yet
+func readState(backend provider.StateBackend, key string) (*LocalState, error) {
+	data, err := backend.Read(context.Background(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s: %w", backend.Location(key), err)
+	}
+	if data == nil {
+		return &LocalState{Resources: []ResourceRecord{}}, nil
+	}
+	if env, ok := parseEnvelope(data); ok {
+		// Encrypted state is only seen here when no key is configured
+		return nil, noKeyError(backend.Location(key), env.KeyID)
 	}
 
 	var state LocalState
 	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, fmt.Errorf("failed to parse state file: %w", err)
+		return nil, fmt.Errorf("failed to parse state %s: %w", backend.Location(key), err)
+	}
+	if state.Resources == nil {
+		state.Resources = []ResourceRecord{}
 	}
 
 	return &state, nil
 }
 
-// SaveLocalState saves the local state to disk
+// readStateFile reads a state file, returning an empty state when it does
+// not exist yet
+func readStateFile(statePath string) (*LocalState, error) {
+	return readState(localBackend(), statePath)
+}
+
+// reload replaces the records with the latest stored version
+func (s *LocalState) reload() error {
+	backend, key, err := s.target()
+	if err != nil {
+		return err
+	}
+	current, err := readState(backend, key)
+	if err != nil {
+		return err
+	}
+	s.Serial = current.Serial
+	s.Resources = current.Resources
+	return nil
+}
+
+// SaveLocalState saves the state while holding the state lock. The file
+// backend keeps the previous version as a backup.
 func (s *LocalState) SaveLocalState() error {
-	statePath := getStateFilePath()
+	writeMu.Lock()
+	defer writeMu.Unlock()
 
+	backend, key, err := s.target()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockState(backend, key, LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.write()
+}
+
+// update applies a change to the latest stored state while holding the
+// state lock, so that records written by other processes since the state
+// was loaded are not lost
+func (s *LocalState) update(change func()) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	backend, key, err := s.target()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockState(backend, key, LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	change()
+	return s.write()
+}
+
+// write stores the state with the next serial. The caller must hold the
+// state lock.
+func (s *LocalState) write() error {
+	backend, key, err := s.target()
+	if err != nil {
+		return err
+	}
+
+	s.Serial++
 	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	if err := os.WriteFile(statePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write state file: %w", err)
+	if err := backend.Write(context.Background(), key, data); err != nil {
+		return fmt.Errorf("failed to write state %s: %w", backend.Location(key), err)
 	}
-
 	return nil
 }
 
+// ConfigPath returns the form configuration files are recorded in: a clean
+// absolute path, so that same-named files in different directories are told
+// apart. Relative paths are resolved against the working directory.
+func ConfigPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
 // AddResource adds a new resource to the state
 func (s *LocalState) AddResource(record ResourceRecord) error {
-	s.Resources = append(s.Resources, record)
-	return s.SaveLocalState()
+	record.ConfigFile = ConfigPath(record.ConfigFile)
+	return s.update(func() {
+		s.Resources = append(s.Resources, record)
+	})
 }
 
 // RemoveResource removes a resource from the state by ID
 func (s *LocalState) RemoveResource(id string) error {
-	for i, resource := range s.Resources {
+	return s.update(func() {
+		for i, resource := range s.Resources {
+			if resource.ID == id {
+				s.Resources = append(s.Resources[:i], s.Resources[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+// ReplaceResource replaces the resource with the given ID, adding the record
+// when no such resource exists
+func (s *LocalState) ReplaceResource(id string, record ResourceRecord) error {
+	record.ConfigFile = ConfigPath(record.ConfigFile)
+	return s.update(func() {
+		for i, resource := range s.Resources {
+			if resource.ID == id {
+				s.Resources[i] = record
+				return
+			}
+		}
+		s.Resources = append(s.Resources, record)
+	})
+}
+
+// FindResource finds the resource of the given type and name
+func (s *LocalState) FindResource(resourceType, name string) (ResourceRecord, bool) {
+	for _, resource := range s.Resources {
+		if resource.Type == resourceType && resource.Name == name {
+			return resource, true
+		}
+	}
+	return ResourceRecord{}, false
+}
+
+// FindResourceByID finds the resource with the given ID
+func (s *LocalState) FindResourceByID(id string) (ResourceRecord, bool) {
+	for _, resource := range s.Resources {
 		if resource.ID == id {
-			s.Resources = append(s.Resources[:i], s.Resources[i+1:]...)
-			break
+			return resource, true
 		}
 	}
-	return s.SaveLocalState()
+	return ResourceRecord{}, false
 }
 
 // FindResourcesByName finds all resources with the given name
@@ -99,38 +351,70 @@ func (s *LocalState) FindResourcesByName(name string) []ResourceRecord {
 	return found
 }
 
-// FindResourcesByConfigFile finds all resources created from a config file
+// FindResourcesByConfigFile finds all resources created from a config file,
+// comparing absolute paths
 func (s *LocalState) FindResourcesByConfigFile(configFile string) []ResourceRecord {
+	configFile = ConfigPath(configFile)
 	var found []ResourceRecord
 	for _, resource := range s.Resources {
-		if resource.ConfigFile == configFile {
+		if ConfigPath(resource.ConfigFile) == configFile {
 			found = append(found, resource)
 		}
 	}
 	return found
 }
 
-// RefreshLocalState reloads the state from disk
+// Outputs returns the outputs of the recorded resources, keyed by resource
+// name. Resources sharing a name with a resource of another type are keyed
+// as <type>/<name>.
+func (s *LocalState) Outputs() map[string]map[string]string {
+	names := make(map[string]int)
+	for _, resource := range s.Resources {
+		names[resource.Name]++
+	}
+
+	outputs := make(map[string]map[string]string)
+	for _, resource := range s.Resources {
+		if len(resource.Outputs) == 0 {
+			continue
+		}
+		key := resource.Name
+		if names[resource.Name] > 1 {
+			key = resource.Type + "/" + resource.Name
+		}
+		outputs[key] = resource.Outputs
+	}
+	return outputs
+}
+
+// RefreshLocalState reloads the state of the current project
 func RefreshLocalState() (*LocalState, error) {
 	return LoadLocalState()
 }
 
-// SyncWithRemote syncs local state with remote state backend (placeholder for future implementation)
+// SyncWithRemote reloads the records from the state backend
 func (s *LocalState) SyncWithRemote() error {
-	// TODO: Implement remote state synchronization
-	// For now, just reload from disk
-	newState, err := LoadLocalState()
-	if err != nil {
-		return fmt.Errorf("failed to refresh local state: %w", err)
+	if err := s.reload(); err != nil {
+		return fmt.Errorf("failed to refresh state: %w", err)
 	}
-
-	s.Resources = newState.Resources
 	return nil
 }
 
-// ValidateResources checks if tracked resources still exist
-func (s *LocalState) ValidateResources() ([]ResourceRecord, error) {
-	// TODO: Implement actual resource validation against cloud providers
-	// For now, return all resources as valid
-	return s.Resources, nil
+// ResourceChecker reports whether a tracked resource still exists. The drift
+// package compares the resources with the provider in more detail.
+type ResourceChecker func(record ResourceRecord) (bool, error)
+
+// ValidateResources returns the tracked resources that still exist
+func (s *LocalState) ValidateResources(exists ResourceChecker) ([]ResourceRecord, error) {
+	var valid []ResourceRecord
+	for _, record := range s.Resources {
+		ok, err := exists(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s %s: %w", record.Type, record.Name, err)
+		}
+		if ok {
+			valid = append(valid, record)
+		}
+	}
+	return valid, nil
 }
@@ -0,0 +1,324 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+func TestSaveKeepsBackup(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	st := NewLocalState(statePath)
+	if err := st.AddResource(ResourceRecord{ID: "one", Name: "one", Type: "s3"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+	if err := st.AddResource(ResourceRecord{ID: "two", Name: "two", Type: "s3"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+
+	backup, err := readStateFile(statePath + backupSuffix)
+	if err != nil {
+		t.Fatalf("reading backup: %v", err)
+	}
+	if len(backup.Resources) != 1 || backup.Serial != 1 {
+		t.Errorf("backup has %d resources at serial %d, want 1 at serial 1", len(backup.Resources), backup.Serial)
+	}
+
+	if leftovers, _ := filepath.Glob(statePath + ".tmp-*"); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestConcurrentUpdatesAreNotLost(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	// Each writer loads its own copy, as separate processes would
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		st, err := LoadState(statePath)
+		if err != nil {
+			t.Fatalf("LoadState() error = %v", err)
+		}
+
+		wg.Add(1)
+		go func(i int, st *LocalState) {
+			defer wg.Done()
+			id := fmt.Sprintf("bucket-%d", i)
+			errs <- st.AddResource(ResourceRecord{ID: id, Name: id, Type: "s3"})
+		}(i, st)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AddResource() error = %v", err)
+		}
+	}
+
+	st, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if len(st.Resources) != 10 {
+		t.Errorf("state has %d resources, want 10", len(st.Resources))
+	}
+	if st.Serial != 10 {
+		t.Errorf("Serial = %d, want 10", st.Serial)
+	}
+}
+
+func TestLockHeldByAnotherProcess(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	// A separately opened lock file stands in for another process
+	held, err := acquireLockFile(statePath, 0, newLockInfo())
+	if err != nil {
+		t.Fatalf("acquireLockFile() error = %v", err)
+	}
+
+	_, err = lockState(fileBackend, statePath, 0)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("lockState() error = %v, want a LockedError", err)
+	}
+	if locked.Info == nil || locked.Info.PID != os.Getpid() {
+		t.Errorf("LockedError.Info = %+v, want the holder's PID", locked.Info)
+	}
+
+	unlockFile(held)
+	held.Close()
+
+	unlock, err := lockState(fileBackend, statePath, 0)
+	if err != nil {
+		t.Fatalf("lockState() after release error = %v", err)
+	}
+	unlock()
+}
+
+func TestWaitingForALockDoesNotBlockOtherStates(t *testing.T) {
+	dir := t.TempDir()
+	busyPath := filepath.Join(dir, "busy", "state.json")
+	freePath := filepath.Join(dir, "free", "state.json")
+
+	held, err := acquireLockFile(busyPath, 0, newLockInfo())
+	if err != nil {
+		t.Fatalf("acquireLockFile() error = %v", err)
+	}
+	defer func() {
+		unlockFile(held)
+		held.Close()
+	}()
+
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := lockState(fileBackend, busyPath, time.Second)
+		waiting <- err
+	}()
+	time.Sleep(2 * lockRetryInterval)
+
+	started := time.Now()
+	unlock, err := lockState(fileBackend, freePath, 0)
+	if err != nil {
+		t.Fatalf("lockState() error = %v", err)
+	}
+	unlock()
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("locking another state took %s while a lock was awaited", elapsed)
+	}
+
+	var locked *LockedError
+	if err := <-waiting; !errors.As(err, &locked) {
+		t.Errorf("lockState() on the busy state error = %v, want a LockedError", err)
+	}
+}
+
+func TestForceUnlockStaleLock(t *testing.T) {
+	timeout := LockTimeout
+	LockTimeout = 0
+	defer func() { LockTimeout = timeout }()
+
+	backend := provider.NewMockStateBackend()
+	st, err := Open(backend, "app/state.json")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	// A run that crashed hours ago left its lock behind
+	abandoned := &LockInfo{ID: "abc123", Who: "ci@runner", Host: "runner", PID: 42, Acquired: time.Now().Add(-2 * StaleLockAge)}
+	if err := backend.Lock(context.Background(), "app/state.json", abandoned); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	err = st.AddResource(ResourceRecord{ID: "b", Name: "b", Type: "s3"})
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("AddResource() error = %v, want a LockedError", err)
+	}
+	if !strings.Contains(err.Error(), "force-unlock abc123") {
+		t.Errorf("error does not suggest force-unlock: %v", err)
+	}
+
+	if err := st.ForceUnlock("wrong"); err == nil {
+		t.Error("ForceUnlock() expected error for another lock ID")
+	}
+	if err := st.ForceUnlock("abc123"); err != nil {
+		t.Fatalf("ForceUnlock() error = %v", err)
+	}
+	if holder, _ := st.LockHolder(); holder != nil {
+		t.Errorf("LockHolder() = %+v after ForceUnlock()", holder)
+	}
+	if err := st.AddResource(ResourceRecord{ID: "b", Name: "b", Type: "s3"}); err != nil {
+		t.Errorf("AddResource() after ForceUnlock() error = %v", err)
+	}
+}
+
+func TestStaleReason(t *testing.T) {
+	host, _ := os.Hostname()
+
+	tests := []struct {
+		name  string
+		info  *LockInfo
+		stale bool
+	}{
+		{"no lock", nil, false},
+		{"fresh lock of this process", &LockInfo{ID: "a", Host: host, PID: os.Getpid(), Acquired: time.Now()}, false},
+		{"fresh lock on another host", &LockInfo{ID: "a", Host: "elsewhere", PID: 1, Acquired: time.Now()}, false},
+		{"old lock", &LockInfo{ID: "a", Host: "elsewhere", PID: 1, Acquired: time.Now().Add(-StaleLockAge - time.Hour)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StaleReason(tt.info); (got != "") != tt.stale {
+				t.Errorf("StaleReason() = %q, want stale = %v", got, tt.stale)
+			}
+		})
+	}
+}
+
+func TestHistoryAndRollback(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	st := NewLocalState(statePath)
+	for _, id := range []string{"one", "two", "three"} {
+		if err := st.AddResource(ResourceRecord{ID: id, Name: id, Type: "s3"}); err != nil {
+			t.Fatalf("AddResource() error = %v", err)
+		}
+	}
+	if err := st.RemoveResource("one"); err != nil {
+		t.Fatalf("RemoveResource() error = %v", err)
+	}
+
+	history, err := st.History()
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(history) != 4 || history[0].Serial != 4 || history[3].Serial != 1 {
+		t.Fatalf("History() = %+v, want serials 4 to 1", history)
+	}
+
+	old, err := st.AtSerial(3)
+	if err != nil {
+		t.Fatalf("AtSerial() error = %v", err)
+	}
+	if len(old.Resources) != 3 {
+		t.Errorf("AtSerial(3) has %d resources, want 3", len(old.Resources))
+	}
+	if _, err := st.AtSerial(9); err == nil {
+		t.Error("AtSerial() expected error for an unknown serial")
+	}
+
+	// Rolling back writes the old records as a new version
+	if err := st.Rollback(3); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	reloaded, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if reloaded.Serial != 5 || len(reloaded.Resources) != 3 {
+		t.Errorf("after Rollback() state has %d resources at serial %d, want 3 at serial 5", len(reloaded.Resources), reloaded.Serial)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	st := NewLocalState(statePath)
+	for i := 0; i < provider.StateVersionsKept+5; i++ {
+		if err := st.SaveLocalState(); err != nil {
+			t.Fatalf("SaveLocalState() error = %v", err)
+		}
+	}
+
+	history, err := st.History()
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(history) != provider.StateVersionsKept || history[0].Serial != int64(provider.StateVersionsKept+5) {
+		t.Errorf("History() kept %d versions, newest serial %d", len(history), history[0].Serial)
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	st := NewLocalState(filepath.Join(t.TempDir(), "state.json"))
+	st.Resources = []ResourceRecord{
+		{ID: "web", Name: "web", Type: "ec2", Outputs: map[string]string{"public_ip": "203.0.113.10"}},
+		{ID: "app", Name: "app", Type: "s3", Outputs: map[string]string{"bucket_arn": "arn:aws:s3:::app"}},
+		{ID: "vpc-1", Name: "app", Type: "vpc", Outputs: map[string]string{"network_id": "vpc-1"}},
+		{ID: "sg-1", Name: "sg", Type: "security-group"},
+	}
+
+	outputs := st.Outputs()
+	if len(outputs) != 3 {
+		t.Errorf("Outputs() has %d resources, want 3: %v", len(outputs), outputs)
+	}
+	if outputs["web"]["public_ip"] != "203.0.113.10" {
+		t.Errorf("web outputs = %v", outputs["web"])
+	}
+	// Names shared across types are qualified by type
+	if outputs["s3/app"]["bucket_arn"] == "" || outputs["vpc/app"]["network_id"] != "vpc-1" {
+		t.Errorf("Outputs() = %v, want s3/app and vpc/app", outputs)
+	}
+}
+
+func TestFindResourcesByConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	projA := filepath.Join(dir, "projA", "app.yaml")
+	projB := filepath.Join(dir, "projB", "app.yaml")
+
+	st := NewLocalState(filepath.Join(dir, "state.json"))
+	if err := st.AddResource(ResourceRecord{ID: "a", Name: "a", Type: "s3", ConfigFile: projA}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+	if err := st.AddResource(ResourceRecord{ID: "b", Name: "b", Type: "s3", ConfigFile: projB}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+
+	// Same-named files in different directories are different configurations
+	if err := os.MkdirAll(filepath.Dir(projB), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Dir(projB))
+	found := st.FindResourcesByConfigFile("app.yaml")
+	if len(found) != 1 || found[0].ID != "b" {
+		t.Errorf("FindResourcesByConfigFile(app.yaml) = %+v, want only b", found)
+	}
+
+	if err := st.AddResource(ResourceRecord{ID: "c", Name: "c", Type: "s3", ConfigFile: "app.yaml"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+	if got, _ := st.FindResource("s3", "c"); got.ConfigFile != projB {
+		t.Errorf("recorded ConfigFile = %q, want %q", got.ConfigFile, projB)
+	}
+}
@@ -0,0 +1,318 @@
+package state
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+// DefaultLockTimeout is how long state writes wait for the state lock by default
+const DefaultLockTimeout = 10 * time.Second
+
+// LockTimeout is how long to wait for another process to release the state
+// lock before giving up. Zero fails immediately when the state is locked.
+var LockTimeout = DefaultLockTimeout
+
+// lockRetryInterval is how often a locked state is checked again
+const lockRetryInterval = 100 * time.Millisecond
+
+// errWouldBlock is returned by tryLockFile when another process holds the lock
+var errWouldBlock = errors.New("lock is held by another process")
+
+// StaleLockAge is how long a lock can be held before it is reported as
+// possibly stale
+const StaleLockAge = 6 * time.Hour
+
+// Operation names the command taking the state lock, recorded in the lock
+// so other users can see what holds it
+var Operation string
+
+// LockInfo describes the process holding the state lock
+type LockInfo = provider.LockInfo
+
+// LockedError is returned when the state stays locked by another process for
+// longer than the lock timeout
+type LockedError struct {
+	Path    string
+	Info    *LockInfo
+	Timeout time.Duration
+
+	// Forceable is set for backends whose locks outlive their process and
+	// can be released with force-unlock
+	Forceable bool
+}
+
+func (e *LockedError) Error() string {
+	holder := "another genesys process"
+	if e.Info != nil {
+		holder = fmt.Sprintf("%s (genesys process %d on %s", e.Info.Who, e.Info.PID, e.Info.Host)
+		if e.Info.Operation != "" {
+			holder += fmt.Sprintf(" running '%s'", e.Info.Operation)
+		}
+		holder += fmt.Sprintf(", lock ID %s, since %s)", e.Info.ID, e.Info.Acquired.Format("2006-01-02 15:04:05"))
+	}
+	msg := fmt.Sprintf("state %s is locked by %s; waited %s, retry when it finishes or raise --lock-timeout",
+		e.Path, holder, e.Timeout)
+
+	if reason := StaleReason(e.Info); reason != "" && e.Forceable {
+		msg += fmt.Sprintf("; the lock looks stale (%s), remove it with 'genesys state force-unlock %s' if no one is using the state",
+			reason, e.Info.ID)
+	}
+	return msg
+}
+
+// StaleReason explains why a lock looks abandoned: its process is no longer
+// running on this host, or it has been held for longer than StaleLockAge. It
+// returns an empty string for locks that look alive.
+func StaleReason(info *LockInfo) string {
+	if info == nil || info.ID == "" {
+		return ""
+	}
+
+	host, _ := os.Hostname()
+	if info.Host == host && info.PID != os.Getpid() && !processRunning(info.PID) {
+		return fmt.Sprintf("process %d is no longer running", info.PID)
+	}
+	if age := time.Since(info.Acquired); age > StaleLockAge {
+		return fmt.Sprintf("held for %s", age.Round(time.Minute))
+	}
+	return ""
+}
+
+// newLockInfo describes this process as the holder of a new lock
+func newLockInfo() *LockInfo {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	host, _ := os.Hostname()
+	who := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		who = u.Username
+	}
+
+	return &LockInfo{
+		ID:        hex.EncodeToString(id),
+		Operation: Operation,
+		Who:       who + "@" + host,
+		Host:      host,
+		PID:       os.Getpid(),
+		Acquired:  time.Now(),
+	}
+}
+
+// heldLocks tracks the state locks held by this process, by state location,
+// so that nested callers, such as a command holding the lock while the
+// executor records resources, do not wait for themselves. The mutex only
+// guards the map and the depths; backend locks are taken without it, so
+// waiting for one state does not block the others.
+var heldLocks = struct {
+	sync.Mutex
+	locks map[string]*heldLock
+}{locks: make(map[string]*heldLock)}
+
+type heldLock struct {
+	backend provider.StateBackend
+	key     string
+	id      string
+	depth   int
+
+	// acquired is closed once the backend lock is taken, or failed with err
+	acquired chan struct{}
+	err      error
+}
+
+// Lock acquires the lock on the state, waiting up to LockTimeout for another
+// process to release it, and reloads the state so the caller sees the latest
+// version. The lock is shared by everything in this process, so a command
+// can hold it for a whole run while the records are written; call the
+// returned function to release it.
+func (s *LocalState) Lock() (func(), error) {
+	backend, key, err := s.target()
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockState(backend, key, LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reload(); err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return unlock, nil
+}
+
+func lockState(backend provider.StateBackend, key string, timeout time.Duration) (func(), error) {
+	location := backend.Location(key)
+
+	heldLocks.Lock()
+	held, ok := heldLocks.locks[location]
+	if !ok {
+		held = &heldLock{backend: backend, key: key, acquired: make(chan struct{})}
+		heldLocks.locks[location] = held
+	}
+	held.depth++
+	heldLocks.Unlock()
+
+	if ok {
+		// Another caller in this process holds the lock or is taking it
+		<-held.acquired
+	} else {
+		held.id, held.err = acquireState(backend, key, location, timeout)
+		if held.err != nil {
+			heldLocks.Lock()
+			delete(heldLocks.locks, location)
+			heldLocks.Unlock()
+		}
+		close(held.acquired)
+	}
+	if held.err != nil {
+		return nil, held.err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { releaseState(location) })
+	}, nil
+}
+
+// acquireState takes the backend lock on a state, waiting up to timeout for
+// another process to release it, and returns the ID it was taken under
+func acquireState(backend provider.StateBackend, key, location string, timeout time.Duration) (string, error) {
+	info := newLockInfo()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := backend.Lock(ctx, key, info)
+	var lockHeld *provider.LockHeldError
+	if errors.As(err, &lockHeld) {
+		local := isFileBackend(backend)
+		return "", &LockedError{Path: location, Info: lockHeld.Info, Timeout: timeout, Forceable: !local}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to lock state %s: %w", location, err)
+	}
+	return info.ID, nil
+}
+
+// isFileBackend reports whether backend stores local files, whose locks are
+// released by the operating system rather than forced
+func isFileBackend(backend provider.StateBackend) bool {
+	if encrypted, ok := backend.(*EncryptedBackend); ok {
+		backend = encrypted.Backend
+	}
+	_, ok := backend.(*FileBackend)
+	return ok
+}
+
+func releaseState(location string) {
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+
+	held, ok := heldLocks.locks[location]
+	if !ok {
+		return
+	}
+	held.depth--
+	if held.depth > 0 {
+		return
+	}
+
+	if err := held.backend.Unlock(context.Background(), held.key, held.id); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to release the lock on state %s: %v\n", location, err)
+	}
+	delete(heldLocks.locks, location)
+}
+
+// ForceUnlock releases the lock on the state if it is held under id,
+// whichever process took it. Use it only for locks left behind by a run that
+// no longer exists.
+func (s *LocalState) ForceUnlock(id string) error {
+	backend, key, err := s.target()
+	if err != nil {
+		return err
+	}
+	if err := backend.Unlock(context.Background(), key, id); err != nil {
+		return fmt.Errorf("failed to unlock state %s: %w", backend.Location(key), err)
+	}
+	return nil
+}
+
+// LockHolder returns the holder of the lock on the state, or nil when the
+// state is not locked
+func (s *LocalState) LockHolder() (*LockInfo, error) {
+	backend, key, err := s.target()
+	if err != nil {
+		return nil, err
+	}
+	info, err := backend.ReadLock(context.Background(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the lock on state %s: %w", backend.Location(key), err)
+	}
+	return info, nil
+}
+
+// acquireLockFile opens the lock file next to the state file and locks it,
+// retrying until timeout. The holder's details are written to the lock file
+// for the error other processes report.
+func acquireLockFile(statePath string, timeout time.Duration, info *LockInfo) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	path := statePath + lockSuffix
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := tryLockFile(file)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errWouldBlock) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock state: %w", err)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, &provider.LockHeldError{Info: readLockInfo(path)}
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	data, _ := json.Marshal(info)
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt(data, 0)
+	}
+
+	return file, nil
+}
+
+// readLockInfo reads the details of the process holding a lock, if recorded
+func readLockInfo(path string) *LockInfo {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil
+	}
+	return &info
+}
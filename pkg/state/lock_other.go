@@ -0,0 +1,21 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package state
+
+import "os"
+
+// tryLockFile does nothing on platforms without file locking; writes are
+// still atomic but concurrent runs are not kept apart
+func tryLockFile(file *os.File) error {
+	return nil
+}
+
+// unlockFile does nothing on platforms without file locking
+func unlockFile(file *os.File) error {
+	return nil
+}
+
+// processRunning assumes processes are running where they cannot be checked
+func processRunning(pid int) bool {
+	return true
+}
@@ -0,0 +1,353 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+const (
+	// ProjectDirName is the directory holding a project's state
+	ProjectDirName = ".genesys"
+
+	// DefaultWorkspace is the workspace used until another one is selected
+	DefaultWorkspace = "default"
+
+	// WorkspaceEnvVar overrides the selected workspace
+	WorkspaceEnvVar = "GENESYS_WORKSPACE"
+
+	projectStateFile  = "state.json"
+	workspaceFile     = "workspace"
+	workspacesDirName = "workspaces"
+	settingsFile      = "settings.yaml"
+)
+
+var workspaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// projectMarkers are the entries of a .genesys directory that make its
+// parent a project. A .genesys directory holding anything else, such as the
+// credentials and policy file in the home directory, does not.
+var projectMarkers = []string{projectStateFile, workspaceFile, workspacesDirName, settingsFile}
+
+// vcsDirs mark the root of a version-controlled tree
+var vcsDirs = []string{".git", ".hg", ".svn"}
+
+// FindProjectDir walks up from start, a configuration file or directory,
+// looking for a directory whose .genesys holds state, workspaces or project
+// settings. The walk
+// stops below the home directory and at the root of a version-controlled
+// tree. When there is no project, the directory of start becomes the project
+// directory.
+func FindProjectDir(start string) (string, error) {
+	abs, err := filepath.Abs(start)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", start, err)
+	}
+
+	dir := abs
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		dir = filepath.Dir(abs)
+	}
+
+	home, err := os.UserHomeDir()
+	if err == nil {
+		home = filepath.Clean(home)
+	}
+
+	for current := dir; ; {
+		if current == home && current != dir {
+			return dir, nil
+		}
+		if isProjectDir(current) {
+			return current, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current || hasEntry(current, vcsDirs) {
+			return dir, nil
+		}
+		current = parent
+	}
+}
+
+// isProjectDir reports whether dir holds the state, workspaces or settings of
+// a project
+func isProjectDir(dir string) bool {
+	return hasEntry(filepath.Join(dir, ProjectDirName), projectMarkers)
+}
+
+// hasEntry reports whether dir contains any of names
+func hasEntry(dir string, names []string) bool {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentWorkspace returns the workspace selected for a project: the
+// GENESYS_WORKSPACE environment variable, the workspace chosen with
+// SelectWorkspace, or the default workspace. A selected name that is not a
+// valid workspace name is an error.
+func CurrentWorkspace(projectDir string) (string, error) {
+	if name := os.Getenv(WorkspaceEnvVar); name != "" {
+		if err := validateWorkspaceName(name); err != nil {
+			return "", fmt.Errorf("%s: %w", WorkspaceEnvVar, err)
+		}
+		return name, nil
+	}
+
+	path := filepath.Join(projectDir, ProjectDirName, workspaceFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DefaultWorkspace, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the selected workspace: %w", err)
+	}
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return DefaultWorkspace, nil
+	}
+	if err := validateWorkspaceName(name); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return name, nil
+}
+
+// SettingsPath returns the file holding the settings shared by every
+// configuration of a project, such as where its state is stored
+func SettingsPath(projectDir string) string {
+	return filepath.Join(projectDir, ProjectDirName, settingsFile)
+}
+
+// StatePath returns the state file of a workspace in a project. The default
+// workspace lives in .genesys/state.json, others in
+// .genesys/workspaces/<name>/state.json.
+func StatePath(projectDir, workspace string) string {
+	if workspace == DefaultWorkspace {
+		return filepath.Join(projectDir, ProjectDirName, projectStateFile)
+	}
+	return filepath.Join(projectDir, ProjectDirName, workspacesDirName, workspace, projectStateFile)
+}
+
+// RemoteStateKey returns the key of a workspace's state in a remote backend,
+// under the project's prefix: <prefix>/state.json for the default workspace
+// and <prefix>/workspaces/<name>/state.json for others
+func RemoteStateKey(prefix, workspace string) string {
+	if workspace == DefaultWorkspace {
+		return path.Join(prefix, projectStateFile)
+	}
+	return path.Join(prefix, workspacesDirName, workspace, projectStateFile)
+}
+
+// OpenProjectState loads the state of the current workspace of the project
+// containing start from a remote backend. Keys are placed under prefix, or
+// under the name of the project directory when prefix is empty.
+func OpenProjectState(start string, backend provider.StateBackend, prefix string) (*LocalState, error) {
+	projectDir, workspace, err := projectWorkspace(start)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix == "" {
+		prefix = filepath.Base(projectDir)
+	}
+
+	st, err := Open(backend, RemoteStateKey(prefix, workspace))
+	if err != nil {
+		return nil, err
+	}
+	st.workspace = workspace
+	return st, nil
+}
+
+// projectWorkspace finds the project containing start and its current
+// workspace, which must exist
+func projectWorkspace(start string) (string, string, error) {
+	projectDir, err := FindProjectDir(start)
+	if err != nil {
+		return "", "", err
+	}
+
+	workspace, err := CurrentWorkspace(projectDir)
+	if err != nil {
+		return "", "", err
+	}
+	if !WorkspaceExists(projectDir, workspace) {
+		return "", "", fmt.Errorf("workspace %s does not exist; create it with 'genesys workspace new %s'", workspace, workspace)
+	}
+	return projectDir, workspace, nil
+}
+
+// ListWorkspaces returns the workspaces of a project, always including the
+// default workspace
+func ListWorkspaces(projectDir string) ([]string, error) {
+	workspaces := []string{DefaultWorkspace}
+
+	entries, err := os.ReadDir(filepath.Join(projectDir, ProjectDirName, workspacesDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultWorkspace {
+			workspaces = append(workspaces, entry.Name())
+		}
+	}
+
+	sort.Strings(workspaces[1:])
+	return workspaces, nil
+}
+
+// WorkspaceExists reports whether a workspace has been created in a project
+func WorkspaceExists(projectDir, workspace string) bool {
+	if workspace == DefaultWorkspace {
+		return true
+	}
+	if validateWorkspaceName(workspace) != nil {
+		return false
+	}
+	info, err := os.Stat(filepath.Dir(StatePath(projectDir, workspace)))
+	return err == nil && info.IsDir()
+}
+
+// NewWorkspace creates an empty workspace in a project
+func NewWorkspace(projectDir, workspace string) error {
+	if err := validateWorkspaceName(workspace); err != nil {
+		return err
+	}
+	if WorkspaceExists(projectDir, workspace) {
+		return fmt.Errorf("workspace %s already exists", workspace)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(StatePath(projectDir, workspace)), 0755); err != nil {
+		return fmt.Errorf("failed to create workspace %s: %w", workspace, err)
+	}
+	return nil
+}
+
+// SelectWorkspace makes workspace the current workspace of a project
+func SelectWorkspace(projectDir, workspace string) error {
+	if err := validateWorkspaceName(workspace); err != nil {
+		return err
+	}
+	if !WorkspaceExists(projectDir, workspace) {
+		return fmt.Errorf("workspace %s does not exist; create it with 'genesys workspace new %s'", workspace, workspace)
+	}
+
+	dir := filepath.Join(projectDir, ProjectDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, workspaceFile), []byte(workspace+"\n")); err != nil {
+		return fmt.Errorf("failed to select workspace %s: %w", workspace, err)
+	}
+	return nil
+}
+
+// DeleteWorkspace removes a workspace and its state. Workspaces that still
+// track resources are only removed with force, and the default and current
+// workspaces cannot be removed.
+func DeleteWorkspace(projectDir, workspace string, force bool) error {
+	if workspace == DefaultWorkspace {
+		return fmt.Errorf("the default workspace cannot be deleted")
+	}
+	if err := validateWorkspaceName(workspace); err != nil {
+		return err
+	}
+	current, err := CurrentWorkspace(projectDir)
+	if err != nil {
+		return err
+	}
+	if workspace == current {
+		return fmt.Errorf("workspace %s is selected; select another workspace before deleting it", workspace)
+	}
+	if !WorkspaceExists(projectDir, workspace) {
+		return fmt.Errorf("workspace %s does not exist", workspace)
+	}
+
+	st, err := LoadState(StatePath(projectDir, workspace))
+	if err != nil {
+		return err
+	}
+	if len(st.Resources) > 0 && !force {
+		return fmt.Errorf("workspace %s still tracks %d resource(s); destroy them first or use --force", workspace, len(st.Resources))
+	}
+
+	if err := os.RemoveAll(filepath.Dir(StatePath(projectDir, workspace))); err != nil {
+		return fmt.Errorf("failed to delete workspace %s: %w", workspace, err)
+	}
+	return nil
+}
+
+func validateWorkspaceName(name string) error {
+	if !workspaceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid workspace name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// legacyStatePath returns the single state file used before state was kept
+// per project
+func legacyStatePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, legacyStateFileName)
+}
+
+// migrateLegacyState seeds the default workspace of a project with the
+// records of the old global state file whose configuration file belongs to
+// the project. The global file is left untouched so other projects can
+// migrate their records too.
+func migrateLegacyState(projectDir string, st *LocalState) (int, error) {
+	legacy, err := readStateFile(legacyStatePath())
+	if err != nil {
+		return 0, err
+	}
+
+	var migrated []ResourceRecord
+	for _, record := range legacy.Resources {
+		if belongsToProject(projectDir, record.ConfigFile) {
+			if !filepath.IsAbs(record.ConfigFile) {
+				record.ConfigFile = filepath.Join(projectDir, record.ConfigFile)
+			}
+			migrated = append(migrated, record)
+		}
+	}
+	if len(migrated) == 0 {
+		return 0, nil
+	}
+
+	err = st.update(func() {
+		st.Resources = append(st.Resources, migrated...)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to migrate %s: %w", legacyStatePath(), err)
+	}
+	return len(migrated), nil
+}
+
+// belongsToProject reports whether a recorded configuration file is part of
+// the project. Relative paths, as recorded by older versions, are matched
+// when the file exists relative to the project directory.
+func belongsToProject(projectDir, configFile string) bool {
+	if configFile == "" {
+		return false
+	}
+
+	path := configFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(projectDir, path)
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+
+	rel, err := filepath.Rel(projectDir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,263 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/genesys/pkg/provider"
+)
+
+func TestFindProjectDir(t *testing.T) {
+	mkdir := func(dir string) string {
+		t.Helper()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	root := t.TempDir()
+	nested := mkdir(filepath.Join(root, "envs", "prod"))
+	mkdir(filepath.Join(root, ProjectDirName, workspacesDirName))
+	other := t.TempDir()
+
+	// A home directory whose .genesys holds state is not the project of
+	// configurations below it
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	mkdir(filepath.Join(home, ProjectDirName))
+	if err := os.WriteFile(filepath.Join(home, ProjectDirName, projectStateFile), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inHome := mkdir(filepath.Join(home, "projA"))
+
+	// Nor is an ancestor with a bare .genesys, or one above a repository root
+	bare := t.TempDir()
+	mkdir(filepath.Join(bare, ProjectDirName))
+	inBare := mkdir(filepath.Join(bare, "app"))
+	repo := mkdir(filepath.Join(root, "repo"))
+	mkdir(filepath.Join(repo, ".git"))
+	inRepo := mkdir(filepath.Join(repo, "svc"))
+
+	tests := []struct {
+		name  string
+		start string
+		want  string
+	}{
+		{"config in project root", filepath.Join(root, "app.yaml"), root},
+		{"config in subdirectory", filepath.Join(nested, "app.yaml"), root},
+		{"directory", nested, root},
+		{"no project", filepath.Join(other, "app.yaml"), other},
+		{"below home", filepath.Join(inHome, "app.yaml"), inHome},
+		{"home itself", filepath.Join(home, "app.yaml"), home},
+		{"bare .genesys", filepath.Join(inBare, "app.yaml"), inBare},
+		{"below repository root", filepath.Join(inRepo, "app.yaml"), inRepo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindProjectDir(tt.start)
+			if err != nil {
+				t.Fatalf("FindProjectDir() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FindProjectDir() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkspaces(t *testing.T) {
+	t.Setenv(WorkspaceEnvVar, "")
+	project := t.TempDir()
+	config := filepath.Join(project, "app.yaml")
+
+	if got, err := CurrentWorkspace(project); err != nil || got != DefaultWorkspace {
+		t.Fatalf("CurrentWorkspace() = %s, %v, want %s", got, err, DefaultWorkspace)
+	}
+
+	if err := NewWorkspace(project, "staging"); err != nil {
+		t.Fatalf("NewWorkspace() error = %v", err)
+	}
+	if err := NewWorkspace(project, "staging"); err == nil {
+		t.Error("NewWorkspace() expected error for an existing workspace")
+	}
+	if err := NewWorkspace(project, "../prod"); err == nil {
+		t.Error("NewWorkspace() expected error for an invalid name")
+	}
+	if err := SelectWorkspace(project, "prod"); err == nil {
+		t.Error("SelectWorkspace() expected error for a missing workspace")
+	}
+	if err := SelectWorkspace(project, "staging"); err != nil {
+		t.Fatalf("SelectWorkspace() error = %v", err)
+	}
+
+	st, err := LoadProjectState(config)
+	if err != nil {
+		t.Fatalf("LoadProjectState() error = %v", err)
+	}
+	if st.Workspace() != "staging" || st.Location() != StatePath(project, "staging") {
+		t.Errorf("state is in workspace %s at %s, want staging", st.Workspace(), st.Location())
+	}
+	if err := st.AddResource(ResourceRecord{ID: "b", Name: "b", Type: "s3"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+
+	// The default workspace is kept apart
+	t.Setenv(WorkspaceEnvVar, DefaultWorkspace)
+	st, err = LoadProjectState(config)
+	if err != nil {
+		t.Fatalf("LoadProjectState() error = %v", err)
+	}
+	if len(st.Resources) != 0 {
+		t.Errorf("default workspace has %d resources, want 0", len(st.Resources))
+	}
+	t.Setenv(WorkspaceEnvVar, "")
+
+	workspaces, err := ListWorkspaces(project)
+	if err != nil {
+		t.Fatalf("ListWorkspaces() error = %v", err)
+	}
+	if len(workspaces) != 2 || workspaces[0] != DefaultWorkspace || workspaces[1] != "staging" {
+		t.Errorf("ListWorkspaces() = %v, want [default staging]", workspaces)
+	}
+
+	if err := DeleteWorkspace(project, "staging", false); err == nil {
+		t.Error("DeleteWorkspace() expected error for the selected workspace")
+	}
+	if err := SelectWorkspace(project, DefaultWorkspace); err != nil {
+		t.Fatalf("SelectWorkspace() error = %v", err)
+	}
+	if err := DeleteWorkspace(project, "staging", false); err == nil {
+		t.Error("DeleteWorkspace() expected error for a workspace tracking resources")
+	}
+	if err := DeleteWorkspace(project, "staging", true); err != nil {
+		t.Fatalf("DeleteWorkspace() error = %v", err)
+	}
+	if WorkspaceExists(project, "staging") {
+		t.Error("workspace staging still exists after DeleteWorkspace()")
+	}
+}
+
+func TestInvalidSelectedWorkspace(t *testing.T) {
+	project := t.TempDir()
+	config := filepath.Join(project, "app.yaml")
+	if err := os.MkdirAll(filepath.Join(project, ProjectDirName), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A name taken from outside must not lead the state out of .genesys
+	t.Setenv(WorkspaceEnvVar, "../../elsewhere")
+	if _, err := CurrentWorkspace(project); err == nil {
+		t.Errorf("CurrentWorkspace() expected error for an invalid %s", WorkspaceEnvVar)
+	}
+	if _, err := LoadProjectState(config); err == nil {
+		t.Errorf("LoadProjectState() expected error for an invalid %s", WorkspaceEnvVar)
+	}
+
+	t.Setenv(WorkspaceEnvVar, "")
+	if err := os.WriteFile(filepath.Join(project, ProjectDirName, workspaceFile), []byte("../..\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CurrentWorkspace(project); err == nil {
+		t.Error("CurrentWorkspace() expected error for an invalid workspace file")
+	}
+	if _, err := LoadProjectState(config); err == nil {
+		t.Error("LoadProjectState() expected error for an invalid workspace file")
+	}
+	if err := DeleteWorkspace(project, "..", true); err == nil {
+		t.Error("DeleteWorkspace() expected error for an invalid name")
+	}
+}
+
+func TestMigrateLegacyState(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(WorkspaceEnvVar, "")
+
+	project := t.TempDir()
+	config := filepath.Join(project, "app.yaml")
+	if err := os.WriteFile(config, []byte("provider: aws\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Older versions recorded configuration files as given, relative or not
+	legacy := NewLocalState(filepath.Join(home, legacyStateFileName))
+	legacy.Resources = []ResourceRecord{
+		{ID: "mine", Name: "mine", Type: "s3", ConfigFile: config},
+		{ID: "relative", Name: "relative", Type: "s3", ConfigFile: "app.yaml"},
+		{ID: "other", Name: "other", Type: "s3", ConfigFile: "/elsewhere/app.yaml"},
+	}
+	if err := legacy.SaveLocalState(); err != nil {
+		t.Fatalf("SaveLocalState() error = %v", err)
+	}
+
+	st, err := LoadProjectState(config)
+	if err != nil {
+		t.Fatalf("LoadProjectState() error = %v", err)
+	}
+	if st.Migrated != 2 || len(st.Resources) != 2 {
+		t.Fatalf("migrated %d record(s), state has %d, want 2", st.Migrated, len(st.Resources))
+	}
+	if found := st.FindResourcesByConfigFile(config); len(found) != 2 {
+		t.Errorf("found %d record(s) for %s, want the relative one resolved against the project", len(found), config)
+	}
+
+	// Migration happens once; the global file is left alone
+	st, err = LoadProjectState(config)
+	if err != nil {
+		t.Fatalf("LoadProjectState() error = %v", err)
+	}
+	if st.Migrated != 0 || len(st.Resources) != 2 {
+		t.Errorf("second load migrated %d record(s), state has %d", st.Migrated, len(st.Resources))
+	}
+	if legacy, _ := LoadState(filepath.Join(home, legacyStateFileName)); len(legacy.Resources) != 3 {
+		t.Errorf("global state has %d records, want 3", len(legacy.Resources))
+	}
+}
+
+func TestOpenProjectStateInBackend(t *testing.T) {
+	t.Setenv(WorkspaceEnvVar, "")
+	project := t.TempDir()
+	config := filepath.Join(project, "app.yaml")
+	backend := provider.NewMockStateBackend()
+
+	st, err := OpenProjectState(config, backend, "")
+	if err != nil {
+		t.Fatalf("OpenProjectState() error = %v", err)
+	}
+	want := "memory:" + filepath.Base(project) + "/state.json"
+	if st.Location() != want {
+		t.Errorf("Location() = %s, want %s", st.Location(), want)
+	}
+	if err := st.AddResource(ResourceRecord{ID: "b", Name: "b", Type: "s3"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+
+	// Another command opening the same backend sees the record
+	other, err := OpenProjectState(config, backend, "")
+	if err != nil {
+		t.Fatalf("OpenProjectState() error = %v", err)
+	}
+	if _, ok := other.FindResource("s3", "b"); !ok || other.Serial != 1 {
+		t.Errorf("reopened state has %d resources at serial %d, want b at serial 1", len(other.Resources), other.Serial)
+	}
+
+	// Nothing is written to the project directory
+	if _, err := os.Stat(StatePath(project, DefaultWorkspace)); !os.IsNotExist(err) {
+		t.Errorf("state file exists for a remote backend: %v", err)
+	}
+
+	if err := NewWorkspace(project, "prod"); err != nil {
+		t.Fatalf("NewWorkspace() error = %v", err)
+	}
+	t.Setenv(WorkspaceEnvVar, "prod")
+	prod, err := OpenProjectState(config, backend, "shop")
+	if err != nil {
+		t.Fatalf("OpenProjectState() error = %v", err)
+	}
+	if prod.Location() != "memory:shop/workspaces/prod/state.json" || len(prod.Resources) != 0 {
+		t.Errorf("prod state at %s has %d resources", prod.Location(), len(prod.Resources))
+	}
+}